@@ -11,19 +11,44 @@ import (
 	"github.com/21TechLabs/factory-backend/database"
 	"github.com/21TechLabs/factory-backend/middleware"
 	"github.com/21TechLabs/factory-backend/models"
+	"github.com/21TechLabs/factory-backend/notifications"
+	"github.com/21TechLabs/factory-backend/ratelimit"
 	"github.com/21TechLabs/factory-backend/utils"
 	"gorm.io/gorm"
 )
 
 type Application struct {
-	Logger                *log.Logger
-	DB                    *gorm.DB
-	Middleware            *middleware.Middleware
-	UserController        *controllers.UserController
-	FileController        *controllers.FileController
-	OAuthController       *oauth_controller.OAuthController
-	HealthCheckController *controllers.HealthCheckController
-	PaymentPlanController *payments_controller.PaymentPlanController
+	Logger                           *log.Logger
+	DB                               *gorm.DB
+	Middleware                       *middleware.Middleware
+	UserController                   *controllers.UserController
+	FileController                   *controllers.FileController
+	OAuthController                  *oauth_controller.OAuthController
+	HealthCheckController            *controllers.HealthCheckController
+	PaymentPlanController            *payments_controller.PaymentPlanController
+	EmailOutboxController            *controllers.EmailOutboxController
+	EmailOutboxStore                 *models.EmailOutboxStore
+	EmailTemplateController          *controllers.EmailTemplateController
+	NotificationPreferenceController *controllers.NotificationPreferenceController
+	WebhookController                *controllers.WebhookController
+	WebhookStore                     *models.WebhookStore
+	PhoneController                  *controllers.PhoneController
+	MagicLinkController              *controllers.MagicLinkController
+	PasskeyController                *controllers.PasskeyController
+	OrganizationController           *controllers.OrganizationController
+	SAMLController                   *controllers.SAMLController
+	SCIMController                   *controllers.SCIMController
+	LoginThrottleController          *controllers.LoginThrottleController
+	LoginThrottleStore               *models.LoginThrottleStore
+	OneTimeTokenStore                *models.OneTimeTokenStore
+	FileStore                        *models.FileStore
+	StorageStore                     *models.StorageStore
+	MultipartUploadStore             *models.MultipartUploadStore
+	DirectUploadStore                *models.DirectUploadStore
+	RateLimitStore                   ratelimit.Store
+	EmailChangeController            *controllers.EmailChangeController
+	ImpersonationController          *controllers.ImpersonationController
+	FolderController                 *controllers.FolderController
 }
 
 // NewApplication creates and configures the Application instance.
@@ -62,6 +87,37 @@ func NewApplication() (*Application, error) {
 		models.ProductPlan{},
 		models.Transaction{},
 		models.UserSubscription{},
+		models.EmailOutbox{},
+		models.NotificationPreference{},
+		models.WebhookEndpoint{},
+		models.WebhookDelivery{},
+		models.WebhookDeliveryAttempt{},
+		models.PhoneOTP{},
+		models.MagicLink{},
+		models.Passkey{},
+		models.PasskeyCeremony{},
+		models.UserIdentity{},
+		models.OAuthFlow{},
+		models.Organization{},
+		models.OrganizationDomain{},
+		models.SAMLConnection{},
+		models.SAMLRequest{},
+		models.SCIMToken{},
+		models.SCIMGroup{},
+		models.SCIMGroupMember{},
+		models.LoginThrottle{},
+		models.RateLimitBucket{},
+		models.EmailChange{},
+		models.OneTimeToken{},
+		models.ImpersonationSession{},
+		models.ImpersonationRequest{},
+		models.MultipartUpload{},
+		models.DirectUpload{},
+		models.FileShare{},
+		models.FileShareLink{},
+		models.FileAccess{},
+		models.Folder{},
+		models.StorageReservation{},
 	}
 
 	for _, model := range modelsToMigrate {
@@ -71,31 +127,136 @@ func NewApplication() (*Application, error) {
 		logger.Printf("✅ Model %T migrated successfully", model)
 	}
 
+	cleared, err := models.ClearLegacyLockouts(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to clear legacy account lockouts: %w", err)
+	}
+	if cleared > 0 {
+		logger.Printf("✅ Cleared %d legacy account lockouts", cleared)
+	}
+
+	emailSender, err := notifications.NewEmailSenderFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure email sender: %w", err)
+	}
+
+	smsSender, err := notifications.NewSMSSenderFromEnv(logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure sms sender: %w", err)
+	}
+
+	passwordPolicy, err := models.NewPasswordPolicyFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure password policy: %w", err)
+	}
+
+	rateLimitStore, err := models.NewRateLimitStoreFromEnv(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure rate limits: %w", err)
+	}
+
+	trashRetention, err := models.TrashRetentionFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure file trash: %w", err)
+	}
+
+	storageDefaultQuota, err := models.StorageDefaultQuotaFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure storage quotas: %w", err)
+	}
+
+	oauthProviders, err := oauth_controller.NewProvidersFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure oauth providers: %w", err)
+	}
+
 	// store initialization
-	fileStore := models.NewFileStore(db)
-	userStore := models.NewUserStore(db, fileStore)
+	storageStore := models.NewStorageStore(db, storageDefaultQuota)
+	fileStore := models.NewFileStore(db, trashRetention, storageStore)
+	folderStore := models.NewFolderStore(db)
+	multipartUploadStore := models.NewMultipartUploadStore(db, storageStore)
+	directUploadStore := models.NewDirectUploadStore(db, storageStore)
+	notificationPreferenceStore := models.NewNotificationPreferenceStore(db)
+	emailOutboxStore := models.NewEmailOutboxStore(db, emailSender, notificationPreferenceStore)
+	webhookStore := models.NewWebhookStore(db)
+	oneTimeTokenStore := models.NewOneTimeTokenStore(db)
+	userStore := models.NewUserStore(db, fileStore, emailOutboxStore, webhookStore, passwordPolicy, oneTimeTokenStore)
+	loginThrottleStore := models.NewLoginThrottleStore(db, userStore)
+	emailChangeStore := models.NewEmailChangeStore(db, userStore)
+	impersonationStore := models.NewImpersonationStore(db, userStore)
+	phoneOTPStore := models.NewPhoneOTPStore(db, smsSender)
+	magicLinkStore := models.NewMagicLinkStore(db, userStore)
+	userIdentityStore := models.NewUserIdentityStore(db, userStore)
+	oauthFlowStore := models.NewOAuthFlowStore(db)
+	passkeyStore, err := models.NewPasskeyStore(db, userStore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure passkeys: %w", err)
+	}
+	organizationStore := models.NewOrganizationStore(db)
+	samlConnectionStore, err := models.NewSAMLConnectionStore(db, userStore, userIdentityStore, organizationStore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure saml: %w", err)
+	}
+	scimStore := models.NewSCIMStore(db, userStore, userIdentityStore, organizationStore)
 	paymentPlanStore := models.NewProductPlanStore(db, userStore)
 	userSubscriptionStore := models.NewUserSubscriptionStore(db, userStore)
 
 	// middleware initialization
-	middleware := middleware.NewMiddleware(logger, userStore)
+	middleware := middleware.NewMiddleware(logger, userStore, scimStore, rateLimitStore, impersonationStore)
 
 	// controller initialization
-	userController := controllers.NewUserController(logger, userStore)
-	fileController := controllers.NewFileController(logger, fileStore, userStore)
-	oauthController := oauth_controller.NewOAuthController(logger, userStore)
+	userController := controllers.NewUserController(logger, userStore, loginThrottleStore)
+	fileController := controllers.NewFileController(logger, fileStore, userStore, multipartUploadStore, directUploadStore)
+	oauthController := oauth_controller.NewOAuthController(logger, userStore, userIdentityStore, oauthFlowStore, oauthProviders)
 	healthCheckController := controllers.NewHealthCheckController(logger)
 	paymentPlanController := payments_controller.NewPaymentPlanController(logger, paymentPlanStore, fileStore, userStore, userSubscriptionStore)
+	emailOutboxController := controllers.NewEmailOutboxController(logger, emailOutboxStore)
+	emailTemplateController := controllers.NewEmailTemplateController(logger)
+	notificationPreferenceController := controllers.NewNotificationPreferenceController(logger, notificationPreferenceStore)
+	webhookController := controllers.NewWebhookController(logger, webhookStore)
+	phoneController := controllers.NewPhoneController(logger, phoneOTPStore, userStore)
+	magicLinkController := controllers.NewMagicLinkController(logger, magicLinkStore, userStore)
+	passkeyController := controllers.NewPasskeyController(logger, passkeyStore, userStore)
+	organizationController := controllers.NewOrganizationController(logger, organizationStore, samlConnectionStore, scimStore)
+	samlController := controllers.NewSAMLController(logger, samlConnectionStore, userStore)
+	scimController := controllers.NewSCIMController(logger, scimStore)
+	loginThrottleController := controllers.NewLoginThrottleController(logger, loginThrottleStore)
+	emailChangeController := controllers.NewEmailChangeController(logger, emailChangeStore, userStore)
+	impersonationController := controllers.NewImpersonationController(logger, impersonationStore, userStore)
+	folderController := controllers.NewFolderController(logger, folderStore)
 
 	app := &Application{
-		Logger:                logger,
-		DB:                    db,
-		Middleware:            middleware,
-		UserController:        userController,
-		FileController:        fileController,
-		OAuthController:       oauthController,
-		HealthCheckController: healthCheckController,
-		PaymentPlanController: paymentPlanController,
+		Logger:                           logger,
+		DB:                               db,
+		Middleware:                       middleware,
+		UserController:                   userController,
+		FileController:                   fileController,
+		OAuthController:                  oauthController,
+		HealthCheckController:            healthCheckController,
+		PaymentPlanController:            paymentPlanController,
+		EmailOutboxController:            emailOutboxController,
+		EmailOutboxStore:                 emailOutboxStore,
+		EmailTemplateController:          emailTemplateController,
+		NotificationPreferenceController: notificationPreferenceController,
+		WebhookController:                webhookController,
+		WebhookStore:                     webhookStore,
+		PhoneController:                  phoneController,
+		MagicLinkController:              magicLinkController,
+		PasskeyController:                passkeyController,
+		OrganizationController:           organizationController,
+		SAMLController:                   samlController,
+		SCIMController:                   scimController,
+		LoginThrottleController:          loginThrottleController,
+		LoginThrottleStore:               loginThrottleStore,
+		OneTimeTokenStore:                oneTimeTokenStore,
+		FileStore:                        fileStore,
+		StorageStore:                     storageStore,
+		MultipartUploadStore:             multipartUploadStore,
+		DirectUploadStore:                directUploadStore,
+		RateLimitStore:                   rateLimitStore,
+		EmailChangeController:            emailChangeController,
+		ImpersonationController:          impersonationController,
+		FolderController:                 folderController,
 	}
 
 	return app, nil
@@ -0,0 +1,40 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/models"
+)
+
+// StartWorkers launches the application's background jobs. They run until ctx is cancelled.
+func (app *Application) StartWorkers(ctx context.Context) {
+	go app.EmailOutboxStore.RunWorker(ctx, app.Logger, 10*time.Second)
+	app.Logger.Println("✅ Email outbox worker started")
+
+	go app.WebhookStore.RunWorker(ctx, app.Logger, 5*time.Second)
+	app.Logger.Println("✅ Webhook delivery worker started")
+
+	go app.LoginThrottleStore.RunWorker(ctx, app.Logger, time.Hour)
+	app.Logger.Println("✅ Login throttle pruning worker started")
+
+	go app.OneTimeTokenStore.RunWorker(ctx, app.Logger, time.Hour)
+	app.Logger.Println("✅ One-time token pruning worker started")
+
+	go app.MultipartUploadStore.RunWorker(ctx, app.Logger, time.Hour)
+	app.Logger.Println("✅ Stale multipart upload sweeper started")
+
+	go app.DirectUploadStore.RunWorker(ctx, app.Logger, 15*time.Minute)
+	app.Logger.Println("✅ Unconfirmed direct upload sweeper started")
+
+	go app.FileStore.RunWorker(ctx, app.Logger, time.Hour)
+	app.Logger.Println("✅ File trash purge worker started")
+
+	go app.StorageStore.RunWorker(ctx, app.Logger, time.Hour)
+	app.Logger.Println("✅ Storage reconciliation worker started")
+
+	if store, ok := app.RateLimitStore.(*models.RateLimitStore); ok {
+		go store.RunWorker(ctx, app.Logger, 10*time.Minute)
+		app.Logger.Println("✅ Rate limit pruning worker started")
+	}
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/models"
+	"github.com/21TechLabs/factory-backend/utils"
+)
+
+type EmailChangeController struct {
+	Logger           *log.Logger
+	EmailChangeStore *models.EmailChangeStore
+	UserStore        *models.UserStore
+}
+
+func NewEmailChangeController(logger *log.Logger, emailChangeStore *models.EmailChangeStore, userStore *models.UserStore) *EmailChangeController {
+	return &EmailChangeController{
+		Logger:           logger,
+		EmailChangeStore: emailChangeStore,
+		UserStore:        userStore,
+	}
+}
+
+func (ecc *EmailChangeController) emailChangeErrorResponse(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, utils.ErrIncorrectPassword):
+		utils.ErrorResponse(ecc.Logger, w, http.StatusUnauthorized, []byte(err.Error()))
+	case errors.Is(err, utils.ErrEmailUnchanged), errors.Is(err, utils.ErrEmailChangeInvalid):
+		utils.ErrorResponse(ecc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+	case errors.Is(err, utils.ErrEmailManagedByOrganization):
+		utils.ErrorResponse(ecc.Logger, w, http.StatusForbidden, []byte(err.Error()))
+	case errors.Is(err, utils.ErrEmailInUse):
+		utils.ErrorResponse(ecc.Logger, w, http.StatusConflict, []byte(err.Error()))
+	default:
+		ecc.Logger.Printf("Email Change Error: %v\n", err)
+		utils.ErrorResponse(ecc.Logger, w, http.StatusInternalServerError, []byte("Something went wrong"))
+	}
+}
+
+// RequestEmailChange emails a confirm link to the new address and a revert
+// link to the current one. The email only changes once the link is used.
+func (ecc *EmailChangeController) RequestEmailChange(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.EmailChangeRequestDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(ecc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	user, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || user == nil {
+		utils.ErrorResponse(ecc.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return
+	}
+
+	change, err := ecc.EmailChangeStore.Request(user, body.Email, body.Password)
+	if err != nil {
+		ecc.emailChangeErrorResponse(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(ecc.Logger, w, http.StatusOK, utils.Map{
+		"success":     true,
+		"message":     "Check your new email address for a confirmation link",
+		"emailChange": change,
+	})
+}
+
+// ConfirmEmailChange switches the account to the new address. It does not
+// sign the user in, the link only proves access to the new mailbox.
+func (ecc *EmailChangeController) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.EmailChangeTokenDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(ecc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	user, err := ecc.EmailChangeStore.Confirm(body.Token)
+	if err != nil {
+		ecc.emailChangeErrorResponse(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(ecc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"message": "Email address changed",
+		"email":   user.Email,
+	})
+}
+
+// RevertEmailChange cancels or undoes a change with the link sent to the old address.
+func (ecc *EmailChangeController) RevertEmailChange(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.EmailChangeTokenDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(ecc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	if err := ecc.EmailChangeStore.Revert(body.Token); err != nil {
+		ecc.emailChangeErrorResponse(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(ecc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"message": "Email change reverted",
+	})
+}
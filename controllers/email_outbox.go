@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/models"
+	"github.com/21TechLabs/factory-backend/utils"
+	"gorm.io/gorm"
+)
+
+type EmailOutboxController struct {
+	Logger           *log.Logger
+	EmailOutboxStore *models.EmailOutboxStore
+}
+
+func NewEmailOutboxController(logger *log.Logger, store *models.EmailOutboxStore) *EmailOutboxController {
+	return &EmailOutboxController{
+		Logger:           logger,
+		EmailOutboxStore: store,
+	}
+}
+
+func (eoc *EmailOutboxController) ListEmails(w http.ResponseWriter, r *http.Request) {
+	filter := &dto.EmailOutboxFilterDto{}
+	if err := utils.ParseQueryParams(r, filter); err != nil {
+		utils.ErrorResponse(eoc.Logger, w, http.StatusBadRequest, []byte("Invalid query parameters"))
+		return
+	}
+
+	if err := utils.ValidateStruct(filter); err != nil {
+		utils.ErrorResponse(eoc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	start, limit, err := utils.ParsePagination(filter.Start, filter.Limit, 50, 200)
+	if err != nil {
+		utils.ErrorResponse(eoc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	emails, err := eoc.EmailOutboxStore.FindBy(*filter, start, limit)
+	if err != nil {
+		utils.ErrorResponse(eoc.Logger, w, http.StatusInternalServerError, []byte(err.Error()))
+		return
+	}
+
+	utils.ResponseWithJSON(eoc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"emails":  emails,
+	})
+}
+
+func (eoc *EmailOutboxController) ResendEmail(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.StringToUID(r, "id")
+	if err != nil {
+		utils.ErrorResponse(eoc.Logger, w, http.StatusBadRequest, []byte("Invalid email ID"))
+		return
+	}
+
+	email, err := eoc.EmailOutboxStore.Resend(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(eoc.Logger, w, http.StatusNotFound, []byte("email not found"))
+			return
+		}
+		utils.ErrorResponse(eoc.Logger, w, http.StatusInternalServerError, []byte(err.Error()))
+		return
+	}
+
+	utils.ResponseWithJSON(eoc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"message": "Email queued for delivery",
+		"email":   email,
+	})
+}
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/21TechLabs/factory-backend/notifications/templates"
+	"github.com/21TechLabs/factory-backend/utils"
+)
+
+type EmailTemplateController struct {
+	Logger *log.Logger
+}
+
+func NewEmailTemplateController(logger *log.Logger) *EmailTemplateController {
+	return &EmailTemplateController{
+		Logger: logger,
+	}
+}
+
+func (etc *EmailTemplateController) ListTemplates(w http.ResponseWriter, r *http.Request) {
+	utils.ResponseWithJSON(etc.Logger, w, http.StatusOK, utils.Map{
+		"success":       true,
+		"defaultLocale": templates.DefaultLocale,
+		"templates":     templates.Definitions(),
+	})
+}
+
+// PreviewTemplate renders a template with sample data. The format query
+// parameter selects the raw html (default) or text body, or json for every part.
+func (etc *EmailTemplateController) PreviewTemplate(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	locale := r.URL.Query().Get("locale")
+
+	rendered, err := templates.RenderSample(name, locale)
+	if err != nil {
+		utils.ErrorResponse(etc.Logger, w, http.StatusNotFound, []byte(err.Error()))
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(rendered.HTML))
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(rendered.Text))
+	case "json":
+		utils.ResponseWithJSON(etc.Logger, w, http.StatusOK, utils.Map{
+			"success":  true,
+			"template": rendered,
+		})
+	default:
+		utils.ErrorResponse(etc.Logger, w, http.StatusBadRequest, []byte("format must be html, text or json"))
+	}
+}
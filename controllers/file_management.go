@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/models"
+	"github.com/21TechLabs/factory-backend/utils"
+)
+
+func (fc *FileController) ListFiles(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || currentUser == nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return
+	}
+
+	filter := &dto.FileFilterDto{}
+	if err := utils.ParseQueryParams(r, filter); err != nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusBadRequest, []byte("Invalid query parameters"))
+		return
+	}
+
+	if err := utils.ValidateStruct(filter); err != nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	start, limit, err := utils.ParsePagination(filter.Start, filter.Limit, 50, 200)
+	if err != nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	files, err := fc.FileStore.FindFiles(currentUser.ID, *filter, start, limit)
+	if err != nil {
+		fileErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(fc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"files":   files,
+	})
+}
+
+func (fc *FileController) RenameFile(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.FileRenameDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	file, ok := fc.ownedFile(w, r)
+	if !ok {
+		return
+	}
+
+	if err := fc.FileStore.Rename(file, body.Name); err != nil {
+		fileErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(fc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"file":    file,
+	})
+}
+
+func (fc *FileController) MoveFile(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.FileMoveDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	file, ok := fc.ownedFile(w, r)
+	if !ok {
+		return
+	}
+
+	if err := fc.FileStore.Move(file, body.FolderID); err != nil {
+		fileErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(fc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"file":    file,
+	})
+}
+
+// TrashFile moves a file to the trash, where it stays restorable until the
+// retention period ends.
+func (fc *FileController) TrashFile(w http.ResponseWriter, r *http.Request) {
+	file, ok := fc.ownedFile(w, r)
+	if !ok {
+		return
+	}
+
+	if err := fc.FileStore.Trash(file); err != nil {
+		fileErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(fc.Logger, w, http.StatusOK, utils.Map{
+		"success":  true,
+		"message":  "File moved to trash",
+		"purgesAt": file.DeletedAt.Time.Add(fc.FileStore.TrashRetention),
+	})
+}
+
+func (fc *FileController) RestoreFile(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || currentUser == nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return
+	}
+
+	id, err := utils.StringToUID(r, "id")
+	if err != nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusBadRequest, []byte("Invalid file ID"))
+		return
+	}
+
+	file, err := fc.FileStore.GetTrashed(currentUser.ID, id)
+	if err != nil {
+		fileErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	if err := fc.FileStore.Restore(file); err != nil {
+		fileErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(fc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"file":    file,
+	})
+}
@@ -0,0 +1,253 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/models"
+	"github.com/21TechLabs/factory-backend/utils"
+)
+
+// fileErrorResponse maps file and folder errors to responses.
+func fileErrorResponse(logger *log.Logger, w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, utils.ErrFileNotFound),
+		errors.Is(err, utils.ErrUserNotFound),
+		errors.Is(err, utils.ErrFileShareNotFound),
+		errors.Is(err, utils.ErrShareLinkInvalid),
+		errors.Is(err, utils.ErrFolderNotFound):
+		utils.ErrorResponse(logger, w, http.StatusNotFound, []byte(err.Error()))
+	case errors.Is(err, utils.ErrFileShareSelf), errors.Is(err, utils.ErrFolderMoveIntoSelf):
+		utils.ErrorResponse(logger, w, http.StatusBadRequest, []byte(err.Error()))
+	case errors.Is(err, utils.ErrFolderNotEmpty), errors.Is(err, utils.ErrFolderNameTaken):
+		utils.ErrorResponse(logger, w, http.StatusConflict, []byte(err.Error()))
+	default:
+		logger.Printf("File Error: %v\n", err)
+		utils.ErrorResponse(logger, w, http.StatusInternalServerError, []byte("Something went wrong"))
+	}
+}
+
+// ownedFile returns the signed-in user's file named by the id path value.
+func (fc *FileController) ownedFile(w http.ResponseWriter, r *http.Request) (*models.File, bool) {
+	currentUser, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || currentUser == nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return nil, false
+	}
+
+	id, err := utils.StringToUID(r, "id")
+	if err != nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusBadRequest, []byte("Invalid file ID"))
+		return nil, false
+	}
+
+	file, err := fc.FileStore.GetOwned(currentUser.ID, id)
+	if err != nil {
+		fileErrorResponse(fc.Logger, w, err)
+		return nil, false
+	}
+	return file, true
+}
+
+func (fc *FileController) SetFileVisibility(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.FileVisibilityDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	file, ok := fc.ownedFile(w, r)
+	if !ok {
+		return
+	}
+
+	if err := fc.FileStore.SetVisibility(file, utils.FileVisibility(body.Visibility)); err != nil {
+		fileErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(fc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"file":    file,
+	})
+}
+
+func (fc *FileController) ListFileShares(w http.ResponseWriter, r *http.Request) {
+	file, ok := fc.ownedFile(w, r)
+	if !ok {
+		return
+	}
+
+	shares, err := fc.FileStore.Shares(file)
+	if err != nil {
+		fileErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(fc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"shares":  shares,
+	})
+}
+
+func (fc *FileController) ShareFile(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.FileShareCreateDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	file, ok := fc.ownedFile(w, r)
+	if !ok {
+		return
+	}
+
+	share, err := fc.FileStore.Share(file, body.UserID)
+	if err != nil {
+		fileErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(fc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"share":   share,
+	})
+}
+
+func (fc *FileController) UnshareFile(w http.ResponseWriter, r *http.Request) {
+	file, ok := fc.ownedFile(w, r)
+	if !ok {
+		return
+	}
+
+	userID, err := utils.StringToUID(r, "userId")
+	if err != nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusBadRequest, []byte("Invalid user ID"))
+		return
+	}
+
+	if err := fc.FileStore.Unshare(file, userID); err != nil {
+		fileErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(fc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"message": "File is no longer shared with this user",
+	})
+}
+
+func (fc *FileController) ListFileShareLinks(w http.ResponseWriter, r *http.Request) {
+	file, ok := fc.ownedFile(w, r)
+	if !ok {
+		return
+	}
+
+	links, err := fc.FileStore.ShareLinks(file)
+	if err != nil {
+		fileErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(fc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"links":   links,
+	})
+}
+
+// CreateFileShareLink returns a link to the file that works without signing
+// in. The link can only be read from this response.
+func (fc *FileController) CreateFileShareLink(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.FileShareLinkCreateDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	file, ok := fc.ownedFile(w, r)
+	if !ok {
+		return
+	}
+
+	link, token, err := fc.FileStore.CreateShareLink(file, time.Duration(body.ExpiresInHours)*time.Hour)
+	if err != nil {
+		fileErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(fc.Logger, w, http.StatusCreated, utils.Map{
+		"success": true,
+		"link":    link,
+		"url":     models.ShareLinkURL(token),
+	})
+}
+
+func (fc *FileController) RevokeFileShareLink(w http.ResponseWriter, r *http.Request) {
+	file, ok := fc.ownedFile(w, r)
+	if !ok {
+		return
+	}
+
+	linkID, err := utils.StringToUID(r, "linkId")
+	if err != nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusBadRequest, []byte("Invalid link ID"))
+		return
+	}
+
+	if err := fc.FileStore.RevokeShareLink(file, linkID); err != nil {
+		fileErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(fc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"message": "Share link revoked",
+	})
+}
+
+// ListFileAccesses returns the recorded reads of one of the user's files.
+func (fc *FileController) ListFileAccesses(w http.ResponseWriter, r *http.Request) {
+	file, ok := fc.ownedFile(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	start, limit, err := utils.ParsePagination(json.Number(query.Get("start")), json.Number(query.Get("limit")), 50, 200)
+	if err != nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	accesses, err := fc.FileStore.Accesses(file, start, limit)
+	if err != nil {
+		fileErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(fc.Logger, w, http.StatusOK, utils.Map{
+		"success":  true,
+		"accesses": accesses,
+	})
+}
+
+// StreamSharedFile streams the file behind a share link to anyone holding
+// it, signed in or not.
+func (fc *FileController) StreamSharedFile(w http.ResponseWriter, r *http.Request) {
+	file, link, err := fc.FileStore.ResolveShareLink(r.PathValue("token"))
+	if err != nil {
+		fileErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	viewer, _ := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err := fc.FileStore.RecordAccess(file, viewer, link, utils.FileAccessViaLink, utils.ClientIP(r)); err != nil {
+		fc.Logger.Printf("StreamSharedFile Error: failed to record access to %s: %v\n", file.ID, err)
+	}
+
+	fc.streamFile(w, file)
+}
@@ -1,25 +1,51 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"mime"
 	"net/http"
 
+	"github.com/21TechLabs/factory-backend/dto"
 	"github.com/21TechLabs/factory-backend/models"
 	"github.com/21TechLabs/factory-backend/utils"
 )
 
+// maxDirectUploadSize caps POST /file requests; larger files go through
+// multipart uploads.
+const maxDirectUploadSize = 32 << 20
+
+// inlineFileTypes are the content types streamFile lets the browser display.
+var inlineFileTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+	"text/plain":      true,
+	"audio/mpeg":      true,
+	"audio/ogg":       true,
+	"audio/wav":       true,
+	"video/mp4":       true,
+	"video/webm":      true,
+}
+
 type FileController struct {
-	Logger    *log.Logger
-	FileStore *models.FileStore
-	UserStore *models.UserStore
+	Logger               *log.Logger
+	FileStore            *models.FileStore
+	UserStore            *models.UserStore
+	MultipartUploadStore *models.MultipartUploadStore
+	DirectUploadStore    *models.DirectUploadStore
 }
 
-func NewFileController(log *log.Logger, fs *models.FileStore, us *models.UserStore) *FileController {
+func NewFileController(log *log.Logger, fs *models.FileStore, us *models.UserStore, mus *models.MultipartUploadStore, dus *models.DirectUploadStore) *FileController {
 	return &FileController{
-		Logger:    log,
-		FileStore: fs,
-		UserStore: us,
+		Logger:               log,
+		FileStore:            fs,
+		UserStore:            us,
+		MultipartUploadStore: mus,
+		DirectUploadStore:    dus,
 	}
 }
 
@@ -31,6 +57,31 @@ func (fc *FileController) FileUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// turn away bodies that cannot fit before reading them, allowing 1 MiB
+	// for the form around the files; the files are checked once parsed
+	usage, err := fc.FileStore.StorageStore.Usage(currentUser)
+	if err != nil {
+		fc.Logger.Printf("FileUpload Error: %v\n", err)
+		utils.ErrorResponse(fc.Logger, w, http.StatusInternalServerError, []byte("Something went wrong"))
+		return
+	}
+	if r.ContentLength > 0 && r.ContentLength > usage.AvailableBytes+1<<20 {
+		utils.ErrorResponse(fc.Logger, w, http.StatusRequestEntityTooLarge, []byte(utils.ErrStorageQuotaExceeded.Error()))
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxDirectUploadSize)
+	if err := r.ParseMultipartForm(maxDirectUploadSize); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			utils.ErrorResponse(fc.Logger, w, http.StatusRequestEntityTooLarge, []byte("File is too large, use a multipart upload"))
+			return
+		}
+		utils.ErrorResponse(fc.Logger, w, http.StatusBadRequest, []byte("Invalid multipart form"))
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
 	form := r.MultipartForm
 
 	files := form.File["files"]
@@ -57,6 +108,10 @@ func (fc *FileController) FileUpload(w http.ResponseWriter, r *http.Request) {
 
 	uploadedFiles, err := fc.UserStore.UploadFile(currentUser, fileUploads)
 
+	if errors.Is(err, utils.ErrStorageQuotaExceeded) {
+		utils.ErrorResponse(fc.Logger, w, http.StatusRequestEntityTooLarge, []byte(err.Error()))
+		return
+	}
 	if err != nil {
 		utils.ErrorResponse(fc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
 		return
@@ -68,11 +123,13 @@ func (fc *FileController) FileUpload(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// FileStreamS3 streams a file by key to its owner, users it is shared with,
+// and anyone else its visibility allows. Every read is recorded.
 func (fc *FileController) FileStreamS3(w http.ResponseWriter, r *http.Request) {
 	fileKey := r.PathValue("fileKey")
 
 	if fileKey == "" {
-		utils.ErrorResponse(fc.Logger, w, http.StatusBadRequest, []byte("file id is required"))
+		utils.ErrorResponse(fc.Logger, w, http.StatusBadRequest, []byte("file key is required"))
 		return
 	}
 
@@ -82,6 +139,27 @@ func (fc *FileController) FileStreamS3(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	viewer, _ := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+
+	via, err := fc.FileStore.Authorize(file, viewer)
+	if err != nil {
+		if errors.Is(err, utils.ErrFileNotFound) {
+			utils.ErrorResponse(fc.Logger, w, http.StatusNotFound, []byte(err.Error()))
+			return
+		}
+		fc.Logger.Printf("FileStreamS3 Error: %v\n", err)
+		utils.ErrorResponse(fc.Logger, w, http.StatusInternalServerError, []byte("Something went wrong"))
+		return
+	}
+
+	if err := fc.FileStore.RecordAccess(file, viewer, nil, via, utils.ClientIP(r)); err != nil {
+		fc.Logger.Printf("FileStreamS3 Error: failed to record access to %s: %v\n", file.ID, err)
+	}
+
+	fc.streamFile(w, file)
+}
+
+func (fc *FileController) streamFile(w http.ResponseWriter, file *models.File) {
 	fileObject, err := file.GetObject()
 
 	if err != nil {
@@ -90,8 +168,20 @@ func (fc *FileController) FileStreamS3(w http.ResponseWriter, r *http.Request) {
 	}
 	defer fileObject.Close()
 
-	w.Header().Set("Content-Type", file.Type)
-	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%s", fileKey)) // Or "attachment; filename=" for download
+	// the content type comes from the uploader, so only types browsers
+	// cannot run script from are shown inline; everything else downloads
+	contentType, disposition := "application/octet-stream", "attachment"
+	if mediaType, _, err := mime.ParseMediaType(file.Type); err == nil && inlineFileTypes[mediaType] {
+		contentType, disposition = file.Type, "inline"
+	}
+	if value := mime.FormatMediaType(disposition, map[string]string{"filename": file.Name}); value != "" {
+		disposition = value
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", disposition)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
 	w.Header().Set("Content-Length", fmt.Sprint(file.Size))
 	w.Header().Set("Transfer-Encoding", "chunked")
 
@@ -115,3 +205,243 @@ func (fc *FileController) FileStreamS3(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
+
+// uploadErrorResponse maps upload errors to responses. Incomplete
+// uploads list the parts still missing.
+func uploadErrorResponse(logger *log.Logger, w http.ResponseWriter, err error) {
+	var incomplete *utils.UploadIncompleteError
+	switch {
+	case errors.As(err, &incomplete):
+		utils.ResponseWithJSON(logger, w, http.StatusConflict, utils.Map{
+			"success":      false,
+			"error":        incomplete.Error(),
+			"missingParts": incomplete.MissingParts,
+		})
+	case errors.Is(err, utils.ErrUploadNotFound):
+		utils.ErrorResponse(logger, w, http.StatusNotFound, []byte(err.Error()))
+	case errors.Is(err, utils.ErrUploadTooLarge), errors.Is(err, utils.ErrStorageQuotaExceeded):
+		utils.ErrorResponse(logger, w, http.StatusRequestEntityTooLarge, []byte(err.Error()))
+	case errors.Is(err, utils.ErrUploadNotPending),
+		errors.Is(err, utils.ErrUploadNotReceived),
+		errors.Is(err, utils.ErrUploadSizeMismatch),
+		errors.Is(err, utils.ErrUploadTypeMismatch),
+		errors.Is(err, utils.ErrUploadChecksumMismatch):
+		utils.ErrorResponse(logger, w, http.StatusConflict, []byte(err.Error()))
+	case errors.Is(err, utils.ErrUploadInvalidPart):
+		utils.ErrorResponse(logger, w, http.StatusBadRequest, []byte(err.Error()))
+	default:
+		logger.Printf("Upload Error: %v\n", err)
+		utils.ErrorResponse(logger, w, http.StatusInternalServerError, []byte("Something went wrong"))
+	}
+}
+
+// currentUpload returns the signed-in user's upload named by the id path value.
+func (fc *FileController) currentUpload(w http.ResponseWriter, r *http.Request) (*models.MultipartUpload, bool) {
+	currentUser, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || currentUser == nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return nil, false
+	}
+
+	id, err := utils.StringToUID(r, "id")
+	if err != nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusBadRequest, []byte("Invalid upload ID"))
+		return nil, false
+	}
+
+	upload, err := fc.MultipartUploadStore.Get(currentUser.ID, id)
+	if err != nil {
+		uploadErrorResponse(fc.Logger, w, err)
+		return nil, false
+	}
+	return upload, true
+}
+
+// CreateMultipartUpload starts a multipart upload. The response tells the
+// client how to split the file into parts.
+func (fc *FileController) CreateMultipartUpload(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.MultipartUploadCreateDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	currentUser, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || currentUser == nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return
+	}
+
+	upload, err := fc.MultipartUploadStore.Create(currentUser, *body)
+	if err != nil {
+		uploadErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(fc.Logger, w, http.StatusCreated, utils.Map{
+		"success": true,
+		"upload":  upload,
+	})
+}
+
+// GetMultipartUpload returns an upload with the parts received so far.
+func (fc *FileController) GetMultipartUpload(w http.ResponseWriter, r *http.Request) {
+	upload, ok := fc.currentUpload(w, r)
+	if !ok {
+		return
+	}
+
+	parts, err := fc.MultipartUploadStore.UploadedParts(upload)
+	if err != nil {
+		uploadErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(fc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"upload":  upload,
+		"parts":   parts,
+	})
+}
+
+// SignMultipartUploadParts returns presigned PUT URLs for the requested parts.
+func (fc *FileController) SignMultipartUploadParts(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.MultipartUploadPartsDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	upload, ok := fc.currentUpload(w, r)
+	if !ok {
+		return
+	}
+
+	urls, err := fc.MultipartUploadStore.PartURLs(upload, body.PartNumbers)
+	if err != nil {
+		uploadErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(fc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"urls":    urls,
+	})
+}
+
+func (fc *FileController) CompleteMultipartUpload(w http.ResponseWriter, r *http.Request) {
+	upload, ok := fc.currentUpload(w, r)
+	if !ok {
+		return
+	}
+
+	file, err := fc.MultipartUploadStore.Complete(upload)
+	if err != nil {
+		uploadErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(fc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"file":    file,
+	})
+}
+
+func (fc *FileController) AbortMultipartUpload(w http.ResponseWriter, r *http.Request) {
+	upload, ok := fc.currentUpload(w, r)
+	if !ok {
+		return
+	}
+
+	if err := fc.MultipartUploadStore.Abort(upload); err != nil {
+		uploadErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(fc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"message": "Upload aborted",
+	})
+}
+
+// CreateDirectUpload registers an upload the client PUTs straight to
+// storage and returns the presigned request to make.
+func (fc *FileController) CreateDirectUpload(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.DirectUploadCreateDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	currentUser, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || currentUser == nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return
+	}
+
+	upload, target, err := fc.DirectUploadStore.Create(currentUser, *body)
+	if err != nil {
+		uploadErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(fc.Logger, w, http.StatusCreated, utils.Map{
+		"success": true,
+		"upload":  upload,
+		"target":  target,
+	})
+}
+
+// ConfirmDirectUpload verifies the uploaded object and returns its file.
+func (fc *FileController) ConfirmDirectUpload(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || currentUser == nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return
+	}
+
+	id, err := utils.StringToUID(r, "id")
+	if err != nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusBadRequest, []byte("Invalid upload ID"))
+		return
+	}
+
+	upload, err := fc.DirectUploadStore.Get(currentUser.ID, id)
+	if err != nil {
+		uploadErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	file, err := fc.DirectUploadStore.Confirm(upload)
+	if err != nil {
+		uploadErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(fc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"file":    file,
+	})
+}
+
+// StorageUsage returns the storage used by the signed-in user, or by their
+// organization, against its quota.
+func (fc *FileController) StorageUsage(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || currentUser == nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return
+	}
+
+	usage, err := fc.FileStore.StorageStore.Usage(currentUser)
+	if err != nil {
+		fc.Logger.Printf("StorageUsage Error: %v\n", err)
+		utils.ErrorResponse(fc.Logger, w, http.StatusInternalServerError, []byte("Something went wrong"))
+		return
+	}
+
+	utils.ResponseWithJSON(fc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"storage": usage,
+	})
+}
@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/models"
+	"github.com/21TechLabs/factory-backend/utils"
+	"github.com/google/uuid"
+)
+
+type FolderController struct {
+	Logger      *log.Logger
+	FolderStore *models.FolderStore
+}
+
+func NewFolderController(logger *log.Logger, folderStore *models.FolderStore) *FolderController {
+	return &FolderController{
+		Logger:      logger,
+		FolderStore: folderStore,
+	}
+}
+
+// ownedFolder returns the signed-in user's folder named by the id path value.
+func (fc *FolderController) ownedFolder(w http.ResponseWriter, r *http.Request) (*models.Folder, bool) {
+	currentUser, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || currentUser == nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return nil, false
+	}
+
+	id, err := utils.StringToUID(r, "id")
+	if err != nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusBadRequest, []byte("Invalid folder ID"))
+		return nil, false
+	}
+
+	folder, err := fc.FolderStore.Get(currentUser.ID, id)
+	if err != nil {
+		fileErrorResponse(fc.Logger, w, err)
+		return nil, false
+	}
+	return folder, true
+}
+
+// ListFolders returns the folders directly under the parentId query
+// parameter, or the top-level folders without it.
+func (fc *FolderController) ListFolders(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || currentUser == nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return
+	}
+
+	var parentID *uuid.UUID
+	if value := r.URL.Query().Get("parentId"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			utils.ErrorResponse(fc.Logger, w, http.StatusBadRequest, []byte("Invalid parent ID"))
+			return
+		}
+		parentID = &id
+	}
+
+	folders, err := fc.FolderStore.List(currentUser.ID, parentID)
+	if err != nil {
+		fileErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(fc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"folders": folders,
+	})
+}
+
+func (fc *FolderController) CreateFolder(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.FolderCreateDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	currentUser, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || currentUser == nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return
+	}
+
+	folder, err := fc.FolderStore.Create(currentUser.ID, body.Name, body.ParentID)
+	if err != nil {
+		fileErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(fc.Logger, w, http.StatusCreated, utils.Map{
+		"success": true,
+		"folder":  folder,
+	})
+}
+
+func (fc *FolderController) RenameFolder(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.FolderRenameDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	folder, ok := fc.ownedFolder(w, r)
+	if !ok {
+		return
+	}
+
+	if err := fc.FolderStore.Rename(folder, body.Name); err != nil {
+		fileErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(fc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"folder":  folder,
+	})
+}
+
+func (fc *FolderController) MoveFolder(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.FolderMoveDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(fc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	folder, ok := fc.ownedFolder(w, r)
+	if !ok {
+		return
+	}
+
+	if err := fc.FolderStore.Move(folder, body.ParentID); err != nil {
+		fileErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(fc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"folder":  folder,
+	})
+}
+
+// DeleteFolder deletes an empty folder.
+func (fc *FolderController) DeleteFolder(w http.ResponseWriter, r *http.Request) {
+	folder, ok := fc.ownedFolder(w, r)
+	if !ok {
+		return
+	}
+
+	if err := fc.FolderStore.Delete(folder); err != nil {
+		fileErrorResponse(fc.Logger, w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(fc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"message": "Folder deleted",
+	})
+}
@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/models"
+	"github.com/21TechLabs/factory-backend/utils"
+)
+
+type ImpersonationController struct {
+	Logger             *log.Logger
+	ImpersonationStore *models.ImpersonationStore
+	UserStore          *models.UserStore
+}
+
+func NewImpersonationController(logger *log.Logger, store *models.ImpersonationStore, userStore *models.UserStore) *ImpersonationController {
+	return &ImpersonationController{
+		Logger:             logger,
+		ImpersonationStore: store,
+		UserStore:          userStore,
+	}
+}
+
+// StartImpersonation issues the signed-in admin a token that acts as another
+// user. The token is only returned in the body, not set as the cookie, so
+// the admin's own session is left alone.
+func (ic *ImpersonationController) StartImpersonation(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.ImpersonationStartDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(ic.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	admin, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || admin == nil {
+		utils.ErrorResponse(ic.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return
+	}
+
+	session, user, err := ic.ImpersonationStore.Start(admin, body.UserID, body.Reason, time.Duration(body.Minutes)*time.Minute)
+	if err != nil {
+		switch {
+		case errors.Is(err, utils.ErrUserNotFound):
+			utils.ErrorResponse(ic.Logger, w, http.StatusNotFound, []byte(err.Error()))
+		case errors.Is(err, utils.ErrImpersonationNotAllowed):
+			utils.ErrorResponse(ic.Logger, w, http.StatusForbidden, []byte(err.Error()))
+		default:
+			ic.Logger.Printf("StartImpersonation Error: %v\n", err)
+			utils.ErrorResponse(ic.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		}
+		return
+	}
+
+	token, err := user.JwtImpersonationTokenGet(session, []byte(utils.GetEnv("JWT_SECRET_KEY", false)))
+	if err != nil {
+		ic.Logger.Printf("StartImpersonation Error: %v\n", err)
+		utils.ErrorResponse(ic.Logger, w, http.StatusInternalServerError, []byte("Failed to generate impersonation token!"))
+		return
+	}
+
+	ic.Logger.Printf("Impersonation: admin %s started session %s as user %s: %s", admin.ID, session.ID, user.ID, session.Reason)
+
+	utils.ResponseWithJSON(ic.Logger, w, http.StatusOK, utils.Map{
+		"success":       true,
+		"token":         token,
+		"impersonation": session,
+		"user":          ic.UserStore.GetDetails(&user, false),
+	})
+}
+
+// EndImpersonation ends the impersonation session the request is made with.
+func (ic *ImpersonationController) EndImpersonation(w http.ResponseWriter, r *http.Request) {
+	session, err := utils.ReadContextValue[*models.ImpersonationSession](r, utils.ImpersonationContextKey)
+	if err != nil || session == nil {
+		utils.ErrorResponse(ic.Logger, w, http.StatusBadRequest, []byte(utils.ErrNotImpersonating.Error()))
+		return
+	}
+
+	if err := ic.ImpersonationStore.End(session.ID); err != nil {
+		if errors.Is(err, utils.ErrImpersonationEnded) {
+			utils.ErrorResponse(ic.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+			return
+		}
+		ic.Logger.Printf("EndImpersonation Error: %v\n", err)
+		utils.ErrorResponse(ic.Logger, w, http.StatusInternalServerError, []byte("Something went wrong"))
+		return
+	}
+
+	utils.ResponseWithJSON(ic.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"message": "Impersonation ended",
+	})
+}
+
+func (ic *ImpersonationController) ListImpersonations(w http.ResponseWriter, r *http.Request) {
+	filter := &dto.ImpersonationFilterDto{}
+	if err := utils.ParseQueryParams(r, filter); err != nil {
+		utils.ErrorResponse(ic.Logger, w, http.StatusBadRequest, []byte("Invalid query parameters"))
+		return
+	}
+
+	if err := utils.ValidateStruct(filter); err != nil {
+		utils.ErrorResponse(ic.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	start, limit, err := utils.ParsePagination(filter.Start, filter.Limit, 50, 200)
+	if err != nil {
+		utils.ErrorResponse(ic.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	sessions, err := ic.ImpersonationStore.FindSessions(*filter, start, limit)
+	if err != nil {
+		utils.ErrorResponse(ic.Logger, w, http.StatusInternalServerError, []byte(err.Error()))
+		return
+	}
+
+	utils.ResponseWithJSON(ic.Logger, w, http.StatusOK, utils.Map{
+		"success":        true,
+		"impersonations": sessions,
+	})
+}
+
+// ListImpersonationRequests returns the audit trail of one session.
+func (ic *ImpersonationController) ListImpersonationRequests(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.StringToUID(r, "id")
+	if err != nil {
+		utils.ErrorResponse(ic.Logger, w, http.StatusBadRequest, []byte("Invalid impersonation ID"))
+		return
+	}
+
+	query := r.URL.Query()
+	start, limit, err := utils.ParsePagination(json.Number(query.Get("start")), json.Number(query.Get("limit")), 100, 500)
+	if err != nil {
+		utils.ErrorResponse(ic.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	requests, err := ic.ImpersonationStore.FindRequests(id, start, limit)
+	if err != nil {
+		utils.ErrorResponse(ic.Logger, w, http.StatusInternalServerError, []byte(err.Error()))
+		return
+	}
+
+	utils.ResponseWithJSON(ic.Logger, w, http.StatusOK, utils.Map{
+		"success":  true,
+		"requests": requests,
+	})
+}
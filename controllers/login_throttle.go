@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/models"
+	"github.com/21TechLabs/factory-backend/utils"
+)
+
+type LoginThrottleController struct {
+	Logger             *log.Logger
+	LoginThrottleStore *models.LoginThrottleStore
+}
+
+func NewLoginThrottleController(logger *log.Logger, store *models.LoginThrottleStore) *LoginThrottleController {
+	return &LoginThrottleController{
+		Logger:             logger,
+		LoginThrottleStore: store,
+	}
+}
+
+// loginThrottledResponse answers a throttled sign-in with 429 and a
+// Retry-After header in whole seconds.
+func loginThrottledResponse(logger *log.Logger, w http.ResponseWriter, err *utils.LoginThrottledError) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(err.RetryAfter.Seconds()))))
+	utils.ErrorResponse(logger, w, http.StatusTooManyRequests, []byte(err.Error()))
+}
+
+// UnlockAccount lifts a lockout with the token from the account locked email.
+func (ltc *LoginThrottleController) UnlockAccount(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.AccountUnlockDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(ltc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	if err := ltc.LoginThrottleStore.Unlock(body.Token); err != nil {
+		if errors.Is(err, utils.ErrUnlockTokenInvalid) {
+			utils.ErrorResponse(ltc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+			return
+		}
+		ltc.Logger.Printf("UnlockAccount Error: %v\n", err)
+		utils.ErrorResponse(ltc.Logger, w, http.StatusInternalServerError, []byte("Something went wrong"))
+		return
+	}
+
+	utils.ResponseWithJSON(ltc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"message": "Account unlocked, you can sign in again",
+	})
+}
+
+func (ltc *LoginThrottleController) ListLockouts(w http.ResponseWriter, r *http.Request) {
+	filter := &dto.LoginThrottleFilterDto{}
+	if err := utils.ParseQueryParams(r, filter); err != nil {
+		utils.ErrorResponse(ltc.Logger, w, http.StatusBadRequest, []byte("Invalid query parameters"))
+		return
+	}
+
+	if err := utils.ValidateStruct(filter); err != nil {
+		utils.ErrorResponse(ltc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	start, limit, err := utils.ParsePagination(filter.Start, filter.Limit, 50, 200)
+	if err != nil {
+		utils.ErrorResponse(ltc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	lockouts, err := ltc.LoginThrottleStore.FindActive(*filter, start, limit)
+	if err != nil {
+		utils.ErrorResponse(ltc.Logger, w, http.StatusInternalServerError, []byte(err.Error()))
+		return
+	}
+
+	utils.ResponseWithJSON(ltc.Logger, w, http.StatusOK, utils.Map{
+		"success":  true,
+		"lockouts": lockouts,
+	})
+}
+
+func (ltc *LoginThrottleController) ClearLockout(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.StringToUID(r, "id")
+	if err != nil {
+		utils.ErrorResponse(ltc.Logger, w, http.StatusBadRequest, []byte("Invalid lockout ID"))
+		return
+	}
+
+	if err := ltc.LoginThrottleStore.Clear(id); err != nil {
+		if errors.Is(err, utils.ErrLockoutNotFound) {
+			utils.ErrorResponse(ltc.Logger, w, http.StatusNotFound, []byte(err.Error()))
+			return
+		}
+		utils.ErrorResponse(ltc.Logger, w, http.StatusInternalServerError, []byte(err.Error()))
+		return
+	}
+
+	utils.ResponseWithJSON(ltc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"message": "Lockout cleared",
+	})
+}
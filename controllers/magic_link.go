@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/models"
+	"github.com/21TechLabs/factory-backend/utils"
+	"gorm.io/gorm"
+)
+
+type MagicLinkController struct {
+	Logger         *log.Logger
+	MagicLinkStore *models.MagicLinkStore
+	UserStore      *models.UserStore
+}
+
+func NewMagicLinkController(logger *log.Logger, magicLinkStore *models.MagicLinkStore, userStore *models.UserStore) *MagicLinkController {
+	return &MagicLinkController{
+		Logger:         logger,
+		MagicLinkStore: magicLinkStore,
+		UserStore:      userStore,
+	}
+}
+
+// RequestMagicLink emails a sign-in link. The response is the same whether
+// or not the address belongs to an account.
+func (mlc *MagicLinkController) RequestMagicLink(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.MagicLinkRequestDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(mlc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	// checked by domain before the lookup so it reveals nothing about the account
+	if err := mlc.UserStore.CheckSSOSignIn(body.Email); err != nil {
+		if errors.Is(err, utils.ErrSSORequired) {
+			utils.ErrorResponse(mlc.Logger, w, http.StatusForbidden, []byte(err.Error()))
+			return
+		}
+		mlc.Logger.Printf("RequestMagicLink Error: %v\n", err)
+		utils.ErrorResponse(mlc.Logger, w, http.StatusInternalServerError, []byte("Something went wrong"))
+		return
+	}
+
+	user, err := mlc.UserStore.UserGetByEmail(body.Email)
+	switch {
+	case err == nil && user.CanLogin() == nil:
+		if err := mlc.MagicLinkStore.Send(&user); err != nil {
+			// not reported to the caller, that would reveal the account exists
+			mlc.Logger.Printf("RequestMagicLink Error: %v\n", err)
+		}
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		mlc.Logger.Printf("RequestMagicLink Error: %v\n", err)
+		utils.ErrorResponse(mlc.Logger, w, http.StatusInternalServerError, []byte("Something went wrong"))
+		return
+	}
+
+	utils.ResponseWithJSON(mlc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"message": "If the email belongs to an account, a sign-in link has been sent",
+	})
+}
+
+// VerifyMagicLink signs the user in with the token from a magic link. It is
+// called by the frontend after the user confirms; top-level navigations, such
+// as a scanner or browser opening this URL directly, are refused so they
+// cannot consume the token.
+func (mlc *MagicLinkController) VerifyMagicLink(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Sec-Fetch-Mode") == "navigate" {
+		utils.ErrorResponse(mlc.Logger, w, http.StatusBadRequest, []byte("Open the sign-in link from your email instead"))
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		utils.ErrorResponse(mlc.Logger, w, http.StatusBadRequest, []byte(utils.ErrMagicLinkInvalid.Error()))
+		return
+	}
+
+	user, err := mlc.MagicLinkStore.Verify(token)
+	if err != nil {
+		if errors.Is(err, utils.ErrMagicLinkInvalid) {
+			utils.ErrorResponse(mlc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+			return
+		}
+		if errors.Is(err, utils.ErrSSORequired) {
+			utils.ErrorResponse(mlc.Logger, w, http.StatusForbidden, []byte(err.Error()))
+			return
+		}
+		mlc.Logger.Printf("VerifyMagicLink Error: %v\n", err)
+		utils.ErrorResponse(mlc.Logger, w, http.StatusInternalServerError, []byte("Something went wrong"))
+		return
+	}
+
+	if err := user.CanLogin(); err != nil {
+		utils.ErrorResponse(mlc.Logger, w, http.StatusForbidden, []byte(err.Error()))
+		return
+	}
+
+	SetLoginTokenAndSendResponse(mlc.Logger, r, w, user, false, mlc.UserStore)
+}
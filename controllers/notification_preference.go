@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/21TechLabs/factory-backend/config"
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/models"
+	"github.com/21TechLabs/factory-backend/utils"
+)
+
+// unsubscribePage asks for confirmation. GET requests (link scanners, people
+// opening the link) must not unsubscribe, so the form POSTs the one-click body.
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>{{.Brand}}</title></head>
+<body style="font-family: sans-serif; max-width: 480px; margin: 48px auto; text-align: center;">
+{{if .Done}}<p>You have been unsubscribed from {{.Category}} emails.</p>
+{{else}}<p>Stop receiving {{.Category}} emails from {{.Brand}}?</p>
+<form method="post">
+<input type="hidden" name="List-Unsubscribe" value="One-Click">
+<button type="submit">Unsubscribe</button>
+</form>
+{{end}}</body>
+</html>
+`))
+
+type NotificationPreferenceController struct {
+	Logger                      *log.Logger
+	NotificationPreferenceStore *models.NotificationPreferenceStore
+}
+
+func NewNotificationPreferenceController(logger *log.Logger, store *models.NotificationPreferenceStore) *NotificationPreferenceController {
+	return &NotificationPreferenceController{
+		Logger:                      logger,
+		NotificationPreferenceStore: store,
+	}
+}
+
+func (npc *NotificationPreferenceController) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	user, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || user == nil {
+		utils.ErrorResponse(npc.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return
+	}
+
+	prefs, err := npc.NotificationPreferenceStore.Get(user.ID)
+	if err != nil {
+		utils.ErrorResponse(npc.Logger, w, http.StatusInternalServerError, []byte(err.Error()))
+		return
+	}
+
+	utils.ResponseWithJSON(npc.Logger, w, http.StatusOK, utils.Map{
+		"success":     true,
+		"preferences": prefs,
+	})
+}
+
+func (npc *NotificationPreferenceController) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.NotificationPreferencesUpdateDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(npc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	user, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || user == nil {
+		utils.ErrorResponse(npc.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return
+	}
+
+	updates := map[utils.NotificationCategory]bool{}
+	if body.Billing != nil {
+		updates[utils.NotificationCategoryBilling] = *body.Billing
+	}
+	if body.ProductUpdates != nil {
+		updates[utils.NotificationCategoryProductUpdates] = *body.ProductUpdates
+	}
+	if body.Marketing != nil {
+		updates[utils.NotificationCategoryMarketing] = *body.Marketing
+	}
+
+	if err := npc.NotificationPreferenceStore.Set(user.ID, updates); err != nil {
+		utils.ErrorResponse(npc.Logger, w, http.StatusInternalServerError, []byte(err.Error()))
+		return
+	}
+
+	prefs, err := npc.NotificationPreferenceStore.Get(user.ID)
+	if err != nil {
+		utils.ErrorResponse(npc.Logger, w, http.StatusInternalServerError, []byte(err.Error()))
+		return
+	}
+
+	utils.ResponseWithJSON(npc.Logger, w, http.StatusOK, utils.Map{
+		"success":     true,
+		"preferences": prefs,
+	})
+}
+
+// UnsubscribePage shows the confirmation form for a List-Unsubscribe link.
+func (npc *NotificationPreferenceController) UnsubscribePage(w http.ResponseWriter, r *http.Request) {
+	_, category, err := models.ParseUnsubscribeToken(r.URL.Query().Get("token"))
+	if err != nil {
+		utils.ErrorResponse(npc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	npc.renderUnsubscribePage(w, category, false)
+}
+
+// Unsubscribe handles RFC 8058 one-click POSTs from mail clients as well as
+// the confirmation form. The signed token is the only credential.
+func (npc *NotificationPreferenceController) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	userID, category, err := models.ParseUnsubscribeToken(r.URL.Query().Get("token"))
+	if err != nil {
+		utils.ErrorResponse(npc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	if err := npc.NotificationPreferenceStore.Set(userID, map[utils.NotificationCategory]bool{category: false}); err != nil {
+		utils.ErrorResponse(npc.Logger, w, http.StatusInternalServerError, []byte(err.Error()))
+		return
+	}
+
+	npc.renderUnsubscribePage(w, category, true)
+}
+
+func (npc *NotificationPreferenceController) renderUnsubscribePage(w http.ResponseWriter, category utils.NotificationCategory, done bool) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	err := unsubscribePage.Execute(w, map[string]interface{}{
+		"Brand":    config.Name,
+		"Category": category,
+		"Done":     done,
+	})
+	if err != nil {
+		npc.Logger.Printf("failed to render unsubscribe page: %v", err)
+	}
+}
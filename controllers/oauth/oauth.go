@@ -0,0 +1,263 @@
+package oauth_controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/controllers"
+	"github.com/21TechLabs/factory-backend/models"
+	"github.com/21TechLabs/factory-backend/oauth"
+	"github.com/21TechLabs/factory-backend/utils"
+	"github.com/google/uuid"
+)
+
+// oauthStateCookie binds a flow to the browser that started it, so a
+// callback URL from someone else's flow cannot sign this browser in.
+const oauthStateCookie = "oauth_state"
+
+type OAuthController struct {
+	Logger            *log.Logger
+	UserStore         *models.UserStore
+	UserIdentityStore *models.UserIdentityStore
+	OAuthFlowStore    *models.OAuthFlowStore
+	Providers         map[string]oauth.Provider
+}
+
+func NewOAuthController(log *log.Logger, userStore *models.UserStore, userIdentityStore *models.UserIdentityStore, oauthFlowStore *models.OAuthFlowStore, providers map[string]oauth.Provider) *OAuthController {
+	return &OAuthController{
+		Logger:            log,
+		UserStore:         userStore,
+		UserIdentityStore: userIdentityStore,
+		OAuthFlowStore:    oauthFlowStore,
+		Providers:         providers,
+	}
+}
+
+func (oac *OAuthController) identityErrorResponse(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, utils.ErrIdentityNotFound), errors.Is(err, utils.ErrOAuthProviderNotFound):
+		utils.ErrorResponse(oac.Logger, w, http.StatusNotFound, []byte(err.Error()))
+	case errors.Is(err, utils.ErrIdentityLinkedElsewhere), errors.Is(err, utils.ErrIdentityProviderLinked),
+		errors.Is(err, utils.ErrIdentityEmailUnverified), errors.Is(err, utils.ErrIdentityLastLoginMethod):
+		utils.ErrorResponse(oac.Logger, w, http.StatusConflict, []byte(err.Error()))
+	case errors.Is(err, utils.ErrOAuthEmailMissing), errors.Is(err, utils.ErrOAuthStateInvalid):
+		utils.ErrorResponse(oac.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+	case errors.Is(err, utils.ErrSSORequired):
+		utils.ErrorResponse(oac.Logger, w, http.StatusForbidden, []byte(err.Error()))
+	default:
+		oac.Logger.Printf("OAuth Error: %v\n", err)
+		utils.ErrorResponse(oac.Logger, w, http.StatusInternalServerError, []byte("Something went wrong"))
+	}
+}
+
+// ListProviders returns the names of the enabled providers.
+func (oac *OAuthController) ListProviders(w http.ResponseWriter, r *http.Request) {
+	names := make([]string, 0, len(oac.Providers))
+	for name := range oac.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	utils.ResponseWithJSON(oac.Logger, w, http.StatusOK, utils.Map{
+		"success":   true,
+		"providers": names,
+	})
+}
+
+// begin redirects to the provider. linkUserID is set when the callback
+// should link the provider account instead of signing in.
+func (oac *OAuthController) begin(w http.ResponseWriter, r *http.Request, linkUserID *uuid.UUID) {
+	provider, ok := oac.Providers[r.PathValue("provider")]
+	if !ok {
+		oac.identityErrorResponse(w, utils.ErrOAuthProviderNotFound)
+		return
+	}
+
+	req, err := oauth.NewAuthRequest()
+	if err != nil {
+		oac.identityErrorResponse(w, err)
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), req)
+	if err != nil {
+		oac.identityErrorResponse(w, err)
+		return
+	}
+
+	if err := oac.OAuthFlowStore.Create(provider.Name(), req.State, req.Nonce, req.CodeVerifier, linkUserID); err != nil {
+		oac.identityErrorResponse(w, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    req.State,
+		Path:     "/user/oauth2/",
+		MaxAge:   int((10 * time.Minute).Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		// Lax lets the cookie through on the provider's top-level redirect back
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Login starts signing in with a provider.
+func (oac *OAuthController) Login(w http.ResponseWriter, r *http.Request) {
+	oac.begin(w, r, nil)
+}
+
+// LinkBegin starts an OAuth flow whose callback links the provider account
+// to the signed-in user instead of signing in.
+func (oac *OAuthController) LinkBegin(w http.ResponseWriter, r *http.Request) {
+	user, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || user == nil {
+		utils.ErrorResponse(oac.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return
+	}
+
+	oac.begin(w, r, &user.ID)
+}
+
+// Callback completes an OAuth flow. A flow started by LinkBegin links the
+// provider account to the signed-in user; any other signs the user in.
+func (oac *OAuthController) Callback(w http.ResponseWriter, r *http.Request) {
+	provider, ok := oac.Providers[r.PathValue("provider")]
+	if !ok {
+		oac.identityErrorResponse(w, utils.ErrOAuthProviderNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	if errCode := query.Get("error"); errCode != "" {
+		oac.Logger.Printf("OAuth Callback error from %s: %s %s\n", provider.Name(), errCode, query.Get("error_description"))
+		utils.ErrorResponse(oac.Logger, w, http.StatusBadRequest, []byte("Sign-in was cancelled or denied"))
+		return
+	}
+
+	state := query.Get("state")
+	cookie, err := r.Cookie(oauthStateCookie)
+	if state == "" || err != nil || cookie.Value != state {
+		oac.identityErrorResponse(w, utils.ErrOAuthStateInvalid)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    "",
+		Path:     "/user/oauth2/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+	})
+
+	flow, err := oac.OAuthFlowStore.Take(provider.Name(), state)
+	if err != nil {
+		oac.identityErrorResponse(w, err)
+		return
+	}
+
+	result, err := provider.Exchange(r.Context(), query.Get("code"), oauth.AuthRequest{
+		State:        state,
+		Nonce:        flow.Nonce,
+		CodeVerifier: string(flow.CodeVerifier),
+	})
+	if err != nil {
+		oac.Logger.Printf("OAuth Callback error Exchange for %s: %v\n", provider.Name(), err)
+		utils.ErrorResponse(oac.Logger, w, http.StatusBadGateway, []byte("Could not complete sign-in with the provider"))
+		return
+	}
+
+	profile := &models.OAuthProfile{
+		Provider:       provider.Name(),
+		ProviderUserID: result.Profile.Subject,
+		Email:          result.Profile.Email,
+		EmailVerified:  result.Profile.EmailVerified,
+		Name:           result.Profile.Name,
+		AvatarURL:      result.Profile.AvatarURL,
+		AccessToken:    result.Token.AccessToken,
+		RefreshToken:   result.Token.RefreshToken,
+	}
+	if !result.Token.Expiry.IsZero() {
+		profile.TokenExpiresAt = &result.Token.Expiry
+	}
+
+	if flow.LinkUserID != nil {
+		// linking also requires the same user to still be signed in
+		user, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+		if err != nil || user == nil || user.ID != *flow.LinkUserID {
+			utils.ErrorResponse(oac.Logger, w, http.StatusUnauthorized, []byte("Sign in to link an account"))
+			return
+		}
+
+		identity, err := oac.UserIdentityStore.Link(user, profile)
+		if err != nil {
+			oac.identityErrorResponse(w, err)
+			return
+		}
+
+		utils.ResponseWithJSON(oac.Logger, w, http.StatusOK, utils.Map{
+			"success":  true,
+			"identity": identity,
+		})
+		return
+	}
+
+	user, err := oac.UserIdentityStore.Login(profile)
+	if err != nil {
+		oac.identityErrorResponse(w, err)
+		return
+	}
+
+	if err := user.CanLogin(); err != nil {
+		utils.ErrorResponse(oac.Logger, w, http.StatusForbidden, []byte(err.Error()))
+		return
+	}
+
+	controllers.SetLoginTokenAndSendResponse(oac.Logger, r, w, user, false, oac.UserStore)
+}
+
+func (oac *OAuthController) ListIdentities(w http.ResponseWriter, r *http.Request) {
+	user, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || user == nil {
+		utils.ErrorResponse(oac.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return
+	}
+
+	identities, err := oac.UserIdentityStore.List(user.ID)
+	if err != nil {
+		oac.identityErrorResponse(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(oac.Logger, w, http.StatusOK, utils.Map{
+		"success":    true,
+		"identities": identities,
+	})
+}
+
+func (oac *OAuthController) UnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	user, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || user == nil {
+		utils.ErrorResponse(oac.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return
+	}
+
+	id, err := utils.StringToUID(r, "id")
+	if err != nil {
+		utils.ErrorResponse(oac.Logger, w, http.StatusBadRequest, []byte("Invalid identity ID"))
+		return
+	}
+
+	if err := oac.UserIdentityStore.Unlink(user, id); err != nil {
+		oac.identityErrorResponse(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(oac.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+	})
+}
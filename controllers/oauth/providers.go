@@ -0,0 +1,72 @@
+package oauth_controller
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/21TechLabs/factory-backend/oauth"
+	"github.com/21TechLabs/factory-backend/utils"
+)
+
+var providerNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// NewProvidersFromEnv builds the providers named in the comma separated
+// OAUTH_PROVIDERS. Each name reads OAUTH_<NAME>_* variables:
+//
+//	KIND           google | github | microsoft | discord | oidc; defaults to the
+//	               name when it is one of these, and to oidc otherwise
+//	CLIENT_ID      required
+//	CLIENT_SECRET  required
+//	REDIRECT_URL   defaults to API_URL/user/oauth2/<name>/login/callback
+//	SCOPES         space separated, replaces the kind's defaults
+//	ISSUER         required for oidc, used for discovery
+//	TENANT         microsoft tenant ID, defaults to common
+func NewProvidersFromEnv() (map[string]oauth.Provider, error) {
+	providers := map[string]oauth.Provider{}
+
+	for _, name := range strings.Split(utils.GetEnv("OAUTH_PROVIDERS", true), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !providerNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid oauth provider name %q", name)
+		}
+		if _, ok := providers[name]; ok {
+			return nil, fmt.Errorf("oauth provider %q is listed twice", name)
+		}
+
+		prefix := "OAUTH_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+
+		kind := oauth.Kind(strings.ToLower(utils.GetEnv(prefix+"KIND", true)))
+		if kind == "" {
+			kind = oauth.Kind(name)
+			if !kind.IsValid() {
+				kind = oauth.KindOIDC
+			}
+		}
+
+		redirectURL := utils.GetEnv(prefix+"REDIRECT_URL", true)
+		if redirectURL == "" {
+			redirectURL = fmt.Sprintf("%s/user/oauth2/%s/login/callback", strings.TrimSuffix(utils.GetEnv("API_URL", false), "/"), name)
+		}
+
+		provider, err := oauth.New(oauth.Config{
+			Name:         name,
+			Kind:         kind,
+			ClientID:     utils.GetEnv(prefix+"CLIENT_ID", false),
+			ClientSecret: utils.GetEnv(prefix+"CLIENT_SECRET", false),
+			RedirectURL:  redirectURL,
+			Scopes:       strings.Fields(utils.GetEnv(prefix+"SCOPES", true)),
+			Issuer:       utils.GetEnv(prefix+"ISSUER", true),
+			Tenant:       utils.GetEnv(prefix+"TENANT", true),
+		})
+		if err != nil {
+			return nil, err
+		}
+		providers[name] = provider
+	}
+
+	return providers, nil
+}
@@ -0,0 +1,363 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/models"
+	"github.com/21TechLabs/factory-backend/utils"
+)
+
+// OrganizationController lets admins manage organizations, their email
+// domains, their SAML connections and their SCIM tokens.
+type OrganizationController struct {
+	Logger              *log.Logger
+	OrganizationStore   *models.OrganizationStore
+	SAMLConnectionStore *models.SAMLConnectionStore
+	SCIMStore           *models.SCIMStore
+}
+
+func NewOrganizationController(logger *log.Logger, organizationStore *models.OrganizationStore, samlConnectionStore *models.SAMLConnectionStore, scimStore *models.SCIMStore) *OrganizationController {
+	return &OrganizationController{
+		Logger:              logger,
+		OrganizationStore:   organizationStore,
+		SAMLConnectionStore: samlConnectionStore,
+		SCIMStore:           scimStore,
+	}
+}
+
+func (oc *OrganizationController) organizationErrorResponse(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, utils.ErrOrganizationNotFound), errors.Is(err, utils.ErrOrganizationDomainNotFound),
+		errors.Is(err, utils.ErrSAMLConnectionNotFound), errors.Is(err, utils.ErrSCIMTokenNotFound):
+		utils.ErrorResponse(oc.Logger, w, http.StatusNotFound, []byte(err.Error()))
+	case errors.Is(err, utils.ErrOrganizationDomainTaken):
+		utils.ErrorResponse(oc.Logger, w, http.StatusConflict, []byte(err.Error()))
+	case errors.Is(err, utils.ErrSAMLMetadataInvalid):
+		utils.ErrorResponse(oc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+	default:
+		oc.Logger.Printf("Organization Error: %v\n", err)
+		utils.ErrorResponse(oc.Logger, w, http.StatusInternalServerError, []byte("Something went wrong"))
+	}
+}
+
+func (oc *OrganizationController) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	orgs, err := oc.OrganizationStore.List()
+	if err != nil {
+		oc.organizationErrorResponse(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(oc.Logger, w, http.StatusOK, utils.Map{
+		"success":       true,
+		"organizations": orgs,
+	})
+}
+
+func (oc *OrganizationController) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.OrganizationCreateDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(oc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	org, err := oc.OrganizationStore.Create(body.Name)
+	if err != nil {
+		oc.organizationErrorResponse(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(oc.Logger, w, http.StatusCreated, utils.Map{
+		"success":      true,
+		"organization": org,
+	})
+}
+
+func (oc *OrganizationController) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.StringToUID(r, "id")
+	if err != nil {
+		utils.ErrorResponse(oc.Logger, w, http.StatusBadRequest, []byte("Invalid organization ID"))
+		return
+	}
+
+	org, err := oc.OrganizationStore.Get(id)
+	if err != nil {
+		oc.organizationErrorResponse(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(oc.Logger, w, http.StatusOK, utils.Map{
+		"success":      true,
+		"organization": org,
+	})
+}
+
+func (oc *OrganizationController) UpdateOrganization(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.OrganizationUpdateDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(oc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	id, err := utils.StringToUID(r, "id")
+	if err != nil {
+		utils.ErrorResponse(oc.Logger, w, http.StatusBadRequest, []byte("Invalid organization ID"))
+		return
+	}
+
+	org, err := oc.OrganizationStore.Update(id, body)
+	if err != nil {
+		oc.organizationErrorResponse(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(oc.Logger, w, http.StatusOK, utils.Map{
+		"success":      true,
+		"organization": org,
+	})
+}
+
+func (oc *OrganizationController) DeleteOrganization(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.StringToUID(r, "id")
+	if err != nil {
+		utils.ErrorResponse(oc.Logger, w, http.StatusBadRequest, []byte("Invalid organization ID"))
+		return
+	}
+
+	if err := oc.OrganizationStore.Delete(id); err != nil {
+		oc.organizationErrorResponse(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(oc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+	})
+}
+
+func (oc *OrganizationController) AddDomain(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.OrganizationDomainCreateDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(oc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	id, err := utils.StringToUID(r, "id")
+	if err != nil {
+		utils.ErrorResponse(oc.Logger, w, http.StatusBadRequest, []byte("Invalid organization ID"))
+		return
+	}
+
+	domain, err := oc.OrganizationStore.AddDomain(id, body.Domain, body.EnforceSSO)
+	if err != nil {
+		oc.organizationErrorResponse(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(oc.Logger, w, http.StatusCreated, utils.Map{
+		"success": true,
+		"domain":  domain,
+	})
+}
+
+func (oc *OrganizationController) UpdateDomain(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.OrganizationDomainUpdateDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(oc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	id, err := utils.StringToUID(r, "id")
+	if err != nil {
+		utils.ErrorResponse(oc.Logger, w, http.StatusBadRequest, []byte("Invalid organization ID"))
+		return
+	}
+	domainID, err := utils.StringToUID(r, "domainId")
+	if err != nil {
+		utils.ErrorResponse(oc.Logger, w, http.StatusBadRequest, []byte("Invalid domain ID"))
+		return
+	}
+
+	domain, err := oc.OrganizationStore.SetDomainEnforceSSO(id, domainID, body.EnforceSSO)
+	if err != nil {
+		oc.organizationErrorResponse(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(oc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"domain":  domain,
+	})
+}
+
+func (oc *OrganizationController) RemoveDomain(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.StringToUID(r, "id")
+	if err != nil {
+		utils.ErrorResponse(oc.Logger, w, http.StatusBadRequest, []byte("Invalid organization ID"))
+		return
+	}
+	domainID, err := utils.StringToUID(r, "domainId")
+	if err != nil {
+		utils.ErrorResponse(oc.Logger, w, http.StatusBadRequest, []byte("Invalid domain ID"))
+		return
+	}
+
+	if err := oc.OrganizationStore.RemoveDomain(id, domainID); err != nil {
+		oc.organizationErrorResponse(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(oc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+	})
+}
+
+// GetSAMLConnection returns the organization's connection along with the
+// service provider details to register at the IdP.
+func (oc *OrganizationController) GetSAMLConnection(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.StringToUID(r, "id")
+	if err != nil {
+		utils.ErrorResponse(oc.Logger, w, http.StatusBadRequest, []byte("Invalid organization ID"))
+		return
+	}
+
+	conn, err := oc.SAMLConnectionStore.Get(id)
+	if err != nil {
+		oc.organizationErrorResponse(w, err)
+		return
+	}
+
+	oc.samlConnectionResponse(w, http.StatusOK, conn)
+}
+
+// UpsertSAMLConnection creates or updates the organization's connection from
+// uploaded IdP metadata and attribute mapping.
+func (oc *OrganizationController) UpsertSAMLConnection(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.SAMLConnectionUpsertDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(oc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	id, err := utils.StringToUID(r, "id")
+	if err != nil {
+		utils.ErrorResponse(oc.Logger, w, http.StatusBadRequest, []byte("Invalid organization ID"))
+		return
+	}
+
+	conn, err := oc.SAMLConnectionStore.Upsert(id, body)
+	if err != nil {
+		oc.organizationErrorResponse(w, err)
+		return
+	}
+
+	oc.samlConnectionResponse(w, http.StatusOK, conn)
+}
+
+func (oc *OrganizationController) DeleteSAMLConnection(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.StringToUID(r, "id")
+	if err != nil {
+		utils.ErrorResponse(oc.Logger, w, http.StatusBadRequest, []byte("Invalid organization ID"))
+		return
+	}
+
+	if err := oc.SAMLConnectionStore.Delete(id); err != nil {
+		oc.organizationErrorResponse(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(oc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+	})
+}
+
+func (oc *OrganizationController) ListSCIMTokens(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.StringToUID(r, "id")
+	if err != nil {
+		utils.ErrorResponse(oc.Logger, w, http.StatusBadRequest, []byte("Invalid organization ID"))
+		return
+	}
+
+	tokens, err := oc.SCIMStore.ListTokens(id)
+	if err != nil {
+		oc.organizationErrorResponse(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(oc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"tokens":  tokens,
+	})
+}
+
+// CreateSCIMToken returns a new token for the organization's SCIM client
+// along with the base URL to configure at the IdP. The token is only shown
+// once.
+func (oc *OrganizationController) CreateSCIMToken(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.SCIMTokenCreateDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(oc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	id, err := utils.StringToUID(r, "id")
+	if err != nil {
+		utils.ErrorResponse(oc.Logger, w, http.StatusBadRequest, []byte("Invalid organization ID"))
+		return
+	}
+
+	token, scimToken, err := oc.SCIMStore.CreateToken(id, body.Description)
+	if err != nil {
+		oc.organizationErrorResponse(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(oc.Logger, w, http.StatusCreated, utils.Map{
+		"success":   true,
+		"token":     token,
+		"scimToken": scimToken,
+		"baseUrl":   models.SCIMURL(),
+	})
+}
+
+func (oc *OrganizationController) DeleteSCIMToken(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.StringToUID(r, "id")
+	if err != nil {
+		utils.ErrorResponse(oc.Logger, w, http.StatusBadRequest, []byte("Invalid organization ID"))
+		return
+	}
+	tokenID, err := utils.StringToUID(r, "tokenId")
+	if err != nil {
+		utils.ErrorResponse(oc.Logger, w, http.StatusBadRequest, []byte("Invalid token ID"))
+		return
+	}
+
+	if err := oc.SCIMStore.DeleteToken(id, tokenID); err != nil {
+		oc.organizationErrorResponse(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(oc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+	})
+}
+
+func (oc *OrganizationController) samlConnectionResponse(w http.ResponseWriter, status int, conn *models.SAMLConnection) {
+	metadataURL := models.SAMLURL(conn.OrganizationID, "metadata")
+	acsURL := models.SAMLURL(conn.OrganizationID, "acs")
+	entityID := conn.SPEntityID
+	if entityID == "" {
+		entityID = metadataURL.String()
+	}
+
+	utils.ResponseWithJSON(oc.Logger, w, status, utils.Map{
+		"success":    true,
+		"connection": conn,
+		"serviceProvider": utils.Map{
+			"entityId":    entityID,
+			"acsUrl":      acsURL.String(),
+			"metadataUrl": metadataURL.String(),
+		},
+	})
+}
@@ -0,0 +1,215 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/models"
+	"github.com/21TechLabs/factory-backend/utils"
+	"github.com/google/uuid"
+)
+
+type PasskeyController struct {
+	Logger       *log.Logger
+	PasskeyStore *models.PasskeyStore
+	UserStore    *models.UserStore
+}
+
+func NewPasskeyController(logger *log.Logger, passkeyStore *models.PasskeyStore, userStore *models.UserStore) *PasskeyController {
+	return &PasskeyController{
+		Logger:       logger,
+		PasskeyStore: passkeyStore,
+		UserStore:    userStore,
+	}
+}
+
+func (pc *PasskeyController) passkeyErrorResponse(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, utils.ErrPasskeyCeremonyInvalid), errors.Is(err, utils.ErrPasskeyVerificationFailed):
+		pc.Logger.Printf("Passkey Error: %v\n", err)
+		utils.ErrorResponse(pc.Logger, w, http.StatusBadRequest, []byte(utils.ErrPasskeyVerificationFailed.Error()))
+	case errors.Is(err, utils.ErrPasskeyNotFound):
+		utils.ErrorResponse(pc.Logger, w, http.StatusNotFound, []byte(err.Error()))
+	case errors.Is(err, utils.ErrPasskeyDisabled), errors.Is(err, utils.ErrSSORequired):
+		utils.ErrorResponse(pc.Logger, w, http.StatusForbidden, []byte(err.Error()))
+	case errors.Is(err, utils.ErrPasskeyAlreadyRegistered), errors.Is(err, utils.ErrPasskeyLimitReached):
+		utils.ErrorResponse(pc.Logger, w, http.StatusConflict, []byte(err.Error()))
+	default:
+		pc.Logger.Printf("Passkey Error: %v\n", err)
+		utils.ErrorResponse(pc.Logger, w, http.StatusInternalServerError, []byte("Something went wrong"))
+	}
+}
+
+// ceremonyID reads the ID returned by a begin endpoint from the ?ceremony=
+// query parameter; the request body is the authenticator's response.
+func (pc *PasskeyController) ceremonyID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(r.URL.Query().Get("ceremony"))
+	if err != nil {
+		utils.ErrorResponse(pc.Logger, w, http.StatusBadRequest, []byte(utils.ErrPasskeyCeremonyInvalid.Error()))
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// BeginRegistration returns the options for navigator.credentials.create().
+func (pc *PasskeyController) BeginRegistration(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.PasskeyRegisterBeginDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(pc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	user, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || user == nil {
+		utils.ErrorResponse(pc.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return
+	}
+
+	options, ceremonyID, err := pc.PasskeyStore.BeginRegistration(user, body.Nickname)
+	if err != nil {
+		pc.passkeyErrorResponse(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(pc.Logger, w, http.StatusOK, utils.Map{
+		"success":    true,
+		"ceremonyId": ceremonyID,
+		"options":    options,
+	})
+}
+
+// FinishRegistration verifies the new credential and saves the passkey.
+func (pc *PasskeyController) FinishRegistration(w http.ResponseWriter, r *http.Request) {
+	user, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || user == nil {
+		utils.ErrorResponse(pc.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return
+	}
+
+	ceremonyID, ok := pc.ceremonyID(w, r)
+	if !ok {
+		return
+	}
+
+	passkey, err := pc.PasskeyStore.FinishRegistration(user, ceremonyID, r)
+	if err != nil {
+		pc.passkeyErrorResponse(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(pc.Logger, w, http.StatusCreated, utils.Map{
+		"success": true,
+		"passkey": passkey,
+	})
+}
+
+// BeginLogin returns the options for navigator.credentials.get().
+func (pc *PasskeyController) BeginLogin(w http.ResponseWriter, r *http.Request) {
+	options, ceremonyID, err := pc.PasskeyStore.BeginLogin()
+	if err != nil {
+		pc.passkeyErrorResponse(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(pc.Logger, w, http.StatusOK, utils.Map{
+		"success":    true,
+		"ceremonyId": ceremonyID,
+		"options":    options,
+	})
+}
+
+// FinishLogin verifies the assertion and signs in the passkey's user.
+func (pc *PasskeyController) FinishLogin(w http.ResponseWriter, r *http.Request) {
+	ceremonyID, ok := pc.ceremonyID(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := pc.PasskeyStore.FinishLogin(ceremonyID, r)
+	if err != nil {
+		pc.passkeyErrorResponse(w, err)
+		return
+	}
+
+	if err := user.CanLogin(); err != nil {
+		utils.ErrorResponse(pc.Logger, w, http.StatusForbidden, []byte(err.Error()))
+		return
+	}
+
+	SetLoginTokenAndSendResponse(pc.Logger, r, w, user, false, pc.UserStore)
+}
+
+func (pc *PasskeyController) ListPasskeys(w http.ResponseWriter, r *http.Request) {
+	user, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || user == nil {
+		utils.ErrorResponse(pc.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return
+	}
+
+	passkeys, err := pc.PasskeyStore.List(user.ID)
+	if err != nil {
+		pc.passkeyErrorResponse(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(pc.Logger, w, http.StatusOK, utils.Map{
+		"success":  true,
+		"passkeys": passkeys,
+	})
+}
+
+func (pc *PasskeyController) UpdatePasskey(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.PasskeyUpdateDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(pc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	user, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || user == nil {
+		utils.ErrorResponse(pc.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return
+	}
+
+	id, err := utils.StringToUID(r, "id")
+	if err != nil {
+		utils.ErrorResponse(pc.Logger, w, http.StatusBadRequest, []byte("Invalid passkey ID"))
+		return
+	}
+
+	passkey, err := pc.PasskeyStore.Rename(user.ID, id, body.Nickname)
+	if err != nil {
+		pc.passkeyErrorResponse(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(pc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"passkey": passkey,
+	})
+}
+
+func (pc *PasskeyController) DeletePasskey(w http.ResponseWriter, r *http.Request) {
+	user, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || user == nil {
+		utils.ErrorResponse(pc.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return
+	}
+
+	id, err := utils.StringToUID(r, "id")
+	if err != nil {
+		utils.ErrorResponse(pc.Logger, w, http.StatusBadRequest, []byte("Invalid passkey ID"))
+		return
+	}
+
+	if err := pc.PasskeyStore.Delete(user.ID, id); err != nil {
+		pc.passkeyErrorResponse(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(pc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+	})
+}
@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/models"
+	"github.com/21TechLabs/factory-backend/utils"
+	"gorm.io/gorm"
+)
+
+type PhoneController struct {
+	Logger        *log.Logger
+	PhoneOTPStore *models.PhoneOTPStore
+	UserStore     *models.UserStore
+}
+
+func NewPhoneController(logger *log.Logger, otpStore *models.PhoneOTPStore, userStore *models.UserStore) *PhoneController {
+	return &PhoneController{
+		Logger:        logger,
+		PhoneOTPStore: otpStore,
+		UserStore:     userStore,
+	}
+}
+
+func (pc *PhoneController) otpErrorResponse(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, utils.ErrInvalidPhoneNumber), errors.Is(err, utils.ErrOTPInvalid):
+		utils.ErrorResponse(pc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+	case errors.Is(err, utils.ErrOTPRateLimited), errors.Is(err, utils.ErrOTPTooManyAttempts):
+		utils.ErrorResponse(pc.Logger, w, http.StatusTooManyRequests, []byte(err.Error()))
+	case errors.Is(err, utils.ErrPhoneNumberInUse):
+		utils.ErrorResponse(pc.Logger, w, http.StatusConflict, []byte(err.Error()))
+	case errors.Is(err, utils.ErrSSORequired):
+		utils.ErrorResponse(pc.Logger, w, http.StatusForbidden, []byte(err.Error()))
+	default:
+		pc.Logger.Printf("Phone OTP Error: %v\n", err)
+		utils.ErrorResponse(pc.Logger, w, http.StatusInternalServerError, []byte("Something went wrong"))
+	}
+}
+
+// SendVerificationCode texts a code that adds the number to the current user.
+func (pc *PhoneController) SendVerificationCode(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.OTPCreateDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(pc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	user, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || user == nil {
+		utils.ErrorResponse(pc.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return
+	}
+
+	phone, err := utils.NormalizeE164(body.CountryCode, body.PhoneNumber)
+	if err != nil {
+		pc.otpErrorResponse(w, err)
+		return
+	}
+
+	owner, err := pc.UserStore.UserGetByPhone(phone)
+	if err == nil && owner.ID != user.ID {
+		pc.otpErrorResponse(w, utils.ErrPhoneNumberInUse)
+		return
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		pc.otpErrorResponse(w, err)
+		return
+	}
+
+	if err := pc.PhoneOTPStore.Send(r.Context(), phone, utils.OTPPurposeVerifyPhone, &user.ID); err != nil {
+		pc.otpErrorResponse(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(pc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"message": "Verification code sent",
+		"phone":   phone,
+	})
+}
+
+// VerifyPhone checks the code sent by SendVerificationCode and saves the number.
+func (pc *PhoneController) VerifyPhone(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.OTPVerifyDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(pc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	user, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || user == nil {
+		utils.ErrorResponse(pc.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return
+	}
+
+	phone, err := utils.NormalizeE164(body.CountryCode, body.PhoneNumber)
+	if err != nil {
+		pc.otpErrorResponse(w, err)
+		return
+	}
+
+	otp, err := pc.PhoneOTPStore.Verify(phone, utils.OTPPurposeVerifyPhone, body.Code)
+	if err != nil {
+		pc.otpErrorResponse(w, err)
+		return
+	}
+	if otp.UserID == nil || *otp.UserID != user.ID {
+		pc.otpErrorResponse(w, utils.ErrOTPInvalid)
+		return
+	}
+
+	if err := pc.UserStore.SetVerifiedPhone(user, phone); err != nil {
+		pc.otpErrorResponse(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(pc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"user":    pc.UserStore.GetDetails(user, false),
+	})
+}
+
+// SendLoginCode texts a login code to a verified number. The response is the
+// same whether or not the number belongs to an account.
+func (pc *PhoneController) SendLoginCode(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.OTPCreateDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(pc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	phone, err := utils.NormalizeE164(body.CountryCode, body.PhoneNumber)
+	if err != nil {
+		pc.otpErrorResponse(w, err)
+		return
+	}
+
+	user, err := pc.UserStore.UserGetByPhone(phone)
+	switch {
+	case err == nil && user.CanLogin() == nil:
+		// accounts that must use SSO get no code, without telling the caller
+		if err := pc.UserStore.CheckSSOSignIn(user.Email); err != nil {
+			pc.Logger.Printf("SendLoginCode Error: %v\n", err)
+			break
+		}
+		if err := pc.PhoneOTPStore.Send(r.Context(), phone, utils.OTPPurposeLogin, nil); err != nil {
+			// not reported to the caller, that would reveal the number is registered
+			pc.Logger.Printf("SendLoginCode Error: %v\n", err)
+		}
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		pc.otpErrorResponse(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(pc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"message": "If the number belongs to an account, a login code has been sent",
+	})
+}
+
+// PhoneLogin signs the user in with a code sent by SendLoginCode.
+func (pc *PhoneController) PhoneLogin(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.OTPVerifyDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(pc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	phone, err := utils.NormalizeE164(body.CountryCode, body.PhoneNumber)
+	if err != nil {
+		pc.otpErrorResponse(w, err)
+		return
+	}
+
+	if _, err := pc.PhoneOTPStore.Verify(phone, utils.OTPPurposeLogin, body.Code); err != nil {
+		pc.otpErrorResponse(w, err)
+		return
+	}
+
+	user, err := pc.UserStore.UserGetByPhone(phone)
+	if err != nil {
+		pc.otpErrorResponse(w, utils.ErrOTPInvalid)
+		return
+	}
+
+	if err := pc.UserStore.CheckSSOSignIn(user.Email); err != nil {
+		pc.otpErrorResponse(w, err)
+		return
+	}
+
+	if err := user.CanLogin(); err != nil {
+		utils.ErrorResponse(pc.Logger, w, http.StatusForbidden, []byte(err.Error()))
+		return
+	}
+
+	SetLoginTokenAndSendResponse(pc.Logger, r, w, user, false, pc.UserStore)
+}
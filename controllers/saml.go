@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/models"
+	"github.com/21TechLabs/factory-backend/utils"
+)
+
+// samlRelayStateCookie binds an SSO sign-in to the browser that started it.
+const samlRelayStateCookie = "saml_relay_state"
+
+type SAMLController struct {
+	Logger              *log.Logger
+	SAMLConnectionStore *models.SAMLConnectionStore
+	UserStore           *models.UserStore
+}
+
+func NewSAMLController(logger *log.Logger, samlConnectionStore *models.SAMLConnectionStore, userStore *models.UserStore) *SAMLController {
+	return &SAMLController{
+		Logger:              logger,
+		SAMLConnectionStore: samlConnectionStore,
+		UserStore:           userStore,
+	}
+}
+
+func (sc *SAMLController) samlErrorResponse(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, utils.ErrSAMLConnectionNotFound), errors.Is(err, utils.ErrOrganizationDomainNotFound):
+		utils.ErrorResponse(sc.Logger, w, http.StatusNotFound, []byte(err.Error()))
+	case errors.Is(err, utils.ErrSAMLAssertionInvalid):
+		sc.Logger.Printf("SAML Error: %v\n", err)
+		utils.ErrorResponse(sc.Logger, w, http.StatusBadRequest, []byte(utils.ErrSAMLAssertionInvalid.Error()))
+	case errors.Is(err, utils.ErrSAMLRequestInvalid), errors.Is(err, utils.ErrSAMLEmailMissing):
+		utils.ErrorResponse(sc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+	case errors.Is(err, utils.ErrSAMLEmailDomainMismatch):
+		utils.ErrorResponse(sc.Logger, w, http.StatusForbidden, []byte(err.Error()))
+	case errors.Is(err, utils.ErrSAMLEmailInUse), errors.Is(err, utils.ErrIdentityLinkedElsewhere),
+		errors.Is(err, utils.ErrIdentityProviderLinked):
+		utils.ErrorResponse(sc.Logger, w, http.StatusConflict, []byte(err.Error()))
+	default:
+		sc.Logger.Printf("SAML Error: %v\n", err)
+		utils.ErrorResponse(sc.Logger, w, http.StatusInternalServerError, []byte("Something went wrong"))
+	}
+}
+
+// Discover tells the login page whether an email signs in through SSO and
+// where to send the user.
+func (sc *SAMLController) Discover(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.SSODiscoverDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(sc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	loginURL, enforced, err := sc.SAMLConnectionStore.SSOLoginURL(body.Email)
+	if err != nil {
+		sc.samlErrorResponse(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(sc.Logger, w, http.StatusOK, utils.Map{
+		"success":  true,
+		"loginUrl": loginURL,
+		"required": enforced,
+	})
+}
+
+// Metadata serves the service provider metadata to register at the IdP.
+func (sc *SAMLController) Metadata(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.StringToUID(r, "id")
+	if err != nil {
+		utils.ErrorResponse(sc.Logger, w, http.StatusBadRequest, []byte("Invalid organization ID"))
+		return
+	}
+
+	metadata, err := sc.SAMLConnectionStore.Metadata(id)
+	if err != nil {
+		sc.samlErrorResponse(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(metadata); err != nil {
+		sc.Logger.Printf("SAML Metadata Error: %v\n", err)
+	}
+}
+
+// Login redirects to the organization's IdP.
+func (sc *SAMLController) Login(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.StringToUID(r, "id")
+	if err != nil {
+		utils.ErrorResponse(sc.Logger, w, http.StatusBadRequest, []byte("Invalid organization ID"))
+		return
+	}
+
+	redirect, relayState, err := sc.SAMLConnectionStore.BeginLogin(id)
+	if err != nil {
+		sc.samlErrorResponse(w, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     samlRelayStateCookie,
+		Value:    relayState,
+		Path:     "/sso/saml/",
+		MaxAge:   int((10 * time.Minute).Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		// the IdP answers with a cross-site form POST, which Lax cookies miss
+		SameSite: http.SameSiteNoneMode,
+	})
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// ACS is the assertion consumer service the IdP posts its response to. A
+// valid response signs the user in, creating their account on first use.
+func (sc *SAMLController) ACS(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.StringToUID(r, "id")
+	if err != nil {
+		utils.ErrorResponse(sc.Logger, w, http.StatusBadRequest, []byte("Invalid organization ID"))
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	if err := r.ParseForm(); err != nil {
+		utils.ErrorResponse(sc.Logger, w, http.StatusBadRequest, []byte("Failed to parse form"))
+		return
+	}
+
+	relayState := r.PostForm.Get("RelayState")
+	cookie, err := r.Cookie(samlRelayStateCookie)
+	if relayState == "" || err != nil || cookie.Value != relayState {
+		sc.samlErrorResponse(w, utils.ErrSAMLRequestInvalid)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     samlRelayStateCookie,
+		Value:    "",
+		Path:     "/sso/saml/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+	})
+
+	user, err := sc.SAMLConnectionStore.FinishLogin(id, r.PostForm.Get("SAMLResponse"), relayState)
+	if err != nil {
+		sc.samlErrorResponse(w, err)
+		return
+	}
+
+	if err := user.CanLogin(); err != nil {
+		utils.ErrorResponse(sc.Logger, w, http.StatusForbidden, []byte(err.Error()))
+		return
+	}
+
+	SetLoginTokenAndSendResponse(sc.Logger, r, w, user, false, sc.UserStore)
+}
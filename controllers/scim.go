@@ -0,0 +1,364 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/21TechLabs/factory-backend/models"
+	"github.com/21TechLabs/factory-backend/scim"
+	"github.com/21TechLabs/factory-backend/utils"
+	"github.com/google/uuid"
+)
+
+// SCIMController serves the SCIM 2.0 endpoints an organization's identity
+// provider uses to provision its users and groups. Requests and responses
+// use the SCIM formats rather than this API's, as IdPs expect them.
+type SCIMController struct {
+	Logger    *log.Logger
+	SCIMStore *models.SCIMStore
+}
+
+func NewSCIMController(logger *log.Logger, scimStore *models.SCIMStore) *SCIMController {
+	return &SCIMController{
+		Logger:    logger,
+		SCIMStore: scimStore,
+	}
+}
+
+func (sc *SCIMController) scimErrorResponse(w http.ResponseWriter, err error) {
+	var scimErr *scim.Error
+	switch {
+	case errors.As(err, &scimErr):
+		sc.writeError(w, scimErr)
+	case errors.Is(err, utils.ErrOrganizationNotFound):
+		sc.writeError(w, scim.ErrNotFound(err.Error()))
+	default:
+		sc.Logger.Printf("SCIM Error: %v\n", err)
+		sc.writeError(w, scim.NewError(http.StatusInternalServerError, "", "Something went wrong"))
+	}
+}
+
+func (sc *SCIMController) writeError(w http.ResponseWriter, err *scim.Error) {
+	if writeErr := scim.WriteError(w, err); writeErr != nil {
+		sc.Logger.Printf("SCIM Response Error: %v\n", writeErr)
+	}
+}
+
+func (sc *SCIMController) write(w http.ResponseWriter, status int, v interface{}) {
+	if err := scim.WriteJSON(w, status, v); err != nil {
+		sc.Logger.Printf("SCIM Response Error: %v\n", err)
+	}
+}
+
+// organizationID returns the organization authenticated by
+// SCIMAuthMiddleware.
+func (sc *SCIMController) organizationID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	orgID, err := utils.ReadContextValue[uuid.UUID](r, utils.SCIMOrganizationContextKey)
+	if err != nil {
+		sc.scimErrorResponse(w, err)
+		return uuid.Nil, false
+	}
+	return orgID, true
+}
+
+// decode reads a JSON body. The schema validator middleware is not used as
+// SCIM clients send application/scim+json.
+func (sc *SCIMController) decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		var scimErr *scim.Error
+		if errors.As(err, &scimErr) {
+			sc.writeError(w, scimErr)
+		} else {
+			sc.writeError(w, scim.ErrInvalidSyntax("invalid JSON body"))
+		}
+		return false
+	}
+	return true
+}
+
+func (sc *SCIMController) decodePatch(w http.ResponseWriter, r *http.Request) (*scim.PatchRequest, bool) {
+	var req scim.PatchRequest
+	if !sc.decode(w, r, &req) {
+		return nil, false
+	}
+	if err := req.Validate(); err != nil {
+		sc.writeError(w, err)
+		return nil, false
+	}
+	return &req, true
+}
+
+// parseFilter returns the filter query parameter, or nil without one.
+func (sc *SCIMController) parseFilter(w http.ResponseWriter, r *http.Request) (scim.Filter, bool) {
+	raw := r.URL.Query().Get("filter")
+	if raw == "" {
+		return nil, true
+	}
+	filter, err := scim.ParseFilter(raw)
+	if err != nil {
+		sc.writeError(w, err)
+		return nil, false
+	}
+	return filter, true
+}
+
+// wantsMembers reports whether the response should list group members,
+// which IdPs leave out with excludedAttributes=members on large groups.
+func wantsMembers(r *http.Request) bool {
+	query := r.URL.Query()
+	split := func(key string) []string {
+		var attrs []string
+		for _, attr := range strings.Split(query.Get(key), ",") {
+			if attr = strings.TrimSpace(attr); attr != "" {
+				attrs = append(attrs, scim.NormalizePath(attr))
+			}
+		}
+		return attrs
+	}
+
+	if slices.Contains(split("excludedAttributes"), "members") {
+		return false
+	}
+	if attrs := split("attributes"); len(attrs) > 0 {
+		return slices.ContainsFunc(attrs, func(attr string) bool {
+			return attr == "members" || strings.HasPrefix(attr, "members.")
+		})
+	}
+	return true
+}
+
+func (sc *SCIMController) ServiceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	sc.write(w, http.StatusOK, scim.ServiceProviderConfig(models.SCIMURL("ServiceProviderConfig")))
+}
+
+func (sc *SCIMController) ResourceTypes(w http.ResponseWriter, r *http.Request) {
+	resourceTypes := scim.ResourceTypes(models.SCIMURL())
+	sc.write(w, http.StatusOK, scim.NewListResponse(resourceTypes, int64(len(resourceTypes)), 1, len(resourceTypes)))
+}
+
+func (sc *SCIMController) ListUsers(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := sc.organizationID(w, r)
+	if !ok {
+		return
+	}
+	filter, ok := sc.parseFilter(w, r)
+	if !ok {
+		return
+	}
+	page, scimErr := scim.ParsePage(r)
+	if scimErr != nil {
+		sc.writeError(w, scimErr)
+		return
+	}
+
+	users, total, err := sc.SCIMStore.ListUsers(orgID, filter, page)
+	if err != nil {
+		sc.scimErrorResponse(w, err)
+		return
+	}
+
+	sc.write(w, http.StatusOK, scim.NewListResponse(users, total, page.StartIndex, len(users)))
+}
+
+func (sc *SCIMController) GetUser(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := sc.organizationID(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := sc.SCIMStore.GetUser(orgID, r.PathValue("id"))
+	if err != nil {
+		sc.scimErrorResponse(w, err)
+		return
+	}
+
+	sc.write(w, http.StatusOK, user)
+}
+
+func (sc *SCIMController) CreateUser(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := sc.organizationID(w, r)
+	if !ok {
+		return
+	}
+	var body scim.User
+	if !sc.decode(w, r, &body) {
+		return
+	}
+
+	user, err := sc.SCIMStore.CreateUser(orgID, &body)
+	if err != nil {
+		sc.scimErrorResponse(w, err)
+		return
+	}
+
+	w.Header().Set("Location", user.Meta.Location)
+	sc.write(w, http.StatusCreated, user)
+}
+
+func (sc *SCIMController) ReplaceUser(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := sc.organizationID(w, r)
+	if !ok {
+		return
+	}
+	var body scim.User
+	if !sc.decode(w, r, &body) {
+		return
+	}
+
+	user, err := sc.SCIMStore.ReplaceUser(orgID, r.PathValue("id"), &body)
+	if err != nil {
+		sc.scimErrorResponse(w, err)
+		return
+	}
+
+	sc.write(w, http.StatusOK, user)
+}
+
+func (sc *SCIMController) PatchUser(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := sc.organizationID(w, r)
+	if !ok {
+		return
+	}
+	req, ok := sc.decodePatch(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := sc.SCIMStore.PatchUser(orgID, r.PathValue("id"), req)
+	if err != nil {
+		sc.scimErrorResponse(w, err)
+		return
+	}
+
+	sc.write(w, http.StatusOK, user)
+}
+
+func (sc *SCIMController) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := sc.organizationID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := sc.SCIMStore.DeleteUser(orgID, r.PathValue("id")); err != nil {
+		sc.scimErrorResponse(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (sc *SCIMController) ListGroups(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := sc.organizationID(w, r)
+	if !ok {
+		return
+	}
+	filter, ok := sc.parseFilter(w, r)
+	if !ok {
+		return
+	}
+	page, scimErr := scim.ParsePage(r)
+	if scimErr != nil {
+		sc.writeError(w, scimErr)
+		return
+	}
+
+	groups, total, err := sc.SCIMStore.ListGroups(orgID, filter, page, wantsMembers(r))
+	if err != nil {
+		sc.scimErrorResponse(w, err)
+		return
+	}
+
+	sc.write(w, http.StatusOK, scim.NewListResponse(groups, total, page.StartIndex, len(groups)))
+}
+
+func (sc *SCIMController) GetGroup(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := sc.organizationID(w, r)
+	if !ok {
+		return
+	}
+
+	group, err := sc.SCIMStore.GetGroup(orgID, r.PathValue("id"), wantsMembers(r))
+	if err != nil {
+		sc.scimErrorResponse(w, err)
+		return
+	}
+
+	sc.write(w, http.StatusOK, group)
+}
+
+func (sc *SCIMController) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := sc.organizationID(w, r)
+	if !ok {
+		return
+	}
+	var body scim.Group
+	if !sc.decode(w, r, &body) {
+		return
+	}
+
+	group, err := sc.SCIMStore.CreateGroup(orgID, &body)
+	if err != nil {
+		sc.scimErrorResponse(w, err)
+		return
+	}
+
+	w.Header().Set("Location", group.Meta.Location)
+	sc.write(w, http.StatusCreated, group)
+}
+
+func (sc *SCIMController) ReplaceGroup(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := sc.organizationID(w, r)
+	if !ok {
+		return
+	}
+	var body scim.Group
+	if !sc.decode(w, r, &body) {
+		return
+	}
+
+	group, err := sc.SCIMStore.ReplaceGroup(orgID, r.PathValue("id"), &body, wantsMembers(r))
+	if err != nil {
+		sc.scimErrorResponse(w, err)
+		return
+	}
+
+	sc.write(w, http.StatusOK, group)
+}
+
+func (sc *SCIMController) PatchGroup(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := sc.organizationID(w, r)
+	if !ok {
+		return
+	}
+	req, ok := sc.decodePatch(w, r)
+	if !ok {
+		return
+	}
+
+	group, err := sc.SCIMStore.PatchGroup(orgID, r.PathValue("id"), req, wantsMembers(r))
+	if err != nil {
+		sc.scimErrorResponse(w, err)
+		return
+	}
+
+	sc.write(w, http.StatusOK, group)
+}
+
+func (sc *SCIMController) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := sc.organizationID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := sc.SCIMStore.DeleteGroup(orgID, r.PathValue("id")); err != nil {
+		sc.scimErrorResponse(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,27 +1,47 @@
 package controllers
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/21TechLabs/factory-backend/dto"
 	"github.com/21TechLabs/factory-backend/models"
+	"github.com/21TechLabs/factory-backend/password"
 	"github.com/21TechLabs/factory-backend/utils"
 )
 
 type UserController struct {
-	Logger    *log.Logger
-	UserStore *models.UserStore
+	Logger             *log.Logger
+	UserStore          *models.UserStore
+	LoginThrottleStore *models.LoginThrottleStore
 }
 
-func NewUserController(logger *log.Logger, store *models.UserStore) *UserController {
+func NewUserController(logger *log.Logger, store *models.UserStore, loginThrottleStore *models.LoginThrottleStore) *UserController {
 	return &UserController{
-		Logger:    logger,
-		UserStore: store,
+		Logger:             logger,
+		UserStore:          store,
+		LoginThrottleStore: loginThrottleStore,
 	}
 }
 
+// passwordErrorResponse answers with every password policy rule err broke,
+// or with err itself when it is not a policy error.
+func passwordErrorResponse(logger *log.Logger, w http.ResponseWriter, err error) {
+	var policyErr *password.PolicyError
+	if !errors.As(err, &policyErr) {
+		utils.ErrorResponse(logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	utils.ResponseWithJSON(logger, w, http.StatusBadRequest, utils.Map{
+		"success":    false,
+		"error":      policyErr.Error(),
+		"violations": policyErr.Violations,
+	})
+}
+
 func (uc *UserController) UserCreate(w http.ResponseWriter, r *http.Request) {
 	usr, err := utils.ReadContextValue[*dto.UserCreateDto](r, utils.SchemaValidatorContextKey)
 	if err != nil {
@@ -33,7 +53,7 @@ func (uc *UserController) UserCreate(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		uc.Logger.Printf("UserCreate Error: %v\n", err)
-		utils.ErrorResponse(uc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		passwordErrorResponse(uc.Logger, w, err)
 		return
 	}
 
@@ -56,7 +76,9 @@ func (uc *UserController) UserUpdateDto(w http.ResponseWriter, r *http.Request)
 	}
 
 	currentUser.Name = parsedBody.Name
-	// currentUser.Email = parsedBody.Email
+	if parsedBody.Locale != "" {
+		currentUser.Locale = parsedBody.Locale
+	}
 
 	err = uc.UserStore.Update(currentUser)
 
@@ -82,14 +104,14 @@ func (uc *UserController) UserPasswordUpdate(w http.ResponseWriter, r *http.Requ
 	currentUser, err := uc.UserStore.UserGetByEmail(parsedBody.Email)
 
 	if err != nil {
-		utils.ErrorResponse(uc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		utils.ErrorResponse(uc.Logger, w, http.StatusBadRequest, []byte(utils.ErrOneTimeTokenInvalid.Error()))
 		return
 	}
 
 	err = uc.UserStore.CompareAndUpdatePasswordWithToken(&currentUser, parsedBody.Token, parsedBody.Password)
 
 	if err != nil {
-		utils.ErrorResponse(uc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		passwordErrorResponse(uc.Logger, w, err)
 		return
 	}
 
@@ -102,6 +124,36 @@ func (uc *UserController) UserPasswordUpdate(w http.ResponseWriter, r *http.Requ
 	})
 }
 
+// UserChangePassword changes the signed-in user's password. Every other
+// session is signed out, and this one gets a fresh login token.
+func (uc *UserController) UserChangePassword(w http.ResponseWriter, r *http.Request) {
+	parsedBody, err := utils.ReadContextValue[*dto.UserChangePasswordDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		uc.Logger.Printf("UserChangePassword Error: %v\n", err)
+		utils.ErrorResponse(uc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	currentUser, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || currentUser == nil {
+		utils.ErrorResponse(uc.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return
+	}
+
+	err = uc.UserStore.ChangePassword(currentUser, parsedBody.CurrentPassword, parsedBody.NewPassword)
+	if err != nil {
+		if errors.Is(err, utils.ErrIncorrectPassword) {
+			utils.ErrorResponse(uc.Logger, w, http.StatusUnauthorized, []byte(err.Error()))
+			return
+		}
+		uc.Logger.Printf("UserChangePassword Error: %v\n", err)
+		passwordErrorResponse(uc.Logger, w, err)
+		return
+	}
+
+	SetLoginTokenAndSendResponse(uc.Logger, r, w, *currentUser, false, uc.UserStore)
+}
+
 func (uc *UserController) UserMarkForDeletion(w http.ResponseWriter, r *http.Request) {
 	currentUser, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
 	if err != nil || currentUser == nil {
@@ -139,15 +191,13 @@ func (uc *UserController) UserRequestPasswordResetLink(w http.ResponseWriter, r
 		return
 	}
 
-	token, err := uc.UserStore.GeneratePasswordResetToken(&user, true)
+	_, err = uc.UserStore.GeneratePasswordResetToken(&user, true)
 
 	if err != nil {
 		utils.ErrorResponse(uc.Logger, w, http.StatusInternalServerError, []byte("Failed to generate password reset token!"))
 		return
 	}
 
-	user.PasswordResetToken = token
-
 	utils.ResponseWithJSON(uc.Logger, w, http.StatusOK, utils.Map{
 		"success": true,
 		"message": "Password reset link sent to your email",
@@ -181,6 +231,31 @@ func (uc *UserController) UserVerifyEmailToken(w http.ResponseWriter, r *http.Re
 	SetLoginTokenAndSendResponse(uc.Logger, r, w, user, false, uc.UserStore)
 }
 
+// UserResendVerificationEmail emails the signed-in user a new verification link.
+func (uc *UserController) UserResendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || currentUser == nil {
+		utils.ErrorResponse(uc.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return
+	}
+
+	err = uc.UserStore.ResendVerificationEmail(currentUser)
+	if err != nil {
+		if errors.Is(err, utils.ErrEmailAlreadyVerified) {
+			utils.ErrorResponse(uc.Logger, w, http.StatusConflict, []byte(err.Error()))
+			return
+		}
+		uc.Logger.Printf("UserResendVerificationEmail Error: %v\n", err)
+		utils.ErrorResponse(uc.Logger, w, http.StatusInternalServerError, []byte("Failed to send verification email!"))
+		return
+	}
+
+	utils.ResponseWithJSON(uc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"message": "Verification link sent to your email",
+	})
+}
+
 func (uc *UserController) UserLogin(w http.ResponseWriter, r *http.Request) {
 
 	loginBody, err := utils.ReadContextValue[*dto.UserLoginDto](r, utils.SchemaValidatorContextKey)
@@ -190,11 +265,21 @@ func (uc *UserController) UserLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := uc.UserStore.UserLogin(*loginBody)
+	user, err := uc.LoginThrottleStore.Login(*loginBody, utils.ClientIP(r))
 
 	if err != nil {
 		uc.Logger.Printf("UserLogin Error: %v\n", err)
-		utils.ErrorResponse(uc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		var throttled *utils.LoginThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			loginThrottledResponse(uc.Logger, w, throttled)
+		case errors.Is(err, utils.ErrInvalidCredentials):
+			utils.ErrorResponse(uc.Logger, w, http.StatusBadRequest, []byte(utils.ErrInvalidCredentials.Error()))
+		case errors.Is(err, utils.ErrSSORequired):
+			utils.ErrorResponse(uc.Logger, w, http.StatusForbidden, []byte(err.Error()))
+		default:
+			utils.ErrorResponse(uc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		}
 		return
 	}
 
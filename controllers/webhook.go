@@ -0,0 +1,295 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/models"
+	"github.com/21TechLabs/factory-backend/utils"
+	"gorm.io/gorm"
+)
+
+type WebhookController struct {
+	Logger       *log.Logger
+	WebhookStore *models.WebhookStore
+}
+
+func NewWebhookController(logger *log.Logger, store *models.WebhookStore) *WebhookController {
+	return &WebhookController{
+		Logger:       logger,
+		WebhookStore: store,
+	}
+}
+
+// checkWebhookURL only allows https endpoints. Where they may connect to is
+// checked again on every delivery, see models.WebhookDialContext.
+func checkWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return errors.New("invalid webhook url")
+	}
+	if u.Scheme != "https" {
+		return errors.New("webhook url must use https")
+	}
+	return nil
+}
+
+// endpointFromRequest loads the {id} endpoint the current user may manage,
+// writing the error response itself when it can't.
+func (wc *WebhookController) endpointFromRequest(w http.ResponseWriter, r *http.Request) (*models.User, *models.WebhookEndpoint, bool) {
+	user, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || user == nil {
+		utils.ErrorResponse(wc.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return nil, nil, false
+	}
+
+	id, err := utils.StringToUID(r, "id")
+	if err != nil {
+		utils.ErrorResponse(wc.Logger, w, http.StatusBadRequest, []byte("Invalid webhook ID"))
+		return nil, nil, false
+	}
+
+	endpoint, err := wc.WebhookStore.GetEndpoint(user, id)
+	if err != nil {
+		if errors.Is(err, utils.ErrWebhookNotFound) {
+			utils.ErrorResponse(wc.Logger, w, http.StatusNotFound, []byte(err.Error()))
+			return nil, nil, false
+		}
+		utils.ErrorResponse(wc.Logger, w, http.StatusInternalServerError, []byte(err.Error()))
+		return nil, nil, false
+	}
+	return user, endpoint, true
+}
+
+func (wc *WebhookController) ListEventTypes(w http.ResponseWriter, r *http.Request) {
+	utils.ResponseWithJSON(wc.Logger, w, http.StatusOK, utils.Map{
+		"success":    true,
+		"eventTypes": utils.WebhookEvents(),
+	})
+}
+
+func (wc *WebhookController) CreateEndpoint(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.WebhookEndpointCreateDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(wc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	user, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || user == nil {
+		utils.ErrorResponse(wc.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return
+	}
+
+	if err := checkWebhookURL(body.URL); err != nil {
+		utils.ErrorResponse(wc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	endpoint, err := wc.WebhookStore.CreateEndpoint(user, *body)
+	if err != nil {
+		utils.ErrorResponse(wc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	utils.ResponseWithJSON(wc.Logger, w, http.StatusCreated, utils.Map{
+		"success":  true,
+		"endpoint": endpoint,
+		// only returned here and on rotation
+		"secret": endpoint.Secret,
+	})
+}
+
+func (wc *WebhookController) ListEndpoints(w http.ResponseWriter, r *http.Request) {
+	user, err := utils.ReadContextValue[*models.User](r, utils.UserContextKey)
+	if err != nil || user == nil {
+		utils.ErrorResponse(wc.Logger, w, http.StatusUnauthorized, []byte("User not found"))
+		return
+	}
+
+	endpoints, err := wc.WebhookStore.ListEndpoints(user)
+	if err != nil {
+		utils.ErrorResponse(wc.Logger, w, http.StatusInternalServerError, []byte(err.Error()))
+		return
+	}
+
+	utils.ResponseWithJSON(wc.Logger, w, http.StatusOK, utils.Map{
+		"success":   true,
+		"endpoints": endpoints,
+	})
+}
+
+func (wc *WebhookController) GetEndpoint(w http.ResponseWriter, r *http.Request) {
+	_, endpoint, ok := wc.endpointFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	utils.ResponseWithJSON(wc.Logger, w, http.StatusOK, utils.Map{
+		"success":  true,
+		"endpoint": endpoint,
+	})
+}
+
+func (wc *WebhookController) UpdateEndpoint(w http.ResponseWriter, r *http.Request) {
+	body, err := utils.ReadContextValue[*dto.WebhookEndpointUpdateDto](r, utils.SchemaValidatorContextKey)
+	if err != nil {
+		utils.ErrorResponse(wc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	_, endpoint, ok := wc.endpointFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if body.URL != nil {
+		if err := checkWebhookURL(*body.URL); err != nil {
+			utils.ErrorResponse(wc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+			return
+		}
+	}
+
+	if err := wc.WebhookStore.UpdateEndpoint(endpoint, *body); err != nil {
+		utils.ErrorResponse(wc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	utils.ResponseWithJSON(wc.Logger, w, http.StatusOK, utils.Map{
+		"success":  true,
+		"endpoint": endpoint,
+	})
+}
+
+func (wc *WebhookController) RotateSecret(w http.ResponseWriter, r *http.Request) {
+	_, endpoint, ok := wc.endpointFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := wc.WebhookStore.RotateSecret(endpoint); err != nil {
+		utils.ErrorResponse(wc.Logger, w, http.StatusInternalServerError, []byte(err.Error()))
+		return
+	}
+
+	utils.ResponseWithJSON(wc.Logger, w, http.StatusOK, utils.Map{
+		"success":  true,
+		"endpoint": endpoint,
+		"secret":   endpoint.Secret,
+	})
+}
+
+func (wc *WebhookController) DeleteEndpoint(w http.ResponseWriter, r *http.Request) {
+	_, endpoint, ok := wc.endpointFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := wc.WebhookStore.DeleteEndpoint(endpoint); err != nil {
+		utils.ErrorResponse(wc.Logger, w, http.StatusInternalServerError, []byte(err.Error()))
+		return
+	}
+
+	utils.ResponseWithJSON(wc.Logger, w, http.StatusOK, utils.Map{
+		"success": true,
+		"message": "Webhook endpoint deleted",
+	})
+}
+
+func (wc *WebhookController) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	_, endpoint, ok := wc.endpointFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	filter := &dto.WebhookDeliveryFilterDto{}
+	if err := utils.ParseQueryParams(r, filter); err != nil {
+		utils.ErrorResponse(wc.Logger, w, http.StatusBadRequest, []byte("Invalid query parameters"))
+		return
+	}
+
+	if err := utils.ValidateStruct(filter); err != nil {
+		utils.ErrorResponse(wc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	start, limit, err := utils.ParsePagination(filter.Start, filter.Limit, 50, 200)
+	if err != nil {
+		utils.ErrorResponse(wc.Logger, w, http.StatusBadRequest, []byte(err.Error()))
+		return
+	}
+
+	deliveries, err := wc.WebhookStore.ListDeliveries(endpoint, *filter, start, limit)
+	if err != nil {
+		utils.ErrorResponse(wc.Logger, w, http.StatusInternalServerError, []byte(err.Error()))
+		return
+	}
+
+	utils.ResponseWithJSON(wc.Logger, w, http.StatusOK, utils.Map{
+		"success":    true,
+		"deliveries": deliveries,
+	})
+}
+
+func (wc *WebhookController) GetDelivery(w http.ResponseWriter, r *http.Request) {
+	_, endpoint, ok := wc.endpointFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := utils.StringToUID(r, "deliveryId")
+	if err != nil {
+		utils.ErrorResponse(wc.Logger, w, http.StatusBadRequest, []byte("Invalid delivery ID"))
+		return
+	}
+
+	delivery, err := wc.WebhookStore.GetDelivery(endpoint, deliveryID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(wc.Logger, w, http.StatusNotFound, []byte("delivery not found"))
+			return
+		}
+		utils.ErrorResponse(wc.Logger, w, http.StatusInternalServerError, []byte(err.Error()))
+		return
+	}
+
+	utils.ResponseWithJSON(wc.Logger, w, http.StatusOK, utils.Map{
+		"success":  true,
+		"delivery": delivery,
+	})
+}
+
+func (wc *WebhookController) Redeliver(w http.ResponseWriter, r *http.Request) {
+	_, endpoint, ok := wc.endpointFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := utils.StringToUID(r, "deliveryId")
+	if err != nil {
+		utils.ErrorResponse(wc.Logger, w, http.StatusBadRequest, []byte("Invalid delivery ID"))
+		return
+	}
+
+	delivery, err := wc.WebhookStore.Redeliver(endpoint, deliveryID)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.ErrorResponse(wc.Logger, w, http.StatusNotFound, []byte("delivery not found"))
+		case errors.Is(err, utils.ErrWebhookDisabled):
+			utils.ErrorResponse(wc.Logger, w, http.StatusConflict, []byte(err.Error()))
+		default:
+			utils.ErrorResponse(wc.Logger, w, http.StatusInternalServerError, []byte(err.Error()))
+		}
+		return
+	}
+
+	utils.ResponseWithJSON(wc.Logger, w, http.StatusOK, utils.Map{
+		"success":  true,
+		"message":  "Delivery queued",
+		"delivery": delivery,
+	})
+}
@@ -30,7 +30,10 @@ func Open(dc *DatabaseCredentials) (*gorm.DB, error) {
 
 	db, err := gorm.Open(postgres.New(postgres.Config{
 		DSN: dsn,
-	}), &gorm.Config{})
+	}), &gorm.Config{
+		// report unique violations as gorm.ErrDuplicatedKey
+		TranslateError: true,
+	})
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
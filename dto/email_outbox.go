@@ -0,0 +1,15 @@
+package dto
+
+import (
+	"encoding/json"
+
+	"github.com/21TechLabs/factory-backend/utils"
+	"github.com/google/uuid"
+)
+
+type EmailOutboxFilterDto struct {
+	Status utils.EmailStatus `json:"status" validate:"omitempty,oneof=pending sending sent failed"`
+	UserID *uuid.UUID        `json:"userId" validate:"omitempty"`
+	Start  json.Number       `json:"start" validate:"omitempty"`
+	Limit  json.Number       `json:"limit" validate:"omitempty"`
+}
@@ -0,0 +1,80 @@
+package dto
+
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+// MultipartUploadCreateDto starts a multipart upload of a file of Size bytes.
+type MultipartUploadCreateDto struct {
+	Name        string `json:"name" validate:"required,max=255"`
+	ContentType string `json:"contentType" validate:"required,max=255"`
+	Size        int64  `json:"size" validate:"required,min=1"`
+}
+
+// MultipartUploadPartsDto asks for upload URLs of up to 100 parts at a time.
+type MultipartUploadPartsDto struct {
+	PartNumbers []int `json:"partNumbers" validate:"required,min=1,max=100,dive,min=1"`
+}
+
+// DirectUploadCreateDto asks for a URL to PUT a file of Size bytes straight
+// to storage. ChecksumSHA256 is the base64 encoded SHA-256 of the file.
+type DirectUploadCreateDto struct {
+	Name           string `json:"name" validate:"required,max=255"`
+	ContentType    string `json:"contentType" validate:"required,max=255"`
+	Size           int64  `json:"size" validate:"required,min=1"`
+	ChecksumSHA256 string `json:"checksumSha256" validate:"required,base64,len=44"`
+}
+
+type FileVisibilityDto struct {
+	Visibility string `json:"visibility" validate:"required,oneof=private organization public"`
+}
+
+type FileShareCreateDto struct {
+	UserID uuid.UUID `json:"userId" validate:"required"`
+}
+
+// FileShareLinkCreateDto creates a share link. ExpiresInHours defaults to 24.
+type FileShareLinkCreateDto struct {
+	ExpiresInHours int `json:"expiresInHours" validate:"omitempty,min=1,max=720"`
+}
+
+// FileFilterDto filters a user's files. FolderID is a folder ID or "root";
+// without it files in every folder are listed. Type matches content types by
+// prefix, for instance "image/".
+type FileFilterDto struct {
+	FolderID string      `json:"folderId" validate:"omitempty,uuid|eq=root"`
+	Query    string      `json:"q" validate:"omitempty,max=255"`
+	Type     string      `json:"type" validate:"omitempty,max=255"`
+	Trashed  string      `json:"trashed" validate:"omitempty,oneof=true false"`
+	Sort     string      `json:"sort" validate:"omitempty,oneof=name size createdAt updatedAt"`
+	Order    string      `json:"order" validate:"omitempty,oneof=asc desc"`
+	Start    json.Number `json:"start" validate:"omitempty"`
+	Limit    json.Number `json:"limit" validate:"omitempty"`
+}
+
+type FileRenameDto struct {
+	Name string `json:"name" validate:"required,max=255"`
+}
+
+// FileMoveDto moves a file into a folder, or out of every folder when
+// FolderID is null.
+type FileMoveDto struct {
+	FolderID *uuid.UUID `json:"folderId"`
+}
+
+type FolderCreateDto struct {
+	Name     string     `json:"name" validate:"required,max=255"`
+	ParentID *uuid.UUID `json:"parentId"`
+}
+
+type FolderRenameDto struct {
+	Name string `json:"name" validate:"required,max=255"`
+}
+
+// FolderMoveDto moves a folder into another one, or to the top level when
+// ParentID is null.
+type FolderMoveDto struct {
+	ParentID *uuid.UUID `json:"parentId"`
+}
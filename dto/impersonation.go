@@ -0,0 +1,21 @@
+package dto
+
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+// ImpersonationStartDto starts an impersonation session. Minutes defaults to 15.
+type ImpersonationStartDto struct {
+	UserID  uuid.UUID `json:"userId" validate:"required"`
+	Reason  string    `json:"reason" validate:"required,max=500"`
+	Minutes int       `json:"minutes" validate:"omitempty,min=1,max=60"`
+}
+
+type ImpersonationFilterDto struct {
+	AdminID *uuid.UUID  `json:"adminId" validate:"omitempty"`
+	UserID  *uuid.UUID  `json:"userId" validate:"omitempty"`
+	Start   json.Number `json:"start" validate:"omitempty"`
+	Limit   json.Number `json:"limit" validate:"omitempty"`
+}
@@ -3,33 +3,93 @@ package dto
 type DtoMapKey string
 
 const (
-	DtoMapKeyPaymentPlanCreate            DtoMapKey = "PaymentPlanCreate"
-	DtoMapKeyOTPCreateDto                 DtoMapKey = "OTPCreateDto"
-	DtoMapKeyUserCreateDto                DtoMapKey = "UserCreateDto"
-	DtoMapKeyUserCreateStep1Dto           DtoMapKey = "UserCreateStep1Dto"
-	DtoMapKeyUserUpdateDto                DtoMapKey = "UserUpdateDto"
-	DtoMapKeyUserPasswordUpdateDto        DtoMapKey = "UserPasswordUpdateDto"
-	DtoMapKeyUserLoginDto                 DtoMapKey = "UserLoginDto"
-	DtoMapKeyUserRequestPasswordResetLink DtoMapKey = "UserRequestPasswordResetLink"
-	DtoMapKeyDiscordTokenExchangeResponse DtoMapKey = "DiscordTokenExchangeResponse"
-	DtoMapKeyDiscordGetExchangeTokenBody  DtoMapKey = "DiscordGetExchangeTokenBody"
-	DtoMapKeyDiscordUserLoginBody         DtoMapKey = "DiscordUserLoginBody"
-	DtoMapKeyDiscordUserWeb               DtoMapKey = "DiscordUserWeb"
+	DtoMapKeyPaymentPlanCreate             DtoMapKey = "PaymentPlanCreate"
+	DtoMapKeyOTPCreateDto                  DtoMapKey = "OTPCreateDto"
+	DtoMapKeyOTPVerifyDto                  DtoMapKey = "OTPVerifyDto"
+	DtoMapKeyMagicLinkRequestDto           DtoMapKey = "MagicLinkRequestDto"
+	DtoMapKeyPasskeyRegisterBeginDto       DtoMapKey = "PasskeyRegisterBeginDto"
+	DtoMapKeyPasskeyUpdateDto              DtoMapKey = "PasskeyUpdateDto"
+	DtoMapKeyUserCreateDto                 DtoMapKey = "UserCreateDto"
+	DtoMapKeyUserCreateStep1Dto            DtoMapKey = "UserCreateStep1Dto"
+	DtoMapKeyUserUpdateDto                 DtoMapKey = "UserUpdateDto"
+	DtoMapKeyUserPasswordUpdateDto         DtoMapKey = "UserPasswordUpdateDto"
+	DtoMapKeyUserChangePasswordDto         DtoMapKey = "UserChangePasswordDto"
+	DtoMapKeyUserLoginDto                  DtoMapKey = "UserLoginDto"
+	DtoMapKeyUserRequestPasswordResetLink  DtoMapKey = "UserRequestPasswordResetLink"
+	DtoMapKeyAccountUnlockDto              DtoMapKey = "AccountUnlockDto"
+	DtoMapKeyEmailChangeRequestDto         DtoMapKey = "EmailChangeRequestDto"
+	DtoMapKeyEmailChangeTokenDto           DtoMapKey = "EmailChangeTokenDto"
+	DtoMapKeyImpersonationStartDto         DtoMapKey = "ImpersonationStartDto"
+	DtoMapKeyMultipartUploadCreateDto      DtoMapKey = "MultipartUploadCreateDto"
+	DtoMapKeyMultipartUploadPartsDto       DtoMapKey = "MultipartUploadPartsDto"
+	DtoMapKeyDirectUploadCreateDto         DtoMapKey = "DirectUploadCreateDto"
+	DtoMapKeyFileVisibilityDto             DtoMapKey = "FileVisibilityDto"
+	DtoMapKeyFileShareCreateDto            DtoMapKey = "FileShareCreateDto"
+	DtoMapKeyFileShareLinkCreateDto        DtoMapKey = "FileShareLinkCreateDto"
+	DtoMapKeyFileRenameDto                 DtoMapKey = "FileRenameDto"
+	DtoMapKeyFileMoveDto                   DtoMapKey = "FileMoveDto"
+	DtoMapKeyFolderCreateDto               DtoMapKey = "FolderCreateDto"
+	DtoMapKeyFolderRenameDto               DtoMapKey = "FolderRenameDto"
+	DtoMapKeyFolderMoveDto                 DtoMapKey = "FolderMoveDto"
+	DtoMapKeyDiscordTokenExchangeResponse  DtoMapKey = "DiscordTokenExchangeResponse"
+	DtoMapKeyDiscordGetExchangeTokenBody   DtoMapKey = "DiscordGetExchangeTokenBody"
+	DtoMapKeyDiscordUserLoginBody          DtoMapKey = "DiscordUserLoginBody"
+	DtoMapKeyDiscordUserWeb                DtoMapKey = "DiscordUserWeb"
+	DtoMapKeyNotificationPreferencesUpdate DtoMapKey = "NotificationPreferencesUpdate"
+	DtoMapKeyWebhookEndpointCreate         DtoMapKey = "WebhookEndpointCreate"
+	DtoMapKeyWebhookEndpointUpdate         DtoMapKey = "WebhookEndpointUpdate"
+	DtoMapKeyOrganizationCreate            DtoMapKey = "OrganizationCreate"
+	DtoMapKeyOrganizationUpdate            DtoMapKey = "OrganizationUpdate"
+	DtoMapKeySCIMTokenCreate               DtoMapKey = "SCIMTokenCreate"
+	DtoMapKeyOrganizationDomainCreate      DtoMapKey = "OrganizationDomainCreate"
+	DtoMapKeyOrganizationDomainUpdate      DtoMapKey = "OrganizationDomainUpdate"
+	DtoMapKeySAMLConnectionUpsert          DtoMapKey = "SAMLConnectionUpsert"
+	DtoMapKeySSODiscover                   DtoMapKey = "SSODiscover"
 )
 
 var DTOMap = map[DtoMapKey]func() interface{}{
-	"PaymentPlanCreate":            dtoMapToRef[ProductPlanCreate](),
-	"OTPCreateDto":                 dtoMapToRef[OTPCreateDto](),
-	"UserCreateDto":                dtoMapToRef[UserCreateDto](),
-	"UserCreateStep1Dto":           dtoMapToRef[UserCreateStep1Dto](),
-	"UserUpdateDto":                dtoMapToRef[UserUpdateDto](),
-	"UserPasswordUpdateDto":        dtoMapToRef[UserPasswordUpdateDto](),
-	"UserLoginDto":                 dtoMapToRef[UserLoginDto](),
-	"UserRequestPasswordResetLink": dtoMapToRef[UserRequestPasswordResetLink](),
-	"DiscordTokenExchangeResponse": dtoMapToRef[DiscordTokenExchangeResponse](),
-	"DiscordGetExchangeTokenBody":  dtoMapToRef[DiscordGetExchangeTokenBody](),
-	"DiscordUserLoginBody":         dtoMapToRef[DiscordUserLoginBody](),
-	"DiscordUserWeb":               dtoMapToRef[DiscordUserWeb](),
+	"PaymentPlanCreate":             dtoMapToRef[ProductPlanCreate](),
+	"OTPCreateDto":                  dtoMapToRef[OTPCreateDto](),
+	"OTPVerifyDto":                  dtoMapToRef[OTPVerifyDto](),
+	"MagicLinkRequestDto":           dtoMapToRef[MagicLinkRequestDto](),
+	"PasskeyRegisterBeginDto":       dtoMapToRef[PasskeyRegisterBeginDto](),
+	"PasskeyUpdateDto":              dtoMapToRef[PasskeyUpdateDto](),
+	"UserCreateDto":                 dtoMapToRef[UserCreateDto](),
+	"UserCreateStep1Dto":            dtoMapToRef[UserCreateStep1Dto](),
+	"UserUpdateDto":                 dtoMapToRef[UserUpdateDto](),
+	"UserPasswordUpdateDto":         dtoMapToRef[UserPasswordUpdateDto](),
+	"UserChangePasswordDto":         dtoMapToRef[UserChangePasswordDto](),
+	"UserLoginDto":                  dtoMapToRef[UserLoginDto](),
+	"UserRequestPasswordResetLink":  dtoMapToRef[UserRequestPasswordResetLink](),
+	"AccountUnlockDto":              dtoMapToRef[AccountUnlockDto](),
+	"EmailChangeRequestDto":         dtoMapToRef[EmailChangeRequestDto](),
+	"EmailChangeTokenDto":           dtoMapToRef[EmailChangeTokenDto](),
+	"ImpersonationStartDto":         dtoMapToRef[ImpersonationStartDto](),
+	"MultipartUploadCreateDto":      dtoMapToRef[MultipartUploadCreateDto](),
+	"MultipartUploadPartsDto":       dtoMapToRef[MultipartUploadPartsDto](),
+	"DirectUploadCreateDto":         dtoMapToRef[DirectUploadCreateDto](),
+	"FileVisibilityDto":             dtoMapToRef[FileVisibilityDto](),
+	"FileShareCreateDto":            dtoMapToRef[FileShareCreateDto](),
+	"FileShareLinkCreateDto":        dtoMapToRef[FileShareLinkCreateDto](),
+	"FileRenameDto":                 dtoMapToRef[FileRenameDto](),
+	"FileMoveDto":                   dtoMapToRef[FileMoveDto](),
+	"FolderCreateDto":               dtoMapToRef[FolderCreateDto](),
+	"FolderRenameDto":               dtoMapToRef[FolderRenameDto](),
+	"FolderMoveDto":                 dtoMapToRef[FolderMoveDto](),
+	"DiscordTokenExchangeResponse":  dtoMapToRef[DiscordTokenExchangeResponse](),
+	"DiscordGetExchangeTokenBody":   dtoMapToRef[DiscordGetExchangeTokenBody](),
+	"DiscordUserLoginBody":          dtoMapToRef[DiscordUserLoginBody](),
+	"DiscordUserWeb":                dtoMapToRef[DiscordUserWeb](),
+	"NotificationPreferencesUpdate": dtoMapToRef[NotificationPreferencesUpdateDto](),
+	"WebhookEndpointCreate":         dtoMapToRef[WebhookEndpointCreateDto](),
+	"WebhookEndpointUpdate":         dtoMapToRef[WebhookEndpointUpdateDto](),
+	"OrganizationCreate":            dtoMapToRef[OrganizationCreateDto](),
+	"OrganizationUpdate":            dtoMapToRef[OrganizationUpdateDto](),
+	"SCIMTokenCreate":               dtoMapToRef[SCIMTokenCreateDto](),
+	"OrganizationDomainCreate":      dtoMapToRef[OrganizationDomainCreateDto](),
+	"OrganizationDomainUpdate":      dtoMapToRef[OrganizationDomainUpdateDto](),
+	"SAMLConnectionUpsert":          dtoMapToRef[SAMLConnectionUpsertDto](),
+	"SSODiscover":                   dtoMapToRef[SSODiscoverDto](),
 }
 
 // dtoMapToRef returns a function that produces a pointer to a zero value of T.
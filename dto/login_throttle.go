@@ -0,0 +1,17 @@
+package dto
+
+import (
+	"encoding/json"
+
+	"github.com/21TechLabs/factory-backend/utils"
+)
+
+type AccountUnlockDto struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type LoginThrottleFilterDto struct {
+	Scope utils.LoginThrottleScope `json:"scope" validate:"omitempty,oneof=account ip"`
+	Start json.Number              `json:"start" validate:"omitempty"`
+	Limit json.Number              `json:"limit" validate:"omitempty"`
+}
@@ -0,0 +1,9 @@
+package dto
+
+// NotificationPreferencesUpdateDto updates the optional email categories;
+// omitted categories are left unchanged.
+type NotificationPreferencesUpdateDto struct {
+	Billing        *bool `json:"billing" validate:"omitempty"`
+	ProductUpdates *bool `json:"product_updates" validate:"omitempty"`
+	Marketing      *bool `json:"marketing" validate:"omitempty"`
+}
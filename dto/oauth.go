@@ -36,4 +36,5 @@ type DiscordUserWeb struct {
 	MfaEnabled           bool   `json:"mfa_enabled"`
 	Locale               string `json:"locale"`
 	Email                string `json:"email"`
+	Verified             bool   `json:"verified"`
 }
@@ -0,0 +1,44 @@
+package dto
+
+type OrganizationCreateDto struct {
+	Name string `json:"name" validate:"required,max=255"`
+}
+
+// OrganizationUpdateDto changes the fields that are set.
+type OrganizationUpdateDto struct {
+	Name            *string `json:"name" validate:"omitempty,min=1,max=255"`
+	AllowSCIMAdmins *bool   `json:"allowScimAdmins"`
+	AllowSSOAdmins  *bool   `json:"allowSsoAdmins"`
+}
+
+// SCIMTokenCreateDto describes a new SCIM token, e.g. the IdP using it.
+type SCIMTokenCreateDto struct {
+	Description string `json:"description" validate:"max=255"`
+}
+
+type OrganizationDomainCreateDto struct {
+	Domain     string `json:"domain" validate:"required,fqdn,max=253"`
+	EnforceSSO bool   `json:"enforceSso"`
+}
+
+type OrganizationDomainUpdateDto struct {
+	EnforceSSO bool `json:"enforceSso"`
+}
+
+// SAMLConnectionUpsertDto configures an organization's identity provider.
+// IdPMetadata is the IdP's metadata XML, required when creating the
+// connection and left unchanged when empty afterwards. Empty attribute names
+// fall back to the common email and name claims.
+type SAMLConnectionUpsertDto struct {
+	IdPMetadata     string   `json:"idpMetadata" validate:"omitempty,max=1048576"`
+	SPEntityID      string   `json:"spEntityId" validate:"omitempty,max=1024"`
+	EmailAttribute  string   `json:"emailAttribute" validate:"omitempty,max=255"`
+	NameAttribute   string   `json:"nameAttribute" validate:"omitempty,max=255"`
+	RoleAttribute   string   `json:"roleAttribute" validate:"omitempty,max=255"`
+	AdminRoleValues []string `json:"adminRoleValues" validate:"omitempty,dive,required,max=255"`
+	Enabled         *bool    `json:"enabled"`
+}
+
+type SSODiscoverDto struct {
+	Email string `json:"email" validate:"required,email"`
+}
@@ -4,3 +4,9 @@ type OTPCreateDto struct {
 	CountryCode string `json:"country_code" validate:"required"`
 	PhoneNumber string `json:"phone_number" validate:"required"`
 }
+
+type OTPVerifyDto struct {
+	CountryCode string `json:"country_code" validate:"required"`
+	PhoneNumber string `json:"phone_number" validate:"required"`
+	Code        string `json:"code" validate:"required,len=6,numeric"`
+}
@@ -0,0 +1,9 @@
+package dto
+
+type PasskeyRegisterBeginDto struct {
+	Nickname string `json:"nickname" validate:"omitempty,max=64"`
+}
+
+type PasskeyUpdateDto struct {
+	Nickname string `json:"nickname" validate:"required,max=64"`
+}
@@ -15,6 +15,7 @@ type ProductPlanCreate struct {
 	PlanDuration     time.Duration         `json:"planDuration" validate:"required"`
 	PlanType         utils.PlanType        `json:"planType" validate:"required,oneof=subscription one_time"`
 	Tokens           int64                 `json:"tokens" validate:"required,gte=0"`
+	StorageQuota     int64                 `json:"storageQuota" validate:"gte=0"`
 	IsActive         bool                  `json:"isActive" validate:"required"`
 	Features         utils.StringSlice     `json:"features" validate:"required"`
 	PaymentGatewayID utils.JSONMap[string] `json:"paymentGatewayId" validate:"required"`
@@ -4,7 +4,8 @@ type UserCreateDto struct {
 	Name            string `json:"name" validate:"required"`
 	Email           string `json:"email" validate:"required,email"`
 	Password        string `json:"password" validate:"required"`
-	ConfirmPassword string `json:"confirm_password" validate:"required"`
+	ConfirmPassword string `json:"confirm_password" validate:"required,eqfield=Password"`
+	Locale          string `json:"locale" validate:"omitempty,bcp47_language_tag"`
 }
 
 // Admin DTOs
@@ -13,9 +14,11 @@ type UserCreateStep1Dto struct {
 	Email string `json:"email" validate:"required,email"`
 }
 
+// UserUpdateDto updates the profile. The email is changed through
+// EmailChangeRequestDto instead.
 type UserUpdateDto struct {
-	Name  string `json:"name" validate:"required"`
-	Email string `json:"email" validate:"required,email"`
+	Name   string `json:"name" validate:"required"`
+	Locale string `json:"locale" validate:"omitempty,bcp47_language_tag"`
 }
 
 type UserPasswordUpdateDto struct {
@@ -24,6 +27,14 @@ type UserPasswordUpdateDto struct {
 	Email    string `json:"email" validate:"required,email"`
 }
 
+// UserChangePasswordDto changes the signed-in user's password.
+// CurrentPassword is required for accounts that have one.
+type UserChangePasswordDto struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password" validate:"required"`
+	ConfirmPassword string `json:"confirm_password" validate:"required,eqfield=NewPassword"`
+}
+
 type UserLoginDto struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required"`
@@ -32,3 +43,18 @@ type UserLoginDto struct {
 type UserRequestPasswordResetLink struct {
 	Email string `json:"email" validate:"required,email"`
 }
+
+type MagicLinkRequestDto struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// EmailChangeRequestDto starts an email change. Password is required for
+// accounts that have one.
+type EmailChangeRequestDto struct {
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password"`
+}
+
+type EmailChangeTokenDto struct {
+	Token string `json:"token" validate:"required"`
+}
@@ -0,0 +1,30 @@
+package dto
+
+import (
+	"encoding/json"
+
+	"github.com/21TechLabs/factory-backend/utils"
+)
+
+// WebhookEndpointCreateDto creates an endpoint. An empty EventTypes subscribes
+// to every event; AllUsers (admins only) receives events for every account.
+type WebhookEndpointCreateDto struct {
+	URL         string               `json:"url" validate:"required,url,startswith=https://,max=2048"`
+	Description string               `json:"description" validate:"omitempty,max=255"`
+	EventTypes  []utils.WebhookEvent `json:"eventTypes" validate:"omitempty,dive,required"`
+	AllUsers    bool                 `json:"allUsers"`
+}
+
+type WebhookEndpointUpdateDto struct {
+	URL         *string               `json:"url" validate:"omitempty,url,startswith=https://,max=2048"`
+	Description *string               `json:"description" validate:"omitempty,max=255"`
+	EventTypes  *[]utils.WebhookEvent `json:"eventTypes" validate:"omitempty,dive,required"`
+	Enabled     *bool                 `json:"enabled" validate:"omitempty"`
+}
+
+type WebhookDeliveryFilterDto struct {
+	Status    utils.WebhookDeliveryStatus `json:"status" validate:"omitempty,oneof=pending delivering succeeded failed"`
+	EventType utils.WebhookEvent          `json:"eventType" validate:"omitempty"`
+	Start     json.Number                 `json:"start" validate:"omitempty"`
+	Limit     json.Number                 `json:"limit" validate:"omitempty"`
+}
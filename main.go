@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -34,6 +35,8 @@ func main() {
 
 	router := routes.SetupRoutes(app)
 
+	app.StartWorkers(context.Background())
+
 	corsUrls := strings.Split(utils.GetEnv("CORS_URLS", false), ",")
 	c := cors.New(cors.Options{
 		AllowedOrigins:   corsUrls,
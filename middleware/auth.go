@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/21TechLabs/factory-backend/models"
 	"github.com/21TechLabs/factory-backend/utils"
 )
 
@@ -19,7 +20,7 @@ func (m *Middleware) UserAuthMiddleware(next http.Handler) http.Handler {
 
 		secretKey := []byte(utils.GetEnv("JWT_SECRET_KEY", false))
 
-		user, err := m.UserStore.JwtTokenVerifyAndGetUser(authToken, secretKey)
+		user, impersonationID, err := m.UserStore.JwtTokenVerifyAndGetUser(authToken, secretKey)
 		if err != nil {
 			m.Logger.Println("Error verifying JWT token:", err)
 			utils.ErrorResponse(m.Logger, w, http.StatusUnauthorized, []byte("Unauthorized: "+err.Error()))
@@ -44,7 +45,19 @@ func (m *Middleware) UserAuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		if user.MarkedForDeletion {
+		var session *models.ImpersonationSession
+		var admin *models.User
+		if impersonationID != nil {
+			session, admin, err = m.ImpersonationStore.Verify(*impersonationID, user.ID)
+			if err != nil {
+				m.Logger.Println("Error verifying impersonation session:", err)
+				utils.ErrorResponse(m.Logger, w, http.StatusUnauthorized, []byte("Unauthorized: "+err.Error()))
+				return
+			}
+		}
+
+		// an admin looking into the account must not cancel its deletion
+		if user.MarkedForDeletion && session == nil {
 			user.MarkedForDeletion = false
 			err := m.UserStore.Update(&user)
 			if err != nil {
@@ -58,6 +71,51 @@ func (m *Middleware) UserAuthMiddleware(next http.Handler) http.Handler {
 			context.WithValue(r.Context(), utils.UserContextKey, &user),
 		)
 
+		if session != nil {
+			m.serveImpersonated(w, r, next, session, admin)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// OptionalUserAuthMiddleware adds the signed-in user to the context when the
+// request carries a valid token for an account that can log in, and passes
+// the request on unchanged otherwise.
+func (m *Middleware) OptionalUserAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authToken, err := utils.GetToken(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, impersonationID, err := m.UserStore.JwtTokenVerifyAndGetUser(authToken, []byte(utils.GetEnv("JWT_SECRET_KEY", false)))
+		if err != nil || user.CanLogin() != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var session *models.ImpersonationSession
+		var admin *models.User
+		if impersonationID != nil {
+			session, admin, err = m.ImpersonationStore.Verify(*impersonationID, user.ID)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		r = r.WithContext(
+			context.WithValue(r.Context(), utils.UserContextKey, &user),
+		)
+
+		if session != nil {
+			m.serveImpersonated(w, r, next, session, admin)
+			return
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
@@ -5,19 +5,27 @@ import (
 	"net/http"
 
 	"github.com/21TechLabs/factory-backend/models"
+	"github.com/21TechLabs/factory-backend/ratelimit"
 )
 
 type IMiddleware = func(next http.Handler) http.Handler
 
 type Middleware struct {
-	Logger    *log.Logger
-	UserStore *models.UserStore
+	Logger         *log.Logger
+	UserStore      *models.UserStore
+	SCIMStore      *models.SCIMStore
+	RateLimitStore ratelimit.Store
+	// ImpersonationStore verifies impersonation tokens and records their requests
+	ImpersonationStore *models.ImpersonationStore
 }
 
-func NewMiddleware(logger *log.Logger, userStore *models.UserStore) *Middleware {
+func NewMiddleware(logger *log.Logger, userStore *models.UserStore, scimStore *models.SCIMStore, rateLimitStore ratelimit.Store, impersonationStore *models.ImpersonationStore) *Middleware {
 	return &Middleware{
-		Logger:    logger,
-		UserStore: userStore,
+		Logger:             logger,
+		UserStore:          userStore,
+		SCIMStore:          scimStore,
+		RateLimitStore:     rateLimitStore,
+		ImpersonationStore: impersonationStore,
 	}
 }
 
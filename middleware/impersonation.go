@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/21TechLabs/factory-backend/models"
+	"github.com/21TechLabs/factory-backend/utils"
+)
+
+// statusRecorder remembers the status code a handler responded with.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	sr.status = status
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// serveImpersonated adds the admin and the session to the context of a
+// request made with an impersonation token, serves it and records it in the
+// session's audit trail.
+func (m *Middleware) serveImpersonated(w http.ResponseWriter, r *http.Request, next http.Handler, session *models.ImpersonationSession, admin *models.User) {
+	ctx := context.WithValue(r.Context(), utils.ImpersonatorContextKey, admin)
+	ctx = context.WithValue(ctx, utils.ImpersonationContextKey, session)
+	r = r.WithContext(ctx)
+
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	next.ServeHTTP(recorder, r)
+
+	m.Logger.Printf("Impersonation: admin %s as user %s (session %s): %s %s -> %d",
+		admin.ID, session.UserID, session.ID, r.Method, r.URL.Path, recorder.status)
+	if err := m.ImpersonationStore.LogRequest(session, r.Method, r.URL.Path, recorder.status, utils.ClientIP(r)); err != nil {
+		m.Logger.Printf("Impersonation Error: failed to record request: %v", err)
+	}
+}
+
+// NoImpersonationMiddleware refuses actions only the user may take, such as
+// payments or changing sign-in details, while an admin impersonates them. It
+// must run after UserAuthMiddleware.
+func (m *Middleware) NoImpersonationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, err := utils.ReadContextValue[*models.ImpersonationSession](r, utils.ImpersonationContextKey)
+		if err == nil && session != nil {
+			utils.ErrorResponse(m.Logger, w, http.StatusForbidden, []byte(utils.ErrImpersonationForbidden.Error()))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/21TechLabs/factory-backend/scim"
+	"github.com/21TechLabs/factory-backend/utils"
+)
+
+// SCIMAuthMiddleware authenticates an organization's SCIM client by its
+// bearer token and adds the organization's ID to the context. Errors use
+// the SCIM error format, which IdPs show to their admins.
+func (m *Middleware) SCIMAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="scim"`)
+			scim.WriteError(w, scim.NewError(http.StatusUnauthorized, "", "a bearer token is required"))
+			return
+		}
+
+		orgID, err := m.SCIMStore.Authenticate(strings.TrimSpace(token))
+		if err != nil {
+			if errors.Is(err, utils.ErrSCIMTokenInvalid) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="scim", error="invalid_token"`)
+				scim.WriteError(w, scim.NewError(http.StatusUnauthorized, "", err.Error()))
+				return
+			}
+			m.Logger.Println("Error authenticating SCIM token:", err)
+			scim.WriteError(w, scim.NewError(http.StatusInternalServerError, "", "Something went wrong"))
+			return
+		}
+
+		r = r.WithContext(
+			context.WithValue(r.Context(), utils.SCIMOrganizationContextKey, orgID),
+		)
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,289 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/utils"
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// S3 accepts at most 5 GiB in a single PUT
+	directUploadMaxSize = 5 << 30
+	directUploadURLTTL  = 15 * time.Minute
+	// unconfirmed uploads are deleted by the sweeper after directUploadTTL
+	directUploadTTL       = time.Hour
+	directUploadS3Timeout = 30 * time.Second
+)
+
+type DirectUploadStore struct {
+	DB           *gorm.DB
+	StorageStore *StorageStore
+}
+
+func NewDirectUploadStore(db *gorm.DB, storageStore *StorageStore) *DirectUploadStore {
+	return &DirectUploadStore{DB: db, StorageStore: storageStore}
+}
+
+// DirectUpload is a file the client PUTs straight to S3 through a presigned
+// URL. Its File is only created once the client confirms the upload and the
+// object matches the declared size, content type and checksum.
+type DirectUpload struct {
+	ID             uuid.UUID          `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	UserID         uuid.UUID          `gorm:"column:user_id;type:uuid;index" json:"userId"`
+	Name           string             `gorm:"column:name" json:"name"`
+	ContentType    string             `gorm:"column:content_type" json:"contentType"`
+	Size           int64              `gorm:"column:size" json:"size"`
+	ChecksumSHA256 string             `gorm:"column:checksum_sha256" json:"checksumSha256"`
+	Bucket         string             `gorm:"column:bucket" json:"-"`
+	Key            string             `gorm:"column:key" json:"-"`
+	Status         utils.UploadStatus `gorm:"column:status;index" json:"status"`
+	FileID         *uuid.UUID         `gorm:"column:file_id;type:uuid" json:"fileId"`
+	ExpiresAt      time.Time          `gorm:"column:expires_at" json:"expiresAt"`
+	CreatedAt      time.Time          `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+	UpdatedAt      time.Time          `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt"`
+}
+
+// DirectUploadTarget is where and how the client uploads the file.
+type DirectUploadTarget struct {
+	URL       string            `json:"url"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+}
+
+func (du *DirectUpload) isLive() bool {
+	return du.Status == utils.UploadStatusPending && time.Now().Before(du.ExpiresAt)
+}
+
+// uploadHeaders are the headers signed into the upload URL. S3 rejects a
+// PUT whose body does not match the checksum header.
+func (du *DirectUpload) uploadHeaders() http.Header {
+	headers := http.Header{}
+	headers.Set("Content-Type", du.ContentType)
+	headers.Set("X-Amz-Checksum-Sha256", du.ChecksumSHA256)
+	return headers
+}
+
+// verify checks the stored object against what the client declared.
+func (du *DirectUpload) verify(info minio.ObjectInfo) error {
+	switch {
+	case info.Size != du.Size:
+		return utils.ErrUploadSizeMismatch
+	case info.ContentType != du.ContentType:
+		return utils.ErrUploadTypeMismatch
+	case info.ChecksumSHA256 != du.ChecksumSHA256:
+		return utils.ErrUploadChecksumMismatch
+	}
+	return nil
+}
+
+// Create registers an upload for user and returns the presigned URL to PUT
+// the file to. Its declared size counts against the user's storage quota
+// until it is confirmed or expires.
+func (dus *DirectUploadStore) Create(user *User, body dto.DirectUploadCreateDto) (*DirectUpload, *DirectUploadTarget, error) {
+	if body.Size > directUploadMaxSize {
+		return nil, nil, utils.ErrUploadTooLarge
+	}
+
+	upload := DirectUpload{
+		ID:             uuid.New(),
+		UserID:         user.ID,
+		Name:           body.Name,
+		ContentType:    body.ContentType,
+		Size:           body.Size,
+		ChecksumSHA256: body.ChecksumSHA256,
+		Bucket:         utils.S3BucketName,
+		Status:         utils.UploadStatusPending,
+		ExpiresAt:      time.Now().Add(directUploadTTL),
+	}
+	// the key never contains the client's file name
+	upload.Key = fmt.Sprintf("%s/%s", user.ID, upload.ID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), directUploadS3Timeout)
+	defer cancel()
+
+	headers := upload.uploadHeaders()
+	u, err := utils.S3PresignPutObject(ctx, upload.Bucket, upload.Key, directUploadURLTTL, headers)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = dus.DB.Transaction(func(tx *gorm.DB) error {
+		if err := dus.StorageStore.Reserve(tx, user, upload.Size); err != nil {
+			return err
+		}
+		return tx.Create(&upload).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	target := DirectUploadTarget{
+		URL:       u,
+		Method:    http.MethodPut,
+		Headers:   make(map[string]string, len(headers)),
+		ExpiresAt: time.Now().Add(directUploadURLTTL),
+	}
+	for name := range headers {
+		target.Headers[name] = headers.Get(name)
+	}
+	return &upload, &target, nil
+}
+
+// Get returns the upload with id if it belongs to userID.
+func (dus *DirectUploadStore) Get(userID, id uuid.UUID) (*DirectUpload, error) {
+	var upload DirectUpload
+	err := dus.DB.Where("id = ? AND user_id = ?", id, userID).First(&upload).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrUploadNotFound
+		}
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// Confirm checks the object the client uploaded and creates its File. An
+// object that does not match the declaration is deleted and the upload
+// aborted; a missing object can be uploaded and confirmed again until the
+// upload expires.
+func (dus *DirectUploadStore) Confirm(upload *DirectUpload) (*File, error) {
+	var file File
+	var rejected error
+
+	err := dus.DB.Transaction(func(tx *gorm.DB) error {
+		// the lock keeps concurrent requests from confirming the upload twice
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", upload.ID).First(upload).Error
+		if err != nil {
+			return err
+		}
+		if !upload.isLive() {
+			return utils.ErrUploadNotPending
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), directUploadS3Timeout)
+		defer cancel()
+
+		info, err := utils.S3StatObject(ctx, upload.Bucket, upload.Key)
+		if err != nil {
+			if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+				return utils.ErrUploadNotReceived
+			}
+			return err
+		}
+
+		if rejected = upload.verify(info); rejected != nil {
+			if err := utils.S3RemoveObject(ctx, upload.Bucket, upload.Key); err != nil {
+				return err
+			}
+			upload.Status = utils.UploadStatusAborted
+			// committed so the upload is not confirmed again
+			return tx.Model(upload).Update("status", upload.Status).Error
+		}
+
+		file = File{
+			UserID: upload.UserID,
+			Name:   upload.Name,
+			Type:   upload.ContentType,
+			Etag:   info.ETag,
+			Size:   info.Size,
+			Key:    upload.Key,
+			Bucket: upload.Bucket,
+		}
+		if err := tx.Create(&file).Error; err != nil {
+			return err
+		}
+
+		upload.Status = utils.UploadStatusCompleted
+		upload.FileID = &file.ID
+		return tx.Model(upload).Updates(map[string]interface{}{
+			"status":  upload.Status,
+			"file_id": upload.FileID,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rejected != nil {
+		return nil, rejected
+	}
+	return &file, nil
+}
+
+// expire deletes whatever the client uploaded for an unconfirmed upload and
+// marks it aborted.
+func (dus *DirectUploadStore) expire(upload *DirectUpload) error {
+	return dus.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", upload.ID).First(upload).Error
+		if err != nil {
+			return err
+		}
+		if upload.Status != utils.UploadStatusPending {
+			return utils.ErrUploadNotPending
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), directUploadS3Timeout)
+		defer cancel()
+
+		// removing an object that was never uploaded is not an error
+		if err := utils.S3RemoveObject(ctx, upload.Bucket, upload.Key); err != nil {
+			return err
+		}
+
+		upload.Status = utils.UploadStatusAborted
+		return tx.Model(upload).Update("status", upload.Status).Error
+	})
+}
+
+// DeleteExpired removes up to limit uploads that were not confirmed within
+// directUploadTTL and returns how many were removed.
+func (dus *DirectUploadStore) DeleteExpired(limit int) (int, error) {
+	var uploads []DirectUpload
+	err := dus.DB.Where("status = ? AND expires_at < ?", utils.UploadStatusPending, time.Now()).
+		Order("expires_at").
+		Limit(limit).
+		Find(&uploads).Error
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for i := range uploads {
+		if err := dus.expire(&uploads[i]); err != nil {
+			if errors.Is(err, utils.ErrUploadNotPending) {
+				continue
+			}
+			return deleted, fmt.Errorf("failed to delete upload %s: %w", uploads[i].ID, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// RunWorker deletes unconfirmed uploads every interval until ctx is
+// cancelled.
+func (dus *DirectUploadStore) RunWorker(ctx context.Context, logger *log.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := dus.DeleteExpired(100); err != nil {
+				logger.Printf("DirectUpload worker error: %v", err)
+			}
+		}
+	}
+}
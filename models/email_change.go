@@ -0,0 +1,236 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/notifications/templates"
+	"github.com/21TechLabs/factory-backend/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	emailChangeConfirmTTL = 24 * time.Hour
+	// the old address can cancel or undo the change for emailChangeRevertTTL
+	// after it was requested
+	emailChangeRevertTTL = 7 * 24 * time.Hour
+)
+
+type EmailChangeStore struct {
+	DB        *gorm.DB
+	UserStore *UserStore
+}
+
+func NewEmailChangeStore(db *gorm.DB, userStore *UserStore) *EmailChangeStore {
+	return &EmailChangeStore{DB: db, UserStore: userStore}
+}
+
+// EmailChange is a request to move a user to a new email address. The new
+// address receives a confirm link and the old one a revert link; only
+// SHA-256 hashes of both tokens are stored.
+type EmailChange struct {
+	ID               uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	UserID           uuid.UUID  `gorm:"column:user_id;type:uuid;index" json:"userId"`
+	OldEmail         string     `gorm:"column:old_email" json:"oldEmail"`
+	NewEmail         string     `gorm:"column:new_email" json:"newEmail"`
+	ConfirmTokenHash string     `gorm:"column:confirm_token_hash;uniqueIndex" json:"-"`
+	RevertTokenHash  string     `gorm:"column:revert_token_hash;uniqueIndex" json:"-"`
+	ExpiresAt        time.Time  `gorm:"column:expires_at" json:"expiresAt"`
+	RevertExpiresAt  time.Time  `gorm:"column:revert_expires_at" json:"revertExpiresAt"`
+	ConfirmedAt      *time.Time `gorm:"column:confirmed_at" json:"confirmedAt"`
+	RevertedAt       *time.Time `gorm:"column:reverted_at" json:"revertedAt"`
+	// CancelledAt is set when a newer request replaces this one
+	CancelledAt *time.Time `gorm:"column:cancelled_at" json:"cancelledAt"`
+	CreatedAt   time.Time  `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+}
+
+func hashEmailChangeToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// emailInUse reports whether another account already uses email.
+func emailInUse(tx *gorm.DB, email string, userID uuid.UUID) (bool, error) {
+	var count int64
+	err := tx.Model(&User{}).Where("LOWER(email) = LOWER(?) AND id <> ?", email, userID).Count(&count).Error
+	return count > 0, err
+}
+
+// Request starts moving user to newEmail once they re-enter their password.
+// Accounts without a password rely on the confirm link alone. Pending
+// requests of the user stop working.
+func (ecs *EmailChangeStore) Request(user *User, newEmail, currentPassword string) (*EmailChange, error) {
+	newEmail = strings.TrimSpace(newEmail)
+
+	if user.OrganizationID != nil {
+		return nil, utils.ErrEmailManagedByOrganization
+	}
+	if user.HasPassword() && !ecs.UserStore.ComparePassword(user, currentPassword) {
+		return nil, utils.ErrIncorrectPassword
+	}
+	if strings.EqualFold(newEmail, user.Email) {
+		return nil, utils.ErrEmailUnchanged
+	}
+
+	confirmToken, err := GetAlphaNumString(48, "alphanum")
+	if err != nil {
+		return nil, err
+	}
+	revertToken, err := GetAlphaNumString(48, "alphanum")
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	change := EmailChange{
+		UserID:           user.ID,
+		OldEmail:         user.Email,
+		NewEmail:         newEmail,
+		ConfirmTokenHash: hashEmailChangeToken(confirmToken),
+		RevertTokenHash:  hashEmailChangeToken(revertToken),
+		ExpiresAt:        now.Add(emailChangeConfirmTTL),
+		RevertExpiresAt:  now.Add(emailChangeRevertTTL),
+	}
+
+	err = ecs.DB.Transaction(func(tx *gorm.DB) error {
+		inUse, err := emailInUse(tx, newEmail, user.ID)
+		if err != nil {
+			return err
+		}
+		if inUse {
+			return utils.ErrEmailInUse
+		}
+
+		err = tx.Model(&EmailChange{}).
+			Where("user_id = ? AND confirmed_at IS NULL AND reverted_at IS NULL AND cancelled_at IS NULL", user.ID).
+			Update("cancelled_at", &now).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(&change).Error; err != nil {
+			return err
+		}
+
+		frontendURL := utils.GetEnv("FRONTEND_URL", false)
+		confirm, err := templates.NewRequest([]string{newEmail}, user.Locale, templates.EmailChangeConfirmMessage{
+			Name:           user.Name,
+			NewEmail:       newEmail,
+			Link:           fmt.Sprintf("%s/confirm-email-change?token=%s", frontendURL, url.QueryEscape(confirmToken)),
+			ExpiresInHours: int(emailChangeConfirmTTL.Hours()),
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := ecs.UserStore.EmailOutboxStore.Enqueue(tx, &user.ID, confirm); err != nil {
+			return err
+		}
+
+		notice, err := templates.NewRequest([]string{user.Email}, user.Locale, templates.EmailChangeNoticeMessage{
+			Name:          user.Name,
+			NewEmail:      newEmail,
+			Link:          fmt.Sprintf("%s/revert-email-change?token=%s", frontendURL, url.QueryEscape(revertToken)),
+			ExpiresInDays: int(emailChangeRevertTTL.Hours() / 24),
+		})
+		if err != nil {
+			return err
+		}
+		_, err = ecs.UserStore.EmailOutboxStore.Enqueue(tx, &user.ID, notice)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &change, nil
+}
+
+// setEmail moves the user from one address to another. The update only
+// applies while the user still has from, and the unique index on
+// users.email settles races with signups and other changes to the same
+// address.
+func (ecs *EmailChangeStore) setEmail(tx *gorm.DB, userID uuid.UUID, from, to string) (User, error) {
+	result := tx.Model(&User{}).
+		Where("id = ? AND email = ?", userID, from).
+		Updates(map[string]interface{}{"email": to, "email_verified": true})
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+			return User{}, utils.ErrEmailInUse
+		}
+		return User{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return User{}, utils.ErrEmailChangeInvalid
+	}
+
+	var user User
+	if err := tx.Where("id = ?", userID).First(&user).Error; err != nil {
+		return User{}, err
+	}
+	return user, ecs.UserStore.WebhookStore.Emit(tx, user.ID, utils.WebhookEventUserEmailChanged, user.WebhookData())
+}
+
+// Confirm switches the user to the new address with the token sent there.
+func (ecs *EmailChangeStore) Confirm(token string) (User, error) {
+	var user User
+
+	err := ecs.DB.Transaction(func(tx *gorm.DB) error {
+		var change EmailChange
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("confirm_token_hash = ? AND confirmed_at IS NULL AND reverted_at IS NULL AND cancelled_at IS NULL AND expires_at > ?",
+				hashEmailChangeToken(token), time.Now()).
+			First(&change).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return utils.ErrEmailChangeInvalid
+			}
+			return err
+		}
+
+		if user, err = ecs.setEmail(tx, change.UserID, change.OldEmail, change.NewEmail); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		return tx.Model(&EmailChange{}).Where("id = ?", change.ID).Update("confirmed_at", &now).Error
+	})
+
+	return user, err
+}
+
+// Revert cancels a pending change, or moves the user back to the old
+// address and signs out every session if the change was already confirmed,
+// with the token sent to the old address.
+func (ecs *EmailChangeStore) Revert(token string) error {
+	return ecs.DB.Transaction(func(tx *gorm.DB) error {
+		var change EmailChange
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("revert_token_hash = ? AND reverted_at IS NULL AND revert_expires_at > ?", hashEmailChangeToken(token), time.Now()).
+			First(&change).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return utils.ErrEmailChangeInvalid
+			}
+			return err
+		}
+
+		now := time.Now()
+		if change.ConfirmedAt != nil {
+			if _, err := ecs.setEmail(tx, change.UserID, change.NewEmail, change.OldEmail); err != nil {
+				return err
+			}
+			// whoever changed the address may still be signed in
+			err := tx.Model(&User{}).Where("id = ?", change.UserID).Update("sessions_revoked_at", &now).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&EmailChange{}).Where("id = ?", change.ID).Update("reverted_at", &now).Error
+	})
+}
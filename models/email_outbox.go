@@ -0,0 +1,309 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/notifications"
+	"github.com/21TechLabs/factory-backend/notifications/message"
+	"github.com/21TechLabs/factory-backend/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	emailOutboxMaxAttempts = 8
+	emailOutboxBatchSize   = 20
+	// emailOutboxLease is how long a worker owns a claimed message. A message
+	// stuck in "sending" past its lease (e.g. the process crashed) is claimed again.
+	emailOutboxLease = 5 * time.Minute
+	// emailOutboxSendTimeout bounds a single delivery attempt, well within the lease.
+	emailOutboxSendTimeout = time.Minute
+)
+
+type EmailOutboxStore struct {
+	DB          *gorm.DB
+	Sender      notifications.EmailSender
+	Preferences *NotificationPreferenceStore
+}
+
+func NewEmailOutboxStore(db *gorm.DB, sender notifications.EmailSender, preferences *NotificationPreferenceStore) *EmailOutboxStore {
+	return &EmailOutboxStore{DB: db, Sender: sender, Preferences: preferences}
+}
+
+type EmailOutbox struct {
+	ID                uuid.UUID                  `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	UserID            *uuid.UUID                 `gorm:"column:user_id;type:uuid;index" json:"userId"`
+	Category          utils.NotificationCategory `gorm:"column:category" json:"category"`
+	To                utils.StringSlice          `gorm:"type:json;column:to_addresses" json:"to"`
+	ReplyTo           utils.StringSlice          `gorm:"type:json;column:reply_to" json:"replyTo"`
+	Subject           string                     `gorm:"column:subject" json:"subject"`
+	HTMLBody          string                     `gorm:"column:body" json:"htmlBody"`
+	TextBody          string                     `gorm:"column:text_body" json:"textBody"`
+	Headers           utils.JSONMap[string]      `gorm:"type:json;column:headers" json:"headers"`
+	Attachments       []message.Attachment       `gorm:"column:attachments;type:jsonb;serializer:json" json:"-"`
+	Template          string                     `gorm:"column:template" json:"template"`
+	TemplateVersion   int                        `gorm:"column:template_version" json:"templateVersion"`
+	Locale            string                     `gorm:"column:locale" json:"locale"`
+	Status            utils.EmailStatus          `gorm:"column:status;index" json:"status"`
+	Attempts          int                        `gorm:"column:attempts" json:"attempts"`
+	MaxAttempts       int                        `gorm:"column:max_attempts" json:"maxAttempts"`
+	LastError         string                     `gorm:"column:last_error" json:"lastError"`
+	ProviderMessageID string                     `gorm:"column:provider_message_id" json:"providerMessageId"`
+	NextAttemptAt     time.Time                  `gorm:"column:next_attempt_at;index" json:"nextAttemptAt"`
+	SentAt            *time.Time                 `gorm:"column:sent_at" json:"sentAt"`
+	ResentFromID      *uuid.UUID                 `gorm:"column:resent_from_id;type:uuid" json:"resentFromId"`
+	CreatedAt         time.Time                  `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+	UpdatedAt         time.Time                  `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt"`
+}
+
+func (EmailOutbox) TableName() string {
+	return "email_outbox"
+}
+
+// Request rebuilds the notifications.Request the message was queued from.
+func (msg *EmailOutbox) Request() *notifications.Request {
+	return &notifications.Request{
+		Category:        msg.Category,
+		To:              msg.To,
+		Subject:         msg.Subject,
+		HTML:            msg.HTMLBody,
+		Text:            msg.TextBody,
+		ReplyTo:         msg.ReplyTo,
+		Headers:         msg.Headers,
+		Attachments:     msg.Attachments,
+		Template:        msg.Template,
+		TemplateVersion: msg.TemplateVersion,
+		Locale:          msg.Locale,
+	}
+}
+
+// Enqueue records req in the outbox for the worker to deliver. Pass the
+// transaction that performs the triggering change as tx so the message is only
+// recorded when that change commits; a nil tx uses the store's connection.
+//
+// Emails to a user in an optional category are checked against the user's
+// notification preferences and carry one-click unsubscribe headers. When the
+// user has opted out, nothing is recorded and a nil message is returned.
+func (eos *EmailOutboxStore) Enqueue(tx *gorm.DB, userID *uuid.UUID, req *notifications.Request) (*EmailOutbox, error) {
+	if tx == nil {
+		tx = eos.DB
+	}
+
+	if !req.Category.IsValid() {
+		return nil, fmt.Errorf("failed to enqueue email: invalid category %q", req.Category)
+	}
+
+	headers := make(map[string]string, len(req.Headers)+2)
+	for key, value := range req.Headers {
+		headers[key] = value
+	}
+
+	if userID != nil && req.Category.IsOptional() {
+		enabled, err := eos.Preferences.IsEnabled(tx, *userID, req.Category)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check notification preferences: %w", err)
+		}
+		if !enabled {
+			return nil, nil
+		}
+
+		// RFC 8058 one-click unsubscribe
+		headers["List-Unsubscribe"] = "<" + UnsubscribeURL(*userID, req.Category) + ">"
+		headers["List-Unsubscribe-Post"] = "List-Unsubscribe=One-Click"
+	}
+
+	msg := EmailOutbox{
+		UserID:          userID,
+		Category:        req.Category,
+		To:              req.To,
+		ReplyTo:         req.ReplyTo,
+		Subject:         req.Subject,
+		HTMLBody:        req.HTML,
+		TextBody:        req.Text,
+		Headers:         headers,
+		Attachments:     req.Attachments,
+		Template:        req.Template,
+		TemplateVersion: req.TemplateVersion,
+		Locale:          req.Locale,
+		Status:          utils.EmailStatusPending,
+		MaxAttempts:     emailOutboxMaxAttempts,
+		NextAttemptAt:   time.Now(),
+	}
+
+	if err := tx.Create(&msg).Error; err != nil {
+		return nil, fmt.Errorf("failed to enqueue email: %w", err)
+	}
+	return &msg, nil
+}
+
+func (eos *EmailOutboxStore) GetByID(id uuid.UUID) (*EmailOutbox, error) {
+	var msg EmailOutbox
+	if err := eos.DB.Where("id = ?", id).First(&msg).Error; err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func (eos *EmailOutboxStore) FindBy(filter dto.EmailOutboxFilterDto, start, limit int) ([]EmailOutbox, error) {
+	var messages []EmailOutbox
+
+	query := eos.DB.Model(&EmailOutbox{})
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.UserID != nil {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+
+	err := query.Order("created_at DESC").Offset(start).Limit(limit).Find(&messages).Error
+	return messages, err
+}
+
+// Resend queues a fresh copy of an existing message. The original row is kept
+// untouched so its delivery history stays intact.
+func (eos *EmailOutboxStore) Resend(id uuid.UUID) (*EmailOutbox, error) {
+	original, err := eos.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := EmailOutbox{
+		UserID:          original.UserID,
+		Category:        original.Category,
+		To:              original.To,
+		ReplyTo:         original.ReplyTo,
+		Subject:         original.Subject,
+		HTMLBody:        original.HTMLBody,
+		TextBody:        original.TextBody,
+		Headers:         original.Headers,
+		Attachments:     original.Attachments,
+		Template:        original.Template,
+		TemplateVersion: original.TemplateVersion,
+		Locale:          original.Locale,
+		Status:          utils.EmailStatusPending,
+		MaxAttempts:     emailOutboxMaxAttempts,
+		NextAttemptAt:   time.Now(),
+		ResentFromID:    &original.ID,
+	}
+
+	if err := eos.DB.Create(&msg).Error; err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// claim locks up to limit due messages and leases them to the caller.
+func (eos *EmailOutboxStore) claim(limit int) ([]EmailOutbox, error) {
+	var messages []EmailOutbox
+
+	err := eos.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status IN ? AND next_attempt_at <= ?", []utils.EmailStatus{utils.EmailStatusPending, utils.EmailStatusSending}, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&messages).Error
+		if err != nil || len(messages) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(messages))
+		for i := range messages {
+			ids[i] = messages[i].ID
+			messages[i].Status = utils.EmailStatusSending
+		}
+
+		return tx.Model(&EmailOutbox{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"status":          utils.EmailStatusSending,
+			"next_attempt_at": now.Add(emailOutboxLease),
+		}).Error
+	})
+
+	return messages, err
+}
+
+func (eos *EmailOutboxStore) deliver(ctx context.Context, msg *EmailOutbox) error {
+	msg.Attempts++
+
+	ctx, cancel := context.WithTimeout(ctx, emailOutboxSendTimeout)
+	messageID, sendErr := eos.Sender.Send(ctx, msg.Request())
+	cancel()
+
+	updates := map[string]interface{}{"attempts": msg.Attempts}
+
+	if sendErr == nil {
+		now := time.Now()
+		updates["status"] = utils.EmailStatusSent
+		updates["provider_message_id"] = messageID
+		updates["last_error"] = ""
+		updates["sent_at"] = &now
+	} else {
+		updates["last_error"] = sendErr.Error()
+		if msg.Attempts >= msg.MaxAttempts {
+			updates["status"] = utils.EmailStatusFailed
+		} else {
+			updates["status"] = utils.EmailStatusPending
+			updates["next_attempt_at"] = time.Now().Add(emailOutboxBackoff(msg.Attempts))
+		}
+	}
+
+	if err := eos.DB.Model(&EmailOutbox{}).Where("id = ?", msg.ID).Updates(updates).Error; err != nil {
+		return errors.Join(sendErr, err)
+	}
+	return sendErr
+}
+
+// emailOutboxBackoff doubles the wait after every failed attempt, capped at an hour.
+func emailOutboxBackoff(attempts int) time.Duration {
+	backoff := time.Minute << (attempts - 1)
+	if backoff <= 0 || backoff > time.Hour {
+		return time.Hour
+	}
+	return backoff
+}
+
+// ProcessPending attempts one batch of due messages and returns how many were claimed.
+func (eos *EmailOutboxStore) ProcessPending(ctx context.Context, logger *log.Logger) (int, error) {
+	messages, err := eos.claim(emailOutboxBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range messages {
+		if err := eos.deliver(ctx, &messages[i]); err != nil {
+			logger.Printf("EmailOutbox: failed to deliver %s (attempt %d/%d): %v", messages[i].ID, messages[i].Attempts, messages[i].MaxAttempts, err)
+		}
+	}
+	return len(messages), nil
+}
+
+// RunWorker polls the outbox every interval until ctx is cancelled.
+func (eos *EmailOutboxStore) RunWorker(ctx context.Context, logger *log.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				claimed, err := eos.ProcessPending(ctx, logger)
+				if err != nil {
+					logger.Printf("EmailOutbox worker error: %v", err)
+					break
+				}
+				if claimed < emailOutboxBatchSize {
+					break
+				}
+			}
+		}
+	}
+}
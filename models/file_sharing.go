@@ -0,0 +1,264 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	shareLinkDefaultTTL = 24 * time.Hour
+	shareLinkMaxTTL     = 30 * 24 * time.Hour
+)
+
+// FileShare gives a user read access to another user's file.
+type FileShare struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	FileID    uuid.UUID `gorm:"column:file_id;type:uuid;uniqueIndex:idx_file_shares_file_user" json:"fileId"`
+	UserID    uuid.UUID `gorm:"column:user_id;type:uuid;uniqueIndex:idx_file_shares_file_user;index" json:"userId"`
+	CreatedBy uuid.UUID `gorm:"column:created_by;type:uuid" json:"createdBy"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+}
+
+// FileShareLink lets anyone holding its token read a file without signing
+// in until it expires or is revoked. Only a SHA-256 hash of the token is
+// stored.
+type FileShareLink struct {
+	ID        uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	FileID    uuid.UUID  `gorm:"column:file_id;type:uuid;index" json:"fileId"`
+	CreatedBy uuid.UUID  `gorm:"column:created_by;type:uuid" json:"createdBy"`
+	TokenHash string     `gorm:"column:token_hash;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"column:expires_at" json:"expiresAt"`
+	RevokedAt *time.Time `gorm:"column:revoked_at" json:"revokedAt"`
+	CreatedAt time.Time  `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+}
+
+// FileAccess records one read of a file for auditing. UserID is nil for
+// reads without a signed-in user.
+type FileAccess struct {
+	ID          uuid.UUID           `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	FileID      uuid.UUID           `gorm:"column:file_id;type:uuid;index" json:"fileId"`
+	UserID      *uuid.UUID          `gorm:"column:user_id;type:uuid" json:"userId"`
+	ShareLinkID *uuid.UUID          `gorm:"column:share_link_id;type:uuid" json:"shareLinkId"`
+	Via         utils.FileAccessVia `gorm:"column:via" json:"via"`
+	IP          string              `gorm:"column:ip" json:"ip"`
+	CreatedAt   time.Time           `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+}
+
+func hashShareLinkToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ShareLinkURL is the address a share link token is opened at.
+func ShareLinkURL(token string) string {
+	return fmt.Sprintf("%s/file/shared/%s", strings.TrimSuffix(utils.GetEnv("API_URL", false), "/"), url.PathEscape(token))
+}
+
+// GetOwned returns the file with id if it belongs to userID.
+func (fs *FileStore) GetOwned(userID, id uuid.UUID) (*File, error) {
+	var file File
+	err := fs.DB.Where("id = ? AND user_id = ?", id, userID).First(&file).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrFileNotFound
+		}
+		return nil, err
+	}
+	return &file, nil
+}
+
+// Authorize reports why viewer may read file. viewer is nil for requests
+// without a signed-in user. Files the viewer cannot read are reported as
+// not found so their existence is not revealed.
+func (fs *FileStore) Authorize(file *File, viewer *User) (utils.FileAccessVia, error) {
+	if viewer != nil {
+		if file.UserID == viewer.ID {
+			return utils.FileAccessViaOwner, nil
+		}
+
+		var shares int64
+		err := fs.DB.Model(&FileShare{}).Where("file_id = ? AND user_id = ?", file.ID, viewer.ID).Count(&shares).Error
+		if err != nil {
+			return "", err
+		}
+		if shares > 0 {
+			return utils.FileAccessViaShare, nil
+		}
+
+		if file.Visibility == utils.FileVisibilityOrganization && viewer.OrganizationID != nil {
+			var owners int64
+			err := fs.DB.Model(&User{}).Where("id = ? AND organization_id = ?", file.UserID, viewer.OrganizationID).Count(&owners).Error
+			if err != nil {
+				return "", err
+			}
+			if owners > 0 {
+				return utils.FileAccessViaOrganization, nil
+			}
+		}
+	}
+
+	if file.Visibility == utils.FileVisibilityPublic {
+		return utils.FileAccessViaPublic, nil
+	}
+	return "", utils.ErrFileNotFound
+}
+
+func (fs *FileStore) SetVisibility(file *File, visibility utils.FileVisibility) error {
+	file.Visibility = visibility
+	return fs.DB.Model(file).Update("visibility", visibility).Error
+}
+
+// Share gives the user with userID read access to file. Sharing a file
+// with the same user again is a no-op.
+func (fs *FileStore) Share(file *File, userID uuid.UUID) (*FileShare, error) {
+	if userID == file.UserID {
+		return nil, utils.ErrFileShareSelf
+	}
+
+	var share FileShare
+	err := fs.DB.Transaction(func(tx *gorm.DB) error {
+		var users int64
+		if err := tx.Model(&User{}).Where("id = ?", userID).Count(&users).Error; err != nil {
+			return err
+		}
+		if users == 0 {
+			return utils.ErrUserNotFound
+		}
+
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&FileShare{
+			FileID:    file.ID,
+			UserID:    userID,
+			CreatedBy: file.UserID,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("file_id = ? AND user_id = ?", file.ID, userID).First(&share).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+func (fs *FileStore) Unshare(file *File, userID uuid.UUID) error {
+	result := fs.DB.Where("file_id = ? AND user_id = ?", file.ID, userID).Delete(&FileShare{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrFileShareNotFound
+	}
+	return nil
+}
+
+func (fs *FileStore) Shares(file *File) ([]FileShare, error) {
+	var shares []FileShare
+	err := fs.DB.Where("file_id = ?", file.ID).Order("created_at").Find(&shares).Error
+	return shares, err
+}
+
+// CreateShareLink creates a link to file that works for ttl, capped at
+// shareLinkMaxTTL; zero means shareLinkDefaultTTL. The token is only
+// returned here.
+func (fs *FileStore) CreateShareLink(file *File, ttl time.Duration) (*FileShareLink, string, error) {
+	if ttl <= 0 {
+		ttl = shareLinkDefaultTTL
+	}
+	ttl = min(ttl, shareLinkMaxTTL)
+
+	token, err := GetAlphaNumString(48, "alphanum")
+	if err != nil {
+		return nil, "", err
+	}
+
+	link := FileShareLink{
+		FileID:    file.ID,
+		CreatedBy: file.UserID,
+		TokenHash: hashShareLinkToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := fs.DB.Create(&link).Error; err != nil {
+		return nil, "", err
+	}
+	return &link, token, nil
+}
+
+// ShareLinks returns the links to file that still work.
+func (fs *FileStore) ShareLinks(file *File) ([]FileShareLink, error) {
+	var links []FileShareLink
+	err := fs.DB.Where("file_id = ? AND revoked_at IS NULL AND expires_at > ?", file.ID, time.Now()).
+		Order("created_at").
+		Find(&links).Error
+	return links, err
+}
+
+func (fs *FileStore) RevokeShareLink(file *File, linkID uuid.UUID) error {
+	now := time.Now()
+	result := fs.DB.Model(&FileShareLink{}).
+		Where("id = ? AND file_id = ? AND revoked_at IS NULL", linkID, file.ID).
+		Update("revoked_at", &now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrShareLinkInvalid
+	}
+	return nil
+}
+
+// ResolveShareLink returns the file a live share link token points to.
+func (fs *FileStore) ResolveShareLink(token string) (*File, *FileShareLink, error) {
+	var link FileShareLink
+	err := fs.DB.Where("token_hash = ? AND revoked_at IS NULL AND expires_at > ?", hashShareLinkToken(token), time.Now()).
+		First(&link).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, utils.ErrShareLinkInvalid
+		}
+		return nil, nil, err
+	}
+
+	var file File
+	if err := fs.DB.Where("id = ?", link.FileID).First(&file).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, utils.ErrShareLinkInvalid
+		}
+		return nil, nil, err
+	}
+	return &file, &link, nil
+}
+
+// RecordAccess logs a read of file by viewer, which is nil for reads
+// without a signed-in user, and link when it was read through one.
+func (fs *FileStore) RecordAccess(file *File, viewer *User, link *FileShareLink, via utils.FileAccessVia, ip string) error {
+	access := FileAccess{
+		FileID: file.ID,
+		Via:    via,
+		IP:     ip,
+	}
+	if viewer != nil {
+		access.UserID = &viewer.ID
+	}
+	if link != nil {
+		access.ShareLinkID = &link.ID
+	}
+	return fs.DB.Create(&access).Error
+}
+
+// Accesses returns the recorded reads of file, latest first.
+func (fs *FileStore) Accesses(file *File, start, limit int) ([]FileAccess, error) {
+	var accesses []FileAccess
+	err := fs.DB.Where("file_id = ?", file.ID).Order("created_at DESC").Offset(start).Limit(limit).Find(&accesses).Error
+	return accesses, err
+}
@@ -2,38 +2,66 @@ package models
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"mime/multipart"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/21TechLabs/factory-backend/dto"
 	"github.com/21TechLabs/factory-backend/utils"
 	"github.com/google/uuid"
 	"github.com/minio/minio-go/v7"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type FileStore struct {
 	DB *gorm.DB
+	// TrashRetention is how long trashed files are kept before they are
+	// purged along with their objects
+	TrashRetention time.Duration
+	StorageStore   *StorageStore
 }
 
-func NewFileStore(db *gorm.DB) *FileStore {
+func NewFileStore(db *gorm.DB, trashRetention time.Duration, storageStore *StorageStore) *FileStore {
 	return &FileStore{
-		DB: db,
+		DB:             db,
+		TrashRetention: trashRetention,
+		StorageStore:   storageStore,
 	}
 }
 
+// TrashRetentionFromEnv reads FILE_TRASH_RETENTION_DAYS, 30 by default.
+func TrashRetentionFromEnv() (time.Duration, error) {
+	days := 30
+	if value := utils.GetEnv("FILE_TRASH_RETENTION_DAYS", true); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("FILE_TRASH_RETENTION_DAYS must be a positive number")
+		}
+		days = n
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
 type File struct {
-	ID        uuid.UUID      `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
-	UserID    uuid.UUID      `gorm:"not null;index"`
-	Name      string         `json:"name" gorm:"column:name"`
-	Type      string         `json:"type" gorm:"column:file_type"`
-	Etag      string         `json:"-" gorm:"column:etag"`
-	Size      int64          `json:"size" gorm:"column:size"`
-	Key       string         `json:"key" gorm:"column:key"`
-	Bucket    string         `json:"-" gorm:"column:bucket"`
-	CreatedAt time.Time      `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
-	UpdatedAt time.Time      `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt"`
-	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at;index" json:"-"`
+	ID         uuid.UUID            `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	UserID     uuid.UUID            `gorm:"not null;index"`
+	Name       string               `json:"name" gorm:"column:name"`
+	Type       string               `json:"type" gorm:"column:file_type"`
+	Etag       string               `json:"-" gorm:"column:etag"`
+	Size       int64                `json:"size" gorm:"column:size"`
+	Key        string               `json:"key" gorm:"column:key"`
+	Bucket     string               `json:"-" gorm:"column:bucket"`
+	Visibility utils.FileVisibility `json:"visibility" gorm:"column:visibility;default:private"`
+	FolderID   *uuid.UUID           `json:"folderId" gorm:"column:folder_id;type:uuid;index"`
+	CheckedAt  *time.Time           `json:"-" gorm:"column:checked_at;index"`
+	CreatedAt  time.Time            `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+	UpdatedAt  time.Time            `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt"`
+	DeletedAt  gorm.DeletedAt       `gorm:"column:deleted_at;index" json:"deletedAt"`
 }
 
 type FileUpload struct {
@@ -80,32 +108,215 @@ func (f *File) Delete() error {
 	return nil
 }
 
-func (fs *FileStore) UploadFile(files []FileUpload, userId uuid.UUID) ([]File, error) {
-	var fileObjs []File
+// UploadFile stores files for user. The whole batch is rejected before
+// anything is stored if it does not fit in the user's storage quota. The
+// bytes are reserved up front so the uploads run without holding the pool's
+// lock; if the Files cannot be created the uploaded objects are deleted.
+func (fs *FileStore) UploadFile(files []FileUpload, user *User) ([]File, error) {
+	var total int64
+	for _, file := range files {
+		total += file.File.Size
+	}
+
+	reservation, err := fs.StorageStore.Hold(user, total)
+	if err != nil {
+		return nil, err
+	}
+
+	fileObjs := make([]File, 0, len(files))
 	for _, file := range files {
-		fileObj := File{
-			UserID: userId,
+		uploadOutput, err := utils.S3UploadFile(utils.S3BucketName, fmt.Sprintf("%d", user.ID), &file.File)
+		if err != nil {
+			return nil, errors.Join(err, fs.abortUpload(reservation, fileObjs))
+		}
+
+		fileObjs = append(fileObjs, File{
+			UserID: user.ID,
 			Name:   file.Title,
 			Type:   file.File.Header.Get("Content-Type"),
 			Size:   file.File.Size,
+			Etag:   uploadOutput.ETag,
+			Key:    uploadOutput.Key,
+			Bucket: utils.S3BucketName,
+		})
+	}
+
+	err = fs.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&fileObjs).Error; err != nil {
+			return err
 		}
+		return fs.StorageStore.Release(tx, reservation)
+	})
+	if err != nil {
+		return nil, errors.Join(err, fs.abortUpload(reservation, fileObjs))
+	}
+	return fileObjs, nil
+}
 
-		uploadOutput, err := utils.S3UploadFile(utils.S3BucketName, fmt.Sprintf("%d", userId), &file.File)
-		if err != nil {
-			return nil, err
+// abortUpload deletes the objects uploaded for files that were never
+// created and releases their reservation.
+func (fs *FileStore) abortUpload(reservation *StorageReservation, files []File) error {
+	ctx, cancel := context.WithTimeout(context.Background(), storageS3Timeout)
+	defer cancel()
+
+	var errs []error
+	for _, file := range files {
+		if err := utils.S3RemoveObject(ctx, file.Bucket, file.Key); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete object %s: %w", file.Key, err))
 		}
+	}
+	if err := fs.StorageStore.Release(fs.DB, reservation); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// fileSortColumns maps the sort options of FileFilterDto to columns.
+var fileSortColumns = map[string]string{
+	"name":      "name",
+	"size":      "size",
+	"createdAt": "created_at",
+	"updatedAt": "updated_at",
+}
 
-		fileObj.Etag = uploadOutput.ETag
-		fileObj.Key = uploadOutput.Key
-		fileObj.Bucket = utils.S3BucketName
+// FindFiles returns userID's files matching filter, newest first unless
+// filter sorts otherwise. Trashed files are only listed when filter asks for
+// the trash.
+func (fs *FileStore) FindFiles(userID uuid.UUID, filter dto.FileFilterDto, start, limit int) ([]File, error) {
+	query := fs.DB.Model(&File{}).Where("user_id = ?", userID)
+	if filter.Trashed == "true" {
+		query = query.Unscoped().Where("deleted_at IS NOT NULL")
+	}
+
+	switch filter.FolderID {
+	case "":
+	case "root":
+		query = query.Where("folder_id IS NULL")
+	default:
+		query = query.Where("folder_id = ?", filter.FolderID)
+	}
+
+	if filter.Query != "" {
+		query = query.Where("name ILIKE ?", "%"+escapeLike(filter.Query)+"%")
+	}
+	if filter.Type != "" {
+		query = query.Where("file_type LIKE ?", escapeLike(filter.Type)+"%")
+	}
+
+	column, ok := fileSortColumns[filter.Sort]
+	if !ok {
+		column = "created_at"
+	}
+	desc := filter.Order == "desc" || (filter.Order == "" && filter.Sort == "")
 
-		// err = mgm.Coll(&fileObj).Create(&fileObj)
-		result := fs.DB.Create(&fileObj)
-		if result.Error != nil {
-			return nil, result.Error
+	var files []File
+	err := query.
+		Order(clause.OrderByColumn{Column: clause.Column{Name: column}, Desc: desc}).
+		Order("id").
+		Offset(start).
+		Limit(limit).
+		Find(&files).Error
+	return files, err
+}
+
+// escapeLike escapes the LIKE wildcards in s so it matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+func (fs *FileStore) Rename(file *File, name string) error {
+	file.Name = strings.TrimSpace(name)
+	return fs.DB.Model(file).Update("name", file.Name).Error
+}
+
+// Move puts file into the folder with folderID, or out of every folder when
+// folderID is nil.
+func (fs *FileStore) Move(file *File, folderID *uuid.UUID) error {
+	if folderID != nil {
+		if _, err := ownedFolder(fs.DB, file.UserID, *folderID); err != nil {
+			return err
 		}
+	}
+	file.FolderID = folderID
+	return fs.DB.Model(file).Update("folder_id", folderID).Error
+}
 
-		fileObjs = append(fileObjs, fileObj)
+// Trash soft-deletes file. It can be restored until it is purged after
+// TrashRetention.
+func (fs *FileStore) Trash(file *File) error {
+	return fs.DB.Delete(file).Error
+}
+
+// GetTrashed returns the trashed file with id if it belongs to userID.
+func (fs *FileStore) GetTrashed(userID, id uuid.UUID) (*File, error) {
+	var file File
+	err := fs.DB.Unscoped().Where("id = ? AND user_id = ? AND deleted_at IS NOT NULL", id, userID).First(&file).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrFileNotFound
+		}
+		return nil, err
+	}
+	return &file, nil
+}
+
+func (fs *FileStore) Restore(file *File) error {
+	file.DeletedAt = gorm.DeletedAt{}
+	return fs.DB.Unscoped().Model(file).Update("deleted_at", nil).Error
+}
+
+// PurgeTrash deletes up to limit files that were trashed more than
+// TrashRetention ago, objects first, and returns how many were purged.
+func (fs *FileStore) PurgeTrash(limit int) (int, error) {
+	var files []File
+	err := fs.DB.Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", time.Now().Add(-fs.TrashRetention)).
+		Order("deleted_at").
+		Limit(limit).
+		Find(&files).Error
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, file := range files {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := utils.S3RemoveObject(ctx, file.Bucket, file.Key)
+		cancel()
+		if err != nil {
+			return purged, fmt.Errorf("failed to delete object of file %s: %w", file.ID, err)
+		}
+
+		err = fs.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("file_id = ?", file.ID).Delete(&FileShare{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("file_id = ?", file.ID).Delete(&FileShareLink{}).Error; err != nil {
+				return err
+			}
+			return tx.Unscoped().Delete(&file).Error
+		})
+		if err != nil {
+			return purged, fmt.Errorf("failed to purge file %s: %w", file.ID, err)
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// RunWorker purges expired trash every interval until ctx is cancelled.
+func (fs *FileStore) RunWorker(ctx context.Context, logger *log.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := fs.PurgeTrash(100); err != nil {
+				logger.Printf("File trash worker error: %v", err)
+			}
+		}
 	}
-	return fileObjs, nil
 }
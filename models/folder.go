@@ -0,0 +1,189 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type FolderStore struct {
+	DB *gorm.DB
+}
+
+func NewFolderStore(db *gorm.DB) *FolderStore {
+	return &FolderStore{DB: db}
+}
+
+// Folder groups a user's files. Folders nest through ParentID; top-level
+// folders have none.
+type Folder struct {
+	ID        uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	UserID    uuid.UUID  `gorm:"column:user_id;type:uuid;index" json:"userId"`
+	ParentID  *uuid.UUID `gorm:"column:parent_id;type:uuid;index" json:"parentId"`
+	Name      string     `gorm:"column:name" json:"name"`
+	CreatedAt time.Time  `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+	UpdatedAt time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt"`
+}
+
+// ownedFolder returns the folder with id if it belongs to userID.
+func ownedFolder(tx *gorm.DB, userID, id uuid.UUID) (*Folder, error) {
+	var folder Folder
+	err := tx.Where("id = ? AND user_id = ?", id, userID).First(&folder).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrFolderNotFound
+		}
+		return nil, err
+	}
+	return &folder, nil
+}
+
+// lockFolders serialises changes to userID's folder tree, so sibling names
+// stay unique and concurrent moves cannot form a cycle.
+func lockFolders(tx *gorm.DB, userID uuid.UUID) error {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", userID).First(&User{}).Error
+}
+
+// checkFolderName reports ErrFolderNameTaken when another folder of userID
+// under parentID is already called name.
+func checkFolderName(tx *gorm.DB, userID uuid.UUID, parentID *uuid.UUID, name string, exclude *uuid.UUID) error {
+	query := tx.Model(&Folder{}).Where("user_id = ? AND LOWER(name) = LOWER(?)", userID, name)
+	if parentID == nil {
+		query = query.Where("parent_id IS NULL")
+	} else {
+		query = query.Where("parent_id = ?", parentID)
+	}
+	if exclude != nil {
+		query = query.Where("id <> ?", exclude)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return utils.ErrFolderNameTaken
+	}
+	return nil
+}
+
+func (fs *FolderStore) Get(userID, id uuid.UUID) (*Folder, error) {
+	return ownedFolder(fs.DB, userID, id)
+}
+
+// List returns the folders of userID directly under parentID, or the
+// top-level ones when parentID is nil.
+func (fs *FolderStore) List(userID uuid.UUID, parentID *uuid.UUID) ([]Folder, error) {
+	query := fs.DB.Where("user_id = ?", userID)
+	if parentID == nil {
+		query = query.Where("parent_id IS NULL")
+	} else {
+		query = query.Where("parent_id = ?", parentID)
+	}
+
+	var folders []Folder
+	err := query.Order("LOWER(name)").Find(&folders).Error
+	return folders, err
+}
+
+func (fs *FolderStore) Create(userID uuid.UUID, name string, parentID *uuid.UUID) (*Folder, error) {
+	folder := Folder{
+		UserID:   userID,
+		ParentID: parentID,
+		Name:     strings.TrimSpace(name),
+	}
+
+	err := fs.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockFolders(tx, userID); err != nil {
+			return err
+		}
+		if parentID != nil {
+			if _, err := ownedFolder(tx, userID, *parentID); err != nil {
+				return err
+			}
+		}
+		if err := checkFolderName(tx, userID, parentID, folder.Name, nil); err != nil {
+			return err
+		}
+		return tx.Create(&folder).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &folder, nil
+}
+
+func (fs *FolderStore) Rename(folder *Folder, name string) error {
+	name = strings.TrimSpace(name)
+
+	return fs.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockFolders(tx, folder.UserID); err != nil {
+			return err
+		}
+		if err := checkFolderName(tx, folder.UserID, folder.ParentID, name, &folder.ID); err != nil {
+			return err
+		}
+		folder.Name = name
+		return tx.Model(folder).Update("name", name).Error
+	})
+}
+
+// Move puts folder under parentID, or at the top level when parentID is
+// nil. A folder cannot be moved into itself or one of its subfolders.
+func (fs *FolderStore) Move(folder *Folder, parentID *uuid.UUID) error {
+	return fs.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockFolders(tx, folder.UserID); err != nil {
+			return err
+		}
+
+		// walk up from the new parent; meeting folder means a cycle
+		for id := parentID; id != nil; {
+			if *id == folder.ID {
+				return utils.ErrFolderMoveIntoSelf
+			}
+			ancestor, err := ownedFolder(tx, folder.UserID, *id)
+			if err != nil {
+				return err
+			}
+			id = ancestor.ParentID
+		}
+
+		if err := checkFolderName(tx, folder.UserID, parentID, folder.Name, &folder.ID); err != nil {
+			return err
+		}
+		folder.ParentID = parentID
+		return tx.Model(folder).Update("parent_id", parentID).Error
+	})
+}
+
+// Delete removes an empty folder. Trashed files that were in it are
+// restored to the top level.
+func (fs *FolderStore) Delete(folder *Folder) error {
+	return fs.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockFolders(tx, folder.UserID); err != nil {
+			return err
+		}
+
+		var subfolders, files int64
+		if err := tx.Model(&Folder{}).Where("parent_id = ?", folder.ID).Count(&subfolders).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&File{}).Where("folder_id = ?", folder.ID).Count(&files).Error; err != nil {
+			return err
+		}
+		if subfolders > 0 || files > 0 {
+			return utils.ErrFolderNotEmpty
+		}
+
+		err := tx.Unscoped().Model(&File{}).Where("folder_id = ?", folder.ID).Update("folder_id", nil).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(folder).Error
+	})
+}
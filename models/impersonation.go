@@ -0,0 +1,165 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	impersonationDefaultTTL = 15 * time.Minute
+	impersonationMaxTTL     = time.Hour
+)
+
+type ImpersonationStore struct {
+	DB        *gorm.DB
+	UserStore *UserStore
+}
+
+func NewImpersonationStore(db *gorm.DB, userStore *UserStore) *ImpersonationStore {
+	return &ImpersonationStore{DB: db, UserStore: userStore}
+}
+
+// ImpersonationSession lets an admin act as a user for a limited time.
+// Sessions are kept as the audit trail and never deleted.
+type ImpersonationSession struct {
+	ID        uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	AdminID   uuid.UUID  `gorm:"column:admin_id;type:uuid;index" json:"adminId"`
+	UserID    uuid.UUID  `gorm:"column:user_id;type:uuid;index" json:"userId"`
+	Reason    string     `gorm:"column:reason" json:"reason"`
+	ExpiresAt time.Time  `gorm:"column:expires_at" json:"expiresAt"`
+	EndedAt   *time.Time `gorm:"column:ended_at" json:"endedAt"`
+	CreatedAt time.Time  `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+}
+
+// ImpersonationRequest records one request made during an impersonation
+// session.
+type ImpersonationRequest struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	SessionID uuid.UUID `gorm:"column:session_id;type:uuid;index" json:"sessionId"`
+	Method    string    `gorm:"column:method" json:"method"`
+	Path      string    `gorm:"column:path" json:"path"`
+	Status    int       `gorm:"column:status" json:"status"`
+	IP        string    `gorm:"column:ip" json:"ip"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+}
+
+// Start opens a session for admin to act as the user with userID for ttl,
+// capped at impersonationMaxTTL; zero means impersonationDefaultTTL. Admins
+// cannot impersonate themselves or other admins.
+func (is *ImpersonationStore) Start(admin *User, userID uuid.UUID, reason string, ttl time.Duration) (*ImpersonationSession, User, error) {
+	var user User
+	err := is.DB.Where("id = ?", userID).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, User{}, utils.ErrUserNotFound
+		}
+		return nil, User{}, err
+	}
+
+	if user.ID == admin.ID || user.UserIsAdmin() {
+		return nil, User{}, utils.ErrImpersonationNotAllowed
+	}
+	if err := user.CanLogin(); err != nil {
+		return nil, User{}, err
+	}
+
+	if ttl <= 0 {
+		ttl = impersonationDefaultTTL
+	}
+	ttl = min(ttl, impersonationMaxTTL)
+
+	session := ImpersonationSession{
+		AdminID:   admin.ID,
+		UserID:    user.ID,
+		Reason:    reason,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := is.DB.Create(&session).Error; err != nil {
+		return nil, User{}, err
+	}
+	return &session, user, nil
+}
+
+// Verify returns the live session with id for the user with userID and the
+// admin behind it. Sessions stop working once ended or expired, or when the
+// admin loses the admin role or can no longer log in.
+func (is *ImpersonationStore) Verify(id, userID uuid.UUID) (*ImpersonationSession, *User, error) {
+	var session ImpersonationSession
+	err := is.DB.Where("id = ? AND user_id = ? AND ended_at IS NULL AND expires_at > ?", id, userID, time.Now()).
+		First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, utils.ErrImpersonationEnded
+		}
+		return nil, nil, err
+	}
+
+	var admin User
+	err = is.DB.Where("id = ?", session.AdminID).First(&admin).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, utils.ErrImpersonationEnded
+		}
+		return nil, nil, err
+	}
+	if !admin.UserIsAdmin() || admin.CanLogin() != nil {
+		return nil, nil, utils.ErrImpersonationEnded
+	}
+
+	return &session, &admin, nil
+}
+
+// End closes the session with id before it expires.
+func (is *ImpersonationStore) End(id uuid.UUID) error {
+	now := time.Now()
+	result := is.DB.Model(&ImpersonationSession{}).
+		Where("id = ? AND ended_at IS NULL", id).
+		Update("ended_at", &now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrImpersonationEnded
+	}
+	return nil
+}
+
+// LogRequest records a request made during session.
+func (is *ImpersonationStore) LogRequest(session *ImpersonationSession, method, path string, status int, ip string) error {
+	return is.DB.Create(&ImpersonationRequest{
+		SessionID: session.ID,
+		Method:    method,
+		Path:      path,
+		Status:    status,
+		IP:        ip,
+	}).Error
+}
+
+// FindSessions returns sessions matching filter, latest first.
+func (is *ImpersonationStore) FindSessions(filter dto.ImpersonationFilterDto, start, limit int) ([]ImpersonationSession, error) {
+	var sessions []ImpersonationSession
+
+	query := is.DB.Model(&ImpersonationSession{})
+	if filter.AdminID != nil {
+		query = query.Where("admin_id = ?", filter.AdminID)
+	}
+	if filter.UserID != nil {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+
+	err := query.Order("created_at DESC").Offset(start).Limit(limit).Find(&sessions).Error
+	return sessions, err
+}
+
+// FindRequests returns the requests made during the session with id, in
+// the order they were made.
+func (is *ImpersonationStore) FindRequests(id uuid.UUID, start, limit int) ([]ImpersonationRequest, error) {
+	var requests []ImpersonationRequest
+	err := is.DB.Where("session_id = ?", id).Order("created_at").Offset(start).Limit(limit).Find(&requests).Error
+	return requests, err
+}
@@ -0,0 +1,366 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/notifications/templates"
+	"github.com/21TechLabs/factory-backend/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// failures older than loginFailureWindow are forgotten
+	loginFailureWindow = time.Hour
+	// every failure past a scope's free failures doubles the wait before the
+	// next attempt, up to loginDelayMax
+	loginDelayMax = time.Minute
+	// the first lockout lasts loginLockoutBase and every further one doubles,
+	// up to loginLockoutMax; the count resets once no lockout happened for
+	// loginLockoutMemory
+	loginLockoutBase   = 15 * time.Minute
+	loginLockoutMax    = 24 * time.Hour
+	loginLockoutMemory = 24 * time.Hour
+	// idle rows are deleted after loginThrottleRetention
+	loginThrottleRetention = 7 * 24 * time.Hour
+)
+
+type loginThrottlePolicy struct {
+	freeFailures int
+	lockAfter    int
+}
+
+// IPs get more room than accounts because many users can share one address.
+var loginThrottlePolicies = map[utils.LoginThrottleScope]loginThrottlePolicy{
+	utils.LoginThrottleScopeAccount: {freeFailures: 3, lockAfter: 10},
+	utils.LoginThrottleScopeIP:      {freeFailures: 10, lockAfter: 50},
+}
+
+type LoginThrottleStore struct {
+	DB        *gorm.DB
+	UserStore *UserStore
+}
+
+func NewLoginThrottleStore(db *gorm.DB, userStore *UserStore) *LoginThrottleStore {
+	return &LoginThrottleStore{DB: db, UserStore: userStore}
+}
+
+// ClearLegacyLockouts unblocks the accounts locked by the five-strike
+// password lockout that throttling replaced. That lockout was the only thing
+// setting account_blocked and nothing ever cleared it. The password_tries
+// column is dropped afterwards, so this only does work once.
+func ClearLegacyLockouts(db *gorm.DB) (int64, error) {
+	if !db.Migrator().HasColumn(&User{}, "password_tries") {
+		return 0, nil
+	}
+
+	var cleared int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).
+			Where("account_blocked AND password_tries >= ?", 5).
+			Update("account_blocked", false)
+		if result.Error != nil {
+			return result.Error
+		}
+		cleared = result.RowsAffected
+		return tx.Migrator().DropColumn(&User{}, "password_tries")
+	})
+	return cleared, err
+}
+
+// LoginThrottle counts failed password sign-ins for one account or IP.
+// Accounts are keyed by normalized email, not user ID, so unknown addresses
+// are throttled exactly like real ones. Only a SHA-256 of the unlock token
+// is stored.
+type LoginThrottle struct {
+	ID              uuid.UUID                `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	Scope           utils.LoginThrottleScope `gorm:"column:scope;uniqueIndex:idx_login_throttles_scope_key" json:"scope"`
+	Key             string                   `gorm:"column:throttle_key;uniqueIndex:idx_login_throttles_scope_key" json:"key"`
+	Failures        int                      `gorm:"column:failures" json:"failures"`
+	Lockouts        int                      `gorm:"column:lockouts" json:"lockouts"`
+	LastFailureAt   time.Time                `gorm:"column:last_failure_at" json:"lastFailureAt"`
+	RetryAt         time.Time                `gorm:"column:retry_at;index" json:"retryAt"`
+	LockedUntil     *time.Time               `gorm:"column:locked_until" json:"lockedUntil"`
+	UnlockTokenHash string                   `gorm:"column:unlock_token_hash;index" json:"-"`
+	CreatedAt       time.Time                `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+	UpdatedAt       time.Time                `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt"`
+}
+
+func normalizeLoginEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func hashUnlockToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// loginDelay is the wait after the nth failure past the free ones.
+func loginDelay(n int) time.Duration {
+	delay := time.Second << (n - 1)
+	if delay <= 0 || delay > loginDelayMax {
+		return loginDelayMax
+	}
+	return delay
+}
+
+// loginLockoutDuration is the length of a lockout after `previous` earlier ones.
+func loginLockoutDuration(previous int) time.Duration {
+	lock := loginLockoutBase << previous
+	if lock <= 0 || lock > loginLockoutMax {
+		return loginLockoutMax
+	}
+	return lock
+}
+
+// Login signs the user in with a password unless the account or ip is being
+// throttled. Every failure, including for unknown emails, counts against both.
+func (lts *LoginThrottleStore) Login(loginDto dto.UserLoginDto, ip string) (User, error) {
+	email := normalizeLoginEmail(loginDto.Email)
+
+	if err := lts.check(email, ip); err != nil {
+		return User{}, err
+	}
+
+	user, err := lts.UserStore.UserLogin(loginDto)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCredentials) {
+			if recordErr := lts.recordFailure(loginDto.Email, ip); recordErr != nil {
+				return User{}, errors.Join(err, recordErr)
+			}
+		}
+		return User{}, err
+	}
+
+	// only the account is cleared, an ip could otherwise reset its own
+	// counter with an account it controls
+	if err := lts.reset(lts.DB, utils.LoginThrottleScopeAccount, email); err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+func (lts *LoginThrottleStore) check(email, ip string) error {
+	var throttles []LoginThrottle
+	err := lts.DB.
+		Where("(scope = ? AND throttle_key = ?) OR (scope = ? AND throttle_key = ?)",
+			utils.LoginThrottleScopeAccount, email, utils.LoginThrottleScopeIP, ip).
+		Where("retry_at > ?", time.Now()).
+		Find(&throttles).Error
+	if err != nil {
+		return err
+	}
+
+	var retryAfter time.Duration
+	for _, throttle := range throttles {
+		retryAfter = max(retryAfter, time.Until(throttle.RetryAt))
+	}
+	if retryAfter > 0 {
+		return &utils.LoginThrottledError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// recordFailure counts a failed sign-in against the account and ip. When it
+// locks the account and the email belongs to a user, they are emailed a link
+// that lifts the lockout.
+func (lts *LoginThrottleStore) recordFailure(email, ip string) error {
+	return lts.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := lts.bump(tx, utils.LoginThrottleScopeIP, ip); err != nil {
+			return err
+		}
+
+		key := normalizeLoginEmail(email)
+		throttle, err := lts.bump(tx, utils.LoginThrottleScopeAccount, key)
+		if err != nil || throttle == nil {
+			return err
+		}
+
+		// matched like the throttle key, so the owner of a lockout caused by
+		// a differently cased address still gets the unlock link
+		var user User
+		err = tx.Where("LOWER(email) = ?", key).First(&user).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		if user.CanLogin() != nil {
+			return nil
+		}
+		return lts.sendUnlockEmail(tx, &user, throttle)
+	})
+}
+
+// bump records one failure for scope and key and returns the throttle if
+// this failure locked it.
+func (lts *LoginThrottleStore) bump(tx *gorm.DB, scope utils.LoginThrottleScope, key string) (*LoginThrottle, error) {
+	err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "scope"}, {Name: "throttle_key"}},
+		DoNothing: true,
+	}).Create(&LoginThrottle{Scope: scope, Key: key}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var throttle LoginThrottle
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("scope = ? AND throttle_key = ?", scope, key).
+		First(&throttle).Error
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	policy := loginThrottlePolicies[scope]
+	if now.Sub(throttle.LastFailureAt) > loginFailureWindow {
+		throttle.Failures = 0
+	}
+	if throttle.LockedUntil != nil && now.Sub(*throttle.LockedUntil) > loginLockoutMemory {
+		throttle.Lockouts = 0
+	}
+	throttle.Failures++
+	throttle.LastFailureAt = now
+
+	locked := throttle.Failures >= policy.lockAfter
+	switch {
+	case locked:
+		lockedUntil := now.Add(loginLockoutDuration(throttle.Lockouts))
+		throttle.LockedUntil = &lockedUntil
+		throttle.RetryAt = lockedUntil
+		throttle.Lockouts++
+		throttle.Failures = 0
+	case throttle.Failures > policy.freeFailures:
+		throttle.RetryAt = now.Add(loginDelay(throttle.Failures - policy.freeFailures))
+	}
+
+	err = tx.Model(&LoginThrottle{}).Where("id = ?", throttle.ID).Updates(map[string]interface{}{
+		"failures":        throttle.Failures,
+		"lockouts":        throttle.Lockouts,
+		"last_failure_at": throttle.LastFailureAt,
+		"retry_at":        throttle.RetryAt,
+		"locked_until":    throttle.LockedUntil,
+	}).Error
+	if err != nil || !locked {
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+func (lts *LoginThrottleStore) sendUnlockEmail(tx *gorm.DB, user *User, throttle *LoginThrottle) error {
+	token, err := GetAlphaNumString(48, "alphanum")
+	if err != nil {
+		return err
+	}
+
+	err = tx.Model(&LoginThrottle{}).Where("id = ?", throttle.ID).Update("unlock_token_hash", hashUnlockToken(token)).Error
+	if err != nil {
+		return err
+	}
+
+	req, err := templates.NewRequest([]string{user.Email}, user.Locale, templates.AccountLockedMessage{
+		Name:          user.Name,
+		Link:          fmt.Sprintf("%s/unlock-account?token=%s", utils.GetEnv("FRONTEND_URL", false), url.QueryEscape(token)),
+		LockedMinutes: int(time.Until(*throttle.LockedUntil).Round(time.Minute).Minutes()),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = lts.UserStore.EmailOutboxStore.Enqueue(tx, &user.ID, req)
+	return err
+}
+
+// reset clears the failures, delay and lockout of scope and key.
+func (lts *LoginThrottleStore) reset(tx *gorm.DB, scope utils.LoginThrottleScope, key string) error {
+	return tx.Model(&LoginThrottle{}).Where("scope = ? AND throttle_key = ?", scope, key).Updates(map[string]interface{}{
+		"failures":          0,
+		"lockouts":          0,
+		"retry_at":          time.Time{},
+		"locked_until":      nil,
+		"unlock_token_hash": "",
+	}).Error
+}
+
+// Unlock lifts the account lockout the token was emailed for. Lockouts of
+// the ips involved are left in place.
+func (lts *LoginThrottleStore) Unlock(token string) error {
+	return lts.DB.Transaction(func(tx *gorm.DB) error {
+		var throttle LoginThrottle
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("unlock_token_hash = ? AND scope = ? AND locked_until > ?", hashUnlockToken(token), utils.LoginThrottleScopeAccount, time.Now()).
+			First(&throttle).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return utils.ErrUnlockTokenInvalid
+			}
+			return err
+		}
+		return lts.reset(tx, throttle.Scope, throttle.Key)
+	})
+}
+
+// FindActive returns the accounts and ips that are currently delayed or
+// locked out, latest first.
+func (lts *LoginThrottleStore) FindActive(filter dto.LoginThrottleFilterDto, start, limit int) ([]LoginThrottle, error) {
+	var throttles []LoginThrottle
+
+	query := lts.DB.Model(&LoginThrottle{}).Where("retry_at > ?", time.Now())
+
+	if filter.Scope != "" {
+		query = query.Where("scope = ?", filter.Scope)
+	}
+
+	err := query.Order("retry_at DESC").Offset(start).Limit(limit).Find(&throttles).Error
+	return throttles, err
+}
+
+// Clear lifts the delay or lockout of the throttle with id.
+func (lts *LoginThrottleStore) Clear(id uuid.UUID) error {
+	var throttle LoginThrottle
+	err := lts.DB.Where("id = ?", id).First(&throttle).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.ErrLockoutNotFound
+		}
+		return err
+	}
+	return lts.reset(lts.DB, throttle.Scope, throttle.Key)
+}
+
+// Prune deletes throttles that saw no failure for loginThrottleRetention and
+// are not locked.
+func (lts *LoginThrottleStore) Prune() (int64, error) {
+	now := time.Now()
+	result := lts.DB.
+		Where("last_failure_at < ? AND retry_at < ?", now.Add(-loginThrottleRetention), now).
+		Delete(&LoginThrottle{})
+	return result.RowsAffected, result.Error
+}
+
+// RunWorker prunes idle throttles every interval until ctx is cancelled.
+func (lts *LoginThrottleStore) RunWorker(ctx context.Context, logger *log.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := lts.Prune(); err != nil {
+				logger.Printf("LoginThrottle worker error: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/notifications/templates"
+	"github.com/21TechLabs/factory-backend/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	magicLinkTTL = 15 * time.Minute
+	// a user can be sent one link per magicLinkResendAfter and at most
+	// magicLinkMaxPerHour links per hour
+	magicLinkResendAfter = time.Minute
+	magicLinkMaxPerHour  = 5
+)
+
+type MagicLinkStore struct {
+	DB        *gorm.DB
+	UserStore *UserStore
+}
+
+func NewMagicLinkStore(db *gorm.DB, userStore *UserStore) *MagicLinkStore {
+	return &MagicLinkStore{DB: db, UserStore: userStore}
+}
+
+// MagicLink is a single-use sign-in token sent by email. Only a SHA-256 of
+// the token is stored.
+type MagicLink struct {
+	ID         uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	UserID     uuid.UUID  `gorm:"column:user_id;type:uuid;index" json:"userId"`
+	TokenHash  string     `gorm:"column:token_hash;uniqueIndex" json:"-"`
+	ExpiresAt  time.Time  `gorm:"column:expires_at" json:"expiresAt"`
+	ConsumedAt *time.Time `gorm:"column:consumed_at" json:"consumedAt"`
+	CreatedAt  time.Time  `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+}
+
+func hashMagicLinkToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Send emails user a new sign-in link; earlier unused links stop working.
+//
+// The link opens the frontend, which asks the user to confirm before calling
+// the verify endpoint. Mail scanners that prefetch links therefore only load
+// the page and never consume the token.
+func (mls *MagicLinkStore) Send(user *User) error {
+	if err := checkSSOSignIn(mls.DB, user.Email); err != nil {
+		return err
+	}
+
+	token, err := GetAlphaNumString(48, "alphanum")
+	if err != nil {
+		return err
+	}
+
+	link := MagicLink{
+		UserID:    user.ID,
+		TokenHash: hashMagicLinkToken(token),
+		ExpiresAt: time.Now().Add(magicLinkTTL),
+	}
+
+	return mls.DB.Transaction(func(tx *gorm.DB) error {
+		var recent []MagicLink
+		err := tx.Where("user_id = ? AND created_at > ?", user.ID, time.Now().Add(-time.Hour)).
+			Order("created_at DESC").
+			Find(&recent).Error
+		if err != nil {
+			return err
+		}
+		if len(recent) >= magicLinkMaxPerHour || (len(recent) > 0 && time.Since(recent[0].CreatedAt) < magicLinkResendAfter) {
+			return utils.ErrMagicLinkRateLimited
+		}
+
+		now := time.Now()
+		err = tx.Model(&MagicLink{}).
+			Where("user_id = ? AND consumed_at IS NULL", user.ID).
+			Update("consumed_at", &now).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(&link).Error; err != nil {
+			return err
+		}
+
+		req, err := templates.NewRequest([]string{user.Email}, user.Locale, templates.MagicLinkMessage{
+			Name:             user.Name,
+			Link:             fmt.Sprintf("%s/magic-login?token=%s", utils.GetEnv("FRONTEND_URL", false), url.QueryEscape(token)),
+			ExpiresInMinutes: int(magicLinkTTL.Minutes()),
+		})
+		if err != nil {
+			return err
+		}
+		_, err = mls.UserStore.EmailOutboxStore.Enqueue(tx, &user.ID, req)
+		return err
+	})
+}
+
+// Verify consumes token and returns its user. Opening the link also proves
+// the user owns the address, so the email is marked verified.
+func (mls *MagicLinkStore) Verify(token string) (User, error) {
+	var user User
+
+	err := mls.DB.Transaction(func(tx *gorm.DB) error {
+		var link MagicLink
+		err := tx.Where("token_hash = ?", hashMagicLinkToken(token)).First(&link).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return utils.ErrMagicLinkInvalid
+			}
+			return err
+		}
+
+		// the conditional update makes the token single-use under concurrent requests
+		now := time.Now()
+		result := tx.Model(&MagicLink{}).
+			Where("id = ? AND consumed_at IS NULL AND expires_at > ?", link.ID, now).
+			Update("consumed_at", &now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return utils.ErrMagicLinkInvalid
+		}
+
+		if err := tx.Where("id = ?", link.UserID).First(&user).Error; err != nil {
+			return err
+		}
+		// links sent before the domain enforced SSO stop working
+		if err := checkSSOSignIn(tx, user.Email); err != nil {
+			return err
+		}
+		if user.EmailVerified {
+			return nil
+		}
+		user.EmailVerified = true
+		if err := tx.Model(&User{}).Where("id = ?", user.ID).Update("email_verified", true).Error; err != nil {
+			return err
+		}
+		return mls.UserStore.WebhookStore.Emit(tx, user.ID, utils.WebhookEventUserEmailVerified, user.WebhookData())
+	})
+
+	return user, err
+}
@@ -0,0 +1,386 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/utils"
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// S3 needs every part but the last to be at least 5 MiB
+	multipartMinPartSize = 8 << 20
+	multipartMaxParts    = 10000
+	multipartMaxSize     = 5 << 30
+	// pending uploads are aborted by the sweeper after multipartUploadTTL
+	multipartUploadTTL  = 24 * time.Hour
+	multipartPartURLTTL = time.Hour
+	multipartS3Timeout  = 30 * time.Second
+)
+
+type MultipartUploadStore struct {
+	DB           *gorm.DB
+	StorageStore *StorageStore
+}
+
+func NewMultipartUploadStore(db *gorm.DB, storageStore *StorageStore) *MultipartUploadStore {
+	return &MultipartUploadStore{DB: db, StorageStore: storageStore}
+}
+
+// MultipartUpload is a large file being uploaded straight to S3 in parts
+// through presigned URLs. Its File is only created once every part is in
+// and S3 confirms the object's size.
+type MultipartUpload struct {
+	ID          uuid.UUID          `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	UserID      uuid.UUID          `gorm:"column:user_id;type:uuid;index" json:"userId"`
+	Name        string             `gorm:"column:name" json:"name"`
+	ContentType string             `gorm:"column:content_type" json:"contentType"`
+	Size        int64              `gorm:"column:size" json:"size"`
+	PartSize    int64              `gorm:"column:part_size" json:"partSize"`
+	PartCount   int                `gorm:"column:part_count" json:"partCount"`
+	Bucket      string             `gorm:"column:bucket" json:"-"`
+	Key         string             `gorm:"column:key" json:"-"`
+	S3UploadID  string             `gorm:"column:s3_upload_id" json:"-"`
+	Status      utils.UploadStatus `gorm:"column:status;index" json:"status"`
+	FileID      *uuid.UUID         `gorm:"column:file_id;type:uuid" json:"fileId"`
+	ExpiresAt   time.Time          `gorm:"column:expires_at" json:"expiresAt"`
+	CreatedAt   time.Time          `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+	UpdatedAt   time.Time          `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt"`
+}
+
+// UploadedPart is a part S3 has received.
+type UploadedPart struct {
+	PartNumber int    `json:"partNumber"`
+	Size       int64  `json:"size"`
+	ETag       string `json:"etag"`
+}
+
+// multipartPartSize picks a part size for a file of size bytes that stays
+// within multipartMaxParts, in whole MiB.
+func multipartPartSize(size int64) int64 {
+	partSize := int64(multipartMinPartSize)
+	if needed := (size + multipartMaxParts - 1) / multipartMaxParts; needed > partSize {
+		partSize = (needed + 1<<20 - 1) &^ (1<<20 - 1)
+	}
+	return partSize
+}
+
+// expectedPartSize is the size part number must have: PartSize for every
+// part but the last, which holds the rest.
+func (mu *MultipartUpload) expectedPartSize(number int) int64 {
+	if number == mu.PartCount {
+		return mu.Size - int64(mu.PartCount-1)*mu.PartSize
+	}
+	return mu.PartSize
+}
+
+func (mu *MultipartUpload) isLive() bool {
+	return mu.Status == utils.UploadStatusPending && time.Now().Before(mu.ExpiresAt)
+}
+
+// Create starts a multipart upload for user. Its declared size counts
+// against the user's storage quota until it completes or is aborted.
+func (mus *MultipartUploadStore) Create(user *User, body dto.MultipartUploadCreateDto) (*MultipartUpload, error) {
+	if body.Size > multipartMaxSize {
+		return nil, utils.ErrUploadTooLarge
+	}
+
+	partSize := multipartPartSize(body.Size)
+	upload := MultipartUpload{
+		ID:          uuid.New(),
+		UserID:      user.ID,
+		Name:        body.Name,
+		ContentType: body.ContentType,
+		Size:        body.Size,
+		PartSize:    partSize,
+		PartCount:   int((body.Size + partSize - 1) / partSize),
+		Bucket:      utils.S3BucketName,
+		Status:      utils.UploadStatusPending,
+		ExpiresAt:   time.Now().Add(multipartUploadTTL),
+	}
+	// the key never contains the client's file name
+	upload.Key = fmt.Sprintf("%s/%s", user.ID, upload.ID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), multipartS3Timeout)
+	defer cancel()
+
+	// started before the transaction so the pool's lock is not held across
+	// the round trip to S3
+	s3UploadID, err := utils.S3NewMultipartUpload(ctx, upload.Bucket, upload.Key, upload.ContentType)
+	if err != nil {
+		return nil, err
+	}
+	upload.S3UploadID = s3UploadID
+
+	err = mus.DB.Transaction(func(tx *gorm.DB) error {
+		if err := mus.StorageStore.Reserve(tx, user, upload.Size); err != nil {
+			return err
+		}
+		return tx.Create(&upload).Error
+	})
+	if err != nil {
+		if abortErr := utils.S3AbortMultipartUpload(ctx, upload.Bucket, upload.Key, s3UploadID); abortErr != nil {
+			log.Printf("MultipartUpload Error: failed to abort %s: %v", upload.Key, abortErr)
+		}
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// Get returns the upload with id if it belongs to userID.
+func (mus *MultipartUploadStore) Get(userID, id uuid.UUID) (*MultipartUpload, error) {
+	var upload MultipartUpload
+	err := mus.DB.Where("id = ? AND user_id = ?", id, userID).First(&upload).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrUploadNotFound
+		}
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// PartURLs returns a presigned PUT URL for each of partNumbers. URLs can be
+// requested again, for instance to retry a part after a failure.
+func (mus *MultipartUploadStore) PartURLs(upload *MultipartUpload, partNumbers []int) (map[int]string, error) {
+	if !upload.isLive() {
+		return nil, utils.ErrUploadNotPending
+	}
+
+	expiry := min(multipartPartURLTTL, time.Until(upload.ExpiresAt))
+
+	ctx, cancel := context.WithTimeout(context.Background(), multipartS3Timeout)
+	defer cancel()
+
+	urls := make(map[int]string, len(partNumbers))
+	for _, number := range partNumbers {
+		if number < 1 || number > upload.PartCount {
+			return nil, utils.ErrUploadInvalidPart
+		}
+		u, err := utils.S3PresignUploadPart(ctx, upload.Bucket, upload.Key, upload.S3UploadID, number, expiry)
+		if err != nil {
+			return nil, err
+		}
+		urls[number] = u
+	}
+	return urls, nil
+}
+
+// UploadedParts lists the parts S3 has received so a client can resume an
+// interrupted upload with the missing ones.
+func (mus *MultipartUploadStore) UploadedParts(upload *MultipartUpload) ([]UploadedPart, error) {
+	if upload.Status != utils.UploadStatusPending {
+		return []UploadedPart{}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), multipartS3Timeout)
+	defer cancel()
+
+	parts, err := utils.S3ListUploadedParts(ctx, upload.Bucket, upload.Key, upload.S3UploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	uploaded := make([]UploadedPart, 0, len(parts))
+	for _, part := range parts {
+		uploaded = append(uploaded, UploadedPart{PartNumber: part.PartNumber, Size: part.Size, ETag: part.ETag})
+	}
+	return uploaded, nil
+}
+
+// Complete assembles the upload once S3 holds every part at the expected
+// size, checks the resulting object and creates its File. A part with the
+// wrong size can be uploaded again before retrying. Once S3 has assembled
+// the object the upload cannot be completed again, so the object is deleted
+// if its File is not created.
+func (mus *MultipartUploadStore) Complete(upload *MultipartUpload) (*File, error) {
+	var file File
+	var assembled bool
+
+	err := mus.DB.Transaction(func(tx *gorm.DB) error {
+		// the lock keeps concurrent requests from completing the upload twice
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", upload.ID).First(upload).Error
+		if err != nil {
+			return err
+		}
+		if !upload.isLive() {
+			return utils.ErrUploadNotPending
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), multipartS3Timeout)
+		defer cancel()
+
+		parts, err := utils.S3ListUploadedParts(ctx, upload.Bucket, upload.Key, upload.S3UploadID)
+		if err != nil {
+			return err
+		}
+		complete, err := upload.completeParts(parts)
+		if err != nil {
+			return err
+		}
+
+		if _, err := utils.S3CompleteMultipartUpload(ctx, upload.Bucket, upload.Key, upload.S3UploadID, complete); err != nil {
+			return err
+		}
+		assembled = true
+
+		info, err := utils.S3StatObject(ctx, upload.Bucket, upload.Key)
+		if err != nil {
+			return err
+		}
+		if info.Size != upload.Size {
+			if err := utils.S3RemoveObject(ctx, upload.Bucket, upload.Key); err != nil {
+				return err
+			}
+			if err := tx.Model(upload).Update("status", utils.UploadStatusAborted).Error; err != nil {
+				return err
+			}
+			// committed so the upload is not completed again
+			return nil
+		}
+
+		file = File{
+			UserID: upload.UserID,
+			Name:   upload.Name,
+			Type:   upload.ContentType,
+			Etag:   info.ETag,
+			Size:   info.Size,
+			Key:    upload.Key,
+			Bucket: upload.Bucket,
+		}
+		if err := tx.Create(&file).Error; err != nil {
+			return err
+		}
+
+		upload.Status = utils.UploadStatusCompleted
+		upload.FileID = &file.ID
+		return tx.Model(upload).Updates(map[string]interface{}{
+			"status":  upload.Status,
+			"file_id": upload.FileID,
+		}).Error
+	})
+	if err != nil {
+		if assembled {
+			ctx, cancel := context.WithTimeout(context.Background(), multipartS3Timeout)
+			defer cancel()
+			if removeErr := utils.S3RemoveObject(ctx, upload.Bucket, upload.Key); removeErr != nil {
+				return nil, errors.Join(err, fmt.Errorf("failed to delete object %s: %w", upload.Key, removeErr))
+			}
+		}
+		return nil, err
+	}
+
+	if upload.Status == utils.UploadStatusAborted {
+		return nil, utils.ErrUploadSizeMismatch
+	}
+	return &file, nil
+}
+
+// completeParts checks that parts holds every part of the upload at the
+// expected size and returns them in order.
+func (mu *MultipartUpload) completeParts(parts []minio.ObjectPart) ([]minio.CompletePart, error) {
+	received := make(map[int]minio.ObjectPart, len(parts))
+	for _, part := range parts {
+		received[part.PartNumber] = part
+	}
+
+	var missing []int
+	complete := make([]minio.CompletePart, 0, mu.PartCount)
+	for number := 1; number <= mu.PartCount; number++ {
+		part, ok := received[number]
+		if !ok {
+			missing = append(missing, number)
+			continue
+		}
+		if part.Size != mu.expectedPartSize(number) {
+			return nil, utils.ErrUploadSizeMismatch
+		}
+		complete = append(complete, minio.CompletePart{PartNumber: number, ETag: part.ETag})
+	}
+	if len(missing) > 0 {
+		return nil, &utils.UploadIncompleteError{MissingParts: missing}
+	}
+	return complete, nil
+}
+
+// Abort cancels a pending upload and discards its parts.
+func (mus *MultipartUploadStore) Abort(upload *MultipartUpload) error {
+	return mus.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", upload.ID).First(upload).Error
+		if err != nil {
+			return err
+		}
+		if upload.Status != utils.UploadStatusPending {
+			return utils.ErrUploadNotPending
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), multipartS3Timeout)
+		defer cancel()
+
+		err = utils.S3AbortMultipartUpload(ctx, upload.Bucket, upload.Key, upload.S3UploadID)
+		if err != nil {
+			if minio.ToErrorResponse(err).Code != "NoSuchUpload" {
+				return err
+			}
+			// S3 discarded the upload through a lifecycle rule, or assembled
+			// it for a Complete whose File was never created; a pending
+			// upload has no File, so any object at its key is unowned
+			if err := utils.S3RemoveObject(ctx, upload.Bucket, upload.Key); err != nil {
+				return err
+			}
+		}
+
+		upload.Status = utils.UploadStatusAborted
+		return tx.Model(upload).Update("status", upload.Status).Error
+	})
+}
+
+// AbortExpired aborts up to limit pending uploads that outlived
+// multipartUploadTTL and returns how many were aborted.
+func (mus *MultipartUploadStore) AbortExpired(limit int) (int, error) {
+	var uploads []MultipartUpload
+	err := mus.DB.Where("status = ? AND expires_at < ?", utils.UploadStatusPending, time.Now()).
+		Order("expires_at").
+		Limit(limit).
+		Find(&uploads).Error
+	if err != nil {
+		return 0, err
+	}
+
+	aborted := 0
+	for i := range uploads {
+		if err := mus.Abort(&uploads[i]); err != nil {
+			if errors.Is(err, utils.ErrUploadNotPending) {
+				continue
+			}
+			return aborted, fmt.Errorf("failed to abort upload %s: %w", uploads[i].ID, err)
+		}
+		aborted++
+	}
+	return aborted, nil
+}
+
+// RunWorker aborts stale uploads every interval until ctx is cancelled.
+func (mus *MultipartUploadStore) RunWorker(ctx context.Context, logger *log.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := mus.AbortExpired(100); err != nil {
+				logger.Printf("MultipartUpload worker error: %v", err)
+			}
+		}
+	}
+}
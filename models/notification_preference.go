@@ -0,0 +1,143 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultNotificationPreferences applies to categories a user never changed.
+// Marketing is opt-in.
+var defaultNotificationPreferences = map[utils.NotificationCategory]bool{
+	utils.NotificationCategorySecurity:       true,
+	utils.NotificationCategoryBilling:        true,
+	utils.NotificationCategoryProductUpdates: true,
+	utils.NotificationCategoryMarketing:      false,
+}
+
+type NotificationPreferenceStore struct {
+	DB *gorm.DB
+}
+
+func NewNotificationPreferenceStore(db *gorm.DB) *NotificationPreferenceStore {
+	return &NotificationPreferenceStore{DB: db}
+}
+
+// NotificationPreference stores a user's choice for one category. Only
+// categories the user has changed have a row.
+type NotificationPreference struct {
+	UserID    uuid.UUID                  `gorm:"type:uuid;primaryKey" json:"userId"`
+	Category  utils.NotificationCategory `gorm:"column:category;primaryKey" json:"category"`
+	Enabled   bool                       `gorm:"column:enabled" json:"enabled"`
+	UpdatedAt time.Time                  `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt"`
+}
+
+// Get returns the user's preference for every category.
+func (nps *NotificationPreferenceStore) Get(userID uuid.UUID) (map[utils.NotificationCategory]bool, error) {
+	var rows []NotificationPreference
+	if err := nps.DB.Where("user_id = ?", userID).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	prefs := make(map[utils.NotificationCategory]bool, len(defaultNotificationPreferences))
+	for category, enabled := range defaultNotificationPreferences {
+		prefs[category] = enabled
+	}
+	for _, row := range rows {
+		if row.Category.IsOptional() {
+			prefs[row.Category] = row.Enabled
+		}
+	}
+	return prefs, nil
+}
+
+// IsEnabled reports whether the user currently accepts emails of category.
+// tx may be nil to use the store's connection.
+func (nps *NotificationPreferenceStore) IsEnabled(tx *gorm.DB, userID uuid.UUID, category utils.NotificationCategory) (bool, error) {
+	if !category.IsOptional() {
+		return true, nil
+	}
+	if tx == nil {
+		tx = nps.DB
+	}
+
+	var rows []NotificationPreference
+	if err := tx.Where("user_id = ? AND category = ?", userID, category).Limit(1).Find(&rows).Error; err != nil {
+		return false, err
+	}
+	if len(rows) == 0 {
+		return defaultNotificationPreferences[category], nil
+	}
+	return rows[0].Enabled, nil
+}
+
+// Set stores the given preferences. Security cannot be turned off and is ignored.
+func (nps *NotificationPreferenceStore) Set(userID uuid.UUID, prefs map[utils.NotificationCategory]bool) error {
+	var rows []NotificationPreference
+	for category, enabled := range prefs {
+		if !category.IsOptional() {
+			continue
+		}
+		rows = append(rows, NotificationPreference{UserID: userID, Category: category, Enabled: enabled})
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	return nps.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "category"}},
+		DoUpdates: clause.AssignmentColumns([]string{"enabled", "updated_at"}),
+	}).Create(&rows).Error
+}
+
+// UnsubscribeToken returns a token that turns category off for userID without
+// a login. Tokens do not expire, as mail clients may use the link at any time.
+func UnsubscribeToken(userID uuid.UUID, category utils.NotificationCategory) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(userID.String() + ":" + string(category)))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(unsubscribeSignature(payload))
+}
+
+// ParseUnsubscribeToken verifies a token created by UnsubscribeToken.
+func ParseUnsubscribeToken(token string) (uuid.UUID, utils.NotificationCategory, error) {
+	payload, signature, found := strings.Cut(token, ".")
+	if !found {
+		return uuid.Nil, "", utils.ErrInvalidUnsubscribe
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, unsubscribeSignature(payload)) {
+		return uuid.Nil, "", utils.ErrInvalidUnsubscribe
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return uuid.Nil, "", utils.ErrInvalidUnsubscribe
+	}
+	id, category, _ := strings.Cut(string(decoded), ":")
+
+	userID, err := uuid.Parse(id)
+	if err != nil || !utils.NotificationCategory(category).IsOptional() {
+		return uuid.Nil, "", utils.ErrInvalidUnsubscribe
+	}
+	return userID, utils.NotificationCategory(category), nil
+}
+
+// UnsubscribeURL is the RFC 8058 one-click endpoint for userID and category.
+func UnsubscribeURL(userID uuid.UUID, category utils.NotificationCategory) string {
+	return fmt.Sprintf("%s/notifications/unsubscribe?token=%s", strings.TrimRight(utils.GetEnv("API_URL", false), "/"), url.QueryEscape(UnsubscribeToken(userID, category)))
+}
+
+func unsubscribeSignature(payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(utils.GetEnv("NOTIFICATIONS_HMAC_SECRET", false)))
+	mac.Write([]byte("unsubscribe:" + payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const oauthFlowTTL = 10 * time.Minute
+
+type OAuthFlowStore struct {
+	DB *gorm.DB
+}
+
+func NewOAuthFlowStore(db *gorm.DB) *OAuthFlowStore {
+	return &OAuthFlowStore{DB: db}
+}
+
+// OAuthFlow is an OAuth sign-in or account link between the redirect to the
+// provider and its callback. Only a SHA-256 of the state is stored.
+type OAuthFlow struct {
+	ID           uuid.UUID             `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	Provider     string                `gorm:"column:provider" json:"provider"`
+	StateHash    string                `gorm:"column:state_hash;uniqueIndex" json:"-"`
+	Nonce        string                `gorm:"column:nonce" json:"-"`
+	CodeVerifier utils.EncryptedString `gorm:"column:code_verifier" json:"-"`
+	// LinkUserID is set when a signed-in user is linking the provider
+	LinkUserID *uuid.UUID `gorm:"column:link_user_id;type:uuid" json:"linkUserId"`
+	ExpiresAt  time.Time  `gorm:"column:expires_at;index" json:"expiresAt"`
+	CreatedAt  time.Time  `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+}
+
+func hashOAuthState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create stores a flow for state. Expired flows are removed on the way.
+func (ofs *OAuthFlowStore) Create(provider, state, nonce, codeVerifier string, linkUserID *uuid.UUID) error {
+	if err := ofs.DB.Where("expires_at < ?", time.Now()).Delete(&OAuthFlow{}).Error; err != nil {
+		return err
+	}
+
+	return ofs.DB.Create(&OAuthFlow{
+		Provider:     provider,
+		StateHash:    hashOAuthState(state),
+		Nonce:        nonce,
+		CodeVerifier: utils.EncryptedString(codeVerifier),
+		LinkUserID:   linkUserID,
+		ExpiresAt:    time.Now().Add(oauthFlowTTL),
+	}).Error
+}
+
+// Take loads and deletes the live flow for provider and state, so that each
+// callback can be completed once.
+func (ofs *OAuthFlowStore) Take(provider, state string) (*OAuthFlow, error) {
+	var flow OAuthFlow
+	err := ofs.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("state_hash = ? AND provider = ? AND expires_at > ?", hashOAuthState(state), provider, time.Now()).
+			First(&flow).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return utils.ErrOAuthStateInvalid
+			}
+			return err
+		}
+
+		result := tx.Where("id = ?", flow.ID).Delete(&OAuthFlow{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return utils.ErrOAuthStateInvalid
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &flow, nil
+}
@@ -0,0 +1,168 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	oneTimeTokenLength      = 48
+	oneTimeTokenMaxAttempts = 5
+	// used and expired tokens are kept this long for support lookups
+	oneTimeTokenRetention = 7 * 24 * time.Hour
+)
+
+type OneTimeTokenStore struct {
+	DB *gorm.DB
+}
+
+func NewOneTimeTokenStore(db *gorm.DB) *OneTimeTokenStore {
+	return &OneTimeTokenStore{DB: db}
+}
+
+// OneTimeToken is a single-use secret emailed to a user for one purpose,
+// such as verifying their email or resetting their password. Only a SHA-256
+// of the secret is stored. A user has at most one live token per purpose,
+// and every wrong guess at it counts against its attempts.
+type OneTimeToken struct {
+	ID          uuid.UUID                 `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	UserID      uuid.UUID                 `gorm:"column:user_id;type:uuid;index:idx_one_time_tokens_user_purpose" json:"userId"`
+	Purpose     utils.OneTimeTokenPurpose `gorm:"column:purpose;index:idx_one_time_tokens_user_purpose" json:"purpose"`
+	SecretHash  string                    `gorm:"column:secret_hash" json:"-"`
+	Attempts    int                       `gorm:"column:attempts" json:"attempts"`
+	MaxAttempts int                       `gorm:"column:max_attempts" json:"maxAttempts"`
+	ExpiresAt   time.Time                 `gorm:"column:expires_at" json:"expiresAt"`
+	ConsumedAt  *time.Time                `gorm:"column:consumed_at" json:"consumedAt"`
+	CreatedAt   time.Time                 `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+}
+
+func hashOneTimeToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Issue creates a token for userID and purpose that expires after ttl and
+// returns its secret, within tx. Earlier unused tokens for the same purpose
+// stop working.
+func (ots *OneTimeTokenStore) Issue(tx *gorm.DB, userID uuid.UUID, purpose utils.OneTimeTokenPurpose, ttl time.Duration) (string, error) {
+	secret, err := GetAlphaNumString(oneTimeTokenLength, "alphanum")
+	if err != nil {
+		return "", err
+	}
+
+	if err := ots.Revoke(tx, userID, purpose); err != nil {
+		return "", err
+	}
+
+	token := OneTimeToken{
+		UserID:      userID,
+		Purpose:     purpose,
+		SecretHash:  hashOneTimeToken(secret),
+		MaxAttempts: oneTimeTokenMaxAttempts,
+		ExpiresAt:   time.Now().Add(ttl),
+	}
+	if err := tx.Create(&token).Error; err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// Revoke stops every unused token of userID for purpose from working, within tx.
+func (ots *OneTimeTokenStore) Revoke(tx *gorm.DB, userID uuid.UUID, purpose utils.OneTimeTokenPurpose) error {
+	now := time.Now()
+	return tx.Model(&OneTimeToken{}).
+		Where("user_id = ? AND purpose = ? AND consumed_at IS NULL", userID, purpose).
+		Update("consumed_at", &now).Error
+}
+
+// Consume checks secret against the live token of userID for purpose. On a
+// match the token is used up and fn runs in the same transaction, so the
+// token stays live if fn fails. A wrong secret counts against the token's
+// attempts.
+func (ots *OneTimeTokenStore) Consume(userID uuid.UUID, purpose utils.OneTimeTokenPurpose, secret string, fn func(tx *gorm.DB) error) error {
+	var token OneTimeToken
+	valid := false
+
+	err := ots.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND purpose = ? AND consumed_at IS NULL AND expires_at > ?", userID, purpose, time.Now()).
+			Order("created_at DESC").
+			First(&token).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return utils.ErrOneTimeTokenInvalid
+			}
+			return err
+		}
+
+		if token.Attempts >= token.MaxAttempts {
+			return utils.ErrOneTimeTokenExhausted
+		}
+
+		token.Attempts++
+		updates := map[string]interface{}{"attempts": token.Attempts}
+
+		valid = subtle.ConstantTimeCompare([]byte(hashOneTimeToken(secret)), []byte(token.SecretHash)) == 1
+		if valid {
+			now := time.Now()
+			updates["consumed_at"] = &now
+		}
+
+		if err := tx.Model(&OneTimeToken{}).Where("id = ?", token.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		if !valid {
+			// a wrong secret is reported after the transaction so the attempt is committed
+			return nil
+		}
+		return fn(tx)
+	})
+	if err != nil {
+		return err
+	}
+
+	if !valid {
+		if token.Attempts >= token.MaxAttempts {
+			return utils.ErrOneTimeTokenExhausted
+		}
+		return utils.ErrOneTimeTokenInvalid
+	}
+	return nil
+}
+
+// Prune deletes tokens that were used or expired more than
+// oneTimeTokenRetention ago.
+func (ots *OneTimeTokenStore) Prune() (int64, error) {
+	cutoff := time.Now().Add(-oneTimeTokenRetention)
+	result := ots.DB.
+		Where("expires_at < ? OR consumed_at < ?", cutoff, cutoff).
+		Delete(&OneTimeToken{})
+	return result.RowsAffected, result.Error
+}
+
+// RunWorker prunes old tokens every interval until ctx is cancelled.
+func (ots *OneTimeTokenStore) RunWorker(ctx context.Context, logger *log.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := ots.Prune(); err != nil {
+				logger.Printf("OneTimeToken worker error: %v", err)
+			}
+		}
+	}
+}
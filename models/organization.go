@@ -0,0 +1,238 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type OrganizationStore struct {
+	DB *gorm.DB
+}
+
+func NewOrganizationStore(db *gorm.DB) *OrganizationStore {
+	return &OrganizationStore{DB: db}
+}
+
+// Organization is a business customer whose users may sign in through the
+// organization's identity provider.
+type Organization struct {
+	ID      uuid.UUID            `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	Name    string               `gorm:"column:name" json:"name"`
+	Domains []OrganizationDomain `gorm:"foreignKey:OrganizationID;references:ID" json:"domains"`
+	// AllowSCIMAdmins lets the organization's SCIM client grant the admin role
+	AllowSCIMAdmins bool `gorm:"column:allow_scim_admins" json:"allowScimAdmins"`
+	// AllowSSOAdmins lets the organization's SAML assertions grant the admin role
+	AllowSSOAdmins bool      `gorm:"column:allow_sso_admins" json:"allowSsoAdmins"`
+	CreatedAt      time.Time `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+	UpdatedAt      time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt"`
+}
+
+func (Organization) TableName() string {
+	return "organizations"
+}
+
+// OrganizationDomain is an email domain owned by an organization. Its SAML
+// connection may only sign in addresses at the organization's domains, and
+// EnforceSSO refuses password login for them.
+type OrganizationDomain struct {
+	ID             uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	OrganizationID uuid.UUID `gorm:"column:organization_id;type:uuid;index" json:"organizationId"`
+	Domain         string    `gorm:"column:domain;uniqueIndex" json:"domain"`
+	EnforceSSO     bool      `gorm:"column:enforce_sso" json:"enforceSso"`
+	CreatedAt      time.Time `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+	UpdatedAt      time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt"`
+}
+
+func (OrganizationDomain) TableName() string {
+	return "organization_domains"
+}
+
+// emailDomain returns the lower cased domain of email, or "" if it has none.
+func emailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(email[at+1:]))
+}
+
+func (ors *OrganizationStore) Create(name string) (*Organization, error) {
+	org := &Organization{Name: name, Domains: []OrganizationDomain{}}
+	if err := ors.DB.Create(org).Error; err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+func (ors *OrganizationStore) List() ([]Organization, error) {
+	var orgs []Organization
+	err := ors.DB.Preload("Domains").Order("name ASC").Find(&orgs).Error
+	return orgs, err
+}
+
+func (ors *OrganizationStore) Get(id uuid.UUID) (*Organization, error) {
+	var org Organization
+	err := ors.DB.Preload("Domains").Where("id = ?", id).First(&org).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrOrganizationNotFound
+		}
+		return nil, err
+	}
+	return &org, nil
+}
+
+func (ors *OrganizationStore) Update(id uuid.UUID, body *dto.OrganizationUpdateDto) (*Organization, error) {
+	updates := map[string]interface{}{}
+	if body.Name != nil {
+		updates["name"] = *body.Name
+	}
+	if body.AllowSCIMAdmins != nil {
+		updates["allow_scim_admins"] = *body.AllowSCIMAdmins
+	}
+	if body.AllowSSOAdmins != nil {
+		updates["allow_sso_admins"] = *body.AllowSSOAdmins
+	}
+	if len(updates) == 0 {
+		return ors.Get(id)
+	}
+
+	result := ors.DB.Model(&Organization{}).Where("id = ?", id).Updates(updates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, utils.ErrOrganizationNotFound
+	}
+	return ors.Get(id)
+}
+
+// Delete removes the organization with its domains, SAML connection and
+// SCIM tokens and groups. Its users keep their accounts but no longer belong
+// to it.
+func (ors *OrganizationStore) Delete(id uuid.UUID) error {
+	return ors.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&User{}).Where("organization_id = ?", id).Update("organization_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", id).Delete(&OrganizationDomain{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", id).Delete(&SAMLConnection{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", id).Delete(&SCIMToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("group_id IN (?)", tx.Model(&SCIMGroup{}).Select("id").Where("organization_id = ?", id)).Delete(&SCIMGroupMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", id).Delete(&SCIMGroup{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("id = ?", id).Delete(&Organization{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return utils.ErrOrganizationNotFound
+		}
+		return nil
+	})
+}
+
+func (ors *OrganizationStore) AddDomain(orgID uuid.UUID, domain string, enforceSSO bool) (*OrganizationDomain, error) {
+	if _, err := ors.Get(orgID); err != nil {
+		return nil, err
+	}
+
+	record := &OrganizationDomain{
+		OrganizationID: orgID,
+		Domain:         strings.ToLower(strings.TrimSpace(domain)),
+		EnforceSSO:     enforceSSO,
+	}
+	if err := ors.DB.Create(record).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, utils.ErrOrganizationDomainTaken
+		}
+		return nil, err
+	}
+	return record, nil
+}
+
+func (ors *OrganizationStore) SetDomainEnforceSSO(orgID, domainID uuid.UUID, enforceSSO bool) (*OrganizationDomain, error) {
+	var record OrganizationDomain
+	err := ors.DB.Where("id = ? AND organization_id = ?", domainID, orgID).First(&record).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrOrganizationDomainNotFound
+		}
+		return nil, err
+	}
+
+	record.EnforceSSO = enforceSSO
+	if err := ors.DB.Model(&record).Update("enforce_sso", enforceSSO).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (ors *OrganizationStore) RemoveDomain(orgID, domainID uuid.UUID) error {
+	result := ors.DB.Where("id = ? AND organization_id = ?", domainID, orgID).Delete(&OrganizationDomain{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrOrganizationDomainNotFound
+	}
+	return nil
+}
+
+// DomainForEmail returns the organization domain email belongs to.
+func (ors *OrganizationStore) DomainForEmail(email string) (*OrganizationDomain, error) {
+	var record OrganizationDomain
+	err := ors.DB.Where("domain = ?", emailDomain(email)).First(&record).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrOrganizationDomainNotFound
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+// ssoEnforced reports whether email must sign in through SSO: its domain
+// enforces SSO and the organization has an enabled SAML connection. A
+// disabled connection lets its users fall back to their passwords.
+func ssoEnforced(db *gorm.DB, email string) (bool, error) {
+	domain := emailDomain(email)
+	if domain == "" {
+		return false, nil
+	}
+
+	var count int64
+	err := db.Model(&OrganizationDomain{}).
+		Joins("JOIN saml_connections ON saml_connections.organization_id = organization_domains.organization_id").
+		Where("organization_domains.domain = ? AND organization_domains.enforce_sso AND saml_connections.enabled", domain).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// checkSSOSignIn returns utils.ErrSSORequired when email must sign in through
+// SSO. Every sign-in method other than SAML calls it before signing a user in.
+func checkSSOSignIn(db *gorm.DB, email string) error {
+	enforced, err := ssoEnforced(db, email)
+	if err != nil {
+		return err
+	}
+	if enforced {
+		return utils.ErrSSORequired
+	}
+	return nil
+}
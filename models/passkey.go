@@ -0,0 +1,415 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/config"
+	"github.com/21TechLabs/factory-backend/utils"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	passkeyCeremonyTTL = 5 * time.Minute
+	// a user can register at most passkeyMaxPerUser passkeys
+	passkeyMaxPerUser = 20
+)
+
+type PasskeyStore struct {
+	DB        *gorm.DB
+	WebAuthn  *webauthn.WebAuthn
+	UserStore *UserStore
+}
+
+// NewPasskeyStore configures the relying party from WEBAUTHN_RP_ID and the
+// comma separated WEBAUTHN_RP_ORIGINS.
+func NewPasskeyStore(db *gorm.DB, userStore *UserStore) (*PasskeyStore, error) {
+	var origins []string
+	for _, origin := range strings.Split(utils.GetEnv("WEBAUTHN_RP_ORIGINS", false), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          utils.GetEnv("WEBAUTHN_RP_ID", false),
+		RPDisplayName: config.Name,
+		RPOrigins:     origins,
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        webauthn.TimeoutConfig{Enforce: true, Timeout: passkeyCeremonyTTL},
+			Registration: webauthn.TimeoutConfig{Enforce: true, Timeout: passkeyCeremonyTTL},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &PasskeyStore{DB: db, WebAuthn: wa, UserStore: userStore}, nil
+}
+
+// Passkey is a WebAuthn credential registered by a user.
+type Passkey struct {
+	ID                uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	UserID            uuid.UUID `gorm:"column:user_id;type:uuid;index" json:"userId"`
+	Nickname          string    `gorm:"column:nickname" json:"nickname"`
+	CredentialID      []byte    `gorm:"column:credential_id;uniqueIndex" json:"credentialId"`
+	PublicKey         []byte    `gorm:"column:public_key" json:"-"`
+	AttestationType   string    `gorm:"column:attestation_type" json:"-"`
+	AttestationFormat string    `gorm:"column:attestation_format" json:"-"`
+	AAGUID            []byte    `gorm:"column:aaguid" json:"-"`
+	SignCount         int64     `gorm:"column:sign_count" json:"signCount"`
+	Transports        []string  `gorm:"column:transports;type:jsonb;serializer:json" json:"transports"`
+	Attachment        string    `gorm:"column:attachment" json:"attachment"`
+	UserVerified      bool      `gorm:"column:user_verified" json:"userVerified"`
+	BackupEligible    bool      `gorm:"column:backup_eligible" json:"backupEligible"`
+	BackupState       bool      `gorm:"column:backup_state" json:"backupState"`
+	// set when an assertion arrives with a sign count that did not increase,
+	// a sign the credential was cloned; the passkey can no longer be used
+	SignCountRegressedAt *time.Time `gorm:"column:sign_count_regressed_at" json:"signCountRegressedAt"`
+	LastUsedAt           *time.Time `gorm:"column:last_used_at" json:"lastUsedAt"`
+	CreatedAt            time.Time  `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+}
+
+func (p *Passkey) credential() webauthn.Credential {
+	transports := make([]protocol.AuthenticatorTransport, len(p.Transports))
+	for i, t := range p.Transports {
+		transports[i] = protocol.AuthenticatorTransport(t)
+	}
+
+	return webauthn.Credential{
+		ID:                p.CredentialID,
+		PublicKey:         p.PublicKey,
+		AttestationType:   p.AttestationType,
+		AttestationFormat: p.AttestationFormat,
+		Transport:         transports,
+		Flags: webauthn.CredentialFlags{
+			UserPresent:    true,
+			UserVerified:   p.UserVerified,
+			BackupEligible: p.BackupEligible,
+			BackupState:    p.BackupState,
+		},
+		Authenticator: webauthn.Authenticator{
+			AAGUID:     p.AAGUID,
+			SignCount:  uint32(p.SignCount),
+			Attachment: protocol.AuthenticatorAttachment(p.Attachment),
+		},
+	}
+}
+
+// PasskeyCeremony holds the server side state of a registration or login
+// between its begin and finish requests. Each ceremony can be finished once.
+type PasskeyCeremony struct {
+	ID        uuid.UUID            `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	UserID    *uuid.UUID           `gorm:"column:user_id;type:uuid" json:"userId"`
+	Nickname  string               `gorm:"column:nickname" json:"nickname"`
+	Session   webauthn.SessionData `gorm:"column:session;type:jsonb;serializer:json" json:"-"`
+	ExpiresAt time.Time            `gorm:"column:expires_at;index" json:"expiresAt"`
+	CreatedAt time.Time            `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+}
+
+// passkeyUser adapts a User and its passkeys to webauthn.User. The user
+// handle is the raw user ID.
+type passkeyUser struct {
+	user     *User
+	passkeys []Passkey
+}
+
+func (pu *passkeyUser) WebAuthnID() []byte {
+	return pu.user.ID[:]
+}
+
+func (pu *passkeyUser) WebAuthnName() string {
+	return pu.user.Email
+}
+
+func (pu *passkeyUser) WebAuthnDisplayName() string {
+	if pu.user.Name != "" {
+		return pu.user.Name
+	}
+	return pu.user.Email
+}
+
+func (pu *passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, len(pu.passkeys))
+	for i := range pu.passkeys {
+		credentials[i] = pu.passkeys[i].credential()
+	}
+	return credentials
+}
+
+func (ps *PasskeyStore) List(userID uuid.UUID) ([]Passkey, error) {
+	var passkeys []Passkey
+	err := ps.DB.Where("user_id = ?", userID).Order("created_at ASC").Find(&passkeys).Error
+	return passkeys, err
+}
+
+func (ps *PasskeyStore) Rename(userID, id uuid.UUID, nickname string) (*Passkey, error) {
+	result := ps.DB.Model(&Passkey{}).Where("id = ? AND user_id = ?", id, userID).Update("nickname", nickname)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, utils.ErrPasskeyNotFound
+	}
+
+	var passkey Passkey
+	err := ps.DB.Where("id = ?", id).First(&passkey).Error
+	return &passkey, err
+}
+
+func (ps *PasskeyStore) Delete(userID, id uuid.UUID) error {
+	result := ps.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&Passkey{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrPasskeyNotFound
+	}
+	return nil
+}
+
+// saveCeremony stores session and returns the ceremony ID the client sends
+// back with its finish request. Expired ceremonies are removed on the way.
+func (ps *PasskeyStore) saveCeremony(userID *uuid.UUID, nickname string, session *webauthn.SessionData) (uuid.UUID, error) {
+	if err := ps.DB.Where("expires_at < ?", time.Now()).Delete(&PasskeyCeremony{}).Error; err != nil {
+		return uuid.Nil, err
+	}
+
+	ceremony := PasskeyCeremony{
+		UserID:    userID,
+		Nickname:  nickname,
+		Session:   *session,
+		ExpiresAt: time.Now().Add(passkeyCeremonyTTL),
+	}
+	if err := ps.DB.Create(&ceremony).Error; err != nil {
+		return uuid.Nil, err
+	}
+	return ceremony.ID, nil
+}
+
+// takeCeremony loads and deletes a live ceremony, so that each can be
+// finished at most once.
+func (ps *PasskeyStore) takeCeremony(id uuid.UUID, userID *uuid.UUID) (*PasskeyCeremony, error) {
+	var ceremony PasskeyCeremony
+	err := ps.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("id = ? AND expires_at > ?", id, time.Now()).First(&ceremony).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return utils.ErrPasskeyCeremonyInvalid
+			}
+			return err
+		}
+
+		result := tx.Where("id = ?", id).Delete(&PasskeyCeremony{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return utils.ErrPasskeyCeremonyInvalid
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// registration ceremonies belong to the user that started them
+	if (ceremony.UserID == nil) != (userID == nil) || (userID != nil && *ceremony.UserID != *userID) {
+		return nil, utils.ErrPasskeyCeremonyInvalid
+	}
+	return &ceremony, nil
+}
+
+// BeginRegistration starts adding a passkey to user. The returned options are
+// passed to navigator.credentials.create() and the ceremony ID to
+// FinishRegistration.
+func (ps *PasskeyStore) BeginRegistration(user *User, nickname string) (*protocol.CredentialCreation, uuid.UUID, error) {
+	passkeys, err := ps.List(user.ID)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	if len(passkeys) >= passkeyMaxPerUser {
+		return nil, uuid.Nil, utils.ErrPasskeyLimitReached
+	}
+
+	pu := &passkeyUser{user: user, passkeys: passkeys}
+	exclusions := make([]protocol.CredentialDescriptor, len(passkeys))
+	for i := range passkeys {
+		credential := passkeys[i].credential()
+		exclusions[i] = credential.Descriptor()
+	}
+
+	creation, session, err := ps.WebAuthn.BeginRegistration(pu,
+		// discoverable credentials are what allow login without a username
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+		webauthn.WithExclusions(exclusions),
+	)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	ceremonyID, err := ps.saveCeremony(&user.ID, nickname, session)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	return creation, ceremonyID, nil
+}
+
+// FinishRegistration verifies the attestation in r against the ceremony and
+// stores the new passkey.
+func (ps *PasskeyStore) FinishRegistration(user *User, ceremonyID uuid.UUID, r *http.Request) (*Passkey, error) {
+	ceremony, err := ps.takeCeremony(ceremonyID, &user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	passkeys, err := ps.List(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := ps.WebAuthn.FinishRegistration(&passkeyUser{user: user, passkeys: passkeys}, ceremony.Session, r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrPasskeyVerificationFailed, err)
+	}
+
+	transports := make([]string, len(credential.Transport))
+	for i, t := range credential.Transport {
+		transports[i] = string(t)
+	}
+
+	nickname := ceremony.Nickname
+	if nickname == "" {
+		nickname = fmt.Sprintf("Passkey %d", len(passkeys)+1)
+	}
+
+	passkey := Passkey{
+		UserID:            user.ID,
+		Nickname:          nickname,
+		CredentialID:      credential.ID,
+		PublicKey:         credential.PublicKey,
+		AttestationType:   credential.AttestationType,
+		AttestationFormat: credential.AttestationFormat,
+		AAGUID:            credential.Authenticator.AAGUID,
+		SignCount:         int64(credential.Authenticator.SignCount),
+		Transports:        transports,
+		Attachment:        string(credential.Authenticator.Attachment),
+		UserVerified:      credential.Flags.UserVerified,
+		BackupEligible:    credential.Flags.BackupEligible,
+		BackupState:       credential.Flags.BackupState,
+	}
+	if err := ps.DB.Create(&passkey).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, utils.ErrPasskeyAlreadyRegistered
+		}
+		return nil, err
+	}
+	return &passkey, nil
+}
+
+// BeginLogin starts a discoverable login; the authenticator picks the
+// account, so no user is needed up front.
+func (ps *PasskeyStore) BeginLogin() (*protocol.CredentialAssertion, uuid.UUID, error) {
+	assertion, session, err := ps.WebAuthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	ceremonyID, err := ps.saveCeremony(nil, "", session)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	return assertion, ceremonyID, nil
+}
+
+// FinishLogin verifies the assertion in r and returns the passkey's user.
+// An assertion whose sign count did not increase disables the passkey.
+func (ps *PasskeyStore) FinishLogin(ceremonyID uuid.UUID, r *http.Request) (User, error) {
+	ceremony, err := ps.takeCeremony(ceremonyID, nil)
+	if err != nil {
+		return User{}, err
+	}
+
+	var (
+		user    User
+		passkey *Passkey
+	)
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		userID, err := uuid.FromBytes(userHandle)
+		if err != nil {
+			return nil, utils.ErrPasskeyNotFound
+		}
+		if err := ps.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+			return nil, utils.ErrPasskeyNotFound
+		}
+		passkeys, err := ps.List(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		for i := range passkeys {
+			if string(passkeys[i].CredentialID) == string(rawID) {
+				passkey = &passkeys[i]
+			}
+		}
+		if passkey == nil {
+			return nil, utils.ErrPasskeyNotFound
+		}
+		if passkey.SignCountRegressedAt != nil {
+			return nil, utils.ErrPasskeyDisabled
+		}
+		return &passkeyUser{user: &user, passkeys: passkeys}, nil
+	}
+
+	_, credential, err := ps.WebAuthn.FinishPasskeyLogin(handler, ceremony.Session, r)
+	if err != nil {
+		if errors.Is(err, utils.ErrPasskeyDisabled) {
+			return User{}, utils.ErrPasskeyDisabled
+		}
+		return User{}, fmt.Errorf("%w: %v", utils.ErrPasskeyVerificationFailed, err)
+	}
+
+	if err := checkSSOSignIn(ps.DB, user.Email); err != nil {
+		return User{}, err
+	}
+
+	now := time.Now()
+	if credential.Authenticator.CloneWarning {
+		err := ps.DB.Model(&Passkey{}).Where("id = ?", passkey.ID).Update("sign_count_regressed_at", &now).Error
+		if err != nil {
+			return User{}, err
+		}
+		return User{}, utils.ErrPasskeyDisabled
+	}
+
+	// authenticators without a counter always report zero; any other count
+	// must increase, which also catches two logins racing with the same count
+	result := ps.DB.Model(&Passkey{}).
+		Where("id = ?", passkey.ID).
+		Where("sign_count < ? OR (sign_count = 0 AND ? = 0)", int64(credential.Authenticator.SignCount), int64(credential.Authenticator.SignCount)).
+		Updates(map[string]interface{}{
+			"sign_count":    int64(credential.Authenticator.SignCount),
+			"user_verified": credential.Flags.UserVerified,
+			"backup_state":  credential.Flags.BackupState,
+			"last_used_at":  &now,
+		})
+	if result.Error != nil {
+		return User{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		err := ps.DB.Model(&Passkey{}).Where("id = ?", passkey.ID).Update("sign_count_regressed_at", &now).Error
+		if err != nil {
+			return User{}, err
+		}
+		return User{}, utils.ErrPasskeyDisabled
+	}
+
+	return user, nil
+}
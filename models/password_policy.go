@@ -0,0 +1,68 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/21TechLabs/factory-backend/password"
+	"github.com/21TechLabs/factory-backend/utils"
+)
+
+// NewPasswordPolicyFromEnv builds the password policy from
+// PASSWORD_MIN_LENGTH (default 10), PASSWORD_MAX_LENGTH (default 128),
+// PASSWORD_REQUIRE (a comma separated subset of upper, lower, digit and
+// symbol), PASSWORD_ALLOW_PERSONAL_INFO and PASSWORD_BREACHED_CORPUS_DIR.
+func NewPasswordPolicyFromEnv() (*password.Policy, error) {
+	policy := &password.Policy{
+		MinLength:         10,
+		MaxLength:         128,
+		AllowPersonalInfo: utils.GetEnv("PASSWORD_ALLOW_PERSONAL_INFO", true) == "true",
+	}
+
+	for env, target := range map[string]*int{
+		"PASSWORD_MIN_LENGTH": &policy.MinLength,
+		"PASSWORD_MAX_LENGTH": &policy.MaxLength,
+	} {
+		if value := utils.GetEnv(env, true); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("%s must be a positive number", env)
+			}
+			*target = n
+		}
+	}
+	if policy.MinLength > policy.MaxLength {
+		return nil, fmt.Errorf("PASSWORD_MIN_LENGTH must not exceed PASSWORD_MAX_LENGTH")
+	}
+
+	for _, class := range strings.Split(utils.GetEnv("PASSWORD_REQUIRE", true), ",") {
+		switch strings.TrimSpace(class) {
+		case "":
+		case "upper":
+			policy.RequireUpper = true
+		case "lower":
+			policy.RequireLower = true
+		case "digit":
+			policy.RequireDigit = true
+		case "symbol":
+			policy.RequireSymbol = true
+		default:
+			return nil, fmt.Errorf("unknown PASSWORD_REQUIRE class %q", class)
+		}
+	}
+
+	if dir := utils.GetEnv("PASSWORD_BREACHED_CORPUS_DIR", true); dir != "" {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, fmt.Errorf("PASSWORD_BREACHED_CORPUS_DIR: %w", err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("PASSWORD_BREACHED_CORPUS_DIR %q is not a directory", dir)
+		}
+		policy.Breached = &password.BreachedCorpus{Dir: dir}
+	}
+
+	return policy, nil
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/21TechLabs/factory-backend/utils"
 	"github.com/razorpay/razorpay-go"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var RazorpayClient *razorpay.Client
@@ -242,21 +243,24 @@ func (rpg *RazorpayPG) CaptureOrderPaid(event RazorpayBaseEvent[RazorpayOrderPai
 	txn.PaymentGatewayRedirectURL = ""
 	txn.PaymentGatewayTransactionID = orderId
 
-	if err := rpg.TransactionStore.Update(txn); err != nil {
-		return nil, fmt.Errorf("failed to update transaction: %w", err)
-	}
+	// complete the transaction, credit the tokens and queue the webhook together
+	err = rpg.TransactionStore.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(txn).Error; err != nil {
+			return fmt.Errorf("failed to update transaction: %w", err)
+		}
 
-	// add tokens to user
-	user, err := rpg.UserStore.UserGetById(txn.UserID)
+		result := tx.Model(&User{}).Where("id = ?", txn.UserID).Update("tokens", gorm.Expr("tokens + ?", txn.Token))
+		if result.Error != nil {
+			return fmt.Errorf("failed to update user tokens: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("failed to update user tokens: user %s not found", txn.UserID)
+		}
 
+		return rpg.UserStore.WebhookStore.Emit(tx, txn.UserID, utils.WebhookEventTransactionCompleted, utils.Map{"transaction": txn})
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user by ID: %w", err)
-	}
-
-	user.Tokens += txn.Token
-
-	if err := rpg.UserStore.Update(&user); err != nil {
-		return nil, fmt.Errorf("failed to update user tokens: %w", err)
+		return nil, err
 	}
 
 	rpg.Logger.Printf("Order %s captured successfully for transaction ID %d", orderId, txn.ID)
@@ -337,6 +341,7 @@ func (rpg *RazorpayPG) ProcessSubscriptions(subscription RazorpayBaseEvent[Razor
 		return nil, errors.New("subscription already processed")
 	}
 
+	previousStatus := userSub.SubscriptionStatus
 	userSub.SubscriptionStatus = subEvent
 	userSub.ChargedCount = subEntity.PaidCount
 	userSub.TotalChargedCount = subEntity.TotalCount
@@ -395,9 +400,10 @@ func (rpg *RazorpayPG) ProcessSubscriptions(subscription RazorpayBaseEvent[Razor
 		return nil, utils.ErrInvalidSubscription
 	}
 
-	if err = rpg.UserSubscriptionStore.Save(userSub); err != nil {
-		return nil, err
-	}
+	// a charge is reported on every billing cycle, so it is emitted even
+	// though the status does not change
+	emitSubscription := previousStatus != subEvent || subEvent == utils.SubscriptionStatusCharged
+	emitTransaction := nTxn != nil
 
 	if nTxn == nil {
 		//	fetch txn using subscription id
@@ -405,12 +411,35 @@ func (rpg *RazorpayPG) ProcessSubscriptions(subscription RazorpayBaseEvent[Razor
 		if err != nil {
 			return nil, err
 		}
+		emitTransaction = nTxn.Status != utils.TransactionStatusCompleted
 	}
 
 	nTxn.Status = utils.TransactionStatusCompleted
 	nTxn.UserSubscriptionID = &userSub.ID
 
-	if err := rpg.TransactionStore.Update(nTxn); err != nil {
+	err = rpg.TransactionStore.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(userSub).Error; err != nil {
+			return err
+		}
+		if err := tx.Omit(clause.Associations).Save(nTxn).Error; err != nil {
+			return err
+		}
+
+		if emitSubscription {
+			err := rpg.UserStore.WebhookStore.Emit(tx, userSub.UserID, utils.WebhookEvent(subEvent), utils.Map{
+				"subscription":   userSub,
+				"previousStatus": previousStatus,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if emitTransaction {
+			return rpg.UserStore.WebhookStore.Emit(tx, nTxn.UserID, utils.WebhookEventTransactionCompleted, utils.Map{"transaction": nTxn})
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,160 @@
+package models
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/config"
+	"github.com/21TechLabs/factory-backend/notifications"
+	"github.com/21TechLabs/factory-backend/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	phoneOTPLength      = 6
+	phoneOTPTTL         = 10 * time.Minute
+	phoneOTPMaxAttempts = 5
+	// a number can be sent one code per phoneOTPResendAfter and at most
+	// phoneOTPMaxPerHour codes per hour
+	phoneOTPResendAfter = time.Minute
+	phoneOTPMaxPerHour  = 5
+	phoneOTPSendTimeout = 30 * time.Second
+)
+
+type PhoneOTPStore struct {
+	DB  *gorm.DB
+	SMS notifications.SMSSender
+}
+
+func NewPhoneOTPStore(db *gorm.DB, sms notifications.SMSSender) *PhoneOTPStore {
+	return &PhoneOTPStore{DB: db, SMS: sms}
+}
+
+// PhoneOTP is a one-time code sent by SMS. Only an HMAC of the code is stored.
+type PhoneOTP struct {
+	ID          uuid.UUID        `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	Phone       string           `gorm:"column:phone;index" json:"phone"`
+	Purpose     utils.OTPPurpose `gorm:"column:purpose" json:"purpose"`
+	UserID      *uuid.UUID       `gorm:"column:user_id;type:uuid" json:"userId"`
+	CodeHash    string           `gorm:"column:code_hash" json:"-"`
+	Attempts    int              `gorm:"column:attempts" json:"attempts"`
+	MaxAttempts int              `gorm:"column:max_attempts" json:"maxAttempts"`
+	ExpiresAt   time.Time        `gorm:"column:expires_at" json:"expiresAt"`
+	ConsumedAt  *time.Time       `gorm:"column:consumed_at" json:"consumedAt"`
+	CreatedAt   time.Time        `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+}
+
+func hashPhoneOTP(id uuid.UUID, code string) string {
+	mac := hmac.New(sha256.New, []byte(utils.GetEnv("OTP_HMAC_SECRET", false)))
+	mac.Write([]byte(id.String() + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send creates a code for phone and purpose and texts it. Earlier unused codes
+// for the same number and purpose stop working. userID records who asked for
+// a verification code and is nil for logins.
+func (ps *PhoneOTPStore) Send(ctx context.Context, phone string, purpose utils.OTPPurpose, userID *uuid.UUID) error {
+	code, err := GetAlphaNumString(phoneOTPLength, "num")
+	if err != nil {
+		return err
+	}
+
+	otp := PhoneOTP{
+		ID:          uuid.New(),
+		Phone:       phone,
+		Purpose:     purpose,
+		UserID:      userID,
+		MaxAttempts: phoneOTPMaxAttempts,
+		ExpiresAt:   time.Now().Add(phoneOTPTTL),
+	}
+	otp.CodeHash = hashPhoneOTP(otp.ID, code)
+
+	err = ps.DB.Transaction(func(tx *gorm.DB) error {
+		var recent []PhoneOTP
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("phone = ? AND created_at > ?", phone, time.Now().Add(-time.Hour)).
+			Order("created_at DESC").
+			Find(&recent).Error
+		if err != nil {
+			return err
+		}
+		if len(recent) >= phoneOTPMaxPerHour || (len(recent) > 0 && time.Since(recent[0].CreatedAt) < phoneOTPResendAfter) {
+			return utils.ErrOTPRateLimited
+		}
+
+		now := time.Now()
+		err = tx.Model(&PhoneOTP{}).
+			Where("phone = ? AND purpose = ? AND consumed_at IS NULL", phone, purpose).
+			Update("consumed_at", &now).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&otp).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, phoneOTPSendTimeout)
+	defer cancel()
+
+	body := fmt.Sprintf("%s is your %s code. It expires in %d minutes.", code, config.Name, int(phoneOTPTTL.Minutes()))
+	if _, err := ps.SMS.SendSMS(ctx, phone, body); err != nil {
+		return fmt.Errorf("failed to send sms: %w", err)
+	}
+	return nil
+}
+
+// Verify checks code against the latest live code for phone and purpose and
+// consumes it on success. Every wrong guess counts against the code's attempts.
+func (ps *PhoneOTPStore) Verify(phone string, purpose utils.OTPPurpose, code string) (*PhoneOTP, error) {
+	var otp PhoneOTP
+
+	err := ps.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("phone = ? AND purpose = ? AND consumed_at IS NULL AND expires_at > ?", phone, purpose, time.Now()).
+			Order("created_at DESC").
+			First(&otp).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return utils.ErrOTPInvalid
+			}
+			return err
+		}
+
+		if otp.Attempts >= otp.MaxAttempts {
+			return utils.ErrOTPTooManyAttempts
+		}
+
+		otp.Attempts++
+		updates := map[string]interface{}{"attempts": otp.Attempts}
+
+		valid := hmac.Equal([]byte(hashPhoneOTP(otp.ID, code)), []byte(otp.CodeHash))
+		if valid {
+			now := time.Now()
+			otp.ConsumedAt = &now
+			updates["consumed_at"] = &now
+		}
+
+		// a wrong code is reported after the transaction so the attempt is committed
+		return tx.Model(&PhoneOTP{}).Where("id = ?", otp.ID).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if otp.ConsumedAt == nil {
+		if otp.Attempts >= otp.MaxAttempts {
+			return nil, utils.ErrOTPTooManyAttempts
+		}
+		return nil, utils.ErrOTPInvalid
+	}
+	return &otp, nil
+}
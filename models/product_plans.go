@@ -29,6 +29,7 @@ type ProductPlan struct {
 	PlanDuration     time.Duration         `gorm:"column:plan_duration" json:"planDuration"`
 	PlanType         utils.PlanType        `gorm:"column:plan_type" json:"planType"`
 	Tokens           int64                 `gorm:"column:tokens" json:"tokens"`
+	StorageQuota     int64                 `gorm:"column:storage_quota" json:"storageQuota"`
 	IsActive         bool                  `gorm:"column:is_active" json:"isActive"`
 	Features         utils.StringSlice     `gorm:"type:json;column:features" json:"features"`
 	UpdatedBy        uuid.UUID             `gorm:"column:updated_by" json:"updatedBy"`
@@ -47,6 +48,7 @@ func (pps *ProductPlanStore) CreateProductPlan(plan *dto.ProductPlanCreate, user
 		PlanDuration:     plan.PlanDuration,
 		PlanType:         plan.PlanType,
 		Tokens:           plan.Tokens,
+		StorageQuota:     plan.StorageQuota,
 		IsActive:         plan.IsActive,
 		Features:         plan.Features,
 		PaymentGatewayID: plan.PaymentGatewayID,
@@ -74,6 +76,7 @@ func (pps *ProductPlanStore) UpdateProductPlan(id uuid.UUID, plan *dto.ProductPl
 	ProductPlan.PlanDuration = plan.PlanDuration
 	ProductPlan.PlanType = plan.PlanType
 	ProductPlan.Tokens = plan.Tokens
+	ProductPlan.StorageQuota = plan.StorageQuota
 	ProductPlan.IsActive = plan.IsActive
 	ProductPlan.Features = plan.Features
 	ProductPlan.PaymentGatewayID = plan.PaymentGatewayID
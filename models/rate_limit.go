@@ -0,0 +1,105 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/ratelimit"
+	"github.com/21TechLabs/factory-backend/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RateLimitStore keeps rate limit buckets in Postgres so every instance of
+// the API shares them. It implements ratelimit.Store.
+type RateLimitStore struct {
+	DB *gorm.DB
+}
+
+func NewRateLimitStore(db *gorm.DB) *RateLimitStore {
+	return &RateLimitStore{DB: db}
+}
+
+// NewRateLimitStoreFromEnv returns the store selected by RATE_LIMIT_STORE:
+// "memory" (the default) for a single instance, or "postgres" when several
+// instances must share their limits.
+func NewRateLimitStoreFromEnv(db *gorm.DB) (ratelimit.Store, error) {
+	switch backend := utils.GetEnv("RATE_LIMIT_STORE", true); backend {
+	case "", "memory":
+		return ratelimit.NewMemoryStore(), nil
+	case "postgres":
+		return NewRateLimitStore(db), nil
+	default:
+		return nil, fmt.Errorf("unknown RATE_LIMIT_STORE %q", backend)
+	}
+}
+
+type RateLimitBucket struct {
+	Key       string    `gorm:"column:key;primaryKey" json:"key"`
+	Tokens    float64   `gorm:"column:tokens" json:"tokens"`
+	UpdatedAt time.Time `gorm:"column:updated_at" json:"updatedAt"`
+	// FullAt is when the bucket will have refilled completely; after that the
+	// row is indistinguishable from a missing one and can be deleted
+	FullAt time.Time `gorm:"column:full_at;index" json:"fullAt"`
+}
+
+func (rls *RateLimitStore) Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	var result ratelimit.Result
+	key = limit.Name + ":" + key
+
+	err := rls.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var stored RateLimitBucket
+		found := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("key = ?", key).
+			Limit(1).
+			Find(&stored)
+		if found.Error != nil {
+			return found.Error
+		}
+
+		var current *ratelimit.Bucket
+		if found.RowsAffected > 0 {
+			current = &ratelimit.Bucket{Tokens: stored.Tokens, UpdatedAt: stored.UpdatedAt}
+		}
+
+		now := time.Now()
+		var bucket ratelimit.Bucket
+		bucket, result = ratelimit.Take(current, limit, now)
+
+		// two instances may create the same bucket at once; the loser
+		// overwrites the winner, which at worst lets one extra request through
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&RateLimitBucket{
+			Key:       key,
+			Tokens:    bucket.Tokens,
+			UpdatedAt: bucket.UpdatedAt,
+			FullAt:    now.Add(result.ResetAfter),
+		}).Error
+	})
+
+	return result, err
+}
+
+// Prune deletes buckets that have refilled completely.
+func (rls *RateLimitStore) Prune() (int64, error) {
+	result := rls.DB.Where("full_at < ?", time.Now()).Delete(&RateLimitBucket{})
+	return result.RowsAffected, result.Error
+}
+
+// RunWorker prunes full buckets every interval until ctx is cancelled.
+func (rls *RateLimitStore) RunWorker(ctx context.Context, logger *log.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := rls.Prune(); err != nil {
+				logger.Printf("RateLimit worker error: %v", err)
+			}
+		}
+	}
+}
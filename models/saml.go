@@ -0,0 +1,614 @@
+package models
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/utils"
+	"github.com/crewjam/saml"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const samlRequestTTL = 10 * time.Minute
+
+// Attribute names used when a connection does not configure its own. They
+// match the claim URIs sent by ADFS and Entra ID as well as the plain names
+// most other IdPs use.
+var (
+	samlDefaultEmailAttributes = []string{"email", "mail", "emailaddress", "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress"}
+	samlDefaultNameAttributes  = []string{"name", "displayName", "cn", "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/name"}
+)
+
+type SAMLConnectionStore struct {
+	DB                *gorm.DB
+	UserStore         *UserStore
+	UserIdentityStore *UserIdentityStore
+	OrganizationStore *OrganizationStore
+	// spKey and spCertificate sign authentication requests and decrypt
+	// encrypted assertions; both are nil when SAML_SP_CERTIFICATE is unset
+	spKey         crypto.Signer
+	spCertificate *x509.Certificate
+}
+
+// NewSAMLConnectionStore reads the optional service provider key pair from
+// SAML_SP_CERTIFICATE and SAML_SP_PRIVATE_KEY (PEM).
+func NewSAMLConnectionStore(db *gorm.DB, userStore *UserStore, userIdentityStore *UserIdentityStore, organizationStore *OrganizationStore) (*SAMLConnectionStore, error) {
+	store := &SAMLConnectionStore{
+		DB:                db,
+		UserStore:         userStore,
+		UserIdentityStore: userIdentityStore,
+		OrganizationStore: organizationStore,
+	}
+
+	certPEM := pemFromEnv(utils.GetEnv("SAML_SP_CERTIFICATE", true))
+	keyPEM := pemFromEnv(utils.GetEnv("SAML_SP_PRIVATE_KEY", true))
+	if certPEM == "" && keyPEM == "" {
+		return store, nil
+	}
+
+	pair, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SAML_SP_CERTIFICATE or SAML_SP_PRIVATE_KEY: %w", err)
+	}
+	key, ok := pair.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("SAML_SP_PRIVATE_KEY must be an RSA key")
+	}
+	store.spKey = key
+	store.spCertificate = pair.Leaf
+	return store, nil
+}
+
+// pemFromEnv allows PEM blocks written on one line with literal \n.
+func pemFromEnv(value string) string {
+	return strings.ReplaceAll(value, `\n`, "\n")
+}
+
+// SAMLConnection is an organization's SAML identity provider. The service
+// provider side is served under API_URL/sso/saml/<organization ID>/.
+type SAMLConnection struct {
+	ID             uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	OrganizationID uuid.UUID `gorm:"column:organization_id;type:uuid;uniqueIndex" json:"organizationId"`
+	// IdPMetadata is the metadata XML uploaded for the identity provider
+	IdPMetadata string `gorm:"column:idp_metadata;type:text" json:"-"`
+	IdPEntityID string `gorm:"column:idp_entity_id" json:"idpEntityId"`
+	IdPSSOURL   string `gorm:"column:idp_sso_url" json:"idpSsoUrl"`
+	// SPEntityID overrides the default entity ID, the metadata URL
+	SPEntityID     string `gorm:"column:sp_entity_id" json:"spEntityId"`
+	EmailAttribute string `gorm:"column:email_attribute" json:"emailAttribute"`
+	NameAttribute  string `gorm:"column:name_attribute" json:"nameAttribute"`
+	// RoleAttribute, when set, lets the IdP grant the admin role to users with
+	// a value in AdminRoleValues, if the organization allows SSO admins. An
+	// assertion never takes the admin role away.
+	RoleAttribute   string    `gorm:"column:role_attribute" json:"roleAttribute"`
+	AdminRoleValues []string  `gorm:"column:admin_role_values;type:jsonb;serializer:json" json:"adminRoleValues"`
+	Enabled         bool      `gorm:"column:enabled" json:"enabled"`
+	CreatedAt       time.Time `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+	UpdatedAt       time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt"`
+}
+
+func (SAMLConnection) TableName() string {
+	return "saml_connections"
+}
+
+// SAMLRequest is an authentication request sent to an IdP and not yet
+// answered. It is found by the RelayState echoed back to the ACS, of which
+// only a SHA-256 is stored, and each can be answered once.
+type SAMLRequest struct {
+	ID             uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	OrganizationID uuid.UUID `gorm:"column:organization_id;type:uuid" json:"organizationId"`
+	RequestID      string    `gorm:"column:request_id" json:"requestId"`
+	RelayStateHash string    `gorm:"column:relay_state_hash;uniqueIndex" json:"-"`
+	ExpiresAt      time.Time `gorm:"column:expires_at;index" json:"expiresAt"`
+	CreatedAt      time.Time `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+}
+
+func hashRelayState(relayState string) string {
+	sum := sha256.Sum256([]byte(relayState))
+	return hex.EncodeToString(sum[:])
+}
+
+// SAMLURL returns the URL of a service provider endpoint of the organization.
+func SAMLURL(orgID uuid.UUID, endpoint string) url.URL {
+	u, _ := url.Parse(fmt.Sprintf("%s/sso/saml/%s/%s", strings.TrimSuffix(utils.GetEnv("API_URL", false), "/"), orgID, endpoint))
+	return *u
+}
+
+// parseIdPMetadata reads an EntityDescriptor, or the first IdP of an
+// EntitiesDescriptor, and checks it can be used to sign in.
+func parseIdPMetadata(data string) (*saml.EntityDescriptor, error) {
+	var entity saml.EntityDescriptor
+	if err := xml.Unmarshal([]byte(data), &entity); err != nil {
+		var entities saml.EntitiesDescriptor
+		if xml.Unmarshal([]byte(data), &entities) != nil {
+			return nil, utils.ErrSAMLMetadataInvalid
+		}
+		found := false
+		for _, e := range entities.EntityDescriptors {
+			if len(e.IDPSSODescriptors) > 0 {
+				entity, found = e, true
+				break
+			}
+		}
+		if !found {
+			return nil, utils.ErrSAMLMetadataInvalid
+		}
+	}
+
+	if entity.EntityID == "" || len(entity.IDPSSODescriptors) == 0 {
+		return nil, utils.ErrSAMLMetadataInvalid
+	}
+	hasSigningKey := false
+	for _, descriptor := range entity.IDPSSODescriptors {
+		for _, key := range descriptor.KeyDescriptors {
+			if key.Use == "" || key.Use == "signing" {
+				hasSigningKey = hasSigningKey || len(key.KeyInfo.X509Data.X509Certificates) > 0
+			}
+		}
+	}
+	if !hasSigningKey {
+		return nil, utils.ErrSAMLMetadataInvalid
+	}
+	return &entity, nil
+}
+
+// serviceProvider builds the service provider for conn. Assertions are only
+// accepted in answer to a request we sent, for our ACS URL and audience,
+// and only when the assertion, or the response containing it, is signed by
+// a certificate in the IdP metadata.
+func (scs *SAMLConnectionStore) serviceProvider(conn *SAMLConnection) (*saml.ServiceProvider, error) {
+	idp, err := parseIdPMetadata(conn.IdPMetadata)
+	if err != nil {
+		return nil, err
+	}
+
+	sp := &saml.ServiceProvider{
+		EntityID:          conn.SPEntityID,
+		Key:               scs.spKey,
+		Certificate:       scs.spCertificate,
+		MetadataURL:       SAMLURL(conn.OrganizationID, "metadata"),
+		AcsURL:            SAMLURL(conn.OrganizationID, "acs"),
+		IDPMetadata:       idp,
+		AuthnNameIDFormat: saml.UnspecifiedNameIDFormat,
+		AllowIDPInitiated: false,
+	}
+	if sp.EntityID == "" {
+		sp.EntityID = sp.MetadataURL.String()
+	}
+	if scs.spKey != nil {
+		sp.SignatureMethod = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	}
+	return sp, nil
+}
+
+func (scs *SAMLConnectionStore) Get(orgID uuid.UUID) (*SAMLConnection, error) {
+	var conn SAMLConnection
+	err := scs.DB.Where("organization_id = ?", orgID).First(&conn).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrSAMLConnectionNotFound
+		}
+		return nil, err
+	}
+	return &conn, nil
+}
+
+// getEnabled returns the connection users of the organization sign in with.
+func (scs *SAMLConnectionStore) getEnabled(orgID uuid.UUID) (*SAMLConnection, error) {
+	conn, err := scs.Get(orgID)
+	if err != nil {
+		return nil, err
+	}
+	if !conn.Enabled {
+		return nil, utils.ErrSAMLConnectionNotFound
+	}
+	return conn, nil
+}
+
+// Upsert creates or replaces the organization's connection. The metadata is
+// only replaced when body carries new metadata.
+func (scs *SAMLConnectionStore) Upsert(orgID uuid.UUID, body *dto.SAMLConnectionUpsertDto) (*SAMLConnection, error) {
+	if _, err := scs.OrganizationStore.Get(orgID); err != nil {
+		return nil, err
+	}
+
+	conn, err := scs.Get(orgID)
+	if errors.Is(err, utils.ErrSAMLConnectionNotFound) {
+		if body.IdPMetadata == "" {
+			return nil, utils.ErrSAMLMetadataInvalid
+		}
+		conn = &SAMLConnection{OrganizationID: orgID, Enabled: true}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if body.IdPMetadata != "" {
+		idp, err := parseIdPMetadata(body.IdPMetadata)
+		if err != nil {
+			return nil, err
+		}
+		conn.IdPMetadata = body.IdPMetadata
+		conn.IdPEntityID = idp.EntityID
+		conn.IdPSSOURL = ""
+		for _, descriptor := range idp.IDPSSODescriptors {
+			for _, endpoint := range descriptor.SingleSignOnServices {
+				if endpoint.Binding == saml.HTTPRedirectBinding {
+					conn.IdPSSOURL = endpoint.Location
+				}
+			}
+		}
+		if conn.IdPSSOURL == "" {
+			return nil, utils.ErrSAMLMetadataInvalid
+		}
+	}
+
+	conn.SPEntityID = body.SPEntityID
+	conn.EmailAttribute = body.EmailAttribute
+	conn.NameAttribute = body.NameAttribute
+	conn.RoleAttribute = body.RoleAttribute
+	conn.AdminRoleValues = body.AdminRoleValues
+	if conn.AdminRoleValues == nil {
+		conn.AdminRoleValues = []string{}
+	}
+	if body.Enabled != nil {
+		conn.Enabled = *body.Enabled
+	}
+
+	if err := scs.DB.Save(conn).Error; err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (scs *SAMLConnectionStore) Delete(orgID uuid.UUID) error {
+	result := scs.DB.Where("organization_id = ?", orgID).Delete(&SAMLConnection{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrSAMLConnectionNotFound
+	}
+	return nil
+}
+
+// Metadata returns the service provider metadata to register at the IdP.
+func (scs *SAMLConnectionStore) Metadata(orgID uuid.UUID) ([]byte, error) {
+	conn, err := scs.getEnabled(orgID)
+	if err != nil {
+		return nil, err
+	}
+	sp, err := scs.serviceProvider(conn)
+	if err != nil {
+		return nil, err
+	}
+	metadata := sp.Metadata()
+	// responses are only accepted through the HTTP-POST binding
+	for i := range metadata.SPSSODescriptors {
+		descriptor := &metadata.SPSSODescriptors[i]
+		descriptor.AssertionConsumerServices = slices.DeleteFunc(descriptor.AssertionConsumerServices, func(endpoint saml.IndexedEndpoint) bool {
+			return endpoint.Binding != saml.HTTPPostBinding
+		})
+	}
+	return xml.MarshalIndent(metadata, "", "  ")
+}
+
+// BeginLogin returns the IdP URL that signs the user in and the RelayState
+// the IdP will post back with its answer.
+func (scs *SAMLConnectionStore) BeginLogin(orgID uuid.UUID) (*url.URL, string, error) {
+	conn, err := scs.getEnabled(orgID)
+	if err != nil {
+		return nil, "", err
+	}
+	sp, err := scs.serviceProvider(conn)
+	if err != nil {
+		return nil, "", err
+	}
+
+	relayState, err := GetAlphaNumString(48, "alphanum")
+	if err != nil {
+		return nil, "", err
+	}
+	req, err := sp.MakeAuthenticationRequest(conn.IdPSSOURL, saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		return nil, "", err
+	}
+	redirect, err := req.Redirect(relayState, sp)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := scs.DB.Where("expires_at < ?", time.Now()).Delete(&SAMLRequest{}).Error; err != nil {
+		return nil, "", err
+	}
+	err = scs.DB.Create(&SAMLRequest{
+		OrganizationID: orgID,
+		RequestID:      req.ID,
+		RelayStateHash: hashRelayState(relayState),
+		ExpiresAt:      time.Now().Add(samlRequestTTL),
+	}).Error
+	if err != nil {
+		return nil, "", err
+	}
+	return redirect, relayState, nil
+}
+
+// takeRequest loads and deletes the pending request for relayState.
+func (scs *SAMLConnectionStore) takeRequest(orgID uuid.UUID, relayState string) (*SAMLRequest, error) {
+	var request SAMLRequest
+	err := scs.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("relay_state_hash = ? AND organization_id = ? AND expires_at > ?", hashRelayState(relayState), orgID, time.Now()).
+			First(&request).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return utils.ErrSAMLRequestInvalid
+			}
+			return err
+		}
+
+		result := tx.Where("id = ?", request.ID).Delete(&SAMLRequest{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return utils.ErrSAMLRequestInvalid
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// samlProfile is the user described by a validated assertion.
+type samlProfile struct {
+	NameID string
+	Email  string
+	Name   string
+	// Role is nil unless the connection maps roles
+	Role *UserRole
+}
+
+// attributeValues returns the values of the first attribute whose Name or
+// FriendlyName is one of names.
+func attributeValues(assertion *saml.Assertion, names ...string) []string {
+	for _, name := range names {
+		for _, statement := range assertion.AttributeStatements {
+			for _, attr := range statement.Attributes {
+				if !strings.EqualFold(attr.Name, name) && !strings.EqualFold(attr.FriendlyName, name) {
+					continue
+				}
+				values := make([]string, 0, len(attr.Values))
+				for _, v := range attr.Values {
+					if value := strings.TrimSpace(v.Value); value != "" {
+						values = append(values, value)
+					}
+				}
+				return values
+			}
+		}
+	}
+	return nil
+}
+
+func firstAttributeValue(assertion *saml.Assertion, names ...string) string {
+	if values := attributeValues(assertion, names...); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// profile maps assertion with the connection's attribute names.
+func (conn *SAMLConnection) profile(assertion *saml.Assertion) (*samlProfile, error) {
+	if assertion.Subject == nil || assertion.Subject.NameID == nil || assertion.Subject.NameID.Value == "" {
+		return nil, utils.ErrSAMLAssertionInvalid
+	}
+	profile := &samlProfile{NameID: assertion.Subject.NameID.Value}
+
+	emailAttributes, nameAttributes := samlDefaultEmailAttributes, samlDefaultNameAttributes
+	if conn.EmailAttribute != "" {
+		emailAttributes = []string{conn.EmailAttribute}
+	}
+	if conn.NameAttribute != "" {
+		nameAttributes = []string{conn.NameAttribute}
+	}
+
+	profile.Email = strings.ToLower(firstAttributeValue(assertion, emailAttributes...))
+	if profile.Email == "" && assertion.Subject.NameID.Format == string(saml.EmailAddressNameIDFormat) {
+		profile.Email = strings.ToLower(profile.NameID)
+	}
+	if profile.Email == "" {
+		return nil, utils.ErrSAMLEmailMissing
+	}
+
+	profile.Name = firstAttributeValue(assertion, nameAttributes...)
+	if profile.Name == "" {
+		profile.Name = strings.TrimSpace(firstAttributeValue(assertion, "givenName", "firstName") + " " + firstAttributeValue(assertion, "surname", "sn", "lastName"))
+	}
+
+	if conn.RoleAttribute != "" {
+		role := UserRoleClient
+		for _, value := range attributeValues(assertion, conn.RoleAttribute) {
+			if slices.Contains(conn.AdminRoleValues, value) {
+				role = UserRoleAdmin
+			}
+		}
+		profile.Role = &role
+	}
+	return profile, nil
+}
+
+// FinishLogin validates the SAML response posted to the organization's ACS
+// and returns the user it signs in, provisioning them on first sign-in.
+func (scs *SAMLConnectionStore) FinishLogin(orgID uuid.UUID, samlResponse, relayState string) (User, error) {
+	conn, err := scs.getEnabled(orgID)
+	if err != nil {
+		return User{}, err
+	}
+	sp, err := scs.serviceProvider(conn)
+	if err != nil {
+		return User{}, err
+	}
+
+	request, err := scs.takeRequest(orgID, relayState)
+	if err != nil {
+		return User{}, err
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(samlResponse)
+	if err != nil {
+		return User{}, utils.ErrSAMLAssertionInvalid
+	}
+	assertion, err := sp.ParseXMLResponse(raw, []string{request.RequestID}, sp.AcsURL)
+	if err != nil {
+		var invalid *saml.InvalidResponseError
+		if errors.As(err, &invalid) {
+			err = invalid.PrivateErr
+		}
+		return User{}, fmt.Errorf("%w: %v", utils.ErrSAMLAssertionInvalid, err)
+	}
+
+	profile, err := conn.profile(assertion)
+	if err != nil {
+		return User{}, err
+	}
+	return scs.provision(conn, profile)
+}
+
+// provision returns the user for profile. The IdP may only sign in
+// addresses at its organization's domains, as it is trusted to assert who
+// owns them: an existing account with the address is linked to the IdP and
+// a new one is created otherwise. Later sign-ins find the user by NameID and
+// update their name, email and mapped role from the assertion.
+func (scs *SAMLConnectionStore) provision(conn *SAMLConnection, profile *samlProfile) (User, error) {
+	domain, err := scs.OrganizationStore.DomainForEmail(profile.Email)
+	if err != nil || domain.OrganizationID != conn.OrganizationID {
+		if err != nil && !errors.Is(err, utils.ErrOrganizationDomainNotFound) {
+			return User{}, err
+		}
+		return User{}, utils.ErrSAMLEmailDomainMismatch
+	}
+
+	identityProfile := &OAuthProfile{
+		Provider:       "saml:" + conn.OrganizationID.String(),
+		ProviderUserID: profile.NameID,
+		Email:          profile.Email,
+		EmailVerified:  true,
+		Name:           profile.Name,
+	}
+
+	var user User
+	identity, err := scs.UserIdentityStore.getByProviderUserID(scs.DB, identityProfile.Provider, identityProfile.ProviderUserID)
+	switch {
+	case err == nil:
+		if err := scs.DB.Where("id = ?", identity.UserID).First(&user).Error; err != nil {
+			return User{}, err
+		}
+		err = scs.DB.Transaction(func(tx *gorm.DB) error {
+			if err := scs.UserIdentityStore.refresh(tx, identity, identityProfile); err != nil {
+				return err
+			}
+			return scs.syncUser(tx, &user, conn, profile)
+		})
+		return user, err
+
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return User{}, err
+	}
+
+	user, err = scs.UserStore.UserGetByEmail(profile.Email)
+	switch {
+	case err == nil:
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		user, err = scs.UserStore.UserCreate(dto.UserCreateDto{
+			Name:  profile.Name,
+			Email: profile.Email,
+		})
+		if err != nil {
+			return User{}, err
+		}
+	default:
+		return User{}, err
+	}
+
+	err = scs.DB.Transaction(func(tx *gorm.DB) error {
+		if !user.EmailVerified {
+			// as with OAuth, credentials set up before the address was
+			// proven may belong to someone else
+			if err := scs.UserIdentityStore.takeOverUnverifiedAccount(tx, &user); err != nil {
+				return err
+			}
+		}
+		if err := scs.syncUser(tx, &user, conn, profile); err != nil {
+			return err
+		}
+		return scs.UserIdentityStore.create(tx, newUserIdentity(user.ID, identityProfile))
+	})
+	return user, err
+}
+
+// syncUser copies the IdP's view of user onto the account.
+func (scs *SAMLConnectionStore) syncUser(tx *gorm.DB, user *User, conn *SAMLConnection, profile *samlProfile) error {
+	updates := map[string]interface{}{
+		"organization_id": conn.OrganizationID,
+	}
+	user.OrganizationID = &conn.OrganizationID
+	if profile.Name != "" {
+		updates["name"] = profile.Name
+		user.Name = profile.Name
+	}
+	if profile.Role != nil && *profile.Role == UserRoleAdmin && !user.UserIsAdmin() {
+		// the admin role is platform-wide, so the organization must opt in
+		var org Organization
+		if err := tx.Select("allow_sso_admins").Where("id = ?", conn.OrganizationID).First(&org).Error; err != nil {
+			return err
+		}
+		if org.AllowSSOAdmins {
+			updates["role"] = UserRoleAdmin
+			user.Role = UserRoleAdmin
+		}
+	}
+	if user.Email != profile.Email {
+		// the NameID stays with the person when their address changes
+		updates["email"] = profile.Email
+		user.Email = profile.Email
+	}
+	if err := tx.Model(&User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return utils.ErrSAMLEmailInUse
+		}
+		return err
+	}
+	return nil
+}
+
+// SSOLoginURL returns where a user with email signs in through SSO, and
+// whether the domain requires it.
+func (scs *SAMLConnectionStore) SSOLoginURL(email string) (string, bool, error) {
+	domain, err := scs.OrganizationStore.DomainForEmail(email)
+	if err != nil {
+		return "", false, err
+	}
+	if _, err := scs.getEnabled(domain.OrganizationID); err != nil {
+		if errors.Is(err, utils.ErrSAMLConnectionNotFound) {
+			return "", false, utils.ErrOrganizationDomainNotFound
+		}
+		return "", false, err
+	}
+	loginURL := SAMLURL(domain.OrganizationID, "login")
+	return loginURL.String(), domain.EnforceSSO, nil
+}
@@ -0,0 +1,738 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/scim"
+	"github.com/21TechLabs/factory-backend/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// scimTokenPrefix makes SCIM tokens recognizable, e.g. to secret scanners.
+const scimTokenPrefix = "scim_"
+
+// SCIMStore provisions an organization's users and groups for its identity
+// provider's SCIM client. Users are the organization's members: creating one
+// adopts the account with the same email if it belongs to no organization,
+// and deleting one suspends the account and removes it from the
+// organization, as accounts may hold payments and files.
+type SCIMStore struct {
+	DB                *gorm.DB
+	UserStore         *UserStore
+	UserIdentityStore *UserIdentityStore
+	OrganizationStore *OrganizationStore
+}
+
+func NewSCIMStore(db *gorm.DB, userStore *UserStore, userIdentityStore *UserIdentityStore, organizationStore *OrganizationStore) *SCIMStore {
+	return &SCIMStore{
+		DB:                db,
+		UserStore:         userStore,
+		UserIdentityStore: userIdentityStore,
+		OrganizationStore: organizationStore,
+	}
+}
+
+// SCIMToken authenticates an organization's SCIM client. Only a hash of the
+// token is stored; it is shown once when created.
+type SCIMToken struct {
+	ID             uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	OrganizationID uuid.UUID  `gorm:"column:organization_id;type:uuid;index" json:"organizationId"`
+	Description    string     `gorm:"column:description" json:"description"`
+	TokenHash      string     `gorm:"column:token_hash;uniqueIndex" json:"-"`
+	LastUsedAt     *time.Time `gorm:"column:last_used_at" json:"lastUsedAt"`
+	CreatedAt      time.Time  `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+}
+
+func (SCIMToken) TableName() string {
+	return "scim_tokens"
+}
+
+// SCIMGroup is a group pushed by an organization's SCIM client.
+type SCIMGroup struct {
+	ID             uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	OrganizationID uuid.UUID `gorm:"column:organization_id;type:uuid;uniqueIndex:idx_scim_groups_organization_name" json:"organizationId"`
+	DisplayName    string    `gorm:"column:display_name;uniqueIndex:idx_scim_groups_organization_name" json:"displayName"`
+	ExternalID     string    `gorm:"column:external_id" json:"externalId"`
+	CreatedAt      time.Time `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+	UpdatedAt      time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt"`
+}
+
+func (SCIMGroup) TableName() string {
+	return "scim_groups"
+}
+
+type SCIMGroupMember struct {
+	GroupID   uuid.UUID `gorm:"column:group_id;type:uuid;primaryKey" json:"groupId"`
+	UserID    uuid.UUID `gorm:"column:user_id;type:uuid;primaryKey;index" json:"userId"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+}
+
+func (SCIMGroupMember) TableName() string {
+	return "scim_group_members"
+}
+
+var (
+	scimUserColumns = scim.Columns{
+		"id":                {SQL: "users.id::text", Kind: scim.KindExactString},
+		"externalid":        {SQL: "users.external_id", Kind: scim.KindExactString},
+		"username":          {SQL: "users.email", Kind: scim.KindString},
+		"emails":            {SQL: "users.email", Kind: scim.KindString},
+		"emails.value":      {SQL: "users.email", Kind: scim.KindString},
+		"displayname":       {SQL: "users.name", Kind: scim.KindString},
+		"name.formatted":    {SQL: "users.name", Kind: scim.KindString},
+		"active":            {SQL: "(NOT users.account_suspended)", Kind: scim.KindBool},
+		"meta.created":      {SQL: "users.created_at", Kind: scim.KindTime},
+		"meta.lastmodified": {SQL: "users.updated_at", Kind: scim.KindTime},
+	}
+	scimGroupColumns = scim.Columns{
+		"id":                {SQL: "scim_groups.id::text", Kind: scim.KindExactString},
+		"externalid":        {SQL: "scim_groups.external_id", Kind: scim.KindExactString},
+		"displayname":       {SQL: "scim_groups.display_name", Kind: scim.KindString},
+		"meta.created":      {SQL: "scim_groups.created_at", Kind: scim.KindTime},
+		"meta.lastmodified": {SQL: "scim_groups.updated_at", Kind: scim.KindTime},
+	}
+)
+
+func hashSCIMToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// SCIMURL returns the URL of a SCIM endpoint, e.g. SCIMURL("Users", id).
+func SCIMURL(path ...string) string {
+	return strings.Join(append([]string{strings.TrimSuffix(utils.GetEnv("API_URL", false), "/") + "/scim/v2"}, path...), "/")
+}
+
+// CreateToken returns a new token for the organization's SCIM client.
+func (ss *SCIMStore) CreateToken(orgID uuid.UUID, description string) (string, *SCIMToken, error) {
+	if _, err := ss.OrganizationStore.Get(orgID); err != nil {
+		return "", nil, err
+	}
+
+	secret, err := GetAlphaNumString(48, "alphanum")
+	if err != nil {
+		return "", nil, err
+	}
+	token := scimTokenPrefix + secret
+
+	scimToken := &SCIMToken{
+		OrganizationID: orgID,
+		Description:    description,
+		TokenHash:      hashSCIMToken(token),
+	}
+	if err := ss.DB.Create(scimToken).Error; err != nil {
+		return "", nil, err
+	}
+	return token, scimToken, nil
+}
+
+func (ss *SCIMStore) ListTokens(orgID uuid.UUID) ([]SCIMToken, error) {
+	tokens := []SCIMToken{}
+	err := ss.DB.Where("organization_id = ?", orgID).Order("created_at DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+func (ss *SCIMStore) DeleteToken(orgID, id uuid.UUID) error {
+	result := ss.DB.Where("id = ? AND organization_id = ?", id, orgID).Delete(&SCIMToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrSCIMTokenNotFound
+	}
+	return nil
+}
+
+// Authenticate returns the organization a SCIM token belongs to.
+func (ss *SCIMStore) Authenticate(token string) (uuid.UUID, error) {
+	if !strings.HasPrefix(token, scimTokenPrefix) {
+		return uuid.Nil, utils.ErrSCIMTokenInvalid
+	}
+
+	var scimToken SCIMToken
+	err := ss.DB.Where("token_hash = ?", hashSCIMToken(token)).First(&scimToken).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return uuid.Nil, utils.ErrSCIMTokenInvalid
+		}
+		return uuid.Nil, err
+	}
+
+	if err := ss.DB.Model(&SCIMToken{}).Where("id = ?", scimToken.ID).Update("last_used_at", time.Now()).Error; err != nil {
+		return uuid.Nil, err
+	}
+	return scimToken.OrganizationID, nil
+}
+
+func filterScope(filter scim.Filter, columns scim.Columns) (func(*gorm.DB) *gorm.DB, error) {
+	if filter == nil {
+		return func(db *gorm.DB) *gorm.DB { return db }, nil
+	}
+	where, args, scimErr := filter.SQL(columns)
+	if scimErr != nil {
+		return nil, scimErr
+	}
+	return func(db *gorm.DB) *gorm.DB { return db.Where(where, args...) }, nil
+}
+
+func toSCIMUser(user *User, groups []scim.Reference) scim.User {
+	active := scim.Bool(!user.AccountSuspended)
+	role := "client"
+	if user.UserIsAdmin() {
+		role = "admin"
+	}
+	return scim.User{
+		Schemas:     []string{scim.SchemaUser},
+		ID:          user.ID.String(),
+		ExternalID:  user.ExternalID,
+		UserName:    user.Email,
+		Name:        &scim.Name{Formatted: user.Name},
+		DisplayName: user.Name,
+		Emails:      []scim.MultiValued{{Value: user.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Roles:       []scim.MultiValued{{Value: role, Primary: true}},
+		Groups:      groups,
+		Meta: &scim.Meta{
+			ResourceType: "User",
+			Created:      user.CreatedAt,
+			LastModified: user.UpdatedAt,
+			Location:     SCIMURL("Users", user.ID.String()),
+		},
+	}
+}
+
+// userGroups returns the groups of each of the users.
+func (ss *SCIMStore) userGroups(userIDs []uuid.UUID) (map[uuid.UUID][]scim.Reference, error) {
+	groups := map[uuid.UUID][]scim.Reference{}
+	if len(userIDs) == 0 {
+		return groups, nil
+	}
+
+	var rows []struct {
+		UserID      uuid.UUID
+		GroupID     uuid.UUID
+		DisplayName string
+	}
+	err := ss.DB.Table("scim_group_members").
+		Select("scim_group_members.user_id, scim_group_members.group_id, scim_groups.display_name").
+		Joins("JOIN scim_groups ON scim_groups.id = scim_group_members.group_id").
+		Where("scim_group_members.user_id IN ?", userIDs).
+		Order("scim_groups.display_name ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		groups[row.UserID] = append(groups[row.UserID], scim.Reference{
+			Value:   row.GroupID.String(),
+			Ref:     SCIMURL("Groups", row.GroupID.String()),
+			Display: row.DisplayName,
+		})
+	}
+	return groups, nil
+}
+
+// ListUsers returns a page of the organization's users matching filter,
+// which may be nil, and the number of matching users.
+func (ss *SCIMStore) ListUsers(orgID uuid.UUID, filter scim.Filter, page scim.Page) ([]scim.User, int64, error) {
+	scope, err := filterScope(filter, scimUserColumns)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	if err := ss.DB.Model(&User{}).Where("organization_id = ?", orgID).Scopes(scope).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	users := []User{}
+	if page.Count > 0 {
+		err := ss.DB.Where("organization_id = ?", orgID).Scopes(scope).
+			Order("created_at ASC, id ASC").Offset(page.Offset()).Limit(page.Count).
+			Find(&users).Error
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	userIDs := make([]uuid.UUID, len(users))
+	for i := range users {
+		userIDs[i] = users[i].ID
+	}
+	groups, err := ss.userGroups(userIDs)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resources := make([]scim.User, len(users))
+	for i := range users {
+		resources[i] = toSCIMUser(&users[i], groups[users[i].ID])
+	}
+	return resources, total, nil
+}
+
+func (ss *SCIMStore) getUser(orgID uuid.UUID, id string) (*User, error) {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, scim.ErrNotFound("user not found")
+	}
+
+	var user User
+	err = ss.DB.Where("id = ? AND organization_id = ?", userID, orgID).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, scim.ErrNotFound("user not found")
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (ss *SCIMStore) GetUser(orgID uuid.UUID, id string) (scim.User, error) {
+	user, err := ss.getUser(orgID, id)
+	if err != nil {
+		return scim.User{}, err
+	}
+	groups, err := ss.userGroups([]uuid.UUID{user.ID})
+	if err != nil {
+		return scim.User{}, err
+	}
+	return toSCIMUser(user, groups[user.ID]), nil
+}
+
+// scimUserInput is a validated User resource.
+type scimUserInput struct {
+	email      string
+	name       string
+	externalID string
+	active     bool
+	// role is nil when the resource has no roles, which leaves it unchanged
+	role *UserRole
+}
+
+func isEmailAddress(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
+
+// userInput validates resource for the organization. current is the account
+// the resource is saved to, if it exists.
+func (ss *SCIMStore) userInput(orgID uuid.UUID, resource *scim.User, current *User) (*scimUserInput, error) {
+	org, err := ss.OrganizationStore.Get(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the account's email is its userName, but IdPs whose usernames are not
+	// addresses send it as the primary email instead
+	email := resource.UserName
+	if !isEmailAddress(email) {
+		email = resource.PrimaryEmail()
+	}
+	if !isEmailAddress(email) {
+		return nil, scim.ErrInvalidValue("userName or the primary email must be an email address")
+	}
+	email = strings.ToLower(email)
+
+	domain, err := ss.OrganizationStore.DomainForEmail(email)
+	if err != nil && !errors.Is(err, utils.ErrOrganizationDomainNotFound) {
+		return nil, err
+	}
+	if err != nil || domain.OrganizationID != orgID {
+		return nil, scim.ErrInvalidValue("the email address is not at a domain of this organization")
+	}
+
+	input := &scimUserInput{
+		email:      email,
+		name:       resource.FullName(),
+		externalID: resource.ExternalID,
+		active:     resource.IsActive(),
+	}
+	if resource.Roles != nil {
+		role := UserRoleClient
+		for _, r := range resource.Roles {
+			if strings.EqualFold(r.Value, "admin") {
+				role = UserRoleAdmin
+			}
+		}
+		alreadyAdmin := current != nil && current.UserIsAdmin()
+		if role == UserRoleAdmin && !org.AllowSCIMAdmins && !alreadyAdmin {
+			return nil, scim.NewError(http.StatusForbidden, "", "this organization may not grant the admin role")
+		}
+		input.role = &role
+	}
+	return input, nil
+}
+
+// saveUser writes input to user and adds them to the organization.
+func (ss *SCIMStore) saveUser(tx *gorm.DB, user *User, orgID uuid.UUID, input *scimUserInput) error {
+	updates := map[string]interface{}{
+		"organization_id":   orgID,
+		"email":             input.email,
+		"external_id":       input.externalID,
+		"account_suspended": !input.active,
+	}
+	if input.name != "" {
+		updates["name"] = input.name
+	}
+	if input.role != nil {
+		updates["role"] = *input.role
+	}
+	if err := tx.Model(&User{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return scim.ErrUniqueness("a user with this userName already exists")
+		}
+		return err
+	}
+	return nil
+}
+
+func (ss *SCIMStore) CreateUser(orgID uuid.UUID, resource *scim.User) (scim.User, error) {
+	email := strings.ToLower(resource.UserName)
+	if !isEmailAddress(email) {
+		email = strings.ToLower(resource.PrimaryEmail())
+	}
+
+	user, err := ss.UserStore.UserGetByEmail(email)
+	switch {
+	case err == nil:
+		if user.OrganizationID != nil {
+			return scim.User{}, scim.ErrUniqueness("a user with this userName already exists")
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		// validated before creating the account below
+		if _, err := ss.userInput(orgID, resource, nil); err != nil {
+			return scim.User{}, err
+		}
+		user, err = ss.UserStore.UserCreate(dto.UserCreateDto{
+			Name:  resource.FullName(),
+			Email: email,
+		})
+		if err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return scim.User{}, scim.ErrUniqueness("a user with this userName already exists")
+			}
+			return scim.User{}, err
+		}
+	default:
+		return scim.User{}, err
+	}
+
+	input, err := ss.userInput(orgID, resource, &user)
+	if err != nil {
+		return scim.User{}, err
+	}
+
+	err = ss.DB.Transaction(func(tx *gorm.DB) error {
+		if !user.EmailVerified {
+			// as with SAML, the IdP vouches for addresses at the
+			// organization's domains, so credentials set up before the
+			// address was proven may belong to someone else
+			if err := ss.UserIdentityStore.takeOverUnverifiedAccount(tx, &user); err != nil {
+				return err
+			}
+		}
+		return ss.saveUser(tx, &user, orgID, input)
+	})
+	if err != nil {
+		return scim.User{}, err
+	}
+	return ss.GetUser(orgID, user.ID.String())
+}
+
+// ReplaceUser replaces the user with resource. Attributes without a
+// counterpart on the account, such as name parts, are not kept.
+func (ss *SCIMStore) ReplaceUser(orgID uuid.UUID, id string, resource *scim.User) (scim.User, error) {
+	user, err := ss.getUser(orgID, id)
+	if err != nil {
+		return scim.User{}, err
+	}
+	input, err := ss.userInput(orgID, resource, user)
+	if err != nil {
+		return scim.User{}, err
+	}
+	if err := ss.saveUser(ss.DB, user, orgID, input); err != nil {
+		return scim.User{}, err
+	}
+	return ss.GetUser(orgID, id)
+}
+
+func (ss *SCIMStore) PatchUser(orgID uuid.UUID, id string, req *scim.PatchRequest) (scim.User, error) {
+	resource, err := ss.GetUser(orgID, id)
+	if err != nil {
+		return scim.User{}, err
+	}
+	if err := resource.ApplyPatch(req); err != nil {
+		return scim.User{}, err
+	}
+	return ss.ReplaceUser(orgID, id, &resource)
+}
+
+// DeleteUser suspends the user and removes them from the organization and
+// its groups.
+func (ss *SCIMStore) DeleteUser(orgID uuid.UUID, id string) error {
+	user, err := ss.getUser(orgID, id)
+	if err != nil {
+		return err
+	}
+
+	return ss.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"account_suspended": true,
+			"organization_id":   nil,
+			"external_id":       "",
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&SCIMGroupMember{}).Error
+	})
+}
+
+func toSCIMGroup(group *SCIMGroup, members []scim.Reference) scim.Group {
+	return scim.Group{
+		Schemas:     []string{scim.SchemaGroup},
+		ID:          group.ID.String(),
+		ExternalID:  group.ExternalID,
+		DisplayName: group.DisplayName,
+		Members:     members,
+		Meta: &scim.Meta{
+			ResourceType: "Group",
+			Created:      group.CreatedAt,
+			LastModified: group.UpdatedAt,
+			Location:     SCIMURL("Groups", group.ID.String()),
+		},
+	}
+}
+
+// groupMembers returns the members of each of the groups.
+func (ss *SCIMStore) groupMembers(groupIDs []uuid.UUID) (map[uuid.UUID][]scim.Reference, error) {
+	members := map[uuid.UUID][]scim.Reference{}
+	if len(groupIDs) == 0 {
+		return members, nil
+	}
+
+	var rows []struct {
+		GroupID uuid.UUID
+		UserID  uuid.UUID
+		Name    string
+	}
+	err := ss.DB.Table("scim_group_members").
+		Select("scim_group_members.group_id, scim_group_members.user_id, users.name").
+		Joins("JOIN users ON users.id = scim_group_members.user_id").
+		Where("scim_group_members.group_id IN ?", groupIDs).
+		Order("scim_group_members.created_at ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		members[row.GroupID] = append(members[row.GroupID], scim.Reference{
+			Value:   row.UserID.String(),
+			Ref:     SCIMURL("Users", row.UserID.String()),
+			Display: row.Name,
+		})
+	}
+	return members, nil
+}
+
+// ListGroups returns a page of the organization's groups matching filter,
+// which may be nil, and the number of matching groups. Members are left out
+// unless withMembers is set.
+func (ss *SCIMStore) ListGroups(orgID uuid.UUID, filter scim.Filter, page scim.Page, withMembers bool) ([]scim.Group, int64, error) {
+	scope, err := filterScope(filter, scimGroupColumns)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	if err := ss.DB.Model(&SCIMGroup{}).Where("organization_id = ?", orgID).Scopes(scope).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	groups := []SCIMGroup{}
+	if page.Count > 0 {
+		err := ss.DB.Where("organization_id = ?", orgID).Scopes(scope).
+			Order("created_at ASC, id ASC").Offset(page.Offset()).Limit(page.Count).
+			Find(&groups).Error
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	members := map[uuid.UUID][]scim.Reference{}
+	if withMembers {
+		groupIDs := make([]uuid.UUID, len(groups))
+		for i := range groups {
+			groupIDs[i] = groups[i].ID
+		}
+		if members, err = ss.groupMembers(groupIDs); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	resources := make([]scim.Group, len(groups))
+	for i := range groups {
+		resources[i] = toSCIMGroup(&groups[i], members[groups[i].ID])
+	}
+	return resources, total, nil
+}
+
+func (ss *SCIMStore) getGroup(orgID uuid.UUID, id string) (*SCIMGroup, error) {
+	groupID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, scim.ErrNotFound("group not found")
+	}
+
+	var group SCIMGroup
+	err = ss.DB.Where("id = ? AND organization_id = ?", groupID, orgID).First(&group).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, scim.ErrNotFound("group not found")
+		}
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (ss *SCIMStore) GetGroup(orgID uuid.UUID, id string, withMembers bool) (scim.Group, error) {
+	group, err := ss.getGroup(orgID, id)
+	if err != nil {
+		return scim.Group{}, err
+	}
+	var members []scim.Reference
+	if withMembers {
+		all, err := ss.groupMembers([]uuid.UUID{group.ID})
+		if err != nil {
+			return scim.Group{}, err
+		}
+		members = all[group.ID]
+	}
+	return toSCIMGroup(group, members), nil
+}
+
+// memberIDs validates that the members are users of the organization.
+func (ss *SCIMStore) memberIDs(orgID uuid.UUID, members []scim.Reference) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, 0, len(members))
+	seen := map[uuid.UUID]bool{}
+	for _, member := range members {
+		id, err := uuid.Parse(member.Value)
+		if err != nil {
+			return nil, scim.ErrInvalidValue(fmt.Sprintf("member %q is not a user of this organization", member.Value))
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return ids, nil
+	}
+
+	var count int64
+	if err := ss.DB.Model(&User{}).Where("id IN ? AND organization_id = ?", ids, orgID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count != int64(len(ids)) {
+		return nil, scim.ErrInvalidValue("members must be users of this organization")
+	}
+	return ids, nil
+}
+
+// setMembers makes userIDs the members of the group, keeping the join
+// dates of existing members.
+func setMembers(tx *gorm.DB, groupID uuid.UUID, userIDs []uuid.UUID) error {
+	remove := tx.Where("group_id = ?", groupID)
+	if len(userIDs) > 0 {
+		remove = remove.Where("user_id NOT IN ?", userIDs)
+	}
+	if err := remove.Delete(&SCIMGroupMember{}).Error; err != nil {
+		return err
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	members := make([]SCIMGroupMember, len(userIDs))
+	for i, userID := range userIDs {
+		members[i] = SCIMGroupMember{GroupID: groupID, UserID: userID}
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(members, 500).Error
+}
+
+func (ss *SCIMStore) saveGroup(orgID uuid.UUID, group *SCIMGroup, resource *scim.Group) error {
+	if strings.TrimSpace(resource.DisplayName) == "" {
+		return scim.ErrInvalidValue("displayName is required")
+	}
+	userIDs, err := ss.memberIDs(orgID, resource.Members)
+	if err != nil {
+		return err
+	}
+
+	group.OrganizationID = orgID
+	group.DisplayName = resource.DisplayName
+	group.ExternalID = resource.ExternalID
+	return ss.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(group).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return scim.ErrUniqueness("a group with this displayName already exists")
+			}
+			return err
+		}
+		return setMembers(tx, group.ID, userIDs)
+	})
+}
+
+func (ss *SCIMStore) CreateGroup(orgID uuid.UUID, resource *scim.Group) (scim.Group, error) {
+	group := &SCIMGroup{}
+	if err := ss.saveGroup(orgID, group, resource); err != nil {
+		return scim.Group{}, err
+	}
+	return ss.GetGroup(orgID, group.ID.String(), true)
+}
+
+func (ss *SCIMStore) ReplaceGroup(orgID uuid.UUID, id string, resource *scim.Group, withMembers bool) (scim.Group, error) {
+	group, err := ss.getGroup(orgID, id)
+	if err != nil {
+		return scim.Group{}, err
+	}
+	if err := ss.saveGroup(orgID, group, resource); err != nil {
+		return scim.Group{}, err
+	}
+	return ss.GetGroup(orgID, id, withMembers)
+}
+
+func (ss *SCIMStore) PatchGroup(orgID uuid.UUID, id string, req *scim.PatchRequest, withMembers bool) (scim.Group, error) {
+	resource, err := ss.GetGroup(orgID, id, true)
+	if err != nil {
+		return scim.Group{}, err
+	}
+	if err := resource.ApplyPatch(req); err != nil {
+		return scim.Group{}, err
+	}
+	return ss.ReplaceGroup(orgID, id, &resource, withMembers)
+}
+
+func (ss *SCIMStore) DeleteGroup(orgID uuid.UUID, id string) error {
+	group, err := ss.getGroup(orgID, id)
+	if err != nil {
+		return err
+	}
+
+	return ss.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ?", group.ID).Delete(&SCIMGroupMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(group).Error
+	})
+}
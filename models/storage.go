@@ -0,0 +1,250 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/utils"
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// files are checked against their objects at most once per
+	// storageCheckInterval
+	storageCheckInterval = 24 * time.Hour
+	storageS3Timeout     = 30 * time.Second
+	// a reservation left behind by an upload that never finished, because
+	// the process stopped, stops counting after storageReservationTTL
+	storageReservationTTL = 15 * time.Minute
+)
+
+// StorageStore accounts for the file storage of users. Users of an
+// organization share one pool. Usage counts every file, trashed ones
+// included, plus the declared size of uploads still in progress.
+type StorageStore struct {
+	DB *gorm.DB
+	// DefaultQuota is the quota in bytes without an active plan granting more
+	DefaultQuota int64
+}
+
+func NewStorageStore(db *gorm.DB, defaultQuota int64) *StorageStore {
+	return &StorageStore{DB: db, DefaultQuota: defaultQuota}
+}
+
+// StorageDefaultQuotaFromEnv reads STORAGE_DEFAULT_QUOTA_MB, 1024 by default.
+func StorageDefaultQuotaFromEnv() (int64, error) {
+	mb := int64(1024)
+	if value := utils.GetEnv("STORAGE_DEFAULT_QUOTA_MB", true); value != "" {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("STORAGE_DEFAULT_QUOTA_MB must be a number of at least 0")
+		}
+		mb = n
+	}
+	return mb << 20, nil
+}
+
+// StorageReservation holds quota for files that are being uploaded outside
+// a transaction. It is released when their Files are created.
+type StorageReservation struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	UserID    uuid.UUID `gorm:"column:user_id;type:uuid;index" json:"userId"`
+	Size      int64     `gorm:"column:size" json:"size"`
+	ExpiresAt time.Time `gorm:"column:expires_at;index" json:"expiresAt"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+}
+
+// StorageUsage is the storage used by a user, or their organization.
+type StorageUsage struct {
+	Scope          utils.StorageScope `json:"scope"`
+	UsedBytes      int64              `json:"usedBytes"`
+	ReservedBytes  int64              `json:"reservedBytes"`
+	QuotaBytes     int64              `json:"quotaBytes"`
+	AvailableBytes int64              `json:"availableBytes"`
+}
+
+// storageOwners returns a subquery of the IDs of the users whose files count
+// against the same quota as user's.
+func storageOwners(tx *gorm.DB, user *User) (*gorm.DB, utils.StorageScope) {
+	if user.OrganizationID != nil {
+		return tx.Model(&User{}).Select("id").Where("organization_id = ?", user.OrganizationID), utils.StorageScopeOrganization
+	}
+	return tx.Model(&User{}).Select("id").Where("id = ?", user.ID), utils.StorageScopeUser
+}
+
+// usage adds up the storage of user's pool within tx.
+func (ss *StorageStore) usage(tx *gorm.DB, user *User) (StorageUsage, error) {
+	owners, scope := storageOwners(tx.Session(&gorm.Session{NewDB: true}), user)
+	usage := StorageUsage{Scope: scope}
+
+	err := tx.Unscoped().Model(&File{}).
+		Select("COALESCE(SUM(size), 0)").
+		Where("user_id IN (?)", owners).
+		Scan(&usage.UsedBytes).Error
+	if err != nil {
+		return StorageUsage{}, err
+	}
+
+	now := time.Now()
+	for _, model := range []interface{}{&MultipartUpload{}, &DirectUpload{}} {
+		var reserved int64
+		err := tx.Model(model).
+			Select("COALESCE(SUM(size), 0)").
+			Where("user_id IN (?) AND status = ? AND expires_at > ?", owners, utils.UploadStatusPending, now).
+			Scan(&reserved).Error
+		if err != nil {
+			return StorageUsage{}, err
+		}
+		usage.ReservedBytes += reserved
+	}
+
+	var held int64
+	err = tx.Model(&StorageReservation{}).
+		Select("COALESCE(SUM(size), 0)").
+		Where("user_id IN (?) AND expires_at > ?", owners, now).
+		Scan(&held).Error
+	if err != nil {
+		return StorageUsage{}, err
+	}
+	usage.ReservedBytes += held
+
+	// the largest storage any active plan in the pool grants
+	var planQuota int64
+	err = tx.Table("user_subscriptions").
+		Joins("JOIN product_plans ON product_plans.id = user_subscriptions.product_plan_id").
+		Select("COALESCE(MAX(product_plans.storage_quota), 0)").
+		Where("user_subscriptions.user_id IN (?) AND user_subscriptions.is_active = ? AND user_subscriptions.suspended = ? AND user_subscriptions.end_date > ?",
+			owners, true, false, now).
+		Scan(&planQuota).Error
+	if err != nil {
+		return StorageUsage{}, err
+	}
+	usage.QuotaBytes = max(ss.DefaultQuota, planQuota)
+	usage.AvailableBytes = max(usage.QuotaBytes-usage.UsedBytes-usage.ReservedBytes, 0)
+
+	return usage, nil
+}
+
+// Usage returns the storage used by user's pool.
+func (ss *StorageStore) Usage(user *User) (StorageUsage, error) {
+	return ss.usage(ss.DB, user)
+}
+
+// Reserve checks within tx that user's pool has size bytes left and holds
+// the pool until tx ends, so concurrent uploads cannot both take the last of
+// the quota. The caller records the bytes, as a File, a pending upload or a
+// StorageReservation, before committing.
+func (ss *StorageStore) Reserve(tx *gorm.DB, user *User, size int64) error {
+	var err error
+	if user.OrganizationID != nil {
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", user.OrganizationID).First(&Organization{}).Error
+	} else {
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", user.ID).First(&User{}).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	usage, err := ss.usage(tx, user)
+	if err != nil {
+		return err
+	}
+	if size > usage.AvailableBytes {
+		return utils.ErrStorageQuotaExceeded
+	}
+	return nil
+}
+
+// Hold reserves size bytes of user's pool in a transaction of its own, for
+// uploads that must not hold the pool's lock while they run. The caller
+// releases the reservation in the transaction that creates the Files, or
+// with Release if the upload fails.
+func (ss *StorageStore) Hold(user *User, size int64) (*StorageReservation, error) {
+	reservation := StorageReservation{
+		UserID:    user.ID,
+		Size:      size,
+		ExpiresAt: time.Now().Add(storageReservationTTL),
+	}
+	err := ss.DB.Transaction(func(tx *gorm.DB) error {
+		if err := ss.Reserve(tx, user, size); err != nil {
+			return err
+		}
+		return tx.Create(&reservation).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+// Release gives the bytes of reservation back to the pool within tx.
+func (ss *StorageStore) Release(tx *gorm.DB, reservation *StorageReservation) error {
+	return tx.Where("id = ?", reservation.ID).Delete(&StorageReservation{}).Error
+}
+
+// Reconcile checks up to limit files, trashed ones included, against the
+// size of their objects and corrects the recorded size where they differ.
+// It returns how many files were corrected.
+func (ss *StorageStore) Reconcile(logger *log.Logger, limit int) (int, error) {
+	var files []File
+	err := ss.DB.Unscoped().
+		Where("checked_at IS NULL OR checked_at < ?", time.Now().Add(-storageCheckInterval)).
+		Order("checked_at NULLS FIRST").
+		Limit(limit).
+		Find(&files).Error
+	if err != nil {
+		return 0, err
+	}
+
+	corrected := 0
+	for _, file := range files {
+		ctx, cancel := context.WithTimeout(context.Background(), storageS3Timeout)
+		info, err := utils.S3StatObject(ctx, file.Bucket, file.Key)
+		cancel()
+
+		updates := map[string]interface{}{"checked_at": time.Now()}
+		switch {
+		case err != nil && minio.ToErrorResponse(err).Code == "NoSuchKey":
+			// counted at its recorded size until someone looks into it
+			logger.Printf("Storage reconciliation: object of file %s is missing", file.ID)
+		case err != nil:
+			return corrected, fmt.Errorf("failed to stat object of file %s: %w", file.ID, err)
+		case info.Size != file.Size:
+			logger.Printf("Storage reconciliation: file %s is %d bytes, recorded as %d", file.ID, info.Size, file.Size)
+			updates["size"] = info.Size
+			corrected++
+		}
+
+		if err := ss.DB.Unscoped().Model(&File{}).Where("id = ?", file.ID).UpdateColumns(updates).Error; err != nil {
+			return corrected, err
+		}
+	}
+	return corrected, nil
+}
+
+// RunWorker reconciles file sizes and deletes expired reservations every
+// interval until ctx is cancelled.
+func (ss *StorageStore) RunWorker(ctx context.Context, logger *log.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := ss.Reconcile(logger, 500); err != nil {
+				logger.Printf("Storage reconciliation worker error: %v", err)
+			}
+			if err := ss.DB.Where("expires_at < ?", time.Now()).Delete(&StorageReservation{}).Error; err != nil {
+				logger.Printf("Storage reservation cleanup error: %v", err)
+			}
+		}
+	}
+}
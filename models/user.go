@@ -4,16 +4,17 @@ import (
 	"crypto/rand"
 	"crypto/sha512"
 	"encoding/hex"
+	"errors"
 	"fmt"
-	"log"
 	"math/big"
+	"net/url"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/21TechLabs/factory-backend/dto"
-	"github.com/21TechLabs/factory-backend/notifications"
 	"github.com/21TechLabs/factory-backend/notifications/templates"
+	"github.com/21TechLabs/factory-backend/password"
 	"github.com/21TechLabs/factory-backend/utils"
 	"github.com/google/uuid"
 	"github.com/kataras/jwt"
@@ -27,37 +28,54 @@ const (
 	UserRoleClient UserRole = iota + 1 // 2
 )
 
+const (
+	emailVerificationTokenTTL = 48 * time.Hour
+	passwordResetTokenTTL     = time.Hour
+)
+
 type UserStore struct {
-	DB        *gorm.DB
-	FileStore *FileStore
+	DB               *gorm.DB
+	FileStore        *FileStore
+	EmailOutboxStore *EmailOutboxStore
+	WebhookStore     *WebhookStore
+	PasswordPolicy   *password.Policy
+	// OneTimeTokenStore holds the email verification and password reset tokens
+	OneTimeTokenStore *OneTimeTokenStore
 }
 
-func NewUserStore(db *gorm.DB, fs *FileStore) *UserStore {
-	return &UserStore{DB: db, FileStore: fs}
+func NewUserStore(db *gorm.DB, fs *FileStore, eos *EmailOutboxStore, ws *WebhookStore, passwordPolicy *password.Policy, ots *OneTimeTokenStore) *UserStore {
+	return &UserStore{DB: db, FileStore: fs, EmailOutboxStore: eos, WebhookStore: ws, PasswordPolicy: passwordPolicy, OneTimeTokenStore: ots}
 }
 
 type User struct {
-	ID                     uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
-	Name                   string    `gorm:"column:name" json:"name"`
-	Role                   UserRole  `gorm:"column:role" json:"role"`
-	Email                  string    `gorm:"column:email;unique" json:"email"`
-	ProfilePicURI          string    `gorm:"column:profile_picture_url" json:"profilePicURI"`
-	EmailVerified          bool      `gorm:"column:email_verified" json:"emailVerified"`
-	EmailVerificationToken string    `gorm:"column:email_verification_token" json:"-"`
-	Password               string    `gorm:"column:password" json:"-"`
-	PasswordResetToken     string    `gorm:"column:password_reset_token" json:"-"`
-	PasswordTries          int       `gorm:"column:password_tries" json:"passwordTries"`
-	OptedInForEmail        bool      `gorm:"column:opted_in_for_email" json:"optedInForEmail"`
-	AccountSuspended       bool      `gorm:"column:account_suspended" json:"accountSuspended"`
-	AccountBlocked         bool      `gorm:"column:account_blocked" json:"accountBlocked"`
-	MarkedForDeletion      bool      `gorm:"column:marked_for_deletion" json:"markedForDeletion"`
-	DeleteAccountAfter     time.Time `gorm:"column:delete_account_after" json:"deleteAccountAfter"`
-	AccountDeleted         bool      `gorm:"column:account_deleted" json:"accountDeleted"`
-	AccountCreated         bool      `gorm:"column:account_created" json:"accountCreated"`
-	Tokens                 int64     `gorm:"column:tokens" json:"tokens"`
-	Files                  []File    `gorm:"foreignKey:UserID;references:ID" json:"files"`
-	CreatedAt              time.Time `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
-	UpdatedAt              time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt"`
+	ID                 uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	Name               string    `gorm:"column:name" json:"name"`
+	Role               UserRole  `gorm:"column:role" json:"role"`
+	Email              string    `gorm:"column:email;unique" json:"email"`
+	PhoneNumber        *string   `gorm:"column:phone_number;uniqueIndex" json:"phoneNumber"`
+	PhoneVerified      bool      `gorm:"column:phone_verified" json:"phoneVerified"`
+	ProfilePicURI      string    `gorm:"column:profile_picture_url" json:"profilePicURI"`
+	Locale             string    `gorm:"column:locale;default:en" json:"locale"`
+	EmailVerified      bool      `gorm:"column:email_verified" json:"emailVerified"`
+	Password           string    `gorm:"column:password" json:"-"`
+	AccountSuspended   bool      `gorm:"column:account_suspended" json:"accountSuspended"`
+	AccountBlocked     bool      `gorm:"column:account_blocked" json:"accountBlocked"`
+	MarkedForDeletion  bool      `gorm:"column:marked_for_deletion" json:"markedForDeletion"`
+	DeleteAccountAfter time.Time `gorm:"column:delete_account_after" json:"deleteAccountAfter"`
+	AccountDeleted     bool      `gorm:"column:account_deleted" json:"accountDeleted"`
+	AccountCreated     bool      `gorm:"column:account_created" json:"accountCreated"`
+	Tokens             int64     `gorm:"column:tokens" json:"tokens"`
+	// PasswordChangedAt is when the password was last changed or reset
+	PasswordChangedAt *time.Time `gorm:"column:password_changed_at" json:"passwordChangedAt"`
+	// SessionsRevokedAt rejects login tokens issued before it
+	SessionsRevokedAt *time.Time `gorm:"column:sessions_revoked_at" json:"-"`
+	// OrganizationID is set for users who sign in through an organization's SSO
+	OrganizationID *uuid.UUID `gorm:"column:organization_id;type:uuid;index" json:"organizationId"`
+	// ExternalID is the organization's SCIM client's own identifier for the user
+	ExternalID string    `gorm:"column:external_id" json:"-"`
+	Files      []File    `gorm:"foreignKey:UserID;references:ID" json:"files"`
+	CreatedAt  time.Time `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+	UpdatedAt  time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt"`
 }
 
 func (User) TableName() string {
@@ -65,9 +83,24 @@ func (User) TableName() string {
 }
 
 func (us *UserStore) UserCreate(user dto.UserCreateDto) (User, error) {
+	var newUser User
+	err := us.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		newUser, err = us.createUser(tx, user)
+		return err
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return newUser, nil
+}
 
+// createUser creates the user within tx, so callers can set up the rest of
+// the account in the same transaction. The verification email and webhooks
+// are only queued if tx commits.
+func (us *UserStore) createUser(tx *gorm.DB, user dto.UserCreateDto) (User, error) {
 	var userCount int64
-	result := us.DB.Model(&User{}).Where("email = ?", user.Email).Count(&userCount)
+	result := tx.Model(&User{}).Where("email = ?", user.Email).Count(&userCount)
 
 	if result.Error != nil {
 		if result.Error != gorm.ErrRecordNotFound {
@@ -80,36 +113,49 @@ func (us *UserStore) UserCreate(user dto.UserCreateDto) (User, error) {
 	}
 
 	var newUser = User{
-		Name:            user.Name,
-		Role:            UserRoleClient,
-		Email:           user.Email,
-		OptedInForEmail: true,
-		AccountCreated:  true,
-		Tokens:          10000,
+		Name:           user.Name,
+		Role:           UserRoleClient,
+		Email:          user.Email,
+		Locale:         user.Locale,
+		AccountCreated: true,
+		Tokens:         10000,
+	}
+	// accounts created through OAuth have no password
+	if user.Password != "" {
+		if err := us.CheckPassword(&newUser, user.Password); err != nil {
+			return User{}, err
+		}
+		var err error
+		newUser.Password, err = SaltPassword(user.Password, "")
+		if err != nil {
+			return User{}, err
+		}
 	}
-	var err error
-	newUser.Password, err = SaltPassword(user.Password, "")
 
-	if err != nil {
+	if err := tx.Create(&newUser).Error; err != nil {
 		return User{}, err
 	}
-
-	result = us.DB.Create(&newUser)
-
-	if result.Error != nil {
-		return User{}, result.Error
+	if err := us.SendEmailVerifyEmail(tx, &newUser); err != nil {
+		return User{}, err
 	}
-
-	// send email verification msg
-	if err := us.SendEmailVerifyEmail(&newUser); err != nil {
+	if err := us.WebhookStore.Emit(tx, newUser.ID, utils.WebhookEventUserCreated, newUser.WebhookData()); err != nil {
 		return User{}, err
 	}
+	return newUser, nil
+}
 
-	if err := us.Update(&newUser); err != nil {
-		return User{}, fmt.Errorf("failed to update user after creation: %w", err)
+// WebhookData is the user as sent in webhook payloads.
+func (user *User) WebhookData() utils.Map {
+	return utils.Map{
+		"user": utils.Map{
+			"id":            user.ID,
+			"name":          user.Name,
+			"email":         user.Email,
+			"emailVerified": user.EmailVerified,
+			"locale":        user.Locale,
+			"createdAt":     user.CreatedAt,
+		},
 	}
-
-	return newUser, nil
 }
 
 func (user *User) UserIsAdmin() bool {
@@ -120,61 +166,99 @@ func (user *User) UserIsClient() bool {
 	return user.Role == UserRoleClient
 }
 
-func (uc *UserStore) JwtTokenVerifyAndGetUser(token string, secretKey []byte) (User, error) {
+// loginClaims are the claims of a login token. ImpersonationID is only set
+// on tokens that let an admin act as the user.
+type loginClaims struct {
+	User
+	ImpersonationID *uuid.UUID `json:"impersonationId,omitempty"`
+}
+
+// JwtTokenVerifyAndGetUser returns the user of a login token, and the
+// impersonation session ID for tokens issued by JwtImpersonationTokenGet.
+func (uc *UserStore) JwtTokenVerifyAndGetUser(token string, secretKey []byte) (User, *uuid.UUID, error) {
 	verifiedToken, err := jwt.Verify(jwt.HS256, secretKey, []byte(token))
 	if err != nil {
-		return User{}, err
+		return User{}, nil, err
 	}
 
-	var userPD User
+	var userPD loginClaims
 	err = verifiedToken.Claims(&userPD)
 	if err != nil {
-		return User{}, err
+		return User{}, nil, err
 	}
 
+	// looked up by ID, the email in older tokens may since have changed or
+	// been taken by another account
 	var user User
-	user, err = uc.UserGetByEmail(userPD.Email)
+	err = uc.DB.Where("id = ?", userPD.ID).First(&user).Error
 
 	if err != nil {
-		return User{}, err
+		return User{}, nil, err
 	}
 
-	return user, nil
+	// iat only has second precision, so a token issued in the same second
+	// as the revocation, like the one handed out with it, stays valid
+	if user.SessionsRevokedAt != nil && verifiedToken.StandardClaims.IssuedAt < user.SessionsRevokedAt.Unix() {
+		return User{}, nil, utils.ErrSessionRevoked
+	}
+
+	return user, userPD.ImpersonationID, nil
 }
 
 func (us *UserStore) GetDetails(u *User, allowPasswordResetToken bool) User {
 	return *u
 }
 
-func (us *UserStore) SendEmailVerifyEmail(u *User) error {
-	// generate token
-	token, err := GetAlphaNumString(64, "alnum")
+// SendEmailVerifyEmail issues a verification token for u and queues the
+// welcome email in the outbox, both within tx.
+func (us *UserStore) SendEmailVerifyEmail(tx *gorm.DB, u *User) error {
+	token, err := us.OneTimeTokenStore.Issue(tx, u.ID, utils.OneTimeTokenPurposeEmailVerification, emailVerificationTokenTTL)
 	if err != nil {
 		return err
 	}
-	// save token
-	u.EmailVerificationToken = token
-	// send email
-	var frontendURL = utils.GetEnv("FRONTEND_URL", false)
-	var req = notifications.NewRequest([]string{u.Email}, fmt.Sprintf("Welcome to the family %s.", u.Name), fmt.Sprintf("Hey %s, we are glad that you joined our family, to verify your email visit %s/verify-email?email=%s&&token=%s", u.Name, frontendURL, u.Email, token))
 
-	var template templates.WelcomeMessage = templates.WelcomeMessage{
-		Name:      u.Name,
-		BrandName: "",
-		Link:      fmt.Sprintf("%s/verify-email?email=%s&&token=%s", frontendURL, u.Email, token),
+	req, err := templates.NewRequest([]string{u.Email}, u.Locale, templates.WelcomeMessage{
+		Name:           u.Name,
+		Link:           emailVerificationLink(u, token),
+		ExpiresInHours: int(emailVerificationTokenTTL.Hours()),
+	})
+	if err != nil {
+		return err
 	}
-	err = template.ParseAsHTML(req)
 
-	if err == nil {
-		go func() {
-			_, err := req.SendEmail()
-			if err != nil {
-				log.Printf("Failed to send email to %s", u.Email)
-			}
-		}()
+	_, err = us.EmailOutboxStore.Enqueue(tx, &u.ID, req)
+	return err
+}
+
+// ResendVerificationEmail emails u a new verification link; earlier links
+// stop working.
+func (us *UserStore) ResendVerificationEmail(u *User) error {
+	if u.EmailVerified {
+		return utils.ErrEmailAlreadyVerified
 	}
 
-	return nil
+	return us.DB.Transaction(func(tx *gorm.DB) error {
+		token, err := us.OneTimeTokenStore.Issue(tx, u.ID, utils.OneTimeTokenPurposeEmailVerification, emailVerificationTokenTTL)
+		if err != nil {
+			return err
+		}
+
+		req, err := templates.NewRequest([]string{u.Email}, u.Locale, templates.VerifyEmailMessage{
+			Name:           u.Name,
+			Link:           emailVerificationLink(u, token),
+			ExpiresInHours: int(emailVerificationTokenTTL.Hours()),
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = us.EmailOutboxStore.Enqueue(tx, &u.ID, req)
+		return err
+	})
+}
+
+func emailVerificationLink(u *User, token string) string {
+	return fmt.Sprintf("%s/verify-email?email=%s&token=%s", utils.GetEnv("FRONTEND_URL", false), url.QueryEscape(u.Email), url.QueryEscape(token))
 }
 
 func (us *UserStore) Update(u *User) error {
@@ -197,25 +281,20 @@ func (us *UserStore) Update(u *User) error {
 	return nil
 }
 
-func (us *UserStore) sendPasswordResetEmail(u *User, token string) error {
+func (us *UserStore) sendPasswordResetEmail(tx *gorm.DB, u *User, token string) error {
 	var frontendURL = utils.GetEnv("FRONTEND_URL", false)
 
-	var req = notifications.NewRequest([]string{u.Email}, fmt.Sprintf("%s, your password reset email.", u.Name), fmt.Sprintf("to reset the password please visit %s/reset-password?email=%s&&token=%s", frontendURL, u.Email, token))
-
-	var template templates.ResetPasswordMessage = templates.ResetPasswordMessage{
-		Name:      u.Name,
-		BrandName: "",
-		Link:      fmt.Sprintf("%s/reset-password?email=%s&&token=%s", frontendURL, u.Email, token),
+	req, err := templates.NewRequest([]string{u.Email}, u.Locale, templates.ResetPasswordMessage{
+		Name:             u.Name,
+		Link:             fmt.Sprintf("%s/reset-password?email=%s&token=%s", frontendURL, url.QueryEscape(u.Email), url.QueryEscape(token)),
+		ExpiresInMinutes: int(passwordResetTokenTTL.Minutes()),
+	})
+	if err != nil {
+		return err
 	}
-	template.ParseAsHTML(req)
-	go func() {
-		_, err := req.SendEmail()
 
-		if err != nil {
-			log.Default().Panicf("Failed to send email to %s", u.Email)
-		}
-	}()
-	return nil
+	_, err = us.EmailOutboxStore.Enqueue(tx, &u.ID, req)
+	return err
 }
 
 func (us *UserStore) UserGetById(id uuid.UUID) (User, error) {
@@ -293,9 +372,25 @@ func SaltPassword(password string, salt string) (string, error) {
 	return password, nil
 }
 
+// CheckPassword applies the password policy to newPassword for user. Broken
+// rules are reported as a *password.PolicyError.
+func (us *UserStore) CheckPassword(user *User, newPassword string) error {
+	return us.PasswordPolicy.Check(newPassword, user.Email, user.Name)
+}
+
+// HasPassword reports whether the user can sign in with a password. Accounts
+// created through OAuth or used only with magic links have none.
+func (user *User) HasPassword() bool {
+	return user.Password != ""
+}
+
 func (us *UserStore) ComparePassword(user *User, password string) bool {
 	var password_and_salt []string = strings.Split(user.Password, ".")
 
+	if len(password_and_salt) != 2 {
+		return false
+	}
+
 	var current_passwd = password_and_salt[0]
 	var salt = password_and_salt[1]
 
@@ -316,65 +411,96 @@ func (us *UserStore) ComparePassword(user *User, password string) bool {
 	return current_passwd == to_compare
 }
 
+// GeneratePasswordResetToken issues a password reset token for user and
+// optionally emails it; earlier reset links stop working.
 func (us *UserStore) GeneratePasswordResetToken(user *User, sendEmail bool) (token string, err error) {
-	resetToken, err := GetAlphaNumString(56, "alphanum")
+	err = us.DB.Transaction(func(tx *gorm.DB) error {
+		token, err = us.OneTimeTokenStore.Issue(tx, user.ID, utils.OneTimeTokenPurposePasswordReset, passwordResetTokenTTL)
+		if err != nil {
+			return err
+		}
+		if sendEmail {
+			return us.sendPasswordResetEmail(tx, user, token)
+		}
+		return nil
+	})
 
 	if err != nil {
 		return "", err
 	}
 
-	user.PasswordResetToken, err = SaltPassword(resetToken, "")
+	return token, nil
+}
 
-	if err != nil {
-		return "", err
+// CompareAndUpdatePasswordWithToken sets a new password with a reset token.
+// The password is checked against the policy first so a rejected password
+// does not use up an attempt.
+func (us *UserStore) CompareAndUpdatePasswordWithToken(user *User, token string, password string) error {
+	if err := us.CheckPassword(user, password); err != nil {
+		return err
 	}
 
-	err = us.Update(user)
+	return us.OneTimeTokenStore.Consume(user.ID, utils.OneTimeTokenPurposePasswordReset, token, func(tx *gorm.DB) error {
+		return us.setPassword(tx, user, password)
+	})
+}
 
-	if err != nil {
-		return "", err
+// ChangePassword replaces the password of a signed-in user after checking
+// currentPassword. Accounts without a password set their first one without it.
+func (us *UserStore) ChangePassword(user *User, currentPassword, newPassword string) error {
+	if user.HasPassword() {
+		if !us.ComparePassword(user, currentPassword) {
+			return utils.ErrIncorrectPassword
+		}
+		if us.ComparePassword(user, newPassword) {
+			return utils.ErrPasswordUnchanged
+		}
 	}
 
-	if sendEmail {
-		err = us.sendPasswordResetEmail(user, resetToken)
-		if err != nil {
-			return "", err
-		}
+	if err := us.CheckPassword(user, newPassword); err != nil {
+		return err
 	}
 
-	return resetToken, err
+	return us.DB.Transaction(func(tx *gorm.DB) error {
+		return us.setPassword(tx, user, newPassword)
+	})
 }
 
-func (us *UserStore) CompareAndUpdatePasswordWithToken(user *User, token string, password string) error {
-	var token_and_salt []string = strings.Split(user.PasswordResetToken, ".")
-
-	var salt = token_and_salt[1]
-
-	salted_token, err := SaltPassword(token, salt)
-
+// setPassword stores newPassword, revokes every existing login token and
+// emails the user about the change, all within tx. Pending reset tokens stop
+// working.
+func (us *UserStore) setPassword(tx *gorm.DB, user *User, newPassword string) error {
+	hashed, err := SaltPassword(newPassword, "")
 	if err != nil {
 		return err
 	}
 
-	if user.PasswordResetToken != salted_token {
-		return fmt.Errorf("invalid token")
-	}
-
-	user.Password, err = SaltPassword(password, "")
-
+	now := time.Now()
+	err = tx.Model(&User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"password":            hashed,
+		"password_changed_at": &now,
+		"sessions_revoked_at": &now,
+	}).Error
 	if err != nil {
 		return err
 	}
+	if err := us.OneTimeTokenStore.Revoke(tx, user.ID, utils.OneTimeTokenPurposePasswordReset); err != nil {
+		return err
+	}
 
-	user.PasswordResetToken = ""
-
-	err = us.Update(user)
+	user.Password = hashed
+	user.PasswordChangedAt = &now
+	user.SessionsRevokedAt = &now
 
+	req, err := templates.NewRequest([]string{user.Email}, user.Locale, templates.PasswordChangedMessage{
+		Name:      user.Name,
+		ChangedAt: now.UTC().Format("2 Jan 2006 15:04 MST"),
+	})
 	if err != nil {
 		return err
 	}
-
-	return nil
+	_, err = us.EmailOutboxStore.Enqueue(tx, &user.ID, req)
+	return err
 }
 
 func (us *UserStore) UserGetByEmail(email string) (User, error) {
@@ -394,8 +520,6 @@ func (us *UserStore) UserGetByEmail(email string) (User, error) {
 func (user *User) JwtTokenGet(expiryTime time.Time, secretKey []byte) (string, error) {
 	claim := *user
 
-	claim.PasswordResetToken = ""
-	claim.EmailVerificationToken = ""
 	claim.Password = ""
 
 	token, err := jwt.Sign(jwt.HS256, secretKey, claim, jwt.MaxAge(time.Hour*24*7))
@@ -407,24 +531,40 @@ func (user *User) JwtTokenGet(expiryTime time.Time, secretKey []byte) (string, e
 	return string(token), nil
 }
 
-func (us *UserStore) UserVerifyEmailToken(email string, token string) (User, error) {
-	user, err := us.UserGetByEmail(email)
+// JwtImpersonationTokenGet returns a login token for user that is only
+// valid while session is live and expires with it.
+func (user *User) JwtImpersonationTokenGet(session *ImpersonationSession, secretKey []byte) (string, error) {
+	claim := loginClaims{User: *user, ImpersonationID: &session.ID}
+	claim.Password = ""
 
+	token, err := jwt.Sign(jwt.HS256, secretKey, claim, jwt.MaxAge(time.Until(session.ExpiresAt)))
 	if err != nil {
-		return user, err
+		return "", err
 	}
 
-	if user.EmailVerificationToken != token {
-		return user, fmt.Errorf("invalid token")
-	}
+	return string(token), nil
+}
 
-	user.EmailVerified = true
-	user.EmailVerificationToken = ""
+func (us *UserStore) UserVerifyEmailToken(email string, token string) (User, error) {
+	user, err := us.UserGetByEmail(email)
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return User{}, utils.ErrOneTimeTokenInvalid
+		}
+		return User{}, err
+	}
 
-	err = us.Update(&user)
+	err = us.OneTimeTokenStore.Consume(user.ID, utils.OneTimeTokenPurposeEmailVerification, token, func(tx *gorm.DB) error {
+		user.EmailVerified = true
+		if err := tx.Model(&User{}).Where("id = ?", user.ID).Update("email_verified", true).Error; err != nil {
+			return err
+		}
+		return us.WebhookStore.Emit(tx, user.ID, utils.WebhookEventUserEmailVerified, user.WebhookData())
+	})
 
 	if err != nil {
-		return user, err
+		return User{}, err
 	}
 
 	return user, nil
@@ -446,64 +586,87 @@ func (us *UserStore) MarkAccountForDeletion(user *User) error {
 }
 
 func (us *UserStore) UploadFile(user *User, data []FileUpload) ([]File, error) {
-	return us.FileStore.UploadFile(data, user.ID)
+	return us.FileStore.UploadFile(data, user)
 }
 
-func (us *UserStore) UserLogin(loginDto dto.UserLoginDto) (User, error) {
-	user, err := us.UserGetByEmail(loginDto.Email)
-
-	if err != nil {
-		fmt.Fprintf(os.Stdout, "UserLogin Error: Failed to find user\n%v", err)
-		return user, err
-	}
-	if user.AccountBlocked {
-		fmt.Fprintln(os.Stdout, "UserLogin Error: Account Blocked!")
-		return user, fmt.Errorf("account blocked")
+// CanLogin reports why the account may not sign in with any method, if at all.
+func (user *User) CanLogin() error {
+	switch {
+	case user.AccountBlocked:
+		return fmt.Errorf("account blocked")
+	case user.AccountDeleted:
+		return fmt.Errorf("account deleted")
+	case user.AccountSuspended:
+		return fmt.Errorf("account suspended")
 	}
+	return nil
+}
 
-	if user.AccountDeleted {
-		fmt.Fprintln(os.Stdout, "UserLogin Error: Account Deleted!")
-		return user, fmt.Errorf("account deleted")
-	}
+// UserGetByPhone returns the user whose verified phone number is phone.
+func (us *UserStore) UserGetByPhone(phone string) (User, error) {
+	var user User
+	err := us.DB.Where("phone_number = ? AND phone_verified = ?", phone, true).First(&user).Error
+	return user, err
+}
 
-	if user.AccountSuspended {
-		fmt.Fprintln(os.Stdout, "UserLogin Error: Account Suspended!")
-		return user, fmt.Errorf("account suspended")
+// SetVerifiedPhone stores phone as the user's verified number.
+func (us *UserStore) SetVerifiedPhone(user *User, phone string) error {
+	err := us.DB.Model(&User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"phone_number":   phone,
+		"phone_verified": true,
+	}).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return utils.ErrPhoneNumberInUse
+		}
+		return err
 	}
 
-	if user.PasswordTries >= 5 {
-		fmt.Fprintln(os.Stdout, "UserLogin Error: Account Blocked!")
-		return user, fmt.Errorf("account blocked")
-	}
+	user.PhoneNumber = &phone
+	user.PhoneVerified = true
+	return nil
+}
 
-	isCorrectPassword := us.ComparePassword(&user, loginDto.Password)
+// CheckSSOSignIn returns utils.ErrSSORequired when email may only sign in
+// through SSO.
+func (us *UserStore) CheckSSOSignIn(email string) error {
+	return checkSSOSignIn(us.DB, email)
+}
 
-	if !isCorrectPassword {
+// UserLogin checks the email and password. Unknown emails, accounts without
+// a password and wrong passwords all return utils.ErrInvalidCredentials, and
+// the account's status is only revealed once the password is correct.
+// Throttling is applied by LoginThrottleStore.Login.
+func (us *UserStore) UserLogin(loginDto dto.UserLoginDto) (User, error) {
+	// checked by domain before the lookup so it reveals nothing about the account
+	if err := checkSSOSignIn(us.DB, loginDto.Email); err != nil {
+		return User{}, err
+	}
 
-		user.PasswordTries++
+	user, err := us.UserGetByEmail(loginDto.Email)
 
-		if user.PasswordTries >= 5 {
-			user.AccountBlocked = true
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			fmt.Fprintln(os.Stdout, "UserLogin Error: User not found!")
+			return User{}, utils.ErrInvalidCredentials
 		}
+		fmt.Fprintf(os.Stdout, "UserLogin Error: Failed to find user\n%v", err)
+		return User{}, err
+	}
 
-		err = us.Update(&user)
-
-		if err != nil {
-			fmt.Fprintf(os.Stdout, "UserLogin Error: Failed to update user\n%v", err)
-			return user, err
-		}
+	if !user.HasPassword() {
+		fmt.Fprintln(os.Stdout, "UserLogin Error: Account has no password!")
+		return User{}, utils.ErrInvalidCredentials
+	}
 
+	if !us.ComparePassword(&user, loginDto.Password) {
 		fmt.Fprintln(os.Stdout, "UserLogin Error: Incorrect Password!")
-		return user, fmt.Errorf("incorrect password")
+		return User{}, utils.ErrInvalidCredentials
 	}
 
-	user.PasswordTries = 0
-
-	err = us.Update(&user)
-
-	if err != nil {
-		fmt.Fprintf(os.Stdout, "UserLogin Error: Failed to update user\n%v", err)
-		return user, err
+	if err := user.CanLogin(); err != nil {
+		fmt.Fprintf(os.Stdout, "UserLogin Error: %v\n", err)
+		return User{}, err
 	}
 
 	return user, nil
@@ -0,0 +1,296 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type UserIdentityStore struct {
+	DB        *gorm.DB
+	UserStore *UserStore
+}
+
+func NewUserIdentityStore(db *gorm.DB, userStore *UserStore) *UserIdentityStore {
+	return &UserIdentityStore{DB: db, UserStore: userStore}
+}
+
+// OAuthProfile is the user as reported by an OAuth provider after sign-in.
+type OAuthProfile struct {
+	Provider       string
+	ProviderUserID string
+	Email          string
+	// EmailVerified is true only when the provider asserts it has verified Email
+	EmailVerified  bool
+	Name           string
+	AvatarURL      string
+	AccessToken    string
+	RefreshToken   string
+	TokenExpiresAt *time.Time
+}
+
+// UserIdentity links a user to an account at an OAuth provider. A user has at
+// most one identity per provider. Provider tokens are encrypted at rest.
+type UserIdentity struct {
+	ID             uuid.UUID             `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	UserID         uuid.UUID             `gorm:"column:user_id;type:uuid;uniqueIndex:idx_user_identities_user_provider" json:"userId"`
+	Provider       string                `gorm:"column:provider;uniqueIndex:idx_user_identities_user_provider;uniqueIndex:idx_user_identities_provider_subject" json:"provider"`
+	ProviderUserID string                `gorm:"column:provider_user_id;uniqueIndex:idx_user_identities_provider_subject" json:"providerUserId"`
+	Email          string                `gorm:"column:email" json:"email"`
+	EmailVerified  bool                  `gorm:"column:email_verified" json:"emailVerified"`
+	Name           string                `gorm:"column:name" json:"name"`
+	AvatarURL      string                `gorm:"column:avatar_url" json:"avatarUrl"`
+	AccessToken    utils.EncryptedString `gorm:"column:access_token" json:"-"`
+	RefreshToken   utils.EncryptedString `gorm:"column:refresh_token" json:"-"`
+	TokenExpiresAt *time.Time            `gorm:"column:token_expires_at" json:"tokenExpiresAt"`
+	LastLoginAt    *time.Time            `gorm:"column:last_login_at" json:"lastLoginAt"`
+	CreatedAt      time.Time             `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+	UpdatedAt      time.Time             `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt"`
+}
+
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
+
+func newUserIdentity(userID uuid.UUID, profile *OAuthProfile) *UserIdentity {
+	return &UserIdentity{
+		UserID:         userID,
+		Provider:       profile.Provider,
+		ProviderUserID: profile.ProviderUserID,
+		Email:          profile.Email,
+		EmailVerified:  profile.EmailVerified,
+		Name:           profile.Name,
+		AvatarURL:      profile.AvatarURL,
+		AccessToken:    utils.EncryptedString(profile.AccessToken),
+		RefreshToken:   utils.EncryptedString(profile.RefreshToken),
+		TokenExpiresAt: profile.TokenExpiresAt,
+	}
+}
+
+// refresh copies the latest profile and tokens onto identity. A missing
+// refresh token keeps the stored one, as most providers only send it once.
+func (uis *UserIdentityStore) refresh(tx *gorm.DB, identity *UserIdentity, profile *OAuthProfile) error {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"email":            profile.Email,
+		"email_verified":   profile.EmailVerified,
+		"name":             profile.Name,
+		"avatar_url":       profile.AvatarURL,
+		"access_token":     utils.EncryptedString(profile.AccessToken),
+		"token_expires_at": profile.TokenExpiresAt,
+		"last_login_at":    &now,
+	}
+	if profile.RefreshToken != "" {
+		updates["refresh_token"] = utils.EncryptedString(profile.RefreshToken)
+	}
+	return tx.Model(&UserIdentity{}).Where("id = ?", identity.ID).Updates(updates).Error
+}
+
+func (uis *UserIdentityStore) getByProviderUserID(tx *gorm.DB, provider, providerUserID string) (*UserIdentity, error) {
+	var identity UserIdentity
+	err := tx.Where("provider = ? AND provider_user_id = ?", provider, providerUserID).First(&identity).Error
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (uis *UserIdentityStore) create(tx *gorm.DB, identity *UserIdentity) error {
+	now := time.Now()
+	identity.LastLoginAt = &now
+	if err := tx.Create(identity).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			// either the provider account was linked concurrently or the user
+			// already has a different account from this provider; the lookup
+			// runs outside tx, which the failed insert has aborted
+			if _, err := uis.getByProviderUserID(uis.DB, identity.Provider, identity.ProviderUserID); err == nil {
+				return utils.ErrIdentityLinkedElsewhere
+			}
+			return utils.ErrIdentityProviderLinked
+		}
+		return err
+	}
+	return nil
+}
+
+// Login returns the user signing in with profile. A known provider account
+// signs in its linked user. Otherwise an account with the same email is
+// linked, but only when the provider asserts the email is verified; if
+// there is no such account a new one is created.
+func (uis *UserIdentityStore) Login(profile *OAuthProfile) (User, error) {
+	identity, err := uis.getByProviderUserID(uis.DB, profile.Provider, profile.ProviderUserID)
+	if err == nil {
+		var user User
+		if err := uis.DB.Where("id = ?", identity.UserID).First(&user).Error; err != nil {
+			return User{}, err
+		}
+		if err := checkSSOSignIn(uis.DB, user.Email); err != nil {
+			return User{}, err
+		}
+		return user, uis.refresh(uis.DB, identity, profile)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return User{}, err
+	}
+
+	if profile.Email == "" {
+		return User{}, utils.ErrOAuthEmailMissing
+	}
+	// also keeps the provider from creating accounts in a domain that enforces SSO
+	if err := checkSSOSignIn(uis.DB, profile.Email); err != nil {
+		return User{}, err
+	}
+
+	user, err := uis.UserStore.UserGetByEmail(profile.Email)
+	switch {
+	case err == nil:
+		if !profile.EmailVerified {
+			return User{}, utils.ErrIdentityEmailUnverified
+		}
+		err = uis.DB.Transaction(func(tx *gorm.DB) error {
+			if !user.EmailVerified {
+				// the existing account never proved it owns the address, so it
+				// may have been registered by someone else ahead of the owner;
+				// their password and passkeys must not survive the link
+				if err := uis.takeOverUnverifiedAccount(tx, &user); err != nil {
+					return err
+				}
+			}
+			return uis.create(tx, newUserIdentity(user.ID, profile))
+		})
+		return user, err
+
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		// one transaction, so a failed link leaves no account behind
+		err = uis.DB.Transaction(func(tx *gorm.DB) error {
+			var err error
+			user, err = uis.UserStore.createUser(tx, dto.UserCreateDto{
+				Name:  profile.Name,
+				Email: profile.Email,
+			})
+			if err != nil {
+				return err
+			}
+			if profile.EmailVerified {
+				if err := uis.markEmailVerified(tx, &user); err != nil {
+					return err
+				}
+			}
+			return uis.create(tx, newUserIdentity(user.ID, profile))
+		})
+		return user, err
+
+	default:
+		return User{}, err
+	}
+}
+
+// takeOverUnverifiedAccount removes every way of signing in set up before
+// user's address was proven, signs out their sessions and marks the address
+// verified on the provider's word. The caller links the new identity after.
+func (uis *UserIdentityStore) takeOverUnverifiedAccount(tx *gorm.DB, user *User) error {
+	now := time.Now()
+	err := tx.Model(&User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"password":            "",
+		"phone_number":        nil,
+		"phone_verified":      false,
+		"sessions_revoked_at": &now,
+	}).Error
+	if err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&Passkey{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&UserIdentity{}).Error; err != nil {
+		return err
+	}
+	user.Password = ""
+	user.PhoneNumber = nil
+	user.PhoneVerified = false
+	user.SessionsRevokedAt = &now
+	return uis.markEmailVerified(tx, user)
+}
+
+func (uis *UserIdentityStore) markEmailVerified(tx *gorm.DB, user *User) error {
+	if err := tx.Model(&User{}).Where("id = ?", user.ID).Update("email_verified", true).Error; err != nil {
+		return err
+	}
+	user.EmailVerified = true
+	return uis.UserStore.WebhookStore.Emit(tx, user.ID, utils.WebhookEventUserEmailVerified, user.WebhookData())
+}
+
+// Link adds the provider account in profile to user, who is already signed in.
+func (uis *UserIdentityStore) Link(user *User, profile *OAuthProfile) (*UserIdentity, error) {
+	identity, err := uis.getByProviderUserID(uis.DB, profile.Provider, profile.ProviderUserID)
+	if err == nil {
+		if identity.UserID != user.ID {
+			return nil, utils.ErrIdentityLinkedElsewhere
+		}
+		if err := uis.refresh(uis.DB, identity, profile); err != nil {
+			return nil, err
+		}
+		return uis.Get(user.ID, identity.ID)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	identity = newUserIdentity(user.ID, profile)
+	if err := uis.create(uis.DB, identity); err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+func (uis *UserIdentityStore) Get(userID, id uuid.UUID) (*UserIdentity, error) {
+	var identity UserIdentity
+	err := uis.DB.Where("id = ? AND user_id = ?", id, userID).First(&identity).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrIdentityNotFound
+		}
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (uis *UserIdentityStore) List(userID uuid.UUID) ([]UserIdentity, error) {
+	var identities []UserIdentity
+	err := uis.DB.Where("user_id = ?", userID).Order("created_at ASC").Find(&identities).Error
+	return identities, err
+}
+
+// Unlink removes one of user's identities. The last way to sign in other
+// than by email cannot be removed.
+func (uis *UserIdentityStore) Unlink(user *User, id uuid.UUID) error {
+	return uis.DB.Transaction(func(tx *gorm.DB) error {
+		var identity UserIdentity
+		err := tx.Where("id = ? AND user_id = ?", id, user.ID).First(&identity).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return utils.ErrIdentityNotFound
+			}
+			return err
+		}
+
+		if !user.HasPassword() {
+			var identities, passkeys int64
+			if err := tx.Model(&UserIdentity{}).Where("user_id = ?", user.ID).Count(&identities).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&Passkey{}).Where("user_id = ?", user.ID).Count(&passkeys).Error; err != nil {
+				return err
+			}
+			if identities <= 1 && passkeys == 0 {
+				return utils.ErrIdentityLastLoginMethod
+			}
+		}
+
+		return tx.Delete(&identity).Error
+	})
+}
@@ -0,0 +1,652 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	webhookMaxAttempts = 10
+	webhookBatchSize   = 20
+	// webhookLease is how long a worker owns a claimed delivery, see emailOutboxLease.
+	webhookLease          = 2 * time.Minute
+	webhookRequestTimeout = 15 * time.Second
+	// an endpoint is disabled once it has failed this many attempts in a row
+	// over at least webhookDisableAfter
+	webhookDisableAfterFailures = 20
+	webhookDisableAfter         = 24 * time.Hour
+	// webhookResponseDrainLimit caps how much of a response body is read so
+	// the connection can be reused; the body itself is never kept.
+	webhookResponseDrainLimit = 4 * 1024
+)
+
+// webhookBlockedPrefixes are ranges endpoints may not connect to on top of
+// the loopback, private, link-local, multicast and unspecified ones.
+var webhookBlockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+type WebhookStore struct {
+	DB     *gorm.DB
+	Client *http.Client
+}
+
+func NewWebhookStore(db *gorm.DB) *WebhookStore {
+	return &WebhookStore{
+		DB:     db,
+		Client: newWebhookClient(),
+	}
+}
+
+// newWebhookClient returns the client deliveries are posted with. It only
+// connects to public addresses and does not follow redirects, so an
+// endpoint cannot point deliveries at internal services.
+func newWebhookClient() *http.Client {
+	return &http.Client{
+		Timeout: webhookRequestTimeout,
+		Transport: &http.Transport{
+			// a proxy would make the connection on our behalf, past the address check
+			Proxy:                 nil,
+			DialContext:           WebhookDialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func webhookAddressAllowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range webhookBlockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// WebhookDialContext resolves the host itself and connects to the vetted
+// address, so a DNS answer that changes between the check and the connection
+// cannot reach a private or reserved address. It fails with
+// utils.ErrWebhookAddressBlocked when any resolved address is not public.
+func WebhookDialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no addresses found for %s", host)
+	}
+	for _, addr := range addrs {
+		if !webhookAddressAllowed(addr) {
+			return nil, utils.ErrWebhookAddressBlocked
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	var dialErr error
+	for _, addr := range addrs {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(addr.Unmap().String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		dialErr = err
+	}
+	return nil, dialErr
+}
+
+// WebhookEndpoint is a customer URL that receives events for the owning user,
+// or for every user when AllUsers is set by an admin.
+type WebhookEndpoint struct {
+	ID                  uuid.UUID            `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	UserID              uuid.UUID            `gorm:"column:user_id;type:uuid;index" json:"userId"`
+	URL                 string               `gorm:"column:url" json:"url"`
+	Description         string               `gorm:"column:description" json:"description"`
+	Secret              string               `gorm:"column:secret" json:"-"`
+	EventTypes          []utils.WebhookEvent `gorm:"column:event_types;type:jsonb;serializer:json" json:"eventTypes"`
+	AllUsers            bool                 `gorm:"column:all_users" json:"allUsers"`
+	Enabled             bool                 `gorm:"column:enabled" json:"enabled"`
+	ConsecutiveFailures int                  `gorm:"column:consecutive_failures" json:"consecutiveFailures"`
+	FailingSince        *time.Time           `gorm:"column:failing_since" json:"failingSince"`
+	DisabledAt          *time.Time           `gorm:"column:disabled_at" json:"disabledAt"`
+	DisabledReason      string               `gorm:"column:disabled_reason" json:"disabledReason"`
+	CreatedAt           time.Time            `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+	UpdatedAt           time.Time            `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt"`
+}
+
+// Subscribed reports whether the endpoint wants event; no filter means every event.
+func (we *WebhookEndpoint) Subscribed(event utils.WebhookEvent) bool {
+	return len(we.EventTypes) == 0 || slices.Contains(we.EventTypes, event)
+}
+
+// WebhookDelivery is one event queued for one endpoint. Payload is the exact
+// body that is signed and posted.
+type WebhookDelivery struct {
+	ID                uuid.UUID                   `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	EndpointID        uuid.UUID                   `gorm:"column:endpoint_id;type:uuid;index" json:"endpointId"`
+	EventID           uuid.UUID                   `gorm:"column:event_id;type:uuid;index" json:"eventId"`
+	EventType         utils.WebhookEvent          `gorm:"column:event_type" json:"eventType"`
+	Payload           utils.JSONMap[any]          `gorm:"column:payload" json:"payload"`
+	Status            utils.WebhookDeliveryStatus `gorm:"column:status;index" json:"status"`
+	Attempts          int                         `gorm:"column:attempts" json:"attempts"`
+	MaxAttempts       int                         `gorm:"column:max_attempts" json:"maxAttempts"`
+	LastResponseCode  int                         `gorm:"column:last_response_code" json:"lastResponseCode"`
+	LastError         string                      `gorm:"column:last_error" json:"lastError"`
+	NextAttemptAt     time.Time                   `gorm:"column:next_attempt_at;index" json:"nextAttemptAt"`
+	DeliveredAt       *time.Time                  `gorm:"column:delivered_at" json:"deliveredAt"`
+	RedeliveredFromID *uuid.UUID                  `gorm:"column:redelivered_from_id;type:uuid" json:"redeliveredFromId"`
+	Log               []WebhookDeliveryAttempt    `gorm:"foreignKey:DeliveryID;references:ID" json:"log,omitempty"`
+	CreatedAt         time.Time                   `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+	UpdatedAt         time.Time                   `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt"`
+}
+
+// WebhookDeliveryAttempt logs a single HTTP attempt of a delivery.
+type WebhookDeliveryAttempt struct {
+	ID           uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4()" json:"id"`
+	DeliveryID   uuid.UUID `gorm:"column:delivery_id;type:uuid;index" json:"deliveryId"`
+	ResponseCode int       `gorm:"column:response_code" json:"responseCode"`
+	Error        string    `gorm:"column:error" json:"error"`
+	DurationMs   int64     `gorm:"column:duration_ms" json:"durationMs"`
+	CreatedAt    time.Time `gorm:"column:created_at;autoCreateTime" json:"createdAt"`
+}
+
+type webhookEnvelope struct {
+	ID        uuid.UUID          `json:"id"`
+	Type      utils.WebhookEvent `json:"type"`
+	CreatedAt time.Time          `json:"createdAt"`
+	Data      interface{}        `json:"data"`
+}
+
+func newWebhookSecret() (string, error) {
+	secret, err := GetAlphaNumString(40, "alphanum")
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + secret, nil
+}
+
+func validateWebhookEvents(events []utils.WebhookEvent) error {
+	for _, event := range events {
+		if !event.IsValid() {
+			return fmt.Errorf("invalid webhook event type %q", event)
+		}
+	}
+	return nil
+}
+
+// CreateEndpoint registers a new endpoint for user. The returned endpoint's
+// Secret is only ever shown to the caller here and by RotateSecret.
+func (ws *WebhookStore) CreateEndpoint(user *User, body dto.WebhookEndpointCreateDto) (*WebhookEndpoint, error) {
+	if err := validateWebhookEvents(body.EventTypes); err != nil {
+		return nil, err
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := WebhookEndpoint{
+		UserID:      user.ID,
+		URL:         body.URL,
+		Description: body.Description,
+		Secret:      secret,
+		EventTypes:  body.EventTypes,
+		AllUsers:    body.AllUsers && user.Role == UserRoleAdmin,
+		Enabled:     true,
+	}
+
+	if err := ws.DB.Create(&endpoint).Error; err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+// GetEndpoint returns an endpoint owned by user; admins can read any endpoint.
+func (ws *WebhookStore) GetEndpoint(user *User, id uuid.UUID) (*WebhookEndpoint, error) {
+	var endpoint WebhookEndpoint
+	query := ws.DB.Where("id = ?", id)
+	if user.Role != UserRoleAdmin {
+		query = query.Where("user_id = ?", user.ID)
+	}
+	if err := query.First(&endpoint).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+func (ws *WebhookStore) ListEndpoints(user *User) ([]WebhookEndpoint, error) {
+	var endpoints []WebhookEndpoint
+	err := ws.DB.Where("user_id = ?", user.ID).Order("created_at DESC").Find(&endpoints).Error
+	return endpoints, err
+}
+
+// UpdateEndpoint applies body. Re-enabling an endpoint clears its failure state.
+func (ws *WebhookStore) UpdateEndpoint(endpoint *WebhookEndpoint, body dto.WebhookEndpointUpdateDto) error {
+	var columns []string
+
+	if body.URL != nil {
+		endpoint.URL = *body.URL
+		columns = append(columns, "url")
+	}
+	if body.Description != nil {
+		endpoint.Description = *body.Description
+		columns = append(columns, "description")
+	}
+	if body.EventTypes != nil {
+		if err := validateWebhookEvents(*body.EventTypes); err != nil {
+			return err
+		}
+		endpoint.EventTypes = *body.EventTypes
+		columns = append(columns, "event_types")
+	}
+	if body.Enabled != nil && *body.Enabled != endpoint.Enabled {
+		endpoint.Enabled = *body.Enabled
+		if endpoint.Enabled {
+			endpoint.ConsecutiveFailures = 0
+			endpoint.FailingSince = nil
+			endpoint.DisabledAt = nil
+			endpoint.DisabledReason = ""
+		} else {
+			now := time.Now()
+			endpoint.DisabledAt = &now
+			endpoint.DisabledReason = "disabled by user"
+		}
+		columns = append(columns, "enabled", "consecutive_failures", "failing_since", "disabled_at", "disabled_reason")
+	}
+
+	if len(columns) == 0 {
+		return nil
+	}
+	return ws.DB.Model(endpoint).Select(columns).Updates(endpoint).Error
+}
+
+func (ws *WebhookStore) RotateSecret(endpoint *WebhookEndpoint) error {
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return err
+	}
+	endpoint.Secret = secret
+	return ws.DB.Model(&WebhookEndpoint{}).Where("id = ?", endpoint.ID).Update("secret", secret).Error
+}
+
+// DeleteEndpoint removes the endpoint together with its delivery log.
+func (ws *WebhookStore) DeleteEndpoint(endpoint *WebhookEndpoint) error {
+	return ws.DB.Transaction(func(tx *gorm.DB) error {
+		deliveries := tx.Model(&WebhookDelivery{}).Select("id").Where("endpoint_id = ?", endpoint.ID)
+		if err := tx.Where("delivery_id IN (?)", deliveries).Delete(&WebhookDeliveryAttempt{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("endpoint_id = ?", endpoint.ID).Delete(&WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(endpoint).Error
+	})
+}
+
+func (ws *WebhookStore) ListDeliveries(endpoint *WebhookEndpoint, filter dto.WebhookDeliveryFilterDto, start, limit int) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+
+	query := ws.DB.Model(&WebhookDelivery{}).Where("endpoint_id = ?", endpoint.ID)
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.EventType != "" {
+		query = query.Where("event_type = ?", filter.EventType)
+	}
+
+	err := query.Order("created_at DESC").Offset(start).Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+// GetDelivery returns a delivery of endpoint with its attempt log.
+func (ws *WebhookStore) GetDelivery(endpoint *WebhookEndpoint, id uuid.UUID) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	err := ws.DB.Where("id = ? AND endpoint_id = ?", id, endpoint.ID).
+		Preload("Log", func(db *gorm.DB) *gorm.DB { return db.Order("created_at") }).
+		First(&delivery).Error
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// Redeliver queues a fresh copy of a delivery with the same event ID and
+// payload, so receivers can deduplicate on the event ID.
+func (ws *WebhookStore) Redeliver(endpoint *WebhookEndpoint, id uuid.UUID) (*WebhookDelivery, error) {
+	if !endpoint.Enabled {
+		return nil, utils.ErrWebhookDisabled
+	}
+
+	original, err := ws.GetDelivery(endpoint, id)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := WebhookDelivery{
+		EndpointID:        endpoint.ID,
+		EventID:           original.EventID,
+		EventType:         original.EventType,
+		Payload:           original.Payload,
+		Status:            utils.WebhookDeliveryStatusPending,
+		MaxAttempts:       webhookMaxAttempts,
+		NextAttemptAt:     time.Now(),
+		RedeliveredFromID: &original.ID,
+	}
+	if err := ws.DB.Create(&delivery).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// Emit queues event for every enabled endpoint subscribed to it: the endpoints
+// of userID and the admin endpoints that receive every user's events. Pass the
+// transaction performing the change as tx so the event is only queued if it
+// commits; a nil tx uses the store's connection.
+func (ws *WebhookStore) Emit(tx *gorm.DB, userID uuid.UUID, event utils.WebhookEvent, data interface{}) error {
+	if tx == nil {
+		tx = ws.DB
+	}
+
+	var endpoints []WebhookEndpoint
+	err := tx.Where("enabled = ? AND (user_id = ? OR all_users = ?)", true, userID, true).Find(&endpoints).Error
+	if err != nil {
+		return fmt.Errorf("failed to find webhook endpoints: %w", err)
+	}
+
+	var deliveries []WebhookDelivery
+	var payload utils.JSONMap[any]
+	eventID := uuid.New()
+
+	for _, endpoint := range endpoints {
+		if !endpoint.Subscribed(event) {
+			continue
+		}
+		if payload == nil {
+			if payload, err = webhookPayload(eventID, event, data); err != nil {
+				return err
+			}
+		}
+		deliveries = append(deliveries, WebhookDelivery{
+			EndpointID:    endpoint.ID,
+			EventID:       eventID,
+			EventType:     event,
+			Payload:       payload,
+			Status:        utils.WebhookDeliveryStatusPending,
+			MaxAttempts:   webhookMaxAttempts,
+			NextAttemptAt: time.Now(),
+		})
+	}
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+	if err := tx.Create(&deliveries).Error; err != nil {
+		return fmt.Errorf("failed to queue webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+func webhookPayload(eventID uuid.UUID, event utils.WebhookEvent, data interface{}) (utils.JSONMap[any], error) {
+	raw, err := json.Marshal(webhookEnvelope{ID: eventID, Type: event, CreatedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+	var payload utils.JSONMap[any]
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// SignWebhook returns the X-Webhook-Signature value for body sent at timestamp:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">". Receivers should
+// recompute it and reject stale timestamps to prevent replays.
+func SignWebhook(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// claim locks up to limit due deliveries and leases them to the caller.
+func (ws *WebhookStore) claim(limit int) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+
+	err := ws.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status IN ? AND next_attempt_at <= ?", []utils.WebhookDeliveryStatus{utils.WebhookDeliveryStatusPending, utils.WebhookDeliveryStatusDelivering}, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&deliveries).Error
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(deliveries))
+		for i := range deliveries {
+			ids[i] = deliveries[i].ID
+			deliveries[i].Status = utils.WebhookDeliveryStatusDelivering
+		}
+
+		return tx.Model(&WebhookDelivery{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"status":          utils.WebhookDeliveryStatusDelivering,
+			"next_attempt_at": now.Add(webhookLease),
+		}).Error
+	})
+
+	return deliveries, err
+}
+
+// post sends the delivery once and returns the response code, 0 when no
+// response was received. Redirects count as failures.
+func (ws *WebhookStore) post(ctx context.Context, endpoint *WebhookEndpoint, delivery *WebhookDelivery) (int, error) {
+	body, err := json.Marshal(delivery.Payload)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	if req.URL.Scheme != "https" {
+		return 0, errors.New("webhook url must use https")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "factory-backend-webhooks/1.0")
+	req.Header.Set("X-Webhook-Id", delivery.EventID.String())
+	req.Header.Set("X-Webhook-Event", string(delivery.EventType))
+	req.Header.Set("X-Webhook-Signature", SignWebhook(endpoint.Secret, time.Now(), body))
+
+	res, err := ws.Client.Do(req)
+	if err != nil {
+		if errors.Is(err, utils.ErrWebhookAddressBlocked) {
+			return 0, utils.ErrWebhookAddressBlocked
+		}
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, webhookResponseDrainLimit))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("endpoint responded with status %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+func (ws *WebhookStore) deliver(ctx context.Context, delivery *WebhookDelivery) error {
+	var endpoint WebhookEndpoint
+	if err := ws.DB.Where("id = ?", delivery.EndpointID).First(&endpoint).Error; err != nil {
+		return err
+	}
+
+	if !endpoint.Enabled {
+		return ws.DB.Model(&WebhookDelivery{}).Where("id = ?", delivery.ID).Updates(map[string]interface{}{
+			"status":     utils.WebhookDeliveryStatusFailed,
+			"last_error": utils.ErrWebhookDisabled.Error(),
+		}).Error
+	}
+
+	delivery.Attempts++
+	started := time.Now()
+	code, sendErr := ws.post(ctx, &endpoint, delivery)
+
+	attempt := WebhookDeliveryAttempt{
+		DeliveryID:   delivery.ID,
+		ResponseCode: code,
+		DurationMs:   time.Since(started).Milliseconds(),
+	}
+	updates := map[string]interface{}{
+		"attempts":           delivery.Attempts,
+		"last_response_code": code,
+	}
+
+	if sendErr == nil {
+		now := time.Now()
+		updates["status"] = utils.WebhookDeliveryStatusSucceeded
+		updates["last_error"] = ""
+		updates["delivered_at"] = &now
+	} else {
+		attempt.Error = sendErr.Error()
+		updates["last_error"] = sendErr.Error()
+		if delivery.Attempts >= delivery.MaxAttempts {
+			updates["status"] = utils.WebhookDeliveryStatusFailed
+		} else {
+			updates["status"] = utils.WebhookDeliveryStatusPending
+			updates["next_attempt_at"] = time.Now().Add(webhookBackoff(delivery.Attempts))
+		}
+	}
+
+	err := ws.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&attempt).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&WebhookDelivery{}).Where("id = ?", delivery.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		return ws.recordEndpointResult(tx, &endpoint, sendErr == nil)
+	})
+	if err != nil {
+		return errors.Join(sendErr, err)
+	}
+	return sendErr
+}
+
+// recordEndpointResult tracks consecutive failures and disables endpoints that
+// have kept failing for webhookDisableAfter.
+func (ws *WebhookStore) recordEndpointResult(tx *gorm.DB, endpoint *WebhookEndpoint, succeeded bool) error {
+	query := tx.Model(&WebhookEndpoint{}).Where("id = ?", endpoint.ID)
+
+	if succeeded {
+		if endpoint.ConsecutiveFailures == 0 {
+			return nil
+		}
+		return query.Updates(map[string]interface{}{"consecutive_failures": 0, "failing_since": nil}).Error
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"consecutive_failures": gorm.Expr("consecutive_failures + 1")}
+	if endpoint.FailingSince == nil {
+		updates["failing_since"] = now
+	} else if endpoint.ConsecutiveFailures+1 >= webhookDisableAfterFailures && now.Sub(*endpoint.FailingSince) >= webhookDisableAfter {
+		updates["enabled"] = false
+		updates["disabled_at"] = now
+		updates["disabled_reason"] = fmt.Sprintf("disabled after %d consecutive failed deliveries since %s", endpoint.ConsecutiveFailures+1, endpoint.FailingSince.UTC().Format(time.RFC3339))
+	}
+	return query.Updates(updates).Error
+}
+
+// webhookBackoff doubles the wait after every failed attempt, capped at six hours.
+func webhookBackoff(attempts int) time.Duration {
+	backoff := 30 * time.Second << (attempts - 1)
+	if backoff <= 0 || backoff > 6*time.Hour {
+		return 6 * time.Hour
+	}
+	return backoff
+}
+
+// ProcessPending attempts one batch of due deliveries and returns how many were claimed.
+func (ws *WebhookStore) ProcessPending(ctx context.Context, logger *log.Logger) (int, error) {
+	deliveries, err := ws.claim(webhookBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range deliveries {
+		if err := ws.deliver(ctx, &deliveries[i]); err != nil {
+			logger.Printf("Webhook: failed to deliver %s (attempt %d/%d): %v", deliveries[i].ID, deliveries[i].Attempts, deliveries[i].MaxAttempts, err)
+		}
+	}
+	return len(deliveries), nil
+}
+
+// RunWorker polls for due deliveries every interval until ctx is cancelled.
+func (ws *WebhookStore) RunWorker(ctx context.Context, logger *log.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				claimed, err := ws.ProcessPending(ctx, logger)
+				if err != nil {
+					logger.Printf("Webhook worker error: %v", err)
+					break
+				}
+				if claimed < webhookBatchSize {
+					break
+				}
+			}
+		}
+	}
+}
@@ -1,86 +1,101 @@
 package notifications
 
 import (
-	"bytes"
 	"fmt"
-	"net/smtp"
-	"text/template"
+	"net/mail"
+	"strings"
+	"time"
 
+	"github.com/21TechLabs/factory-backend/config"
+	"github.com/21TechLabs/factory-backend/notifications/message"
 	"github.com/21TechLabs/factory-backend/utils"
+	"github.com/google/uuid"
 )
 
-var emailFrom string
-var emailHOST string
-var emailPORT string
-var emailUSER string
-var emailPASSWORD string
-var emailAuth smtp.Auth
-
-func init() {
-	utils.LoadEnv()
-
-	emailFrom = utils.GetEnv("EMAIL_FROM", false)
-	emailHOST = utils.GetEnv("EMAIL_HOST", false)
-	emailPORT = utils.GetEnv("EMAIL_PORT", false)
-	emailUSER = utils.GetEnv("EMAIL_USER", false)
-	emailPASSWORD = utils.GetEnv("EMAIL_PASSWORD", false)
-	emailAuth = smtp.PlainAuth("", emailUSER, emailPASSWORD, emailHOST)
+// Request is an email waiting to be rendered by an EmailSender. HTML and Text
+// are both optional but at least one must be set; with both the message is
+// sent as multipart/alternative.
+type Request struct {
+	// Category decides whether the recipient's notification preferences
+	// allow the email to be sent; see utils.NotificationCategory.
+	Category    utils.NotificationCategory
+	To          []string
+	Subject     string
+	HTML        string
+	Text        string
+	ReplyTo     []string
+	Headers     map[string]string
+	Attachments []message.Attachment
+	// Template, TemplateVersion and Locale record what the body was rendered
+	// from; they are informational and never sent.
+	Template        string
+	TemplateVersion int
+	Locale          string
 }
 
-func SendEmail(_to string, _subject string, _body string) error {
-	var to = []string{_to}
-
-	msg := []byte(fmt.Sprintf("To: %s\r\n"+
-		"Subject: %s\r\n"+
-		"\r\n"+
-		"%s\r\n", to, _subject, _body))
-
-	err := smtp.SendMail(fmt.Sprintf("%s:%s", emailHOST, emailPORT), emailAuth, emailFrom, to, msg)
-
-	if err != nil {
-		fmt.Println(err)
-		return err
+// NewRequest creates a request with a plain-text body; set HTML for the rich
+// alternative or build the request with templates.NewRequest.
+func NewRequest(category utils.NotificationCategory, to []string, subject, text string) *Request {
+	return &Request{
+		Category: category,
+		To:       to,
+		Subject:  subject,
+		Text:     text,
 	}
-
-	return nil
 }
 
-// Request struct
-type Request struct {
-	from    string
-	to      []string
-	subject string
-	body    string
+// Attach adds a file, e.g. an invoice PDF, to the request.
+func (r *Request) Attach(filename, contentType string, data []byte) {
+	r.Attachments = append(r.Attachments, message.Attachment{
+		Filename:    filename,
+		ContentType: contentType,
+		Data:        data,
+	})
 }
 
-func (r *Request) SendEmail() (bool, error) {
-	mime := "MIME-version: 1.0;\nContent-Type: text/html; charset=\"UTF-8\";\n\n"
-	subject := "Subject: " + r.subject + "!\n"
-	msg := []byte(subject + mime + "\n" + r.body)
+// Message builds the MIME message for the request sent from the given address.
+// A bare from address is given the brand name as its display name.
+func (r *Request) Message(from string, messageID string) (*message.Message, error) {
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from address %q: %w", from, err)
+	}
+	if sender.Name == "" {
+		sender.Name = config.Name
+	}
 
-	if err := smtp.SendMail(fmt.Sprintf("%s:%s", emailHOST, emailPORT), emailAuth, emailFrom, r.to, msg); err != nil {
-		return false, err
+	to, err := message.ParseAddressList(r.To)
+	if err != nil {
+		return nil, err
 	}
-	return true, nil
-}
 
-func NewRequest(to []string, subject, body string) *Request {
-	return &Request{
-		to:      to,
-		subject: subject,
-		body:    body,
+	replyTo, err := message.ParseAddressList(r.ReplyTo)
+	if err != nil {
+		return nil, err
 	}
+
+	return &message.Message{
+		From:        *sender,
+		To:          to,
+		ReplyTo:     replyTo,
+		Subject:     r.Subject,
+		Date:        time.Now(),
+		MessageID:   messageID,
+		Headers:     r.Headers,
+		Text:        r.Text,
+		HTML:        r.HTML,
+		Attachments: r.Attachments,
+	}, nil
 }
 
-func (r *Request) ParseTemplate(templateFileName string, data interface{}) error {
-	t, err := template.ParseFiles(templateFileName)
-	if err != nil {
-		return err
+// newMessageID returns an RFC 5322 Message-ID scoped to the sender's domain.
+func newMessageID(from string) string {
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		from = addr.Address
 	}
-	buf := new(bytes.Buffer)
-	if err = t.Execute(buf, data); err != nil {
-		return err
+	if at := strings.LastIndex(from, "@"); at >= 0 && at < len(from)-1 {
+		domain = from[at+1:]
 	}
-	r.body = buf.String()
-	return nil
+	return fmt.Sprintf("<%s@%s>", uuid.NewString(), domain)
 }
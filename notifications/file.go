@@ -0,0 +1,69 @@
+package notifications
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileSender appends every message to a local mbox file instead of sending it.
+// It is meant for development and tests; open the file with any mail client.
+type FileSender struct {
+	from string
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileSender(from, path string) *FileSender {
+	return &FileSender{from: from, path: path}
+}
+
+func (s *FileSender) Send(ctx context.Context, r *Request) (string, error) {
+	messageID := newMessageID(s.from)
+	msg, err := r.Message(s.from, messageID)
+	if err != nil {
+		return "", err
+	}
+	raw, err := msg.Bytes()
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From %s %s\n", msg.From.Address, time.Now().UTC().Format(time.ANSIC))
+
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		// mboxrd quoting so body lines can't be mistaken for a message separator
+		if strings.HasPrefix(strings.TrimLeft(line, ">"), "From ") {
+			line = ">" + line
+		}
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	buf.WriteByte('\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("failed to open mbox %s: %w", s.path, err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		return "", fmt.Errorf("failed to write mbox %s: %w", s.path, err)
+	}
+	return messageID, nil
+}
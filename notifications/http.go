@@ -0,0 +1,108 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/notifications/message"
+)
+
+type HTTPConfig struct {
+	From   string
+	URL    string
+	APIKey string
+}
+
+// HTTPSender posts messages as JSON to a generic email API, with attachment
+// data base64 encoded. Any 2xx response is treated as accepted; an "id" or
+// "messageId" field in the response body is recorded as the provider message ID.
+type HTTPSender struct {
+	config HTTPConfig
+	client *http.Client
+}
+
+type httpSendBody struct {
+	From        string               `json:"from"`
+	To          []string             `json:"to"`
+	ReplyTo     []string             `json:"replyTo,omitempty"`
+	Subject     string               `json:"subject"`
+	HTML        string               `json:"html,omitempty"`
+	Text        string               `json:"text,omitempty"`
+	Headers     map[string]string    `json:"headers,omitempty"`
+	Attachments []message.Attachment `json:"attachments,omitempty"`
+	MessageID   string               `json:"messageId"`
+}
+
+type httpSendResponse struct {
+	ID        string `json:"id"`
+	MessageID string `json:"messageId"`
+}
+
+func NewHTTPSender(config HTTPConfig) *HTTPSender {
+	return &HTTPSender{
+		config: config,
+		client: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (s *HTTPSender) Send(ctx context.Context, r *Request) (string, error) {
+	messageID := newMessageID(s.config.From)
+
+	// build the MIME message anyway so the API gets the same validated input
+	msg, err := r.Message(s.config.From, messageID)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := json.Marshal(httpSendBody{
+		From:        msg.From.String(),
+		To:          r.To,
+		ReplyTo:     r.ReplyTo,
+		Subject:     msg.Subject,
+		HTML:        msg.HTML,
+		Text:        msg.Text,
+		Headers:     msg.Headers,
+		Attachments: msg.Attachments,
+		MessageID:   messageID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create new HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.config.APIKey)
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send email request: %w", err)
+	}
+	defer res.Body.Close()
+
+	resBody, _ := io.ReadAll(io.LimitReader(res.Body, 64*1024))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return "", fmt.Errorf("failed to send email, status code: %d: %s", res.StatusCode, resBody)
+	}
+
+	var parsed httpSendResponse
+	if json.Unmarshal(resBody, &parsed) == nil {
+		if parsed.ID != "" {
+			return parsed.ID, nil
+		}
+		if parsed.MessageID != "" {
+			return parsed.MessageID, nil
+		}
+	}
+	return messageID, nil
+}
@@ -0,0 +1,313 @@
+// Package message composes RFC 5322 email messages with MIME bodies.
+//
+// A Message with both Text and HTML is sent as multipart/alternative, and any
+// attachments wrap that in multipart/mixed. Non-ASCII header values are encoded
+// per RFC 2047 and text parts use quoted-printable.
+package message
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+const crlf = "\r\n"
+
+// maxLineLength is the RFC 5322 recommended line length we wrap base64 at.
+const maxLineLength = 76
+
+var (
+	ErrMissingFrom       = errors.New("message: From is required")
+	ErrMissingRecipients = errors.New("message: at least one To recipient is required")
+	ErrMissingBody       = errors.New("message: Text or HTML body is required")
+	ErrInvalidHeader     = errors.New("message: header values must not contain line breaks")
+)
+
+// newBoundary returns a random multipart boundary. Tests swap it for a
+// deterministic generator so output can be compared with golden files.
+var newBoundary = func() string {
+	var buf [15]byte
+	if _, err := io.ReadFull(rand.Reader, buf[:]); err != nil {
+		panic(err)
+	}
+	return "factory-" + hex.EncodeToString(buf[:])
+}
+
+type Attachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	Data        []byte `json:"data"`
+}
+
+type Message struct {
+	From    mail.Address
+	To      []mail.Address
+	Cc      []mail.Address
+	ReplyTo []mail.Address
+	Subject string
+	// Date defaults to the current time when zero.
+	Date time.Time
+	// MessageID is written verbatim, including the angle brackets.
+	MessageID string
+	// Headers holds extra headers such as List-Unsubscribe. Values are RFC 2047
+	// encoded when they contain non-ASCII characters.
+	Headers     map[string]string
+	Text        string
+	HTML        string
+	Attachments []Attachment
+}
+
+// ParseAddressList parses a list of RFC 5322 addresses such as
+// `"Jane" <jane@example.com>, bob@example.com`.
+func ParseAddressList(list []string) ([]mail.Address, error) {
+	var addresses []mail.Address
+	for _, item := range list {
+		parsed, err := mail.ParseAddressList(item)
+		if err != nil {
+			return nil, fmt.Errorf("message: invalid address %q: %w", item, err)
+		}
+		for _, a := range parsed {
+			addresses = append(addresses, *a)
+		}
+	}
+	return addresses, nil
+}
+
+// Recipients returns the envelope recipients (To and Cc) as bare addresses.
+func (m *Message) Recipients() []string {
+	var rcpt []string
+	for _, a := range append(append([]mail.Address{}, m.To...), m.Cc...) {
+		rcpt = append(rcpt, a.Address)
+	}
+	return rcpt
+}
+
+// Bytes renders the complete message, headers and body, with CRLF line endings.
+func (m *Message) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *Message) validate() error {
+	if m.From.Address == "" {
+		return ErrMissingFrom
+	}
+	if len(m.To) == 0 {
+		return ErrMissingRecipients
+	}
+	if m.Text == "" && m.HTML == "" {
+		return ErrMissingBody
+	}
+	values := []string{m.Subject, m.MessageID}
+	for k, v := range m.Headers {
+		values = append(values, k, v)
+	}
+	for _, v := range values {
+		if strings.ContainsAny(v, "\r\n") {
+			return ErrInvalidHeader
+		}
+	}
+	return nil
+}
+
+// WriteTo implements io.WriterTo.
+func (m *Message) WriteTo(w io.Writer) (int64, error) {
+	if err := m.validate(); err != nil {
+		return 0, err
+	}
+
+	date := m.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	var buf bytes.Buffer
+	writeHeader(&buf, "Date", date.Format(time.RFC1123Z))
+	writeHeader(&buf, "From", m.From.String())
+	writeHeader(&buf, "To", formatAddressList(m.To))
+	if len(m.Cc) > 0 {
+		writeHeader(&buf, "Cc", formatAddressList(m.Cc))
+	}
+	if len(m.ReplyTo) > 0 {
+		writeHeader(&buf, "Reply-To", formatAddressList(m.ReplyTo))
+	}
+	writeHeader(&buf, "Subject", encodeHeaderValue(m.Subject))
+	if m.MessageID != "" {
+		writeHeader(&buf, "Message-ID", m.MessageID)
+	}
+
+	extra := make([]string, 0, len(m.Headers))
+	for k := range m.Headers {
+		extra = append(extra, k)
+	}
+	sort.Strings(extra)
+	for _, k := range extra {
+		writeHeader(&buf, textproto.CanonicalMIMEHeaderKey(k), encodeHeaderValue(m.Headers[k]))
+	}
+
+	writeHeader(&buf, "MIME-Version", "1.0")
+
+	if err := m.writeBody(&buf); err != nil {
+		return 0, err
+	}
+
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+// writeBody writes the Content-Type header for the top-level entity followed by its body.
+func (m *Message) writeBody(buf *bytes.Buffer) error {
+	if len(m.Attachments) == 0 {
+		return m.writeAlternative(buf, nil)
+	}
+
+	mixed := multipart.NewWriter(buf)
+	if err := mixed.SetBoundary(newBoundary()); err != nil {
+		return err
+	}
+	writeHeader(buf, "Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": mixed.Boundary()}))
+	buf.WriteString(crlf)
+
+	if err := m.writeAlternative(buf, mixed); err != nil {
+		return err
+	}
+
+	for _, a := range m.Attachments {
+		if err := writeAttachment(mixed, a); err != nil {
+			return err
+		}
+	}
+	return mixed.Close()
+}
+
+// writeAlternative writes the text and/or HTML body. With a nil parent the
+// headers go straight into buf, otherwise a new part of parent is created.
+func (m *Message) writeAlternative(buf *bytes.Buffer, parent *multipart.Writer) error {
+	if m.Text != "" && m.HTML != "" {
+		boundary := newBoundary()
+		contentType := mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": boundary})
+
+		var w io.Writer = buf
+		if parent == nil {
+			writeHeader(buf, "Content-Type", contentType)
+			buf.WriteString(crlf)
+		} else {
+			part, err := parent.CreatePart(textproto.MIMEHeader{"Content-Type": {contentType}})
+			if err != nil {
+				return err
+			}
+			w = part
+		}
+
+		alt := multipart.NewWriter(w)
+		if err := alt.SetBoundary(boundary); err != nil {
+			return err
+		}
+		if err := writeTextPart(alt, "text/plain", m.Text); err != nil {
+			return err
+		}
+		if err := writeTextPart(alt, "text/html", m.HTML); err != nil {
+			return err
+		}
+		return alt.Close()
+	}
+
+	mediaType, body := "text/plain", m.Text
+	if m.HTML != "" {
+		mediaType, body = "text/html", m.HTML
+	}
+
+	if parent != nil {
+		return writeTextPart(parent, mediaType, body)
+	}
+
+	writeHeader(buf, "Content-Type", mime.FormatMediaType(mediaType, map[string]string{"charset": "UTF-8"}))
+	writeHeader(buf, "Content-Transfer-Encoding", "quoted-printable")
+	buf.WriteString(crlf)
+	return writeQuotedPrintable(buf, body)
+}
+
+func writeTextPart(w *multipart.Writer, mediaType, body string) error {
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType(mediaType, map[string]string{"charset": "UTF-8"})},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+	return writeQuotedPrintable(part, body)
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func writeAttachment(w *multipart.Writer, a Attachment) error {
+	contentType := a.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType(contentType, map[string]string{"name": a.Filename})},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename})},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return err
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(a.Data)
+	for len(encoded) > maxLineLength {
+		if _, err := io.WriteString(part, encoded[:maxLineLength]+crlf); err != nil {
+			return err
+		}
+		encoded = encoded[maxLineLength:]
+	}
+	_, err = io.WriteString(part, encoded+crlf)
+	return err
+}
+
+func writeHeader(buf *bytes.Buffer, key, value string) {
+	buf.WriteString(key)
+	buf.WriteString(": ")
+	buf.WriteString(value)
+	buf.WriteString(crlf)
+}
+
+func formatAddressList(addresses []mail.Address) string {
+	formatted := make([]string, len(addresses))
+	for i := range addresses {
+		formatted[i] = addresses[i].String()
+	}
+	return strings.Join(formatted, ","+crlf+" ")
+}
+
+// encodeHeaderValue RFC 2047 encodes non-ASCII values and folds the resulting
+// encoded-words onto continuation lines.
+func encodeHeaderValue(value string) string {
+	encoded := mime.QEncoding.Encode("UTF-8", value)
+	if encoded == value {
+		return value
+	}
+	return strings.ReplaceAll(encoded, "?= =?", "?="+crlf+" =?")
+}
@@ -0,0 +1,154 @@
+package message
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+func deterministicBoundaries(t *testing.T) {
+	t.Helper()
+	original := newBoundary
+	n := 0
+	newBoundary = func() string {
+		n++
+		return fmt.Sprintf("boundary-%d", n)
+	}
+	t.Cleanup(func() { newBoundary = original })
+}
+
+func baseMessage() Message {
+	return Message{
+		From:      mail.Address{Name: "21 TechLabs", Address: "no-reply@21techlabs.com"},
+		To:        []mail.Address{{Name: "Jane Doe", Address: "jane@example.com"}},
+		Subject:   "Welcome to the family Jane.",
+		Date:      time.Date(2025, time.November, 7, 15, 57, 31, 0, time.UTC),
+		MessageID: "<0d5f1c2e-6f1e-4c59-9c3e-6a5f4d1c2b3a@21techlabs.com>",
+	}
+}
+
+func TestMessageGolden(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func() Message
+	}{
+		{
+			name: "text_only",
+			build: func() Message {
+				m := baseMessage()
+				m.Text = "Hey Jane,\nverify your email at https://example.com/verify-email?token=abc\n"
+				return m
+			},
+		},
+		{
+			name: "html_only",
+			build: func() Message {
+				m := baseMessage()
+				m.HTML = "<p>Hey Jane,</p>\n<p><a href=\"https://example.com/verify-email?token=abc\">Verify</a></p>\n"
+				return m
+			},
+		},
+		{
+			name: "alternative",
+			build: func() Message {
+				m := baseMessage()
+				m.ReplyTo = []mail.Address{{Name: "Support", Address: "contact@21techlabs.com"}}
+				m.Headers = map[string]string{
+					"List-Unsubscribe":      "<https://api.example.com/notifications/unsubscribe?token=xyz>",
+					"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+				}
+				m.Text = "Hey Jane,\nthanks for joining.\n"
+				m.HTML = "<p>Hey Jane,</p><p>thanks for joining.</p>\n"
+				return m
+			},
+		},
+		{
+			name: "non_ascii_headers",
+			build: func() Message {
+				m := baseMessage()
+				m.From.Name = "Équipe 21 TechLabs"
+				m.To = []mail.Address{{Name: "José Müller", Address: "jose@example.com"}, {Address: "ops@example.com"}}
+				m.Subject = "¡Bienvenido José! Tu cuenta está lista y esperamos que disfrutes de todas las funciones"
+				m.Text = "Hola José,\nqué alegría tenerte aquí.\n"
+				return m
+			},
+		},
+		{
+			name: "attachments",
+			build: func() Message {
+				m := baseMessage()
+				m.Subject = "Your invoice"
+				m.Text = "Your invoice is attached.\n"
+				m.HTML = "<p>Your invoice is attached.</p>\n"
+				m.Attachments = []Attachment{
+					{Filename: "invoice-0001.pdf", ContentType: "application/pdf", Data: bytes.Repeat([]byte("%PDF-1.4 fake invoice body "), 6)},
+					{Filename: "reçu.txt", Data: []byte("merci")},
+				}
+				return m
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deterministicBoundaries(t)
+
+			m := tt.build()
+			got, err := m.Bytes()
+			if err != nil {
+				t.Fatalf("Bytes() error = %v", err)
+			}
+
+			golden := filepath.Join("testdata", tt.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("reading golden file (run with -update to create it): %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("message does not match %s\n--- got ---\n%s\n--- want ---\n%s", golden, got, want)
+			}
+
+			if _, err := mail.ReadMessage(bytes.NewReader(got)); err != nil {
+				t.Errorf("output is not a parseable RFC 5322 message: %v", err)
+			}
+		})
+	}
+}
+
+func TestMessageValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Message)
+		want   error
+	}{
+		{"missing from", func(m *Message) { m.From = mail.Address{} }, ErrMissingFrom},
+		{"missing to", func(m *Message) { m.To = nil }, ErrMissingRecipients},
+		{"missing body", func(m *Message) { m.Text = "" }, ErrMissingBody},
+		{"subject injection", func(m *Message) { m.Subject = "hi\r\nBcc: victim@example.com" }, ErrInvalidHeader},
+		{"header injection", func(m *Message) { m.Headers = map[string]string{"X-Test": "a\nb"} }, ErrInvalidHeader},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := baseMessage()
+			m.Text = "body"
+			tt.modify(&m)
+			if _, err := m.Bytes(); err != tt.want {
+				t.Errorf("Bytes() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/21TechLabs/factory-backend/utils"
+)
+
+type EmailBackend string
+
+const (
+	EmailBackendSMTP EmailBackend = "smtp"
+	EmailBackendHTTP EmailBackend = "http"
+	EmailBackendFile EmailBackend = "file"
+)
+
+// EmailSender delivers a rendered Request and returns the provider's message ID.
+type EmailSender interface {
+	Send(ctx context.Context, r *Request) (string, error)
+}
+
+// NewEmailSenderFromEnv builds the EmailSender selected by EMAIL_BACKEND
+// (smtp when unset) from the matching EMAIL_* variables.
+func NewEmailSenderFromEnv() (EmailSender, error) {
+	from := utils.GetEnv("EMAIL_FROM", false)
+
+	backend := EmailBackend(strings.ToLower(utils.GetEnv("EMAIL_BACKEND", true)))
+	if backend == "" {
+		backend = EmailBackendSMTP
+	}
+
+	switch backend {
+	case EmailBackendSMTP:
+		return NewSMTPSender(SMTPConfig{
+			From:     from,
+			Host:     utils.GetEnv("EMAIL_HOST", false),
+			Port:     utils.GetEnv("EMAIL_PORT", false),
+			Username: utils.GetEnv("EMAIL_USER", true),
+			Password: utils.GetEnv("EMAIL_PASSWORD", true),
+			Security: SMTPSecurity(strings.ToLower(utils.GetEnv("EMAIL_SMTP_TLS", true))),
+		})
+	case EmailBackendHTTP:
+		return NewHTTPSender(HTTPConfig{
+			From:   from,
+			URL:    utils.GetEnv("EMAIL_API_URL", false),
+			APIKey: utils.GetEnv("EMAIL_API_KEY", true),
+		}), nil
+	case EmailBackendFile:
+		path := utils.GetEnv("EMAIL_FILE_PATH", true)
+		if path == "" {
+			path = "emails.mbox"
+		}
+		return NewFileSender(from, path), nil
+	default:
+		return nil, fmt.Errorf("unknown EMAIL_BACKEND %q", backend)
+	}
+}
@@ -0,0 +1,115 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/21TechLabs/factory-backend/utils"
+	"github.com/google/uuid"
+)
+
+type SMSBackend string
+
+const (
+	SMSBackendLog    SMSBackend = "log"
+	SMSBackendTwilio SMSBackend = "twilio"
+)
+
+// SMSSender delivers a text message to an E.164 number and returns the
+// provider's message ID.
+type SMSSender interface {
+	SendSMS(ctx context.Context, to, body string) (string, error)
+}
+
+// NewSMSSenderFromEnv builds the SMSSender selected by SMS_BACKEND (log when
+// unset) from the matching SMS_* variables.
+func NewSMSSenderFromEnv(logger *log.Logger) (SMSSender, error) {
+	backend := SMSBackend(strings.ToLower(utils.GetEnv("SMS_BACKEND", true)))
+	if backend == "" {
+		backend = SMSBackendLog
+	}
+
+	switch backend {
+	case SMSBackendLog:
+		return NewLogSMSSender(logger), nil
+	case SMSBackendTwilio:
+		return NewTwilioSMSSender(
+			utils.GetEnv("SMS_TWILIO_ACCOUNT_SID", false),
+			utils.GetEnv("SMS_TWILIO_AUTH_TOKEN", false),
+			utils.GetEnv("SMS_FROM", false),
+		), nil
+	default:
+		return nil, fmt.Errorf("unknown SMS_BACKEND %q", backend)
+	}
+}
+
+// LogSMSSender writes messages to the log instead of sending them. It is
+// meant for local development only, as the log then contains the codes.
+type LogSMSSender struct {
+	logger *log.Logger
+}
+
+func NewLogSMSSender(logger *log.Logger) *LogSMSSender {
+	return &LogSMSSender{logger: logger}
+}
+
+func (s *LogSMSSender) SendSMS(ctx context.Context, to, body string) (string, error) {
+	id := uuid.NewString()
+	s.logger.Printf("SMS %s to %s: %s", id, to, body)
+	return id, nil
+}
+
+// TwilioSMSSender sends messages through the Twilio Messages API.
+type TwilioSMSSender struct {
+	accountSID string
+	authToken  string
+	from       string
+	client     *http.Client
+}
+
+func NewTwilioSMSSender(accountSID, authToken, from string) *TwilioSMSSender {
+	return &TwilioSMSSender{
+		accountSID: accountSID,
+		authToken:  authToken,
+		from:       from,
+		client:     &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (s *TwilioSMSSender) SendSMS(ctx context.Context, to, body string) (string, error) {
+	endpoint := fmt.Sprintf("https://api.twilio.com/2010-04-01/Accounts/%s/Messages.json", url.PathEscape(s.accountSID))
+	form := url.Values{"To": {to}, "From": {s.from}, "Body": {body}}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create new HTTP request: %w", err)
+	}
+	req.SetBasicAuth(s.accountSID, s.authToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send sms request: %w", err)
+	}
+	defer res.Body.Close()
+
+	resBody, _ := io.ReadAll(io.LimitReader(res.Body, 64*1024))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return "", fmt.Errorf("failed to send sms, status code: %d: %s", res.StatusCode, resBody)
+	}
+
+	var parsed struct {
+		SID string `json:"sid"`
+	}
+	if err := json.Unmarshal(resBody, &parsed); err != nil {
+		return "", fmt.Errorf("failed to parse sms response: %w", err)
+	}
+	return parsed.SID, nil
+}
@@ -0,0 +1,138 @@
+package notifications
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+type SMTPSecurity string
+
+const (
+	// SMTPSecurityStartTLS upgrades a plain connection with STARTTLS (usually port 587).
+	SMTPSecurityStartTLS SMTPSecurity = "starttls"
+	// SMTPSecurityTLS dials straight into TLS (implicit TLS, usually port 465).
+	SMTPSecurityTLS SMTPSecurity = "tls"
+	// SMTPSecurityNone sends in plain text and is only meant for local relays.
+	SMTPSecurityNone SMTPSecurity = "none"
+)
+
+type SMTPConfig struct {
+	From     string
+	Host     string
+	Port     string
+	Username string
+	Password string
+	Security SMTPSecurity
+}
+
+type SMTPSender struct {
+	config SMTPConfig
+	dialer *net.Dialer
+}
+
+// NewSMTPSender validates config and returns an SMTP backed EmailSender. When
+// Security is empty it is inferred from the port: 465 uses implicit TLS and
+// everything else STARTTLS.
+func NewSMTPSender(config SMTPConfig) (*SMTPSender, error) {
+	if config.Security == "" {
+		config.Security = SMTPSecurityStartTLS
+		if config.Port == "465" {
+			config.Security = SMTPSecurityTLS
+		}
+	}
+
+	switch config.Security {
+	case SMTPSecurityStartTLS, SMTPSecurityTLS, SMTPSecurityNone:
+	default:
+		return nil, fmt.Errorf("unknown EMAIL_SMTP_TLS mode %q", config.Security)
+	}
+
+	return &SMTPSender{
+		config: config,
+		dialer: &net.Dialer{Timeout: 15 * time.Second},
+	}, nil
+}
+
+func (s *SMTPSender) Send(ctx context.Context, r *Request) (string, error) {
+	messageID := newMessageID(s.config.From)
+	msg, err := r.Message(s.config.From, messageID)
+	if err != nil {
+		return "", err
+	}
+	raw, err := msg.Bytes()
+	if err != nil {
+		return "", err
+	}
+
+	addr := net.JoinHostPort(s.config.Host, s.config.Port)
+	tlsConfig := &tls.Config{ServerName: s.config.Host}
+
+	var conn net.Conn
+	if s.config.Security == SMTPSecurityTLS {
+		conn, err = (&tls.Dialer{NetDialer: s.dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = s.dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return "", fmt.Errorf("smtp dial %s: %w", addr, err)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		conn.Close()
+		return "", fmt.Errorf("smtp handshake: %w", err)
+	}
+	defer client.Close()
+
+	if s.config.Security == SMTPSecurityStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return "", errors.New("smtp server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return "", fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+
+	if s.config.Username != "" {
+		if ok, _ := client.Extension("AUTH"); ok {
+			if err := client.Auth(smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)); err != nil {
+				return "", fmt.Errorf("smtp auth: %w", err)
+			}
+		}
+	}
+
+	if err := client.Mail(msg.From.Address); err != nil {
+		return "", fmt.Errorf("smtp MAIL FROM: %w", err)
+	}
+	for _, to := range msg.Recipients() {
+		if err := client.Rcpt(to); err != nil {
+			return "", fmt.Errorf("smtp RCPT TO %s: %w", to, err)
+		}
+	}
+
+	wc, err := client.Data()
+	if err != nil {
+		return "", fmt.Errorf("smtp DATA: %w", err)
+	}
+
+	if _, err := wc.Write(raw); err != nil {
+		wc.Close()
+		return "", fmt.Errorf("smtp write: %w", err)
+	}
+	if err := wc.Close(); err != nil {
+		return "", fmt.Errorf("smtp DATA close: %w", err)
+	}
+
+	// the message was accepted with DATA; a failing QUIT must not trigger a resend
+	client.Quit()
+	return messageID, nil
+}
@@ -1,52 +1,351 @@
+// Package templates renders the transactional emails. Templates are embedded
+// in the binary and organised per locale: every <locale>/<name>.html defines a
+// "content" block rendered inside layouts/layout.html, and every
+// <locale>/<name>.txt defines "subject" and the plain-text "content" rendered
+// inside layouts/layout.txt. Missing locales fall back to DefaultLocale.
 package templates
 
 import (
-	"os"
-	"path/filepath"
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	texttemplate "text/template"
 
 	"github.com/21TechLabs/factory-backend/config"
 	"github.com/21TechLabs/factory-backend/notifications"
+	"github.com/21TechLabs/factory-backend/utils"
 )
 
-func GenericParse(r *notifications.Request, template string, body interface{}) error {
-	wd, err := os.Getwd()
+const DefaultLocale = "en"
+
+//go:embed layouts/*.html layouts/*.txt */*.html */*.txt
+var files embed.FS
+
+// Message is the data for one template; TemplateName selects the files.
+type Message interface {
+	TemplateName() string
+}
+
+// Definition describes a template. Version must be bumped whenever the
+// wording or data of a template changes so sent emails can be traced back to
+// the copy they were rendered from.
+type Definition struct {
+	Name        string                     `json:"name"`
+	Version     int                        `json:"version"`
+	Category    utils.NotificationCategory `json:"category"`
+	Description string                     `json:"description"`
+	Locales     []string                   `json:"locales"`
+	sample      Message
+}
+
+// View is what the layout and content blocks are executed with.
+type View struct {
+	Brand        string
+	SupportEmail string
+	Locale       string
+	Data         Message
+}
+
+type Rendered struct {
+	Name     string                     `json:"name"`
+	Version  int                        `json:"version"`
+	Category utils.NotificationCategory `json:"category"`
+	Locale   string                     `json:"locale"`
+	Subject  string                     `json:"subject"`
+	HTML     string                     `json:"html"`
+	Text     string                     `json:"text"`
+}
+
+type localized struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+var definitions = map[string]*Definition{
+	AccountLockedMessage{}.TemplateName(): {
+		Version:     1,
+		Category:    utils.NotificationCategorySecurity,
+		Description: "Sent when repeated failed sign-ins lock an account, with a link to unlock it.",
+		sample:      AccountLockedMessage{Name: "Jane Doe", Link: "https://example.com/unlock-account?token=sample", LockedMinutes: 15},
+	},
+	WelcomeMessage{}.TemplateName(): {
+		Version:     3,
+		Category:    utils.NotificationCategorySecurity,
+		Description: "Sent after signup with the email verification link.",
+		sample:      WelcomeMessage{Name: "Jane Doe", Link: "https://example.com/verify-email?email=jane%40example.com&token=sample", ExpiresInHours: 48},
+	},
+	VerifyEmailMessage{}.TemplateName(): {
+		Version:     1,
+		Category:    utils.NotificationCategorySecurity,
+		Description: "Sent with a new email verification link when the user asks for one.",
+		sample:      VerifyEmailMessage{Name: "Jane Doe", Link: "https://example.com/verify-email?email=jane%40example.com&token=sample", ExpiresInHours: 48},
+	},
+	EmailChangeConfirmMessage{}.TemplateName(): {
+		Version:     1,
+		Category:    utils.NotificationCategorySecurity,
+		Description: "Sent to the new address with the link that confirms an email change.",
+		sample:      EmailChangeConfirmMessage{Name: "Jane Doe", NewEmail: "jane@example.org", Link: "https://example.com/confirm-email-change?token=sample", ExpiresInHours: 24},
+	},
+	EmailChangeNoticeMessage{}.TemplateName(): {
+		Version:     1,
+		Category:    utils.NotificationCategorySecurity,
+		Description: "Sent to the old address when an email change is requested, with a link that cancels or undoes it.",
+		sample:      EmailChangeNoticeMessage{Name: "Jane Doe", NewEmail: "jane@example.org", Link: "https://example.com/revert-email-change?token=sample", ExpiresInDays: 7},
+	},
+	GoodbyeMessage{}.TemplateName(): {
+		Version:     2,
+		Category:    utils.NotificationCategorySecurity,
+		Description: "Sent when an account is closed.",
+		sample:      GoodbyeMessage{Name: "Jane Doe"},
+	},
+	MagicLinkMessage{}.TemplateName(): {
+		Version:     1,
+		Category:    utils.NotificationCategorySecurity,
+		Description: "Sent with a single-use passwordless sign-in link.",
+		sample:      MagicLinkMessage{Name: "Jane Doe", Link: "https://example.com/magic-login?token=sample", ExpiresInMinutes: 15},
+	},
+	PasswordChangedMessage{}.TemplateName(): {
+		Version:     1,
+		Category:    utils.NotificationCategorySecurity,
+		Description: "Sent after the account's password is changed or reset.",
+		sample:      PasswordChangedMessage{Name: "Jane Doe", ChangedAt: "2 Jan 2006 15:04 UTC"},
+	},
+	ResetPasswordMessage{}.TemplateName(): {
+		Version:     3,
+		Category:    utils.NotificationCategorySecurity,
+		Description: "Sent with the password reset link.",
+		sample:      ResetPasswordMessage{Name: "Jane Doe", Link: "https://example.com/reset-password?email=jane%40example.com&token=sample", ExpiresInMinutes: 60},
+	},
+}
+
+// compiled maps locale -> template name -> parsed templates.
+var compiled = map[string]map[string]localized{}
+
+func init() {
+	if err := compile(); err != nil {
+		panic(fmt.Sprintf("email templates: %v", err))
+	}
+}
+
+func compile() error {
+	htmlLayout, err := htmltemplate.ParseFS(files, "layouts/layout.html")
 	if err != nil {
 		return err
 	}
-	return r.ParseTemplate(filepath.Join(wd, "notifications", "templates", template), body)
+	textLayout, err := texttemplate.ParseFS(files, "layouts/layout.txt")
+	if err != nil {
+		return err
+	}
+
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		locale := entry.Name()
+		if !entry.IsDir() || locale == "layouts" {
+			continue
+		}
+		compiled[locale] = map[string]localized{}
+
+		for name, def := range definitions {
+			htmlFile, textFile := path.Join(locale, name+".html"), path.Join(locale, name+".txt")
+			if _, err := fs.Stat(files, htmlFile); err != nil {
+				continue
+			}
+
+			h, err := htmltemplate.Must(htmlLayout.Clone()).ParseFS(files, htmlFile)
+			if err != nil {
+				return err
+			}
+			t, err := texttemplate.Must(textLayout.Clone()).ParseFS(files, textFile)
+			if err != nil {
+				return err
+			}
+			if t.Lookup("subject") == nil {
+				return fmt.Errorf("%s does not define a subject", textFile)
+			}
+
+			compiled[locale][name] = localized{html: h, text: t}
+			def.Locales = append(def.Locales, locale)
+		}
+	}
+
+	for name, def := range definitions {
+		def.Name = name
+		sort.Strings(def.Locales)
+		if !def.Category.IsValid() {
+			return fmt.Errorf("template %q has invalid category %q", name, def.Category)
+		}
+		if _, ok := compiled[DefaultLocale][name]; !ok {
+			return fmt.Errorf("template %q has no %s version", name, DefaultLocale)
+		}
+	}
+	return nil
 }
 
-type WelcomeMessage struct {
-	Name      string
-	BrandName string
-	Link      string
+// resolveLocale picks the best available locale for name: the exact tag,
+// then its base language ("es-MX" -> "es"), then DefaultLocale.
+func resolveLocale(name, locale string) string {
+	locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	candidates := []string{locale}
+	if base, _, found := strings.Cut(locale, "-"); found {
+		candidates = append(candidates, base)
+	}
+	for _, candidate := range candidates {
+		if _, ok := compiled[candidate][name]; ok {
+			return candidate
+		}
+	}
+	return DefaultLocale
 }
 
-func (msg *WelcomeMessage) ParseAsHTML(r *notifications.Request) error {
-	if len(msg.BrandName) == 0 {
-		msg.BrandName = config.Name
+// Render renders msg in the requested locale, falling back as needed.
+func Render(locale string, msg Message) (*Rendered, error) {
+	name := msg.TemplateName()
+	def, ok := definitions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown email template %q", name)
+	}
+
+	locale = resolveLocale(name, locale)
+	tmpl := compiled[locale][name]
+	view := View{
+		Brand:        config.Name,
+		SupportEmail: config.CustomerEmail,
+		Locale:       locale,
+		Data:         msg,
 	}
-	return GenericParse(r, "html/welcome.html", msg)
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", view); err != nil {
+		return nil, err
+	}
+	if err := tmpl.text.ExecuteTemplate(&text, "layout", view); err != nil {
+		return nil, err
+	}
+	if err := tmpl.html.ExecuteTemplate(&html, "layout", view); err != nil {
+		return nil, err
+	}
+
+	return &Rendered{
+		Name:     name,
+		Version:  def.Version,
+		Category: def.Category,
+		Locale:   locale,
+		Subject:  strings.TrimSpace(subject.String()),
+		HTML:     html.String(),
+		Text:     text.String(),
+	}, nil
+}
+
+// RenderSample renders the named template with its built-in sample data.
+func RenderSample(name, locale string) (*Rendered, error) {
+	def, ok := definitions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown email template %q", name)
+	}
+	return Render(locale, def.sample)
 }
 
-type GoodbyeMessage WelcomeMessage
+// Definitions lists every template sorted by name.
+func Definitions() []Definition {
+	defs := make([]Definition, 0, len(definitions))
+	for _, def := range definitions {
+		defs = append(defs, *def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
 
-func (msg *GoodbyeMessage) ParseAsHTML(r *notifications.Request) error {
-	if len(msg.BrandName) == 0 {
-		msg.BrandName = config.Name
+// NewRequest renders msg and wraps it in a notifications.Request addressed to to.
+func NewRequest(to []string, locale string, msg Message) (*notifications.Request, error) {
+	rendered, err := Render(locale, msg)
+	if err != nil {
+		return nil, err
 	}
-	return GenericParse(r, "html/goodbye.html", msg)
+
+	req := notifications.NewRequest(rendered.Category, to, rendered.Subject, rendered.Text)
+	req.HTML = rendered.HTML
+	req.Template = rendered.Name
+	req.TemplateVersion = rendered.Version
+	req.Locale = rendered.Locale
+	return req, nil
+}
+
+type WelcomeMessage struct {
+	Name           string
+	Link           string
+	ExpiresInHours int
+}
+
+func (WelcomeMessage) TemplateName() string { return "welcome" }
+
+type VerifyEmailMessage struct {
+	Name           string
+	Link           string
+	ExpiresInHours int
+}
+
+func (VerifyEmailMessage) TemplateName() string { return "verify-email" }
+
+type GoodbyeMessage struct {
+	Name string
 }
 
+func (GoodbyeMessage) TemplateName() string { return "goodbye" }
+
 type ResetPasswordMessage struct {
-	Name      string
-	BrandName string
-	Link      string
+	Name             string
+	Link             string
+	ExpiresInMinutes int
 }
 
-func (msg *ResetPasswordMessage) ParseAsHTML(r *notifications.Request) error {
-	if len(msg.BrandName) == 0 {
-		msg.BrandName = config.Name
-	}
-	return GenericParse(r, "html/reset-password.html", msg)
+func (ResetPasswordMessage) TemplateName() string { return "reset-password" }
+
+type MagicLinkMessage struct {
+	Name             string
+	Link             string
+	ExpiresInMinutes int
 }
+
+func (MagicLinkMessage) TemplateName() string { return "magic-link" }
+
+type AccountLockedMessage struct {
+	Name          string
+	Link          string
+	LockedMinutes int
+}
+
+func (AccountLockedMessage) TemplateName() string { return "account-locked" }
+
+type EmailChangeConfirmMessage struct {
+	Name           string
+	NewEmail       string
+	Link           string
+	ExpiresInHours int
+}
+
+func (EmailChangeConfirmMessage) TemplateName() string { return "email-change-confirm" }
+
+type EmailChangeNoticeMessage struct {
+	Name          string
+	NewEmail      string
+	Link          string
+	ExpiresInDays int
+}
+
+func (EmailChangeNoticeMessage) TemplateName() string { return "email-change-notice" }
+
+type PasswordChangedMessage struct {
+	Name      string
+	ChangedAt string
+}
+
+func (PasswordChangedMessage) TemplateName() string { return "password-changed" }
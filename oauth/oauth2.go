@@ -0,0 +1,175 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/endpoints"
+)
+
+// profileFetcher loads the user from the provider's API with an authorized
+// client.
+type profileFetcher func(ctx context.Context, client *http.Client) (Profile, error)
+
+// oauth2Provider is a provider without OpenID Connect, whose user comes from
+// its REST API.
+type oauth2Provider struct {
+	cfg          Config
+	config       *oauth2.Config
+	fetchProfile profileFetcher
+}
+
+func (p *oauth2Provider) Name() string {
+	return p.cfg.Name
+}
+
+func (p *oauth2Provider) AuthCodeURL(ctx context.Context, req AuthRequest) (string, error) {
+	return p.config.AuthCodeURL(req.State, oauth2.S256ChallengeOption(req.CodeVerifier)), nil
+}
+
+func (p *oauth2Provider) Exchange(ctx context.Context, code string, req AuthRequest) (*Result, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.cfg.HTTPClient)
+	token, err := p.config.Exchange(ctx, code, oauth2.VerifierOption(req.CodeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("code exchange failed: %w", err)
+	}
+
+	profile, err := p.fetchProfile(ctx, p.config.Client(ctx, token))
+	if err != nil {
+		return nil, err
+	}
+	if profile.Subject == "" {
+		return nil, fmt.Errorf("%s did not return a user ID", p.cfg.Name)
+	}
+	return &Result{Profile: profile, Token: token}, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request to %s failed: %w", url, err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("request to %s failed, status code: %d: %s", url, res.StatusCode, body)
+	}
+	return json.Unmarshal(body, v)
+}
+
+type gitHubUser struct {
+	ID        int64  `json:"id"`
+	Login     string `json:"login"`
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+type gitHubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+// mapGitHubUser maps a GitHub user and their email addresses. The primary
+// address is used, as the public profile email may be unset or unverified.
+func mapGitHubUser(user gitHubUser, emails []gitHubEmail) Profile {
+	profile := Profile{
+		Subject:   strconv.FormatInt(user.ID, 10),
+		Name:      user.Name,
+		AvatarURL: user.AvatarURL,
+	}
+	if profile.Name == "" {
+		profile.Name = user.Login
+	}
+	for _, email := range emails {
+		if email.Primary {
+			profile.Email = email.Email
+			profile.EmailVerified = email.Verified
+		}
+	}
+	return profile
+}
+
+func newGitHubProvider(cfg Config) *oauth2Provider {
+	return &oauth2Provider{
+		cfg: cfg,
+		config: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     endpoints.GitHub,
+			Scopes:       cfg.scopes("read:user", "user:email"),
+		},
+		fetchProfile: func(ctx context.Context, client *http.Client) (Profile, error) {
+			var user gitHubUser
+			if err := getJSON(ctx, client, "https://api.github.com/user", &user); err != nil {
+				return Profile{}, err
+			}
+			var emails []gitHubEmail
+			if err := getJSON(ctx, client, "https://api.github.com/user/emails", &emails); err != nil {
+				return Profile{}, err
+			}
+			return mapGitHubUser(user, emails), nil
+		},
+	}
+}
+
+type discordUser struct {
+	ID         string `json:"id"`
+	Username   string `json:"username"`
+	GlobalName string `json:"global_name"`
+	Avatar     string `json:"avatar"`
+	Email      string `json:"email"`
+	Verified   bool   `json:"verified"`
+}
+
+func mapDiscordUser(user discordUser) Profile {
+	profile := Profile{
+		Subject:       user.ID,
+		Email:         user.Email,
+		EmailVerified: user.Verified,
+		Name:          user.GlobalName,
+	}
+	if profile.Name == "" {
+		profile.Name = user.Username
+	}
+	if user.Avatar != "" {
+		profile.AvatarURL = fmt.Sprintf("https://cdn.discordapp.com/avatars/%s/%s.png", user.ID, user.Avatar)
+	}
+	return profile
+}
+
+func newDiscordProvider(cfg Config) *oauth2Provider {
+	return &oauth2Provider{
+		cfg: cfg,
+		config: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     endpoints.Discord,
+			Scopes:       cfg.scopes("identify", "email"),
+		},
+		fetchProfile: func(ctx context.Context, client *http.Client) (Profile, error) {
+			var user discordUser
+			if err := getJSON(ctx, client, "https://discord.com/api/users/@me", &user); err != nil {
+				return Profile{}, err
+			}
+			return mapDiscordUser(user), nil
+		},
+	}
+}
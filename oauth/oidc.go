@@ -0,0 +1,245 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// oidcClaims are the ID token and userinfo claims the mappers read.
+type oidcClaims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Email             string   `json:"email"`
+	EmailVerified     flexBool `json:"email_verified"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username"`
+	Picture           string   `json:"picture"`
+	// Microsoft: the tenant, and whether the tenant owns the email's domain
+	TenantID                 string   `json:"tid"`
+	EmailDomainOwnerVerified flexBool `json:"xms_edov"`
+}
+
+// flexBool accepts both JSON booleans and the "true"/"false" strings some
+// providers send.
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case bool:
+		*b = flexBool(v)
+	case string:
+		*b = flexBool(strings.EqualFold(v, "true"))
+	default:
+		*b = false
+	}
+	return nil
+}
+
+type claimsMapper func(claims oidcClaims) (Profile, error)
+
+func mapOIDCClaims(claims oidcClaims) (Profile, error) {
+	name := claims.Name
+	if name == "" {
+		name = claims.PreferredUsername
+	}
+	return Profile{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          name,
+		AvatarURL:     claims.Picture,
+	}, nil
+}
+
+// mapGoogleClaims maps Google's claims, which follow the standard ones;
+// email_verified is reliable for both Gmail and Workspace accounts.
+func mapGoogleClaims(claims oidcClaims) (Profile, error) {
+	return mapOIDCClaims(claims)
+}
+
+// mapMicrosoftClaims maps Microsoft Entra claims. The email claim there is
+// editable by tenant admins and carries no email_verified, so it only counts
+// as verified when xms_edov says the tenant owns the domain.
+func mapMicrosoftClaims(claims oidcClaims) (Profile, error) {
+	if claims.TenantID == "" || claims.Issuer != microsoftIssuer(claims.TenantID) {
+		return Profile{}, fmt.Errorf("id token issuer %q does not match tenant %q", claims.Issuer, claims.TenantID)
+	}
+	profile, err := mapOIDCClaims(claims)
+	if err != nil {
+		return Profile{}, err
+	}
+	profile.EmailVerified = bool(claims.EmailDomainOwnerVerified)
+	return profile, nil
+}
+
+func microsoftIssuer(tenant string) string {
+	return "https://login.microsoftonline.com/" + tenant + "/v2.0"
+}
+
+type oidcProvider struct {
+	cfg    Config
+	issuer string
+	// discoveryIssuer is the issuer the discovery document announces when
+	// it differs from the URL it is served at
+	discoveryIssuer string
+	skipIssuerCheck bool
+	mapClaims       claimsMapper
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+	verifier *oidc.IDTokenVerifier
+}
+
+func newOIDCProvider(cfg Config, issuer string, mapClaims claimsMapper) *oidcProvider {
+	return &oidcProvider{cfg: cfg, issuer: strings.TrimSuffix(issuer, "/"), mapClaims: mapClaims}
+}
+
+// newMicrosoftProvider signs in through Microsoft Entra ID. The multi-tenant
+// endpoints ("common", "organizations", "consumers") issue tokens from each
+// user's own tenant, so the issuer is checked against the tid claim instead.
+func newMicrosoftProvider(cfg Config) *oidcProvider {
+	tenant := cfg.Tenant
+	if tenant == "" {
+		tenant = "common"
+	}
+	p := newOIDCProvider(cfg, microsoftIssuer(tenant), mapMicrosoftClaims)
+	switch tenant {
+	case "common", "organizations", "consumers":
+		p.discoveryIssuer = microsoftIssuer("{tenantid}")
+		p.skipIssuerCheck = true
+	}
+	return p
+}
+
+func (p *oidcProvider) Name() string {
+	return p.cfg.Name
+}
+
+// discover loads the discovery document once; a failure is retried on the
+// next call.
+func (p *oidcProvider) discover() (*oidc.Provider, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.provider != nil {
+		return p.provider, p.verifier, nil
+	}
+
+	// the provider keeps this context for fetching signing keys later, so it
+	// must not be tied to a request
+	ctx := oidc.ClientContext(context.Background(), p.cfg.HTTPClient)
+	if p.discoveryIssuer != "" {
+		ctx = oidc.InsecureIssuerURLContext(ctx, p.discoveryIssuer)
+	}
+
+	provider, err := oidc.NewProvider(ctx, p.issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("oidc discovery for %s failed: %w", p.cfg.Name, err)
+	}
+
+	p.provider = provider
+	p.verifier = provider.VerifierContext(ctx, &oidc.Config{
+		ClientID:        p.cfg.ClientID,
+		SkipIssuerCheck: p.skipIssuerCheck,
+	})
+	return p.provider, p.verifier, nil
+}
+
+func (p *oidcProvider) oauth2Config(provider *oidc.Provider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       p.cfg.scopes(oidc.ScopeOpenID, "email", "profile"),
+	}
+}
+
+func (p *oidcProvider) AuthCodeURL(ctx context.Context, req AuthRequest) (string, error) {
+	provider, _, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+	return p.oauth2Config(provider).AuthCodeURL(req.State,
+		oidc.Nonce(req.Nonce),
+		oauth2.S256ChallengeOption(req.CodeVerifier),
+	), nil
+}
+
+func (p *oidcProvider) Exchange(ctx context.Context, code string, req AuthRequest) (*Result, error) {
+	provider, verifier, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = oidc.ClientContext(ctx, p.cfg.HTTPClient)
+	token, err := p.oauth2Config(provider).Exchange(ctx, code, oauth2.VerifierOption(req.CodeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("code exchange failed: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+	if idToken.Nonce != req.Nonce {
+		return nil, errors.New("invalid id_token: nonce mismatch")
+	}
+	if idToken.AccessTokenHash != "" {
+		if err := idToken.VerifyAccessToken(token.AccessToken); err != nil {
+			return nil, fmt.Errorf("invalid id_token: %w", err)
+		}
+	}
+
+	var claims oidcClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	// some providers leave the email out of the ID token
+	if claims.Email == "" && provider.UserInfoEndpoint() != "" {
+		userInfo, err := provider.UserInfo(ctx, oauth2.StaticTokenSource(token))
+		if err != nil {
+			return nil, fmt.Errorf("userinfo request failed: %w", err)
+		}
+		if userInfo.Subject != claims.Subject {
+			return nil, errors.New("userinfo subject does not match id_token")
+		}
+		var extra oidcClaims
+		if err := userInfo.Claims(&extra); err != nil {
+			return nil, err
+		}
+		claims.Email = extra.Email
+		claims.EmailVerified = extra.EmailVerified
+		if claims.Name == "" {
+			claims.Name = extra.Name
+		}
+		if claims.Picture == "" {
+			claims.Picture = extra.Picture
+		}
+	}
+
+	profile, err := p.mapClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+	if profile.Subject == "" {
+		return nil, errors.New("id_token has no subject")
+	}
+	return &Result{Profile: profile, Token: token}, nil
+}
@@ -0,0 +1,364 @@
+package oauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// fakeOIDCServer is a minimal OpenID Connect provider: discovery, JWKS, an
+// authorize endpoint that signs in a fixed user and a token endpoint that
+// enforces PKCE.
+type fakeOIDCServer struct {
+	*httptest.Server
+	t      *testing.T
+	key    *rsa.PrivateKey
+	claims map[string]interface{}
+
+	mu    sync.Mutex
+	codes map[string]fakeAuthorization
+}
+
+type fakeAuthorization struct {
+	challenge string
+	nonce     string
+}
+
+func newFakeOIDCServer(t *testing.T) *fakeOIDCServer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &fakeOIDCServer{
+		t:     t,
+		key:   key,
+		codes: map[string]fakeAuthorization{},
+		claims: map[string]interface{}{
+			"sub":            "user-123",
+			"email":          "ada@example.com",
+			"email_verified": true,
+			"name":           "Ada Lovelace",
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("GET /jwks", s.jwks)
+	mux.HandleFunc("GET /authorize", s.authorize)
+	mux.HandleFunc("POST /token", s.token)
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeOIDCServer) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.t.Error(err)
+	}
+}
+
+func (s *fakeOIDCServer) discovery(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                s.URL,
+		"authorization_endpoint":                s.URL + "/authorize",
+		"token_endpoint":                        s.URL + "/token",
+		"jwks_uri":                              s.URL + "/jwks",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (s *fakeOIDCServer) jwks(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(s.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (s *fakeOIDCServer) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "PKCE required", http.StatusBadRequest)
+		return
+	}
+
+	code, err := randomString()
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	s.mu.Lock()
+	s.codes[code] = fakeAuthorization{challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	s.mu.Unlock()
+
+	redirect, _ := url.Parse(q.Get("redirect_uri"))
+	rq := redirect.Query()
+	rq.Set("code", code)
+	rq.Set("state", q.Get("state"))
+	redirect.RawQuery = rq.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *fakeOIDCServer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		s.t.Fatal(err)
+	}
+
+	s.mu.Lock()
+	auth, ok := s.codes[r.PostForm.Get("code")]
+	delete(s.codes, r.PostForm.Get("code"))
+	s.mu.Unlock()
+
+	if !ok || oauth2.S256ChallengeFromVerifier(r.PostForm.Get("code_verifier")) != auth.challenge {
+		s.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	clientID, _, _ := r.BasicAuth()
+	claims := map[string]interface{}{
+		"iss":   s.URL,
+		"aud":   clientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": auth.nonce,
+	}
+	for k, v := range s.claims {
+		claims[k] = v
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     s.sign(claims),
+	})
+}
+
+func (s *fakeOIDCServer) sign(claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// signIn follows the provider's redirect like a browser and returns the
+// code and state from the callback URL.
+func signIn(t *testing.T, authURL string) (string, string) {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("authorize returned %d", res.StatusCode)
+	}
+	callback, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return callback.Query().Get("code"), callback.Query().Get("state")
+}
+
+func newTestProvider(t *testing.T, server *fakeOIDCServer) Provider {
+	provider, err := New(Config{
+		Name:         "test",
+		Kind:         KindOIDC,
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		RedirectURL:  "https://app.example.com/callback",
+		Issuer:       server.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+func TestOIDCFlow(t *testing.T) {
+	server := newFakeOIDCServer(t)
+	provider := newTestProvider(t, server)
+	ctx := context.Background()
+
+	req, err := NewAuthRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := provider.AuthCodeURL(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, state := signIn(t, authURL)
+	if state != req.State {
+		t.Fatalf("state = %q, want %q", state, req.State)
+	}
+
+	result, err := provider.Exchange(ctx, code, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Profile{Subject: "user-123", Email: "ada@example.com", EmailVerified: true, Name: "Ada Lovelace"}
+	if result.Profile != want {
+		t.Fatalf("profile = %+v, want %+v", result.Profile, want)
+	}
+	if result.Token.AccessToken != "access-token" {
+		t.Fatalf("access token = %q", result.Token.AccessToken)
+	}
+}
+
+func TestOIDCRejectsWrongCodeVerifier(t *testing.T) {
+	server := newFakeOIDCServer(t)
+	provider := newTestProvider(t, server)
+	ctx := context.Background()
+
+	req, _ := NewAuthRequest()
+	authURL, err := provider.AuthCodeURL(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := signIn(t, authURL)
+
+	req.CodeVerifier = oauth2.GenerateVerifier()
+	if _, err := provider.Exchange(ctx, code, req); err == nil {
+		t.Fatal("exchange with another code verifier succeeded")
+	}
+}
+
+func TestOIDCRejectsNonceMismatch(t *testing.T) {
+	server := newFakeOIDCServer(t)
+	provider := newTestProvider(t, server)
+	ctx := context.Background()
+
+	req, _ := NewAuthRequest()
+	authURL, err := provider.AuthCodeURL(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := signIn(t, authURL)
+
+	req.Nonce = "another-nonce"
+	_, err = provider.Exchange(ctx, code, req)
+	if err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Fatalf("err = %v, want nonce mismatch", err)
+	}
+}
+
+func TestOIDCRejectsForeignAudience(t *testing.T) {
+	server := newFakeOIDCServer(t)
+	server.claims["aud"] = "another-client"
+	provider := newTestProvider(t, server)
+	ctx := context.Background()
+
+	req, _ := NewAuthRequest()
+	authURL, err := provider.AuthCodeURL(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := signIn(t, authURL)
+
+	if _, err := provider.Exchange(ctx, code, req); err == nil {
+		t.Fatal("exchange accepted an id_token for another client")
+	}
+}
+
+func TestProfileMappers(t *testing.T) {
+	tests := []struct {
+		name string
+		got  func() (Profile, error)
+		want Profile
+	}{
+		{
+			name: "oidc string email_verified",
+			got: func() (Profile, error) {
+				var claims oidcClaims
+				err := json.Unmarshal([]byte(`{"sub":"1","email":"a@example.com","email_verified":"true","preferred_username":"ada"}`), &claims)
+				if err != nil {
+					return Profile{}, err
+				}
+				return mapOIDCClaims(claims)
+			},
+			want: Profile{Subject: "1", Email: "a@example.com", EmailVerified: true, Name: "ada"},
+		},
+		{
+			name: "microsoft email is unverified without xms_edov",
+			got: func() (Profile, error) {
+				return mapMicrosoftClaims(oidcClaims{
+					Issuer: microsoftIssuer("t1"), TenantID: "t1", Subject: "1", Email: "a@example.com", EmailVerified: true,
+				})
+			},
+			want: Profile{Subject: "1", Email: "a@example.com"},
+		},
+		{
+			name: "microsoft xms_edov",
+			got: func() (Profile, error) {
+				return mapMicrosoftClaims(oidcClaims{
+					Issuer: microsoftIssuer("t1"), TenantID: "t1", Subject: "1", Email: "a@example.com", EmailDomainOwnerVerified: true,
+				})
+			},
+			want: Profile{Subject: "1", Email: "a@example.com", EmailVerified: true},
+		},
+		{
+			name: "github primary email",
+			got: func() (Profile, error) {
+				return mapGitHubUser(gitHubUser{ID: 42, Login: "ada"}, []gitHubEmail{
+					{Email: "old@example.com", Verified: true},
+					{Email: "a@example.com", Primary: true, Verified: false},
+				}), nil
+			},
+			want: Profile{Subject: "42", Email: "a@example.com", Name: "ada"},
+		},
+		{
+			name: "discord",
+			got: func() (Profile, error) {
+				return mapDiscordUser(discordUser{ID: "7", Username: "ada", Avatar: "abc", Email: "a@example.com", Verified: true}), nil
+			},
+			want: Profile{Subject: "7", Email: "a@example.com", EmailVerified: true, Name: "ada", AvatarURL: "https://cdn.discordapp.com/avatars/7/abc.png"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.got()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := mapMicrosoftClaims(oidcClaims{Issuer: microsoftIssuer("t1"), TenantID: "t2", Subject: "1"}); err == nil {
+		t.Fatal("microsoft mapper accepted an issuer from another tenant")
+	}
+}
@@ -0,0 +1,147 @@
+// Package oauth signs users in with external OAuth 2.0 and OpenID Connect
+// providers. Every flow uses PKCE, and OpenID Connect providers additionally
+// have their ID token and nonce verified. Providers report the user as a
+// Profile so callers do not deal with provider specific payloads.
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+type Kind string
+
+const (
+	KindOIDC      Kind = "oidc"
+	KindGoogle    Kind = "google"
+	KindMicrosoft Kind = "microsoft"
+	KindGitHub    Kind = "github"
+	KindDiscord   Kind = "discord"
+)
+
+func (k Kind) IsValid() bool {
+	switch k {
+	case KindOIDC, KindGoogle, KindMicrosoft, KindGitHub, KindDiscord:
+		return true
+	}
+	return false
+}
+
+// Profile is the signed-in user as reported by a provider.
+type Profile struct {
+	// Subject is the provider's stable ID for the user
+	Subject string
+	Email   string
+	// EmailVerified is true only when the provider asserts it verified Email
+	EmailVerified bool
+	Name          string
+	AvatarURL     string
+}
+
+// Result is the outcome of a completed authorization.
+type Result struct {
+	Profile Profile
+	Token   *oauth2.Token
+}
+
+// AuthRequest holds the secrets of one authorization flow. They are created
+// when the flow starts and must be kept by the caller until the callback.
+type AuthRequest struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+}
+
+// NewAuthRequest generates a fresh state, nonce and PKCE code verifier.
+func NewAuthRequest() (AuthRequest, error) {
+	state, err := randomString()
+	if err != nil {
+		return AuthRequest{}, err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return AuthRequest{}, err
+	}
+	return AuthRequest{State: state, Nonce: nonce, CodeVerifier: oauth2.GenerateVerifier()}, nil
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Provider runs the authorization code flow against one provider.
+type Provider interface {
+	Name() string
+	// AuthCodeURL is where the user is sent to sign in.
+	AuthCodeURL(ctx context.Context, req AuthRequest) (string, error)
+	// Exchange redeems the code from the callback and returns the user.
+	Exchange(ctx context.Context, code string, req AuthRequest) (*Result, error)
+}
+
+// Config configures a provider. Issuer is required for KindOIDC; Tenant
+// selects the Microsoft Entra tenant and defaults to "common".
+type Config struct {
+	Name         string
+	Kind         Kind
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes replaces the kind's default scopes when set
+	Scopes     []string
+	Issuer     string
+	Tenant     string
+	HTTPClient *http.Client
+}
+
+// New builds the provider described by cfg. OpenID Connect discovery happens
+// on first use, so a provider that is briefly unreachable does not prevent
+// startup.
+func New(cfg Config) (Provider, error) {
+	if cfg.Name == "" {
+		cfg.Name = string(cfg.Kind)
+	}
+	if cfg.ClientID == "" {
+		return nil, fmt.Errorf("oauth provider %s: client ID is required", cfg.Name)
+	}
+	if cfg.RedirectURL == "" {
+		return nil, fmt.Errorf("oauth provider %s: redirect URL is required", cfg.Name)
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 15 * time.Second}
+	}
+
+	switch cfg.Kind {
+	case KindOIDC:
+		if cfg.Issuer == "" {
+			return nil, fmt.Errorf("oauth provider %s: issuer is required", cfg.Name)
+		}
+		return newOIDCProvider(cfg, cfg.Issuer, mapOIDCClaims), nil
+	case KindGoogle:
+		return newOIDCProvider(cfg, "https://accounts.google.com", mapGoogleClaims), nil
+	case KindMicrosoft:
+		return newMicrosoftProvider(cfg), nil
+	case KindGitHub:
+		return newGitHubProvider(cfg), nil
+	case KindDiscord:
+		return newDiscordProvider(cfg), nil
+	default:
+		return nil, fmt.Errorf("oauth provider %s: unknown kind %q", cfg.Name, cfg.Kind)
+	}
+}
+
+func (cfg Config) scopes(defaults ...string) []string {
+	if len(cfg.Scopes) > 0 {
+		return cfg.Scopes
+	}
+	return defaults
+}
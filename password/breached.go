@@ -0,0 +1,49 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// prefixLength is how many hex characters of the SHA-1 name a range file.
+const prefixLength = 5
+
+// BreachedCorpus looks passwords up in a local copy of a breached-password
+// list laid out like the Pwned Passwords range API: the upper-case SHA-1 of
+// every password is split after its first five hex characters, and Dir holds
+// one <PREFIX>.txt per prefix listing the remaining "SUFFIX:COUNT" lines.
+// A lookup only ever opens the one small file for its prefix, and a missing
+// file means no password with that prefix is listed.
+type BreachedCorpus struct {
+	Dir string
+}
+
+func (bc *BreachedCorpus) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:prefixLength], hash[prefixLength:]
+
+	file, err := os.Open(filepath.Join(bc.Dir, prefix+".txt"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		candidate, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(candidate, suffix) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
@@ -0,0 +1,141 @@
+// Package password enforces the password policy: length and complexity
+// rules, a ban on passwords built from the user's own email or name, and a
+// check against a local corpus of breached passwords. Every rule a password
+// breaks is reported so clients can show them all at once.
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Rule names a policy rule. Clients match on it, so values must not change.
+type Rule string
+
+const (
+	RuleMinLength    Rule = "min_length"
+	RuleMaxLength    Rule = "max_length"
+	RuleUppercase    Rule = "uppercase"
+	RuleLowercase    Rule = "lowercase"
+	RuleDigit        Rule = "digit"
+	RuleSymbol       Rule = "symbol"
+	RuleContainsInfo Rule = "personal_info"
+	RuleBreached     Rule = "breached"
+)
+
+// personal info shorter than this is too common to reject passwords over
+const minPersonalInfoLength = 3
+
+type Violation struct {
+	Rule    Rule   `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PolicyError lists every rule a password broke.
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return "password does not meet the requirements: " + strings.Join(messages, "; ")
+}
+
+type Policy struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// AllowPersonalInfo permits passwords containing the user's email or name
+	AllowPersonalInfo bool
+	// Breached is nil when no corpus is configured
+	Breached *BreachedCorpus
+}
+
+// Check returns a *PolicyError listing every rule password breaks. email
+// and name are the account's, used to reject passwords built from them.
+// Other errors come from reading the breached corpus.
+func (p *Policy) Check(password, email, name string) error {
+	var violations []Violation
+	add := func(rule Rule, format string, args ...interface{}) {
+		violations = append(violations, Violation{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		add(RuleMinLength, "must be at least %d characters long", p.MinLength)
+	}
+	if length > p.MaxLength {
+		add(RuleMaxLength, "must be at most %d characters long", p.MaxLength)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r) && !unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		add(RuleUppercase, "must contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		add(RuleLowercase, "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		add(RuleDigit, "must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		add(RuleSymbol, "must contain a symbol")
+	}
+
+	if !p.AllowPersonalInfo && containsPersonalInfo(password, email, name) {
+		add(RuleContainsInfo, "must not contain your email address or name")
+	}
+
+	if p.Breached != nil {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			add(RuleBreached, "has appeared in a data breach, choose a different one")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// containsPersonalInfo reports whether password contains any word of name
+// or of the local part of email ("jane" or "doe" for jane.doe@...), ignoring
+// case.
+func containsPersonalInfo(password, email, name string) bool {
+	password = strings.ToLower(password)
+	local, _, _ := strings.Cut(email, "@")
+
+	parts := strings.FieldsFunc(strings.ToLower(name+" "+local), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, part := range parts {
+		if utf8.RuneCountInString(part) >= minPersonalInfoLength && strings.Contains(password, part) {
+			return true
+		}
+	}
+	return false
+}
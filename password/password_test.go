@@ -0,0 +1,89 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func rules(t *testing.T, err error) []Rule {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("expected a *PolicyError, got %v", err)
+	}
+	var broken []Rule
+	for _, v := range policyErr.Violations {
+		broken = append(broken, v.Rule)
+	}
+	return broken
+}
+
+func TestCheckReportsEveryBrokenRule(t *testing.T) {
+	policy := &Policy{MinLength: 10, MaxLength: 20, RequireUpper: true, RequireDigit: true, RequireSymbol: true}
+
+	got := rules(t, policy.Check("short", "", ""))
+	want := []Rule{RuleMinLength, RuleUppercase, RuleDigit, RuleSymbol}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	got = rules(t, policy.Check(strings.Repeat("Aa1!", 6), "", ""))
+	if !slices.Equal(got, []Rule{RuleMaxLength}) {
+		t.Fatalf("expected only max_length, got %v", got)
+	}
+
+	if err := policy.Check("Correct-Horse-9", "", ""); err != nil {
+		t.Fatalf("expected the password to pass, got %v", err)
+	}
+}
+
+func TestCheckRejectsPersonalInfo(t *testing.T) {
+	policy := &Policy{MinLength: 1, MaxLength: 128}
+
+	for _, password := range []string{"xJaneDoe2024", "my-JANE.DOE-pass", "lovelace!!"} {
+		got := rules(t, policy.Check(password, "jane.doe@example.com", "Ada Lovelace"))
+		if !slices.Equal(got, []Rule{RuleContainsInfo}) {
+			t.Errorf("%q: expected personal_info, got %v", password, got)
+		}
+	}
+
+	// names shorter than minPersonalInfoLength are ignored
+	if err := policy.Check("bowling-alley", "al@example.com", "Al Bo"); err != nil {
+		t.Fatalf("expected short names to be ignored, got %v", err)
+	}
+
+	policy.AllowPersonalInfo = true
+	if err := policy.Check("lovelace!!", "", "Ada Lovelace"); err != nil {
+		t.Fatalf("expected personal info to be allowed, got %v", err)
+	}
+}
+
+func TestBreachedCorpus(t *testing.T) {
+	dir := t.TempDir()
+	sum := sha1.Sum([]byte("password123"))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	corpus := "0018A45C4D1DEF81644B54AB7F969B88D65:3\n" + hash[prefixLength:] + ":251682\n"
+	if err := os.WriteFile(filepath.Join(dir, hash[:prefixLength]+".txt"), []byte(corpus), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := &Policy{MinLength: 1, MaxLength: 128, Breached: &BreachedCorpus{Dir: dir}}
+
+	if got := rules(t, policy.Check("password123", "", "")); !slices.Equal(got, []Rule{RuleBreached}) {
+		t.Fatalf("expected breached, got %v", got)
+	}
+	// same prefix file missing for other passwords
+	if err := policy.Check("a fresh passphrase", "", ""); err != nil {
+		t.Fatalf("expected an unlisted password to pass, got %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memorySweepInterval is how often full buckets are dropped from memory.
+const memorySweepInterval = time.Minute
+
+type memoryBucket struct {
+	Bucket
+	// fullAt is when the bucket will have refilled completely; after that
+	// it is indistinguishable from a missing one
+	fullAt time.Time
+}
+
+// MemoryStore keeps buckets in process memory. Limits are per instance, so
+// it only suits a single instance of the API.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]memoryBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: map[string]memoryBucket{},
+		now:     time.Now,
+	}
+}
+
+func (ms *MemoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := ms.now()
+	ms.sweep(now)
+
+	key = limit.Name + ":" + key
+	var current *Bucket
+	if stored, ok := ms.buckets[key]; ok {
+		current = &stored.Bucket
+	}
+
+	bucket, result := Take(current, limit, now)
+	ms.buckets[key] = memoryBucket{Bucket: bucket, fullAt: now.Add(result.ResetAfter)}
+	return result, nil
+}
+
+func (ms *MemoryStore) sweep(now time.Time) {
+	if now.Sub(ms.lastSweep) < memorySweepInterval {
+		return
+	}
+	ms.lastSweep = now
+
+	for key, bucket := range ms.buckets {
+		if !now.Before(bucket.fullAt) {
+			delete(ms.buckets, key)
+		}
+	}
+}
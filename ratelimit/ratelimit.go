@@ -0,0 +1,82 @@
+// Package ratelimit implements token-bucket rate limits. A Limit describes a
+// bucket, and a Store keeps one bucket per key so several API instances can
+// share them. The bucket arithmetic lives in Take so every Store behaves the
+// same.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Limit allows Burst requests at once and refills the bucket completely over
+// Period, so the sustained rate is Burst per Period. Name namespaces the
+// buckets of one limit from those of every other.
+type Limit struct {
+	Name   string
+	Burst  int
+	Period time.Duration
+}
+
+// Policy is the limit as advertised in the RateLimit-Policy header.
+func (l Limit) Policy() string {
+	return fmt.Sprintf("%d;w=%d", l.Burst, int(l.Period.Seconds()))
+}
+
+func (l Limit) perSecond() float64 {
+	return float64(l.Burst) / l.Period.Seconds()
+}
+
+// Bucket is the stored state of one key.
+type Bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long until a token is available, zero when allowed
+	RetryAfter time.Duration
+	// ResetAfter is how long until the bucket is full again
+	ResetAfter time.Duration
+}
+
+// Store takes one token for key from its bucket under limit.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Take refills bucket for the time since it was last updated and takes one
+// token if there is one. A nil bucket starts full. It returns the new state
+// of the bucket.
+func Take(bucket *Bucket, limit Limit, now time.Time) (Bucket, Result) {
+	rate := limit.perSecond()
+	burst := float64(limit.Burst)
+
+	tokens := burst
+	if bucket != nil {
+		elapsed := now.Sub(bucket.UpdatedAt).Seconds()
+		tokens = math.Min(burst, bucket.Tokens+math.Max(0, elapsed)*rate)
+	}
+
+	result := Result{Limit: limit.Burst}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	result.Remaining = int(math.Floor(tokens))
+	result.ResetAfter = seconds((burst - tokens) / rate)
+
+	return Bucket{Tokens: tokens, UpdatedAt: now}, result
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
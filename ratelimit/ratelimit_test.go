@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTakeDrainsAndRefills(t *testing.T) {
+	limit := Limit{Name: "test", Burst: 3, Period: 3 * time.Second}
+	now := time.Unix(1_700_000_000, 0)
+
+	var bucket *Bucket
+	for i := 2; i >= 0; i-- {
+		next, result := Take(bucket, limit, now)
+		if !result.Allowed || result.Remaining != i {
+			t.Fatalf("expected an allowed request with %d remaining, got %+v", i, result)
+		}
+		bucket = &next
+	}
+
+	next, result := Take(bucket, limit, now)
+	if result.Allowed {
+		t.Fatal("expected the empty bucket to refuse the request")
+	}
+	if result.RetryAfter != time.Second {
+		t.Fatalf("expected to retry after 1s, got %v", result.RetryAfter)
+	}
+	if result.ResetAfter != 3*time.Second {
+		t.Fatalf("expected the bucket to be full after 3s, got %v", result.ResetAfter)
+	}
+	bucket = &next
+
+	_, result = Take(bucket, limit, now.Add(time.Second))
+	if !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("expected one refilled token after 1s, got %+v", result)
+	}
+}
+
+func TestTakeCapsRefillAtBurst(t *testing.T) {
+	limit := Limit{Name: "test", Burst: 2, Period: time.Minute}
+	now := time.Unix(1_700_000_000, 0)
+
+	_, result := Take(&Bucket{Tokens: 0, UpdatedAt: now}, limit, now.Add(time.Hour))
+	if !result.Allowed || result.Remaining != 1 {
+		t.Fatalf("expected a full bucket of 2 after an hour, got %+v", result)
+	}
+}
+
+func TestMemoryStoreSeparatesKeysAndLimits(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	login := Limit{Name: "login", Burst: 1, Period: time.Minute}
+	signup := Limit{Name: "signup", Burst: 1, Period: time.Minute}
+	ctx := context.Background()
+
+	take := func(key string, limit Limit) bool {
+		t.Helper()
+		result, err := store.Take(ctx, key, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.Allowed
+	}
+
+	if !take("1.2.3.4", login) {
+		t.Fatal("expected the first login to be allowed")
+	}
+	if take("1.2.3.4", login) {
+		t.Fatal("expected the second login to be refused")
+	}
+	if !take("5.6.7.8", login) {
+		t.Fatal("expected another ip to have its own bucket")
+	}
+	if !take("1.2.3.4", signup) {
+		t.Fatal("expected another limit to have its own bucket")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if !take("1.2.3.4", login) {
+		t.Fatal("expected the bucket to refill")
+	}
+	if len(store.buckets) != 1 {
+		t.Fatalf("expected full buckets to be swept, %d left", len(store.buckets))
+	}
+}
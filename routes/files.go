@@ -4,19 +4,176 @@ import (
 	"net/http"
 
 	"github.com/21TechLabs/factory-backend/app"
+	"github.com/21TechLabs/factory-backend/dto"
 	"github.com/21TechLabs/factory-backend/middleware"
 )
 
 // app *app.Application)
 func SetupFile(router *http.ServeMux, app *app.Application) {
 	router.Handle("POST /file", app.Middleware.CreateStackWithHandler(
-		[]middleware.MiddlewareStack{app.Middleware.UserAuthMiddleware},
+		[]middleware.MiddlewareStack{
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.RateLimitMiddleware(uploadLimit, middleware.RateLimitByUser),
+		},
 		app.FileController.FileUpload,
 	))
 
-	router.Handle("GET /file/stream/:fileKey", app.Middleware.CreateStackWithHandler(
+	router.Handle("POST /file/uploads", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyMultipartUploadCreateDto),
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.RateLimitMiddleware(uploadLimit, middleware.RateLimitByUser),
+		},
+		app.FileController.CreateMultipartUpload,
+	))
+
+	router.Handle("GET /file/uploads/{id}", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{app.Middleware.UserAuthMiddleware},
+		app.FileController.GetMultipartUpload,
+	))
+
+	router.Handle("POST /file/uploads/{id}/parts", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyMultipartUploadPartsDto),
+			app.Middleware.UserAuthMiddleware,
+		},
+		app.FileController.SignMultipartUploadParts,
+	))
+
+	router.Handle("POST /file/uploads/{id}/complete", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.RateLimitMiddleware(uploadLimit, middleware.RateLimitByUser),
+		},
+		app.FileController.CompleteMultipartUpload,
+	))
+
+	router.Handle("DELETE /file/uploads/{id}", app.Middleware.CreateStackWithHandler(
 		[]middleware.MiddlewareStack{app.Middleware.UserAuthMiddleware},
+		app.FileController.AbortMultipartUpload,
+	))
+
+	router.Handle("POST /file/direct-uploads", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyDirectUploadCreateDto),
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.RateLimitMiddleware(uploadLimit, middleware.RateLimitByUser),
+		},
+		app.FileController.CreateDirectUpload,
+	))
+
+	router.Handle("POST /file/direct-uploads/{id}/confirm", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.RateLimitMiddleware(uploadLimit, middleware.RateLimitByUser),
+		},
+		app.FileController.ConfirmDirectUpload,
+	))
+
+	// keys may contain slashes, so the wildcard takes the rest of the path;
+	// public files can be read without signing in
+	router.Handle("GET /file/stream/{fileKey...}", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{app.Middleware.OptionalUserAuthMiddleware},
 		app.FileController.FileStreamS3,
 	))
 
+	router.Handle("GET /file/shared/{token}", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.OptionalUserAuthMiddleware,
+			app.Middleware.RateLimitMiddleware(shareLinkLimit, middleware.RateLimitByIP),
+		},
+		app.FileController.StreamSharedFile,
+	))
+
+	router.Handle("GET /user/storage", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{app.Middleware.UserAuthMiddleware},
+		app.FileController.StorageUsage,
+	))
+
+	router.Handle("GET /files", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{app.Middleware.UserAuthMiddleware},
+		app.FileController.ListFiles,
+	))
+
+	router.Handle("PATCH /files/{id}", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyFileRenameDto),
+			app.Middleware.UserAuthMiddleware,
+		},
+		app.FileController.RenameFile,
+	))
+
+	router.Handle("POST /files/{id}/move", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyFileMoveDto),
+			app.Middleware.UserAuthMiddleware,
+		},
+		app.FileController.MoveFile,
+	))
+
+	router.Handle("DELETE /files/{id}", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.NoImpersonationMiddleware,
+		},
+		app.FileController.TrashFile,
+	))
+
+	router.Handle("POST /files/{id}/restore", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{app.Middleware.UserAuthMiddleware},
+		app.FileController.RestoreFile,
+	))
+
+	router.Handle("PATCH /files/{id}/visibility", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyFileVisibilityDto),
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.NoImpersonationMiddleware,
+		},
+		app.FileController.SetFileVisibility,
+	))
+
+	router.Handle("GET /files/{id}/shares", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{app.Middleware.UserAuthMiddleware},
+		app.FileController.ListFileShares,
+	))
+
+	router.Handle("POST /files/{id}/shares", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyFileShareCreateDto),
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.NoImpersonationMiddleware,
+		},
+		app.FileController.ShareFile,
+	))
+
+	router.Handle("DELETE /files/{id}/shares/{userId}", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{app.Middleware.UserAuthMiddleware},
+		app.FileController.UnshareFile,
+	))
+
+	router.Handle("GET /files/{id}/links", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{app.Middleware.UserAuthMiddleware},
+		app.FileController.ListFileShareLinks,
+	))
+
+	router.Handle("POST /files/{id}/links", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyFileShareLinkCreateDto),
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.NoImpersonationMiddleware,
+		},
+		app.FileController.CreateFileShareLink,
+	))
+
+	router.Handle("DELETE /files/{id}/links/{linkId}", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{app.Middleware.UserAuthMiddleware},
+		app.FileController.RevokeFileShareLink,
+	))
+
+	router.Handle("GET /files/{id}/accesses", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{app.Middleware.UserAuthMiddleware},
+		app.FileController.ListFileAccesses,
+	))
+
 }
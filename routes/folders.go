@@ -0,0 +1,48 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/21TechLabs/factory-backend/app"
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/middleware"
+)
+
+func SetupFolder(router *http.ServeMux, app *app.Application) {
+	router.Handle("GET /folders", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{app.Middleware.UserAuthMiddleware},
+		app.FolderController.ListFolders,
+	))
+
+	router.Handle("POST /folders", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyFolderCreateDto),
+			app.Middleware.UserAuthMiddleware,
+		},
+		app.FolderController.CreateFolder,
+	))
+
+	router.Handle("PATCH /folders/{id}", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyFolderRenameDto),
+			app.Middleware.UserAuthMiddleware,
+		},
+		app.FolderController.RenameFolder,
+	))
+
+	router.Handle("POST /folders/{id}/move", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyFolderMoveDto),
+			app.Middleware.UserAuthMiddleware,
+		},
+		app.FolderController.MoveFolder,
+	))
+
+	router.Handle("DELETE /folders/{id}", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.NoImpersonationMiddleware,
+		},
+		app.FolderController.DeleteFolder,
+	))
+}
@@ -0,0 +1,43 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/21TechLabs/factory-backend/app"
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/middleware"
+	"github.com/21TechLabs/factory-backend/models"
+)
+
+func SetupImpersonation(router *http.ServeMux, app *app.Application) {
+	router.Handle("POST /admin/impersonations", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyImpersonationStartDto),
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.HasRoleMiddleware([]models.UserRole{models.UserRoleAdmin}),
+		},
+		app.ImpersonationController.StartImpersonation,
+	))
+
+	router.Handle("GET /admin/impersonations", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.HasRoleMiddleware([]models.UserRole{models.UserRoleAdmin}),
+		},
+		app.ImpersonationController.ListImpersonations,
+	))
+
+	router.Handle("GET /admin/impersonations/{id}/requests", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.HasRoleMiddleware([]models.UserRole{models.UserRoleAdmin}),
+		},
+		app.ImpersonationController.ListImpersonationRequests,
+	))
+
+	// called with the impersonation token itself
+	router.Handle("POST /user/impersonation/end", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{app.Middleware.UserAuthMiddleware},
+		app.ImpersonationController.EndImpersonation,
+	))
+}
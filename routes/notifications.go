@@ -0,0 +1,71 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/21TechLabs/factory-backend/app"
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/middleware"
+	"github.com/21TechLabs/factory-backend/models"
+)
+
+// SetupNotifications registers the notification preference and unsubscribe
+// endpoints, plus the admin endpoints used to inspect and resend queued emails
+// and to preview the email templates.
+func SetupNotifications(router *http.ServeMux, app *app.Application) {
+
+	router.Handle("GET /user/notification-preferences", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{app.Middleware.UserAuthMiddleware},
+		app.NotificationPreferenceController.GetPreferences,
+	))
+
+	router.Handle("PUT /user/notification-preferences", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyNotificationPreferencesUpdate),
+			app.Middleware.UserAuthMiddleware,
+		},
+		app.NotificationPreferenceController.UpdatePreferences,
+	))
+
+	router.Handle("GET /notifications/unsubscribe", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{},
+		app.NotificationPreferenceController.UnsubscribePage,
+	))
+
+	router.Handle("POST /notifications/unsubscribe", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{},
+		app.NotificationPreferenceController.Unsubscribe,
+	))
+
+	router.Handle("GET /admin/emails", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.HasRoleMiddleware([]models.UserRole{models.UserRoleAdmin}),
+		},
+		app.EmailOutboxController.ListEmails,
+	))
+
+	router.Handle("POST /admin/emails/{id}/resend", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.HasRoleMiddleware([]models.UserRole{models.UserRoleAdmin}),
+		},
+		app.EmailOutboxController.ResendEmail,
+	))
+
+	router.Handle("GET /admin/email-templates", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.HasRoleMiddleware([]models.UserRole{models.UserRoleAdmin}),
+		},
+		app.EmailTemplateController.ListTemplates,
+	))
+
+	router.Handle("GET /admin/email-templates/{name}/preview", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.HasRoleMiddleware([]models.UserRole{models.UserRoleAdmin}),
+		},
+		app.EmailTemplateController.PreviewTemplate,
+	))
+}
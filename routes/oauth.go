@@ -5,17 +5,43 @@ import (
 
 	"github.com/21TechLabs/factory-backend/app"
 	"github.com/21TechLabs/factory-backend/middleware"
-	"github.com/markbates/goth/gothic"
 )
 
 func SetupOAuth(router *http.ServeMux, app *app.Application) {
-	router.Handle("GET /user/oauth2/:provider/login", app.Middleware.CreateStackWithHandler(
+	router.Handle("GET /user/oauth2/providers", app.Middleware.CreateStackWithHandler(
 		[]middleware.MiddlewareStack{},
-		gothic.BeginAuthHandler,
+		app.OAuthController.ListProviders,
 	))
 
-	router.Handle("GET /user/oauth2/:provider/login/callback", app.Middleware.CreateStackWithHandler(
+	router.Handle("GET /user/oauth2/{provider}/login", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{},
+		app.OAuthController.Login,
+	))
+
+	// the user is optional: only flows started from the link route use it
+	router.Handle("GET /user/oauth2/{provider}/login/callback", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{app.Middleware.OptionalUserAuthMiddleware},
+		app.OAuthController.Callback,
+	))
+
+	router.Handle("GET /user/oauth2/{provider}/link", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.NoImpersonationMiddleware,
+		},
+		app.OAuthController.LinkBegin,
+	))
+
+	router.Handle("GET /user/identities", app.Middleware.CreateStackWithHandler(
 		[]middleware.MiddlewareStack{app.Middleware.UserAuthMiddleware},
-		app.OAuthController.GothicCallback,
+		app.OAuthController.ListIdentities,
+	))
+
+	router.Handle("DELETE /user/identities/{id}", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.NoImpersonationMiddleware,
+		},
+		app.OAuthController.UnlinkIdentity,
 	))
 }
@@ -50,6 +50,7 @@ func SetupProductPlans(router *http.ServeMux, app *app.Application) {
 	router.Handle("GET /products/{productId}/buy/{paymentGateway}", app.Middleware.CreateStackWithHandler(
 		[]middleware.MiddlewareStack{
 			app.Middleware.UserAuthMiddleware,
+			app.Middleware.NoImpersonationMiddleware,
 			app.Middleware.HasRoleMiddleware([]models.UserRole{models.UserRoleAdmin, models.UserRoleClient}),
 		},
 		app.PaymentPlanController.ProductBuy,
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"time"
+
+	"github.com/21TechLabs/factory-backend/ratelimit"
+)
+
+// Rate limits applied to routes. Each Name gets its own buckets, so routes
+// sharing a limit also share its budget.
+var (
+	// globalLimit applies to every request per client address
+	globalLimit = ratelimit.Limit{Name: "global", Burst: 600, Period: time.Minute}
+
+	loginLimit         = ratelimit.Limit{Name: "login", Burst: 10, Period: time.Minute}
+	signupLimit        = ratelimit.Limit{Name: "signup", Burst: 5, Period: time.Hour}
+	passwordResetLimit = ratelimit.Limit{Name: "password-reset", Burst: 5, Period: 15 * time.Minute}
+	// guesses at the current password of a signed-in account
+	passwordChangeLimit = ratelimit.Limit{Name: "password-change", Burst: 5, Period: 15 * time.Minute}
+	// codes and links sent by email or SMS
+	verificationLimit = ratelimit.Limit{Name: "verification", Burst: 5, Period: 15 * time.Minute}
+	uploadLimit       = ratelimit.Limit{Name: "upload", Burst: 30, Period: time.Minute}
+	scimLimit         = ratelimit.Limit{Name: "scim", Burst: 600, Period: time.Minute}
+	// downloads through share links, which need no sign-in
+	shareLinkLimit = ratelimit.Limit{Name: "share-link", Burst: 60, Period: time.Minute}
+)
@@ -7,12 +7,12 @@ import (
 	"github.com/21TechLabs/factory-backend/middleware"
 )
 
-// SetupRoutes configures and returns an http.ServeMux populated with the application's HTTP routes.
+// SetupRoutes configures and returns an http.Handler serving the application's HTTP routes.
 //
 // It registers the root (GET "/") and health (GET "/health") endpoints to the application's health check
-// handler and sets up user, file, OAuth, and product-plan related routes by invoking the respective setup
-// functions.
-func SetupRoutes(app *app.Application) *http.ServeMux {
+// handler and sets up user, impersonation, file, OAuth, SSO, SCIM, product-plan, notification and webhook related routes by invoking the
+// respective setup functions. Every request is subject to globalLimit per client address on top of its route's own limit.
+func SetupRoutes(app *app.Application) http.Handler {
 	router := http.NewServeMux()
 
 	router.Handle("GET /", app.Middleware.CreateStackWithHandler(
@@ -26,9 +26,15 @@ func SetupRoutes(app *app.Application) *http.ServeMux {
 	))
 
 	SetupUser(router, app)
+	SetupImpersonation(router, app)
 	SetupFile(router, app)
+	SetupFolder(router, app)
 	SetupOAuth(router, app)
+	SetupSSO(router, app)
+	SetupSCIM(router, app)
 	SetupProductPlans(router, app)
+	SetupNotifications(router, app)
+	SetupWebhooks(router, app)
 
-	return router
+	return app.Middleware.RateLimitMiddleware(globalLimit, middleware.RateLimitByIP)(router)
 }
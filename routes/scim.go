@@ -0,0 +1,88 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/21TechLabs/factory-backend/app"
+	"github.com/21TechLabs/factory-backend/middleware"
+)
+
+// SetupSCIM registers the SCIM 2.0 endpoints organizations' identity
+// providers use to provision users and groups. Tokens are managed under
+// /admin/organizations/{id}/scim-tokens.
+func SetupSCIM(router *http.ServeMux, app *app.Application) {
+	scimAuth := []middleware.MiddlewareStack{
+		app.Middleware.RateLimitMiddleware(scimLimit, middleware.RateLimitByAPIKey),
+		app.Middleware.SCIMAuthMiddleware,
+	}
+
+	router.Handle("GET /scim/v2/ServiceProviderConfig", app.Middleware.CreateStackWithHandler(
+		scimAuth,
+		app.SCIMController.ServiceProviderConfig,
+	))
+
+	router.Handle("GET /scim/v2/ResourceTypes", app.Middleware.CreateStackWithHandler(
+		scimAuth,
+		app.SCIMController.ResourceTypes,
+	))
+
+	router.Handle("GET /scim/v2/Users", app.Middleware.CreateStackWithHandler(
+		scimAuth,
+		app.SCIMController.ListUsers,
+	))
+
+	router.Handle("POST /scim/v2/Users", app.Middleware.CreateStackWithHandler(
+		scimAuth,
+		app.SCIMController.CreateUser,
+	))
+
+	router.Handle("GET /scim/v2/Users/{id}", app.Middleware.CreateStackWithHandler(
+		scimAuth,
+		app.SCIMController.GetUser,
+	))
+
+	router.Handle("PUT /scim/v2/Users/{id}", app.Middleware.CreateStackWithHandler(
+		scimAuth,
+		app.SCIMController.ReplaceUser,
+	))
+
+	router.Handle("PATCH /scim/v2/Users/{id}", app.Middleware.CreateStackWithHandler(
+		scimAuth,
+		app.SCIMController.PatchUser,
+	))
+
+	router.Handle("DELETE /scim/v2/Users/{id}", app.Middleware.CreateStackWithHandler(
+		scimAuth,
+		app.SCIMController.DeleteUser,
+	))
+
+	router.Handle("GET /scim/v2/Groups", app.Middleware.CreateStackWithHandler(
+		scimAuth,
+		app.SCIMController.ListGroups,
+	))
+
+	router.Handle("POST /scim/v2/Groups", app.Middleware.CreateStackWithHandler(
+		scimAuth,
+		app.SCIMController.CreateGroup,
+	))
+
+	router.Handle("GET /scim/v2/Groups/{id}", app.Middleware.CreateStackWithHandler(
+		scimAuth,
+		app.SCIMController.GetGroup,
+	))
+
+	router.Handle("PUT /scim/v2/Groups/{id}", app.Middleware.CreateStackWithHandler(
+		scimAuth,
+		app.SCIMController.ReplaceGroup,
+	))
+
+	router.Handle("PATCH /scim/v2/Groups/{id}", app.Middleware.CreateStackWithHandler(
+		scimAuth,
+		app.SCIMController.PatchGroup,
+	))
+
+	router.Handle("DELETE /scim/v2/Groups/{id}", app.Middleware.CreateStackWithHandler(
+		scimAuth,
+		app.SCIMController.DeleteGroup,
+	))
+}
@@ -0,0 +1,113 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/21TechLabs/factory-backend/app"
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/middleware"
+	"github.com/21TechLabs/factory-backend/models"
+)
+
+// SetupSSO registers SAML sign-in for organizations and the admin endpoints
+// that configure organizations, their domains, identity providers and SCIM
+// tokens.
+func SetupSSO(router *http.ServeMux, app *app.Application) {
+	admin := []middleware.MiddlewareStack{
+		app.Middleware.UserAuthMiddleware,
+		app.Middleware.HasRoleMiddleware([]models.UserRole{models.UserRoleAdmin}),
+	}
+	adminWithBody := func(key dto.DtoMapKey) []middleware.MiddlewareStack {
+		return append([]middleware.MiddlewareStack{app.Middleware.SchemaValidatorMiddleware(key)}, admin...)
+	}
+
+	router.Handle("POST /sso/saml/discover", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeySSODiscover)},
+		app.SAMLController.Discover,
+	))
+
+	router.Handle("GET /sso/saml/{id}/metadata", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{},
+		app.SAMLController.Metadata,
+	))
+
+	router.Handle("GET /sso/saml/{id}/login", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{},
+		app.SAMLController.Login,
+	))
+
+	router.Handle("POST /sso/saml/{id}/acs", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{},
+		app.SAMLController.ACS,
+	))
+
+	router.Handle("GET /admin/organizations", app.Middleware.CreateStackWithHandler(
+		admin,
+		app.OrganizationController.ListOrganizations,
+	))
+
+	router.Handle("POST /admin/organizations", app.Middleware.CreateStackWithHandler(
+		adminWithBody(dto.DtoMapKeyOrganizationCreate),
+		app.OrganizationController.CreateOrganization,
+	))
+
+	router.Handle("GET /admin/organizations/{id}", app.Middleware.CreateStackWithHandler(
+		admin,
+		app.OrganizationController.GetOrganization,
+	))
+
+	router.Handle("PATCH /admin/organizations/{id}", app.Middleware.CreateStackWithHandler(
+		adminWithBody(dto.DtoMapKeyOrganizationUpdate),
+		app.OrganizationController.UpdateOrganization,
+	))
+
+	router.Handle("DELETE /admin/organizations/{id}", app.Middleware.CreateStackWithHandler(
+		admin,
+		app.OrganizationController.DeleteOrganization,
+	))
+
+	router.Handle("POST /admin/organizations/{id}/domains", app.Middleware.CreateStackWithHandler(
+		adminWithBody(dto.DtoMapKeyOrganizationDomainCreate),
+		app.OrganizationController.AddDomain,
+	))
+
+	router.Handle("PATCH /admin/organizations/{id}/domains/{domainId}", app.Middleware.CreateStackWithHandler(
+		adminWithBody(dto.DtoMapKeyOrganizationDomainUpdate),
+		app.OrganizationController.UpdateDomain,
+	))
+
+	router.Handle("DELETE /admin/organizations/{id}/domains/{domainId}", app.Middleware.CreateStackWithHandler(
+		admin,
+		app.OrganizationController.RemoveDomain,
+	))
+
+	router.Handle("GET /admin/organizations/{id}/saml", app.Middleware.CreateStackWithHandler(
+		admin,
+		app.OrganizationController.GetSAMLConnection,
+	))
+
+	router.Handle("PUT /admin/organizations/{id}/saml", app.Middleware.CreateStackWithHandler(
+		adminWithBody(dto.DtoMapKeySAMLConnectionUpsert),
+		app.OrganizationController.UpsertSAMLConnection,
+	))
+
+	router.Handle("DELETE /admin/organizations/{id}/saml", app.Middleware.CreateStackWithHandler(
+		admin,
+		app.OrganizationController.DeleteSAMLConnection,
+	))
+
+	router.Handle("GET /admin/organizations/{id}/scim-tokens", app.Middleware.CreateStackWithHandler(
+		admin,
+		app.OrganizationController.ListSCIMTokens,
+	))
+
+	router.Handle("POST /admin/organizations/{id}/scim-tokens", app.Middleware.CreateStackWithHandler(
+		adminWithBody(dto.DtoMapKeySCIMTokenCreate),
+		app.OrganizationController.CreateSCIMToken,
+	))
+
+	router.Handle("DELETE /admin/organizations/{id}/scim-tokens/{tokenId}", app.Middleware.CreateStackWithHandler(
+		admin,
+		app.OrganizationController.DeleteSCIMToken,
+	))
+}
@@ -6,9 +6,11 @@ import (
 	"github.com/21TechLabs/factory-backend/app"
 	"github.com/21TechLabs/factory-backend/dto"
 	"github.com/21TechLabs/factory-backend/middleware"
+	"github.com/21TechLabs/factory-backend/models"
 )
 
-// SetupUser registers user-related HTTP routes on the provided router,
+// SetupUser registers user-related HTTP routes on the provided router, including
+// the admin endpoints that list and clear sign-in lockouts,
 // composing each route's handler with middleware from the application (for example, schema validation and authentication).
 //
 // router is the http.ServeMux to register routes on.
@@ -17,6 +19,7 @@ func SetupUser(router *http.ServeMux, app *app.Application) {
 
 	router.Handle("POST /user/create", app.Middleware.CreateStackWithHandler(
 		[]middleware.MiddlewareStack{
+			app.Middleware.RateLimitMiddleware(signupLimit, middleware.RateLimitByIP),
 			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyUserCreateDto),
 		},
 		app.UserController.UserCreate,
@@ -24,11 +27,113 @@ func SetupUser(router *http.ServeMux, app *app.Application) {
 
 	router.Handle("POST /user/login", app.Middleware.CreateStackWithHandler(
 		[]middleware.MiddlewareStack{
+			app.Middleware.RateLimitMiddleware(loginLimit, middleware.RateLimitByIP),
 			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyUserLoginDto),
 		},
 		app.UserController.UserLogin,
 	))
 
+	router.Handle("POST /user/login/magic", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.RateLimitMiddleware(verificationLimit, middleware.RateLimitByIP),
+			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyMagicLinkRequestDto),
+		},
+		app.MagicLinkController.RequestMagicLink,
+	))
+
+	router.Handle("GET /user/login/magic", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.RateLimitMiddleware(verificationLimit, middleware.RateLimitByIP),
+		},
+		app.MagicLinkController.VerifyMagicLink,
+	))
+
+	router.Handle("POST /user/login/phone/otp", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.RateLimitMiddleware(verificationLimit, middleware.RateLimitByIP),
+			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyOTPCreateDto),
+		},
+		app.PhoneController.SendLoginCode,
+	))
+
+	router.Handle("POST /user/login/phone", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.RateLimitMiddleware(loginLimit, middleware.RateLimitByIP),
+			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyOTPVerifyDto),
+		},
+		app.PhoneController.PhoneLogin,
+	))
+
+	router.Handle("POST /user/phone/otp", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyOTPCreateDto),
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.NoImpersonationMiddleware,
+		},
+		app.PhoneController.SendVerificationCode,
+	))
+
+	router.Handle("POST /user/phone/verify", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyOTPVerifyDto),
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.NoImpersonationMiddleware,
+		},
+		app.PhoneController.VerifyPhone,
+	))
+
+	router.Handle("POST /user/login/passkey/begin", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.RateLimitMiddleware(loginLimit, middleware.RateLimitByIP),
+		},
+		app.PasskeyController.BeginLogin,
+	))
+
+	router.Handle("POST /user/login/passkey/finish", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.RateLimitMiddleware(loginLimit, middleware.RateLimitByIP),
+		},
+		app.PasskeyController.FinishLogin,
+	))
+
+	router.Handle("GET /user/passkeys", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{app.Middleware.UserAuthMiddleware},
+		app.PasskeyController.ListPasskeys,
+	))
+
+	router.Handle("POST /user/passkeys/register/begin", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyPasskeyRegisterBeginDto),
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.NoImpersonationMiddleware,
+		},
+		app.PasskeyController.BeginRegistration,
+	))
+
+	router.Handle("POST /user/passkeys/register/finish", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.NoImpersonationMiddleware,
+		},
+		app.PasskeyController.FinishRegistration,
+	))
+
+	router.Handle("PATCH /user/passkeys/{id}", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyPasskeyUpdateDto),
+			app.Middleware.UserAuthMiddleware,
+		},
+		app.PasskeyController.UpdatePasskey,
+	))
+
+	router.Handle("DELETE /user/passkeys/{id}", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.NoImpersonationMiddleware,
+		},
+		app.PasskeyController.DeletePasskey,
+	))
+
 	router.Handle("PATCH /user/update", app.Middleware.CreateStackWithHandler(
 		[]middleware.MiddlewareStack{
 			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyUserUpdateDto),
@@ -37,39 +142,107 @@ func SetupUser(router *http.ServeMux, app *app.Application) {
 		app.UserController.UserUpdateDto,
 	))
 
+	router.Handle("POST /user/email/change", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyEmailChangeRequestDto),
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.NoImpersonationMiddleware,
+			app.Middleware.RateLimitMiddleware(verificationLimit, middleware.RateLimitByUser),
+		},
+		app.EmailChangeController.RequestEmailChange,
+	))
+
+	router.Handle("POST /user/email/confirm", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.RateLimitMiddleware(verificationLimit, middleware.RateLimitByIP),
+			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyEmailChangeTokenDto),
+		},
+		app.EmailChangeController.ConfirmEmailChange,
+	))
+
+	router.Handle("POST /user/email/revert", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.RateLimitMiddleware(verificationLimit, middleware.RateLimitByIP),
+			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyEmailChangeTokenDto),
+		},
+		app.EmailChangeController.RevertEmailChange,
+	))
+
 	router.Handle("POST /user/login/verify", app.Middleware.CreateStackWithHandler(
 		[]middleware.MiddlewareStack{app.Middleware.UserAuthMiddleware},
 		app.UserController.UserLoginVerify,
 	))
 
 	router.Handle("GET /user/reset-password", app.Middleware.CreateStackWithHandler(
-		[]middleware.MiddlewareStack{},
+		[]middleware.MiddlewareStack{
+			app.Middleware.RateLimitMiddleware(passwordResetLimit, middleware.RateLimitByIP),
+		},
 		app.UserController.UserRequestPasswordResetLink,
 	))
 
 	router.Handle("POST /user/reset-password", app.Middleware.CreateStackWithHandler(
 		[]middleware.MiddlewareStack{
+			app.Middleware.RateLimitMiddleware(passwordResetLimit, middleware.RateLimitByIP),
 			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyUserPasswordUpdateDto),
 		},
 		app.UserController.UserPasswordUpdate,
 	))
 
+	router.Handle("POST /user/unlock", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.RateLimitMiddleware(passwordResetLimit, middleware.RateLimitByIP),
+			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyAccountUnlockDto),
+		},
+		app.LoginThrottleController.UnlockAccount,
+	))
+
+	router.Handle("GET /admin/lockouts", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.HasRoleMiddleware([]models.UserRole{models.UserRoleAdmin}),
+		},
+		app.LoginThrottleController.ListLockouts,
+	))
+
+	router.Handle("DELETE /admin/lockouts/{id}", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.HasRoleMiddleware([]models.UserRole{models.UserRoleAdmin}),
+		},
+		app.LoginThrottleController.ClearLockout,
+	))
+
 	router.Handle("GET /user/verify-email", app.Middleware.CreateStackWithHandler(
-		[]middleware.MiddlewareStack{},
+		[]middleware.MiddlewareStack{
+			app.Middleware.RateLimitMiddleware(verificationLimit, middleware.RateLimitByIP),
+		},
 		app.UserController.UserVerifyEmailToken,
 	))
 
+	router.Handle("POST /user/verify-email/resend", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.RateLimitMiddleware(verificationLimit, middleware.RateLimitByUser),
+		},
+		app.UserController.UserResendVerificationEmail,
+	))
+
 	router.Handle("DELETE /user/:id", app.Middleware.CreateStackWithHandler(
-		[]middleware.MiddlewareStack{app.Middleware.UserAuthMiddleware},
+		[]middleware.MiddlewareStack{
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.NoImpersonationMiddleware,
+		},
 		app.UserController.UserMarkForDeletion,
 	))
 
-	router.Handle("PATCH /user/:id/password", app.Middleware.CreateStackWithHandler(
+	router.Handle("PATCH /user/password", app.Middleware.CreateStackWithHandler(
 		[]middleware.MiddlewareStack{
-			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyUserPasswordUpdateDto),
+			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyUserChangePasswordDto),
 			app.Middleware.UserAuthMiddleware,
+			app.Middleware.NoImpersonationMiddleware,
+			app.Middleware.RateLimitMiddleware(passwordChangeLimit, middleware.RateLimitByUser),
 		},
-		app.UserController.UserPasswordUpdate,
+		app.UserController.UserChangePassword,
 	))
 
 	router.Handle("GET /user/logout", app.Middleware.CreateStackWithHandler(
@@ -0,0 +1,78 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/21TechLabs/factory-backend/app"
+	"github.com/21TechLabs/factory-backend/dto"
+	"github.com/21TechLabs/factory-backend/middleware"
+)
+
+// SetupWebhooks registers the endpoints customers use to manage their outbound
+// webhook endpoints and inspect or replay deliveries.
+func SetupWebhooks(router *http.ServeMux, app *app.Application) {
+
+	router.Handle("GET /webhooks/event-types", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{app.Middleware.UserAuthMiddleware},
+		app.WebhookController.ListEventTypes,
+	))
+
+	router.Handle("GET /webhooks", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{app.Middleware.UserAuthMiddleware},
+		app.WebhookController.ListEndpoints,
+	))
+
+	router.Handle("POST /webhooks", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyWebhookEndpointCreate),
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.NoImpersonationMiddleware,
+		},
+		app.WebhookController.CreateEndpoint,
+	))
+
+	router.Handle("GET /webhooks/{id}", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{app.Middleware.UserAuthMiddleware},
+		app.WebhookController.GetEndpoint,
+	))
+
+	router.Handle("PATCH /webhooks/{id}", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.SchemaValidatorMiddleware(dto.DtoMapKeyWebhookEndpointUpdate),
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.NoImpersonationMiddleware,
+		},
+		app.WebhookController.UpdateEndpoint,
+	))
+
+	router.Handle("DELETE /webhooks/{id}", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.NoImpersonationMiddleware,
+		},
+		app.WebhookController.DeleteEndpoint,
+	))
+
+	router.Handle("POST /webhooks/{id}/rotate-secret", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{
+			app.Middleware.UserAuthMiddleware,
+			app.Middleware.NoImpersonationMiddleware,
+		},
+		app.WebhookController.RotateSecret,
+	))
+
+	router.Handle("GET /webhooks/{id}/deliveries", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{app.Middleware.UserAuthMiddleware},
+		app.WebhookController.ListDeliveries,
+	))
+
+	router.Handle("GET /webhooks/{id}/deliveries/{deliveryId}", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{app.Middleware.UserAuthMiddleware},
+		app.WebhookController.GetDelivery,
+	))
+
+	router.Handle("POST /webhooks/{id}/deliveries/{deliveryId}/redeliver", app.Middleware.CreateStackWithHandler(
+		[]middleware.MiddlewareStack{app.Middleware.UserAuthMiddleware},
+		app.WebhookController.Redeliver,
+	))
+}
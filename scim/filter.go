@@ -0,0 +1,380 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ColumnKind is how an attribute is compared in SQL.
+type ColumnKind int
+
+const (
+	// KindString compares case-insensitively, as for userName.
+	KindString ColumnKind = iota
+	// KindExactString compares case-sensitively, as for id and externalId.
+	KindExactString
+	KindBool
+	KindTime
+)
+
+// Column maps a filter attribute to a trusted SQL expression.
+type Column struct {
+	SQL  string
+	Kind ColumnKind
+}
+
+// Columns maps lower cased attribute paths, e.g. "name.givenname", to
+// columns.
+type Columns map[string]Column
+
+// Filter is a parsed filter expression (RFC 7644 section 3.4.2.2). Value
+// paths such as emails[type eq "work"] are not supported.
+type Filter interface {
+	// SQL returns a WHERE clause with placeholders for its arguments.
+	SQL(columns Columns) (string, []interface{}, *Error)
+}
+
+type logicalFilter struct {
+	op          string
+	left, right Filter
+}
+
+type notFilter struct {
+	inner Filter
+}
+
+type attrFilter struct {
+	path  string
+	op    string
+	value interface{}
+}
+
+func (f *logicalFilter) SQL(columns Columns) (string, []interface{}, *Error) {
+	left, leftArgs, err := f.left.SQL(columns)
+	if err != nil {
+		return "", nil, err
+	}
+	right, rightArgs, err := f.right.SQL(columns)
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("(%s %s %s)", left, strings.ToUpper(f.op), right), append(leftArgs, rightArgs...), nil
+}
+
+func (f *notFilter) SQL(columns Columns) (string, []interface{}, *Error) {
+	inner, args, err := f.inner.SQL(columns)
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("(NOT %s)", inner), args, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func (f *attrFilter) SQL(columns Columns) (string, []interface{}, *Error) {
+	column, ok := columns[f.path]
+	if !ok {
+		return "", nil, ErrInvalidFilter(fmt.Sprintf("filtering on %q is not supported", f.path))
+	}
+	col := column.SQL
+
+	present := fmt.Sprintf("(%s IS NOT NULL)", col)
+	if column.Kind == KindString || column.Kind == KindExactString {
+		present = fmt.Sprintf("(%s IS NOT NULL AND %s <> '')", col, col)
+	}
+	switch {
+	case f.op == "pr":
+		return present, nil, nil
+	case f.value == nil && f.op == "eq":
+		return "(NOT " + present + ")", nil, nil
+	case f.value == nil && f.op == "ne":
+		return present, nil, nil
+	case f.value == nil:
+		return "", nil, ErrInvalidFilter(fmt.Sprintf("%q cannot be compared to null", f.op))
+	}
+
+	switch column.Kind {
+	case KindBool:
+		value, ok := parseBool(f.value)
+		if !ok || (f.op != "eq" && f.op != "ne") {
+			return "", nil, ErrInvalidFilter(fmt.Sprintf("%s only supports eq and ne with a boolean", f.path))
+		}
+		if f.op == "eq" {
+			return fmt.Sprintf("(%s = ?)", col), []interface{}{value}, nil
+		}
+		return fmt.Sprintf("(%s <> ?)", col), []interface{}{value}, nil
+
+	case KindTime:
+		raw, ok := f.value.(string)
+		if !ok {
+			return "", nil, ErrInvalidFilter(fmt.Sprintf("%s must be compared to a date", f.path))
+		}
+		value, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return "", nil, ErrInvalidFilter(fmt.Sprintf("%s must be compared to an RFC 3339 date", f.path))
+		}
+		op, ok := map[string]string{"eq": "=", "ne": "<>", "gt": ">", "ge": ">=", "lt": "<", "le": "<="}[f.op]
+		if !ok {
+			return "", nil, ErrInvalidFilter(fmt.Sprintf("%q is not supported for dates", f.op))
+		}
+		return fmt.Sprintf("(%s %s ?)", col, op), []interface{}{value}, nil
+	}
+
+	value, ok := f.value.(string)
+	if !ok {
+		return "", nil, ErrInvalidFilter(fmt.Sprintf("%s must be compared to a string", f.path))
+	}
+	like := "LIKE"
+	if column.Kind == KindString {
+		col = fmt.Sprintf("LOWER(%s)", col)
+		value = strings.ToLower(value)
+	}
+	switch f.op {
+	case "eq":
+		return fmt.Sprintf("(%s = ?)", col), []interface{}{value}, nil
+	case "ne":
+		return fmt.Sprintf("(%s IS NULL OR %s <> ?)", column.SQL, col), []interface{}{value}, nil
+	case "co":
+		return fmt.Sprintf("(%s %s ?)", col, like), []interface{}{"%" + escapeLike(value) + "%"}, nil
+	case "sw":
+		return fmt.Sprintf("(%s %s ?)", col, like), []interface{}{escapeLike(value) + "%"}, nil
+	case "ew":
+		return fmt.Sprintf("(%s %s ?)", col, like), []interface{}{"%" + escapeLike(value)}, nil
+	case "gt", "ge", "lt", "le":
+		op := map[string]string{"gt": ">", "ge": ">=", "lt": "<", "le": "<="}[f.op]
+		return fmt.Sprintf("(%s %s ?)", col, op), []interface{}{value}, nil
+	}
+	return "", nil, ErrInvalidFilter(fmt.Sprintf("unknown operator %q", f.op))
+}
+
+var comparisonOperators = map[string]bool{
+	"eq": true, "ne": true, "co": true, "sw": true, "ew": true,
+	"gt": true, "ge": true, "lt": true, "le": true,
+}
+
+type token struct {
+	text string
+	// quoted is set for string literals, whose text is the decoded value
+	quoted bool
+}
+
+func tokenize(s string) ([]token, *Error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')' || c == '[' || c == ']':
+			tokens = append(tokens, token{text: string(c)})
+			i++
+		case c == '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(s) {
+				return nil, ErrInvalidFilter("unterminated string")
+			}
+			var value string
+			if err := json.Unmarshal([]byte(s[i:j+1]), &value); err != nil {
+				return nil, ErrInvalidFilter("invalid string")
+			}
+			tokens = append(tokens, token{text: value, quoted: true})
+			i = j + 1
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\n()[]\"", rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, token{text: s[i:j]})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *parser) peekKeyword(keyword string) bool {
+	t, ok := p.peek()
+	return ok && !t.quoted && strings.EqualFold(t.text, keyword)
+}
+
+func (p *parser) next() (token, bool) {
+	t, ok := p.peek()
+	if ok {
+		p.pos++
+	}
+	return t, ok
+}
+
+// ParseFilter parses a filter expression.
+func ParseFilter(s string) (Filter, *Error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	filter, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := p.peek(); ok {
+		return nil, ErrInvalidFilter(fmt.Sprintf("unexpected %q", t.text))
+	}
+	return filter, nil
+}
+
+func (p *parser) parseOr() (Filter, *Error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalFilter{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Filter, *Error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("and") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalFilter{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Filter, *Error) {
+	if p.peekKeyword("not") {
+		p.next()
+		if t, ok := p.peek(); !ok || t.text != "(" || t.quoted {
+			return nil, ErrInvalidFilter("not must be followed by a parenthesized filter")
+		}
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notFilter{inner: inner}, nil
+	}
+
+	t, ok := p.next()
+	if !ok {
+		return nil, ErrInvalidFilter("unexpected end of filter")
+	}
+	if t.text == "(" && !t.quoted {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing, ok := p.next(); !ok || closing.text != ")" || closing.quoted {
+			return nil, ErrInvalidFilter("missing )")
+		}
+		return inner, nil
+	}
+	if t.quoted || !isAttrPath(t.text) {
+		return nil, ErrInvalidFilter(fmt.Sprintf("expected an attribute, got %q", t.text))
+	}
+	if next, ok := p.peek(); ok && next.text == "[" && !next.quoted {
+		return nil, ErrInvalidFilter("value path filters are not supported")
+	}
+
+	path := NormalizePath(t.text)
+	op, ok := p.next()
+	if !ok || op.quoted {
+		return nil, ErrInvalidFilter(fmt.Sprintf("expected an operator after %q", t.text))
+	}
+	opName := strings.ToLower(op.text)
+	if opName == "pr" {
+		return &attrFilter{path: path, op: opName}, nil
+	}
+	if !comparisonOperators[opName] {
+		return nil, ErrInvalidFilter(fmt.Sprintf("unknown operator %q", op.text))
+	}
+
+	valueToken, ok := p.next()
+	if !ok {
+		return nil, ErrInvalidFilter(fmt.Sprintf("expected a value after %q", op.text))
+	}
+	value, err := parseValue(valueToken)
+	if err != nil {
+		return nil, err
+	}
+	return &attrFilter{path: path, op: opName, value: value}, nil
+}
+
+func parseValue(t token) (interface{}, *Error) {
+	if t.quoted {
+		return t.text, nil
+	}
+	switch strings.ToLower(t.text) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	var number json.Number
+	if err := json.Unmarshal([]byte(t.text), &number); err != nil {
+		return nil, ErrInvalidFilter(fmt.Sprintf("invalid value %q", t.text))
+	}
+	return number, nil
+}
+
+func isAttrPath(s string) bool {
+	if s == "" || !unicode.IsLetter(rune(s[0])) && s[0] != '$' {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(":._-$", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// NormalizePath lower cases an attribute path and strips its schema URN, so
+// that "urn:ietf:params:scim:schemas:core:2.0:User:userName" and "USERNAME"
+// both become "username".
+func NormalizePath(path string) string {
+	lower := strings.ToLower(path)
+	for _, schema := range []string{SchemaUser, SchemaGroup} {
+		prefix := strings.ToLower(schema) + ":"
+		if strings.HasPrefix(lower, prefix) {
+			return lower[len(prefix):]
+		}
+	}
+	return lower
+}
@@ -0,0 +1,215 @@
+package scim
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testColumns = Columns{
+	"username":          {SQL: "users.email", Kind: KindString},
+	"externalid":        {SQL: "users.external_id", Kind: KindExactString},
+	"active":            {SQL: "users.active", Kind: KindBool},
+	"meta.lastmodified": {SQL: "users.updated_at", Kind: KindTime},
+}
+
+func filterSQL(t *testing.T, filter string) (string, []interface{}, *Error) {
+	t.Helper()
+	parsed, err := ParseFilter(filter)
+	if err != nil {
+		t.Fatalf("ParseFilter(%q): %v", filter, err)
+	}
+	return parsed.SQL(testColumns)
+}
+
+func TestFilterPrecedence(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+		sql    string
+		args   []interface{}
+	}{
+		{
+			name:   "and binds tighter than or",
+			filter: `userName eq "a" or userName eq "b" and active eq true`,
+			sql:    "((LOWER(users.email) = ?) OR ((LOWER(users.email) = ?) AND (users.active = ?)))",
+			args:   []interface{}{"a", "b", true},
+		},
+		{
+			name:   "parentheses group or",
+			filter: `(userName eq "a" or userName eq "b") and active eq true`,
+			sql:    "(((LOWER(users.email) = ?) OR (LOWER(users.email) = ?)) AND (users.active = ?))",
+			args:   []interface{}{"a", "b", true},
+		},
+		{
+			name:   "and is left associative",
+			filter: `userName eq "a" and userName eq "b" and userName eq "c"`,
+			sql:    "(((LOWER(users.email) = ?) AND (LOWER(users.email) = ?)) AND (LOWER(users.email) = ?))",
+			args:   []interface{}{"a", "b", "c"},
+		},
+		{
+			name:   "not applies to its group only",
+			filter: `not (active eq true) and userName eq "a"`,
+			sql:    "((NOT (users.active = ?)) AND (LOWER(users.email) = ?))",
+			args:   []interface{}{true, "a"},
+		},
+		{
+			name:   "not around a compound group",
+			filter: `not (userName eq "a" or userName eq "b")`,
+			sql:    "(NOT ((LOWER(users.email) = ?) OR (LOWER(users.email) = ?)))",
+			args:   []interface{}{"a", "b"},
+		},
+		{
+			name:   "nested groups",
+			filter: `((userName eq "a"))`,
+			sql:    "(LOWER(users.email) = ?)",
+			args:   []interface{}{"a"},
+		},
+		{
+			name:   "keywords and operators are case-insensitive",
+			filter: `userName EQ "a" AND NOT (active Eq true) OR externalId Pr`,
+			sql:    "(((LOWER(users.email) = ?) AND (NOT (users.active = ?))) OR (users.external_id IS NOT NULL AND users.external_id <> ''))",
+			args:   []interface{}{"a", true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args, err := filterSQL(t, tt.filter)
+			if err != nil {
+				t.Fatalf("SQL: %v", err)
+			}
+			if sql != tt.sql {
+				t.Errorf("expected SQL\n%s\ngot\n%s", tt.sql, sql)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("expected args %#v, got %#v", tt.args, args)
+			}
+		})
+	}
+}
+
+func TestFilterOperators(t *testing.T) {
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		filter string
+		sql    string
+		args   []interface{}
+	}{
+		// case-insensitive strings compare lower cased
+		{`userName eq "Alice"`, "(LOWER(users.email) = ?)", []interface{}{"alice"}},
+		{`userName ne "Alice"`, "(users.email IS NULL OR LOWER(users.email) <> ?)", []interface{}{"alice"}},
+		{`userName co "Ali"`, "(LOWER(users.email) LIKE ?)", []interface{}{"%ali%"}},
+		{`userName sw "Ali"`, "(LOWER(users.email) LIKE ?)", []interface{}{"ali%"}},
+		{`userName ew "Ce"`, "(LOWER(users.email) LIKE ?)", []interface{}{"%ce"}},
+		{`userName gt "b"`, "(LOWER(users.email) > ?)", []interface{}{"b"}},
+		{`userName ge "b"`, "(LOWER(users.email) >= ?)", []interface{}{"b"}},
+		{`userName lt "b"`, "(LOWER(users.email) < ?)", []interface{}{"b"}},
+		{`userName le "b"`, "(LOWER(users.email) <= ?)", []interface{}{"b"}},
+		{`userName pr`, "(users.email IS NOT NULL AND users.email <> '')", nil},
+		{`userName eq null`, "(NOT (users.email IS NOT NULL AND users.email <> ''))", nil},
+		{`userName ne null`, "(users.email IS NOT NULL AND users.email <> '')", nil},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName eq "a"`, "(LOWER(users.email) = ?)", []interface{}{"a"}},
+
+		// exact strings keep their case
+		{`externalId eq "AbC"`, "(users.external_id = ?)", []interface{}{"AbC"}},
+		{`externalId ne "AbC"`, "(users.external_id IS NULL OR users.external_id <> ?)", []interface{}{"AbC"}},
+		{`externalId sw "AbC"`, "(users.external_id LIKE ?)", []interface{}{"AbC%"}},
+
+		// LIKE wildcards in the value match literally
+		{`userName co "50%_off"`, "(LOWER(users.email) LIKE ?)", []interface{}{`%50\%\_off%`}},
+		{`userName sw "a\\b"`, "(LOWER(users.email) LIKE ?)", []interface{}{`a\\b%`}},
+		{`externalId ew "_%"`, "(users.external_id LIKE ?)", []interface{}{`%\_\%`}},
+
+		// booleans, including the strings some IdPs send
+		{`active eq true`, "(users.active = ?)", []interface{}{true}},
+		{`active ne false`, "(users.active <> ?)", []interface{}{false}},
+		{`active eq "False"`, "(users.active = ?)", []interface{}{false}},
+		{`active pr`, "(users.active IS NOT NULL)", nil},
+
+		// dates
+		{`meta.lastModified eq "2024-01-02T03:04:05Z"`, "(users.updated_at = ?)", []interface{}{date}},
+		{`meta.lastModified ne "2024-01-02T03:04:05Z"`, "(users.updated_at <> ?)", []interface{}{date}},
+		{`meta.lastModified gt "2024-01-02T03:04:05Z"`, "(users.updated_at > ?)", []interface{}{date}},
+		{`meta.lastModified ge "2024-01-02T03:04:05Z"`, "(users.updated_at >= ?)", []interface{}{date}},
+		{`meta.lastModified lt "2024-01-02T03:04:05Z"`, "(users.updated_at < ?)", []interface{}{date}},
+		{`meta.lastModified le "2024-01-02T03:04:05Z"`, "(users.updated_at <= ?)", []interface{}{date}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			sql, args, err := filterSQL(t, tt.filter)
+			if err != nil {
+				t.Fatalf("SQL: %v", err)
+			}
+			if sql != tt.sql {
+				t.Errorf("expected SQL %s, got %s", tt.sql, sql)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("expected args %#v, got %#v", tt.args, args)
+			}
+		})
+	}
+}
+
+func TestParseFilterRejectsInvalidSyntax(t *testing.T) {
+	for _, filter := range []string{
+		``,
+		`userName`,
+		`userName zz "a"`,
+		`userName like "a"`,
+		`userName eq`,
+		`userName eq "a`,
+		`userName eq bogus`,
+		`"userName" eq "a"`,
+		`(userName eq "a"`,
+		`userName eq "a")`,
+		`userName eq "a" and`,
+		`userName eq "a" xor userName eq "b"`,
+		`not active eq true`,
+		`emails[type eq "work"]`,
+		`emails[type eq "work"].value eq "a"`,
+	} {
+		t.Run(filter, func(t *testing.T) {
+			parsed, err := ParseFilter(filter)
+			if err == nil {
+				t.Fatalf("expected an error, parsed %#v", parsed)
+			}
+			if err.ScimType != "invalidFilter" {
+				t.Errorf("expected invalidFilter, got %q", err.ScimType)
+			}
+		})
+	}
+}
+
+func TestFilterSQLRejectsUnsupportedComparisons(t *testing.T) {
+	for _, filter := range []string{
+		// unknown attributes
+		`nickName eq "a"`,
+		`name.givenName eq "a"`,
+		`urn:ietf:params:scim:schemas:core:2.0:Group:displayName eq "a"`,
+		`userName eq "a" and nickName eq "b"`,
+		`not (nickName pr)`,
+		// operators the attribute type does not support
+		`active co true`,
+		`active gt false`,
+		`active eq "maybe"`,
+		`meta.lastModified co "2024-01-02T03:04:05Z"`,
+		`meta.lastModified gt "yesterday"`,
+		`meta.lastModified gt 5`,
+		`userName gt null`,
+		`userName eq 5`,
+		`userName eq true`,
+	} {
+		t.Run(filter, func(t *testing.T) {
+			sql, _, err := filterSQL(t, filter)
+			if err == nil {
+				t.Fatalf("expected an error, got %s", sql)
+			}
+			if err.ScimType != "invalidFilter" {
+				t.Errorf("expected invalidFilter, got %q", err.ScimType)
+			}
+		})
+	}
+}
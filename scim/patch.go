@@ -0,0 +1,367 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// PatchRequest is a PATCH body (RFC 7644 section 3.5.2).
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Validate checks the schema and operation names. Op is case-insensitive as
+// some IdPs send "Replace".
+func (p *PatchRequest) Validate() *Error {
+	if !slices.Contains(p.Schemas, SchemaPatchOp) {
+		return ErrInvalidSyntax("the request must use the " + SchemaPatchOp + " schema")
+	}
+	if len(p.Operations) == 0 {
+		return ErrInvalidSyntax("the request has no operations")
+	}
+	for i := range p.Operations {
+		op := &p.Operations[i]
+		op.Op = strings.ToLower(op.Op)
+		switch op.Op {
+		case "add", "replace":
+			if len(op.Value) == 0 {
+				return ErrInvalidValue(fmt.Sprintf("%s needs a value", op.Op))
+			}
+		case "remove":
+			if op.Path == "" {
+				return ErrNoTarget("remove needs a path")
+			}
+		default:
+			return ErrInvalidSyntax(fmt.Sprintf("unknown operation %q", op.Op))
+		}
+	}
+	return nil
+}
+
+// splitPath splits a path such as emails[type eq "work"].value into its
+// attribute, its value filter and its sub-attribute.
+func splitPath(path string) (attr string, filter string, sub string, err *Error) {
+	open := strings.Index(path, "[")
+	if open < 0 {
+		return NormalizePath(path), "", "", nil
+	}
+	end := strings.LastIndex(path, "]")
+	if end < open {
+		return "", "", "", ErrInvalidPath(fmt.Sprintf("invalid path %q", path))
+	}
+	attr = NormalizePath(path[:open])
+	filter = path[open+1 : end]
+	rest := path[end+1:]
+	if rest != "" {
+		if !strings.HasPrefix(rest, ".") {
+			return "", "", "", ErrInvalidPath(fmt.Sprintf("invalid path %q", path))
+		}
+		sub = strings.ToLower(rest[1:])
+	}
+	if _, err := ParseFilter(filter); err != nil {
+		return "", "", "", ErrInvalidPath(fmt.Sprintf("invalid filter in path %q", path))
+	}
+	return attr, filter, sub, nil
+}
+
+// isExtension reports whether path belongs to a schema extension, which
+// is accepted and ignored.
+func isExtension(path string) bool {
+	return strings.HasPrefix(NormalizePath(path), "urn:")
+}
+
+// patchObject applies an add or replace without a path, whose value is an
+// object of attribute paths.
+func patchObject(op PatchOperation, apply func(op, path string, value json.RawMessage) *Error) *Error {
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(op.Value, &values); err != nil {
+		return ErrInvalidValue("an operation without a path needs an object value")
+	}
+	for path, value := range values {
+		if err := apply(op.Op, path, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeValue(path string, value json.RawMessage, v interface{}) *Error {
+	if err := json.Unmarshal(value, v); err != nil {
+		return ErrInvalidValue(fmt.Sprintf("invalid value for %s", path))
+	}
+	return nil
+}
+
+// ApplyPatch applies the operations of a validated request to u.
+func (u *User) ApplyPatch(req *PatchRequest) *Error {
+	for _, op := range req.Operations {
+		var err *Error
+		if op.Path == "" {
+			err = patchObject(op, u.applyPath)
+		} else {
+			err = u.applyPath(op.Op, op.Path, op.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyPath sets or removes the attribute at path. Users have a single name,
+// email and role, so a value filter on emails or roles selects that one.
+func (u *User) applyPath(op, path string, value json.RawMessage) *Error {
+	if isExtension(path) {
+		return nil
+	}
+	attr, _, sub, err := splitPath(path)
+	if err != nil {
+		return err
+	}
+	remove := op == "remove"
+
+	switch attr {
+	case "active":
+		u.Active = nil
+		if remove {
+			return nil
+		}
+		var active Bool
+		if err := decodeValue(path, value, &active); err != nil {
+			return err
+		}
+		u.Active = &active
+
+	case "username":
+		if remove {
+			return ErrMutability("userName is required")
+		}
+		return decodeValue(path, value, &u.UserName)
+
+	case "displayname":
+		// a user has one name, so a new display name replaces the name parts
+		u.DisplayName, u.Name = "", nil
+		if remove {
+			return nil
+		}
+		return decodeValue(path, value, &u.DisplayName)
+
+	case "externalid":
+		u.ExternalID = ""
+		if remove {
+			return nil
+		}
+		return decodeValue(path, value, &u.ExternalID)
+
+	case "name":
+		u.DisplayName, u.Name = "", nil
+		if remove {
+			return nil
+		}
+		var name Name
+		if err := decodeValue(path, value, &name); err != nil {
+			return err
+		}
+		u.Name = &name
+
+	case "name.givenname", "name.familyname", "name.formatted":
+		u.DisplayName = ""
+		if u.Name == nil {
+			u.Name = &Name{}
+		}
+		var v string
+		if !remove {
+			if err := decodeValue(path, value, &v); err != nil {
+				return err
+			}
+		}
+		switch attr {
+		case "name.givenname":
+			u.Name.GivenName = v
+		case "name.familyname":
+			u.Name.FamilyName = v
+		default:
+			u.Name.Formatted = v
+		}
+
+	case "emails", "emails.value":
+		if remove {
+			return ErrMutability("an email is required")
+		}
+		if attr == "emails.value" {
+			sub = "value"
+		}
+		return patchMultiValued(path, sub, value, &u.Emails)
+
+	case "roles", "roles.value":
+		if attr == "roles.value" {
+			sub = "value"
+		}
+		if remove {
+			u.Roles = []MultiValued{}
+			return nil
+		}
+		return patchMultiValued(path, sub, value, &u.Roles)
+
+	case "groups":
+		return ErrMutability("groups are managed through the Groups endpoint")
+
+	case "id", "meta", "schemas":
+		return ErrMutability(fmt.Sprintf("%s is read-only", path))
+
+	default:
+		return ErrInvalidPath(fmt.Sprintf("unknown attribute %q", path))
+	}
+	return nil
+}
+
+// patchMultiValued replaces a single-entry multi-valued attribute, accepting
+// either entries or, for a sub-attribute path, the bare value.
+func patchMultiValued(path, sub string, value json.RawMessage, values *[]MultiValued) *Error {
+	switch sub {
+	case "":
+		var entries []MultiValued
+		if err := json.Unmarshal(value, &entries); err != nil {
+			var entry MultiValued
+			if err := decodeValue(path, value, &entry); err != nil {
+				return err
+			}
+			entries = []MultiValued{entry}
+		}
+		*values = entries
+	case "value":
+		var v string
+		if err := decodeValue(path, value, &v); err != nil {
+			return err
+		}
+		*values = []MultiValued{{Value: v, Primary: true}}
+	case "primary", "type", "display":
+		// only the value is stored
+	default:
+		return ErrInvalidPath(fmt.Sprintf("unknown attribute %q", path))
+	}
+	return nil
+}
+
+// ApplyPatch applies the operations of a validated request to g. Members
+// are added and removed by reference, so large groups need no full member
+// list.
+func (g *Group) ApplyPatch(req *PatchRequest) *Error {
+	for _, op := range req.Operations {
+		var err *Error
+		if op.Path == "" {
+			err = patchObject(op, g.applyPath)
+		} else {
+			err = g.applyPath(op.Op, op.Path, op.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *Group) applyPath(op, path string, value json.RawMessage) *Error {
+	if isExtension(path) {
+		return nil
+	}
+	attr, filter, _, err := splitPath(path)
+	if err != nil {
+		return err
+	}
+	remove := op == "remove"
+
+	switch attr {
+	case "displayname":
+		if remove {
+			return ErrMutability("displayName is required")
+		}
+		return decodeValue(path, value, &g.DisplayName)
+
+	case "externalid":
+		g.ExternalID = ""
+		if remove {
+			return nil
+		}
+		return decodeValue(path, value, &g.ExternalID)
+
+	case "members":
+		return g.patchMembers(op, path, filter, value)
+
+	case "id", "meta", "schemas":
+		return ErrMutability(fmt.Sprintf("%s is read-only", path))
+
+	default:
+		return ErrInvalidPath(fmt.Sprintf("unknown attribute %q", path))
+	}
+}
+
+func (g *Group) patchMembers(op, path, filter string, value json.RawMessage) *Error {
+	var members []Reference
+	if len(value) > 0 {
+		if err := decodeValue(path, value, &members); err != nil {
+			return err
+		}
+	}
+
+	switch op {
+	case "replace":
+		g.Members = members
+	case "add":
+		for _, member := range members {
+			if !slices.ContainsFunc(g.Members, func(m Reference) bool { return m.Value == member.Value }) {
+				g.Members = append(g.Members, member)
+			}
+		}
+	case "remove":
+		var remove []string
+		switch {
+		case filter != "":
+			id, err := memberFilterValue(filter)
+			if err != nil {
+				return err
+			}
+			remove = []string{id}
+		case len(members) > 0:
+			// Azure AD names the members to remove in the value
+			for _, member := range members {
+				remove = append(remove, member.Value)
+			}
+		default:
+			g.Members = []Reference{}
+			return nil
+		}
+		g.Members = slices.DeleteFunc(g.Members, func(m Reference) bool { return slices.Contains(remove, m.Value) })
+		if g.Members == nil {
+			g.Members = []Reference{}
+		}
+	}
+	return nil
+}
+
+// memberFilterValue returns the id selected by a members[value eq "id"]
+// filter, the only member filter supported.
+func memberFilterValue(filter string) (string, *Error) {
+	parsed, err := ParseFilter(filter)
+	if err != nil {
+		return "", err
+	}
+	attr, ok := parsed.(*attrFilter)
+	if !ok || attr.path != "value" || attr.op != "eq" {
+		return "", ErrInvalidFilter("members can only be filtered by value eq")
+	}
+	id, ok := attr.value.(string)
+	if !ok {
+		return "", ErrInvalidFilter("members can only be filtered by value eq")
+	}
+	return id, nil
+}
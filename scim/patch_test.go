@@ -0,0 +1,280 @@
+package scim
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func patchRequest(ops ...PatchOperation) *PatchRequest {
+	return &PatchRequest{Schemas: []string{SchemaPatchOp}, Operations: ops}
+}
+
+func patchOp(op, path, value string) PatchOperation {
+	operation := PatchOperation{Op: op, Path: path}
+	if value != "" {
+		operation.Value = json.RawMessage(value)
+	}
+	return operation
+}
+
+func testUser() *User {
+	return &User{
+		UserName: "ada@example.com",
+		Name:     &Name{GivenName: "Ada", FamilyName: "Lovelace"},
+		Emails:   []MultiValued{{Value: "ada@example.com", Type: "work", Primary: true}},
+		Roles:    []MultiValued{{Value: "member", Primary: true}},
+	}
+}
+
+func TestPatchRequestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      *PatchRequest
+		scimType string
+	}{
+		{"missing schema", &PatchRequest{Operations: []PatchOperation{patchOp("add", "userName", `"a"`)}}, "invalidSyntax"},
+		{"no operations", patchRequest(), "invalidSyntax"},
+		{"unknown operation", patchRequest(patchOp("move", "userName", `"a"`)), "invalidSyntax"},
+		{"add without value", patchRequest(patchOp("add", "userName", "")), "invalidValue"},
+		{"replace without value", patchRequest(patchOp("replace", "userName", "")), "invalidValue"},
+		{"remove without path", patchRequest(patchOp("remove", "", "")), "noTarget"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if err.ScimType != tt.scimType {
+				t.Errorf("expected %q, got %q", tt.scimType, err.ScimType)
+			}
+		})
+	}
+
+	req := patchRequest(patchOp("Replace", "userName", `"a"`), patchOp("REMOVE", "displayName", ""))
+	if err := req.Validate(); err != nil {
+		t.Fatalf("expected mixed case operations to be accepted, got %v", err)
+	}
+	if req.Operations[0].Op != "replace" || req.Operations[1].Op != "remove" {
+		t.Errorf("expected operations to be lower cased, got %q and %q", req.Operations[0].Op, req.Operations[1].Op)
+	}
+}
+
+func TestUserApplyPatch(t *testing.T) {
+	active := Bool(false)
+
+	tests := []struct {
+		name string
+		ops  []PatchOperation
+		want func(u *User)
+	}{
+		{
+			name: "replace email through a value path filter",
+			ops:  []PatchOperation{patchOp("replace", `emails[type eq "work"].value`, `"new@example.com"`)},
+			want: func(u *User) { u.Emails = []MultiValued{{Value: "new@example.com", Primary: true}} },
+		},
+		{
+			name: "add email through a value path filter",
+			ops:  []PatchOperation{patchOp("add", `emails[type eq "work"].value`, `"new@example.com"`)},
+			want: func(u *User) { u.Emails = []MultiValued{{Value: "new@example.com", Primary: true}} },
+		},
+		{
+			name: "replace emails with entries",
+			ops:  []PatchOperation{patchOp("replace", "emails", `[{"value":"new@example.com","type":"home","primary":"True"}]`)},
+			want: func(u *User) { u.Emails = []MultiValued{{Value: "new@example.com", Type: "home", Primary: true}} },
+		},
+		{
+			name: "replace a single email entry",
+			ops:  []PatchOperation{patchOp("replace", "emails", `{"value":"new@example.com"}`)},
+			want: func(u *User) { u.Emails = []MultiValued{{Value: "new@example.com"}} },
+		},
+		{
+			name: "sub-attributes other than value are ignored",
+			ops:  []PatchOperation{patchOp("replace", `emails[type eq "work"].primary`, `true`)},
+			want: func(u *User) {},
+		},
+		{
+			name: "replace role through a value path filter",
+			ops:  []PatchOperation{patchOp("replace", `roles[primary eq true].value`, `"admin"`)},
+			want: func(u *User) { u.Roles = []MultiValued{{Value: "admin", Primary: true}} },
+		},
+		{
+			name: "remove role through a value path filter",
+			ops:  []PatchOperation{patchOp("remove", `roles[value eq "member"]`, "")},
+			want: func(u *User) { u.Roles = []MultiValued{} },
+		},
+		{
+			name: "replace given name",
+			ops:  []PatchOperation{patchOp("replace", "name.givenName", `"Augusta"`)},
+			want: func(u *User) { u.Name.GivenName = "Augusta" },
+		},
+		{
+			name: "remove family name",
+			ops:  []PatchOperation{patchOp("remove", "name.familyName", "")},
+			want: func(u *User) { u.Name.FamilyName = "" },
+		},
+		{
+			name: "display name replaces the name parts",
+			ops:  []PatchOperation{patchOp("add", "displayName", `"Ada L."`)},
+			want: func(u *User) { u.Name, u.DisplayName = nil, "Ada L." },
+		},
+		{
+			name: "replace active from a string",
+			ops:  []PatchOperation{patchOp("replace", "active", `"False"`)},
+			want: func(u *User) { u.Active = &active },
+		},
+		{
+			name: "replace without a path",
+			ops:  []PatchOperation{patchOp("replace", "", `{"active":false,"externalId":"ext-1"}`)},
+			want: func(u *User) { u.Active, u.ExternalID = &active, "ext-1" },
+		},
+		{
+			name: "schema qualified paths",
+			ops:  []PatchOperation{patchOp("replace", SchemaUser+":userName", `"lovelace@example.com"`)},
+			want: func(u *User) { u.UserName = "lovelace@example.com" },
+		},
+		{
+			name: "extension attributes are ignored",
+			ops:  []PatchOperation{patchOp("add", "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department", `"R&D"`)},
+			want: func(u *User) {},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := testUser()
+			if err := got.ApplyPatch(patchRequest(tt.ops...)); err != nil {
+				t.Fatalf("ApplyPatch: %v", err)
+			}
+			want := testUser()
+			tt.want(want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("expected %+v, got %+v", want, got)
+			}
+		})
+	}
+}
+
+func TestUserApplyPatchRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		op       PatchOperation
+		scimType string
+	}{
+		{"remove email through a value path filter", patchOp("remove", `emails[type eq "work"].value`, ""), "mutability"},
+		{"remove userName", patchOp("remove", "userName", ""), "mutability"},
+		{"replace id", patchOp("replace", "id", `"1"`), "mutability"},
+		{"add groups", patchOp("add", "groups", `[{"value":"1"}]`), "mutability"},
+		{"unknown attribute", patchOp("replace", "nickName", `"a"`), "invalidPath"},
+		{"unknown sub-attribute", patchOp("replace", `emails[type eq "work"].label`, `"a"`), "invalidPath"},
+		{"invalid value path filter", patchOp("replace", `emails[type zz "work"].value`, `"a"`), "invalidPath"},
+		{"unclosed value path filter", patchOp("replace", `emails[type eq "work".value`, `"a"`), "invalidPath"},
+		{"text after a value path filter", patchOp("replace", `emails[type eq "work"]value`, `"a"`), "invalidPath"},
+		{"wrong value type", patchOp("replace", "userName", `5`), "invalidValue"},
+		{"non-object value without a path", patchOp("replace", "", `"a"`), "invalidValue"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := testUser().ApplyPatch(patchRequest(tt.op))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if err.ScimType != tt.scimType {
+				t.Errorf("expected %q, got %q: %v", tt.scimType, err.ScimType, err)
+			}
+		})
+	}
+}
+
+func TestGroupApplyPatch(t *testing.T) {
+	members := func(ids ...string) []Reference {
+		refs := []Reference{}
+		for _, id := range ids {
+			refs = append(refs, Reference{Value: id})
+		}
+		return refs
+	}
+
+	tests := []struct {
+		name string
+		ops  []PatchOperation
+		want []Reference
+	}{
+		{
+			name: "add skips existing members",
+			ops:  []PatchOperation{patchOp("add", "members", `[{"value":"2"},{"value":"3"}]`)},
+			want: members("1", "2", "3"),
+		},
+		{
+			name: "replace members",
+			ops:  []PatchOperation{patchOp("replace", "members", `[{"value":"3"}]`)},
+			want: members("3"),
+		},
+		{
+			name: "remove through a value path filter",
+			ops:  []PatchOperation{patchOp("remove", `members[value eq "1"]`, "")},
+			want: members("2"),
+		},
+		{
+			name: "remove the members named in the value",
+			ops:  []PatchOperation{patchOp("remove", "members", `[{"value":"1"},{"value":"2"}]`)},
+			want: members(),
+		},
+		{
+			name: "remove every member",
+			ops:  []PatchOperation{patchOp("remove", "members", "")},
+			want: members(),
+		},
+		{
+			name: "operations apply in order",
+			ops: []PatchOperation{
+				patchOp("remove", `members[value eq "2"]`, ""),
+				patchOp("add", "members", `[{"value":"4"}]`),
+			},
+			want: members("1", "4"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group := &Group{DisplayName: "Engineering", Members: members("1", "2")}
+			if err := group.ApplyPatch(patchRequest(tt.ops...)); err != nil {
+				t.Fatalf("ApplyPatch: %v", err)
+			}
+			if !reflect.DeepEqual(group.Members, tt.want) {
+				t.Errorf("expected members %+v, got %+v", tt.want, group.Members)
+			}
+		})
+	}
+}
+
+func TestGroupApplyPatchRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		op       PatchOperation
+		scimType string
+	}{
+		{"filter on another attribute", patchOp("remove", `members[display eq "Ada"]`, ""), "invalidFilter"},
+		{"filter with another operator", patchOp("remove", `members[value sw "1"]`, ""), "invalidFilter"},
+		{"compound filter", patchOp("remove", `members[value eq "1" or value eq "2"]`, ""), "invalidFilter"},
+		{"invalid filter", patchOp("remove", `members[value eq]`, ""), "invalidPath"},
+		{"remove displayName", patchOp("remove", "displayName", ""), "mutability"},
+		{"unknown attribute", patchOp("replace", "owner", `"a"`), "invalidPath"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group := &Group{DisplayName: "Engineering", Members: []Reference{{Value: "1"}}}
+			err := group.ApplyPatch(patchRequest(tt.op))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if err.ScimType != tt.scimType {
+				t.Errorf("expected %q, got %q: %v", tt.scimType, err.ScimType, err)
+			}
+		})
+	}
+}
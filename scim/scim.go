@@ -0,0 +1,308 @@
+// Package scim holds the SCIM 2.0 (RFC 7643, RFC 7644) wire types, the
+// filter parser and the PATCH operations used by the provisioning endpoints.
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ContentType = "application/scim+json"
+
+	SchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SchemaResourceType          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+
+	// MaxResults caps the page size of list requests.
+	MaxResults = 200
+	// DefaultResults is the page size when a request has no count.
+	DefaultResults = 100
+)
+
+// Error is a SCIM error response (RFC 7644 section 3.12).
+type Error struct {
+	Status   int
+	ScimType string
+	Detail   string
+}
+
+func (e *Error) Error() string {
+	return e.Detail
+}
+
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Schemas  []string `json:"schemas"`
+		Status   string   `json:"status"`
+		ScimType string   `json:"scimType,omitempty"`
+		Detail   string   `json:"detail,omitempty"`
+	}{[]string{SchemaError}, strconv.Itoa(e.Status), e.ScimType, e.Detail})
+}
+
+func NewError(status int, scimType, detail string) *Error {
+	return &Error{Status: status, ScimType: scimType, Detail: detail}
+}
+
+func ErrInvalidFilter(detail string) *Error {
+	return NewError(http.StatusBadRequest, "invalidFilter", detail)
+}
+
+func ErrInvalidSyntax(detail string) *Error {
+	return NewError(http.StatusBadRequest, "invalidSyntax", detail)
+}
+
+func ErrInvalidPath(detail string) *Error {
+	return NewError(http.StatusBadRequest, "invalidPath", detail)
+}
+
+func ErrInvalidValue(detail string) *Error {
+	return NewError(http.StatusBadRequest, "invalidValue", detail)
+}
+
+func ErrNoTarget(detail string) *Error {
+	return NewError(http.StatusBadRequest, "noTarget", detail)
+}
+
+func ErrUniqueness(detail string) *Error {
+	return NewError(http.StatusConflict, "uniqueness", detail)
+}
+
+func ErrMutability(detail string) *Error {
+	return NewError(http.StatusBadRequest, "mutability", detail)
+}
+
+func ErrNotFound(detail string) *Error {
+	return NewError(http.StatusNotFound, "", detail)
+}
+
+// WriteJSON writes v with the SCIM content type.
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(status)
+	_, err = w.Write(body)
+	return err
+}
+
+// WriteError writes err as a SCIM error response.
+func WriteError(w http.ResponseWriter, err *Error) error {
+	return WriteJSON(w, err.Status, err)
+}
+
+type Meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+}
+
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// MultiValued is an entry of a multi-valued attribute such as emails or
+// roles.
+type MultiValued struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary Bool   `json:"primary,omitempty"`
+}
+
+// Reference points at another resource, e.g. a group member.
+type Reference struct {
+	Value   string `json:"value"`
+	Ref     string `json:"$ref,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+// Bool accepts the "True" and "False" strings some IdPs send for booleans.
+type Bool bool
+
+func (b *Bool) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	parsed, ok := parseBool(v)
+	if !ok {
+		return ErrInvalidValue("expected a boolean")
+	}
+	*b = Bool(parsed)
+	return nil
+}
+
+func parseBool(v interface{}) (bool, bool) {
+	switch v := v.(type) {
+	case bool:
+		return v, true
+	case string:
+		parsed, err := strconv.ParseBool(v)
+		return parsed, err == nil
+	}
+	return false, false
+}
+
+// User is the core User resource. Active is nil when a request leaves it
+// out, which means active.
+type User struct {
+	Schemas     []string      `json:"schemas"`
+	ID          string        `json:"id,omitempty"`
+	ExternalID  string        `json:"externalId,omitempty"`
+	UserName    string        `json:"userName"`
+	Name        *Name         `json:"name,omitempty"`
+	DisplayName string        `json:"displayName,omitempty"`
+	Emails      []MultiValued `json:"emails,omitempty"`
+	Active      *Bool         `json:"active,omitempty"`
+	Roles       []MultiValued `json:"roles,omitempty"`
+	Groups      []Reference   `json:"groups,omitempty"`
+	Meta        *Meta         `json:"meta,omitempty"`
+}
+
+// PrimaryEmail returns the primary email, or the first one if none is marked.
+func (u *User) PrimaryEmail() string {
+	for _, email := range u.Emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+	if len(u.Emails) > 0 {
+		return u.Emails[0].Value
+	}
+	return ""
+}
+
+// FullName returns the name to store for the user, preferring the name
+// parts over the formatted and display names.
+func (u *User) FullName() string {
+	if u.Name != nil {
+		if name := strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName); name != "" {
+			return name
+		}
+		if u.Name.Formatted != "" {
+			return u.Name.Formatted
+		}
+	}
+	return u.DisplayName
+}
+
+// IsActive reports whether the user should be able to sign in.
+func (u *User) IsActive() bool {
+	return u.Active == nil || bool(*u.Active)
+}
+
+type Group struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id,omitempty"`
+	ExternalID  string      `json:"externalId,omitempty"`
+	DisplayName string      `json:"displayName"`
+	Members     []Reference `json:"members,omitempty"`
+	Meta        *Meta       `json:"meta,omitempty"`
+}
+
+type ListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int64       `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+func NewListResponse(resources interface{}, total int64, startIndex, count int) *ListResponse {
+	return &ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: count,
+		Resources:    resources,
+	}
+}
+
+// Page is the pagination of a list request; StartIndex is 1-based.
+type Page struct {
+	StartIndex int
+	Count      int
+}
+
+// Offset is the number of rows to skip.
+func (p Page) Offset() int {
+	return p.StartIndex - 1
+}
+
+// ParsePage reads startIndex and count, clamping them as RFC 7644 section
+// 3.4.2.4 asks instead of rejecting out of range values.
+func ParsePage(r *http.Request) (Page, *Error) {
+	page := Page{StartIndex: 1, Count: DefaultResults}
+	query := r.URL.Query()
+
+	if raw := query.Get("startIndex"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return page, ErrInvalidValue("startIndex must be an integer")
+		}
+		page.StartIndex = max(v, 1)
+	}
+	if raw := query.Get("count"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return page, ErrInvalidValue("count must be an integer")
+		}
+		page.Count = min(max(v, 0), MaxResults)
+	}
+	return page, nil
+}
+
+// ServiceProviderConfig describes the supported features.
+func ServiceProviderConfig(documentationURI string) map[string]interface{} {
+	supported := func(v bool) map[string]bool { return map[string]bool{"supported": v} }
+	return map[string]interface{}{
+		"schemas":          []string{SchemaServiceProviderConfig},
+		"documentationUri": documentationURI,
+		"patch":            supported(true),
+		"bulk":             map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":           map[string]interface{}{"supported": true, "maxResults": MaxResults},
+		"changePassword":   supported(false),
+		"sort":             supported(false),
+		"etag":             supported(false),
+		"authenticationSchemes": []map[string]interface{}{{
+			"type":        "oauthbearertoken",
+			"name":        "OAuth Bearer Token",
+			"description": "Authentication with a per-organization SCIM token",
+			"primary":     true,
+		}},
+	}
+}
+
+// ResourceTypes describes the User and Group endpoints.
+func ResourceTypes(baseURL string) []map[string]interface{} {
+	resourceType := func(name, endpoint, schema string) map[string]interface{} {
+		return map[string]interface{}{
+			"schemas":  []string{SchemaResourceType},
+			"id":       name,
+			"name":     name,
+			"endpoint": endpoint,
+			"schema":   schema,
+			"meta": map[string]string{
+				"resourceType": "ResourceType",
+				"location":     baseURL + "/ResourceTypes/" + name,
+			},
+		}
+	}
+	return []map[string]interface{}{
+		resourceType("User", "/Users", SchemaUser),
+		resourceType("Group", "/Groups", SchemaGroup),
+	}
+}
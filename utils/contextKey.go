@@ -10,6 +10,12 @@ type ContextKey string
 const UserContextKey ContextKey = "user"
 const JWTContextKey ContextKey = "jwt"
 const SchemaValidatorContextKey ContextKey = "parsedBody"
+const SCIMOrganizationContextKey ContextKey = "scimOrganization"
+
+// ImpersonatorContextKey holds the admin behind an impersonation session and
+// ImpersonationContextKey the session; UserContextKey holds the impersonated user.
+const ImpersonatorContextKey ContextKey = "impersonator"
+const ImpersonationContextKey ContextKey = "impersonation"
 
 func ReadContextValue[T any](r *http.Request, key ContextKey) (T, error) {
 	value, ok := r.Context().Value(key).(T)
@@ -1,10 +1,15 @@
 package utils
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
 	"database/sql/driver"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/schema"
@@ -23,6 +28,10 @@ func (m JSONMap[T]) Value() (driver.Value, error) {
 
 // Scan implements the sql.Scanner interface, converting the JSON string from the DB to a map.
 func (m *JSONMap[T]) Scan(value interface{}) error {
+	if value == nil {
+		*m = nil
+		return nil
+	}
 	bytes, ok := value.([]byte)
 	if !ok {
 		return errors.New(fmt.Sprint("Failed to unmarshal JSONB value:", value))
@@ -69,3 +78,88 @@ func (s *StringSlice) Scan(value interface{}) error {
 func (StringSlice) GormDataType() string {
 	return "json"
 }
+
+// EncryptedString is a string stored encrypted with AES-256-GCM under the
+// base64 encoded 32 byte DATA_ENCRYPTION_KEY. The column holds
+// "v1:" + base64(nonce || ciphertext); the empty string is stored as is.
+type EncryptedString string
+
+const encryptedStringPrefix = "v1:"
+
+func dataEncryptionCipher() (cipher.AEAD, error) {
+	key, err := base64.StdEncoding.DecodeString(GetEnv("DATA_ENCRYPTION_KEY", false))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DATA_ENCRYPTION_KEY: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, errors.New("invalid DATA_ENCRYPTION_KEY: must be 32 bytes")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Value encrypts the string for database storage.
+func (s EncryptedString) Value() (driver.Value, error) {
+	if s == "" {
+		return "", nil
+	}
+	aead, err := dataEncryptionCipher()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(s), nil)
+	return encryptedStringPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Scan decrypts a value written by Value.
+func (s *EncryptedString) Scan(value interface{}) error {
+	var raw string
+	switch v := value.(type) {
+	case nil:
+		*s = ""
+		return nil
+	case string:
+		raw = v
+	case []byte:
+		raw = string(v)
+	default:
+		return errors.New("failed to scan encrypted string")
+	}
+	if raw == "" {
+		*s = ""
+		return nil
+	}
+	if !strings.HasPrefix(raw, encryptedStringPrefix) {
+		return errors.New("failed to scan encrypted string: unknown format")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(raw, encryptedStringPrefix))
+	if err != nil {
+		return err
+	}
+	aead, err := dataEncryptionCipher()
+	if err != nil {
+		return err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return errors.New("failed to scan encrypted string: too short")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt encrypted string: %w", err)
+	}
+	*s = EncryptedString(plain)
+	return nil
+}
+
+// GormDataType sets the GORM data type.
+func (EncryptedString) GormDataType() string {
+	return "text"
+}
@@ -1,21 +1,120 @@
 package utils
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 type PaymentGatewayError struct {
 	Message string `json:"message"`
 }
 
 var (
-	ErrInvalidPlanType        = errors.New("invalid plan type")
-	ErrSubscriptionNotFound   = errors.New("subscription not found")
-	ErrInvalidSubscription    = errors.New("invalid subscription")
-	ErrPaymentGatewayNotFound = errors.New("payment gateway not found")
-	ErrTransactionNotFound    = errors.New("transaction not found")
-	ErrInvalidOrderID         = errors.New("invalid order ID")
-	ErrInvalidLimit           = errors.New("invalid limit")
+	ErrInvalidPlanType            = errors.New("invalid plan type")
+	ErrSubscriptionNotFound       = errors.New("subscription not found")
+	ErrInvalidSubscription        = errors.New("invalid subscription")
+	ErrPaymentGatewayNotFound     = errors.New("payment gateway not found")
+	ErrTransactionNotFound        = errors.New("transaction not found")
+	ErrInvalidOrderID             = errors.New("invalid order ID")
+	ErrInvalidLimit               = errors.New("invalid limit")
+	ErrInvalidStart               = errors.New("invalid start")
+	ErrInvalidUnsubscribe         = errors.New("invalid unsubscribe token")
+	ErrWebhookNotFound            = errors.New("webhook endpoint not found")
+	ErrWebhookDisabled            = errors.New("webhook endpoint is disabled")
+	ErrWebhookAddressBlocked      = errors.New("webhook url resolves to a private or reserved address")
+	ErrInvalidPhoneNumber         = errors.New("invalid phone number")
+	ErrPhoneNumberInUse           = errors.New("phone number is already in use")
+	ErrOTPInvalid                 = errors.New("invalid or expired code")
+	ErrOTPTooManyAttempts         = errors.New("too many attempts, request a new code")
+	ErrOTPRateLimited             = errors.New("too many codes requested, try again later")
+	ErrMagicLinkInvalid           = errors.New("invalid or expired sign-in link")
+	ErrMagicLinkRateLimited       = errors.New("too many sign-in links requested, try again later")
+	ErrPasskeyNotFound            = errors.New("passkey not found")
+	ErrPasskeyDisabled            = errors.New("passkey has been disabled because it may have been cloned, remove it and register a new one")
+	ErrPasskeyAlreadyRegistered   = errors.New("passkey is already registered")
+	ErrPasskeyLimitReached        = errors.New("passkey limit reached, remove one to add another")
+	ErrPasskeyCeremonyInvalid     = errors.New("invalid or expired passkey ceremony")
+	ErrPasskeyVerificationFailed  = errors.New("passkey verification failed")
+	ErrIdentityNotFound           = errors.New("linked account not found")
+	ErrIdentityLinkedElsewhere    = errors.New("this provider account is linked to another user")
+	ErrIdentityProviderLinked     = errors.New("a different account from this provider is already linked")
+	ErrIdentityEmailUnverified    = errors.New("an account with this email already exists, sign in and link the provider from your account settings")
+	ErrIdentityLastLoginMethod    = errors.New("set a password or add a passkey before unlinking your last sign-in method")
+	ErrOAuthEmailMissing          = errors.New("the provider did not share an email address")
+	ErrOAuthProviderNotFound      = errors.New("unknown sign-in provider")
+	ErrOAuthStateInvalid          = errors.New("invalid or expired sign-in request, please try again")
+	ErrOrganizationNotFound       = errors.New("organization not found")
+	ErrOrganizationDomainNotFound = errors.New("organization domain not found")
+	ErrOrganizationDomainTaken    = errors.New("this domain already belongs to an organization")
+	ErrSAMLConnectionNotFound     = errors.New("single sign-on is not configured for this organization")
+	ErrSAMLMetadataInvalid        = errors.New("invalid IdP metadata, it must describe an identity provider with a signing certificate and an HTTP-Redirect sign-in URL")
+	ErrSAMLRequestInvalid         = errors.New("invalid or expired single sign-on request, please try again")
+	ErrSAMLAssertionInvalid       = errors.New("invalid single sign-on response")
+	ErrSAMLEmailMissing           = errors.New("the identity provider did not share an email address")
+	ErrSAMLEmailDomainMismatch    = errors.New("the identity provider may not sign in this email address")
+	ErrSAMLEmailInUse             = errors.New("the email address from the identity provider belongs to another account")
+	ErrSCIMTokenInvalid           = errors.New("invalid SCIM token")
+	ErrSCIMTokenNotFound          = errors.New("SCIM token not found")
+	ErrSSORequired                = errors.New("this account must sign in with single sign-on")
+	ErrInvalidCredentials         = errors.New("invalid email or password")
+	ErrUnlockTokenInvalid         = errors.New("invalid or expired unlock link")
+	ErrLockoutNotFound            = errors.New("lockout not found")
+	ErrRateLimited                = errors.New("too many requests, try again later")
+	ErrIncorrectPassword          = errors.New("incorrect password")
+	ErrPasswordUnchanged          = errors.New("the new password must be different from the current one")
+	ErrSessionRevoked             = errors.New("session has been signed out, please sign in again")
+	ErrEmailUnchanged             = errors.New("the new email address is the same as the current one")
+	ErrEmailInUse                 = errors.New("this email address is already in use")
+	ErrEmailChangeInvalid         = errors.New("invalid or expired email change link")
+	ErrEmailManagedByOrganization = errors.New("your email address is managed by your organization")
+	ErrOneTimeTokenInvalid        = errors.New("invalid or expired link")
+	ErrOneTimeTokenExhausted      = errors.New("too many attempts, request a new link")
+	ErrEmailAlreadyVerified       = errors.New("email address is already verified")
+	ErrUserNotFound               = errors.New("user not found")
+	ErrImpersonationNotAllowed    = errors.New("this user cannot be impersonated")
+	ErrImpersonationEnded         = errors.New("impersonation session has ended")
+	ErrImpersonationForbidden     = errors.New("this action is not allowed while impersonating a user")
+	ErrNotImpersonating           = errors.New("not impersonating a user")
+	ErrUploadNotFound             = errors.New("upload not found")
+	ErrUploadTooLarge             = errors.New("file is too large")
+	ErrUploadNotPending           = errors.New("upload is no longer in progress")
+	ErrUploadInvalidPart          = errors.New("invalid part number")
+	ErrUploadSizeMismatch         = errors.New("the uploaded data does not match the declared size")
+	ErrUploadTypeMismatch         = errors.New("the uploaded data does not match the declared content type")
+	ErrUploadChecksumMismatch     = errors.New("the uploaded data does not match the declared checksum")
+	ErrUploadNotReceived          = errors.New("the file has not been uploaded yet")
+	ErrFileNotFound               = errors.New("file not found")
+	ErrFileShareSelf              = errors.New("you cannot share a file with yourself")
+	ErrShareLinkInvalid           = errors.New("invalid or expired share link")
+	ErrFileShareNotFound          = errors.New("file is not shared with this user")
+	ErrFolderNotFound             = errors.New("folder not found")
+	ErrFolderNotEmpty             = errors.New("folder is not empty")
+	ErrFolderNameTaken            = errors.New("a folder with this name already exists here")
+	ErrFolderMoveIntoSelf         = errors.New("a folder cannot be moved into itself")
+	ErrStorageQuotaExceeded       = errors.New("storage quota exceeded")
 )
 
+// UploadIncompleteError is returned when a multipart upload is completed
+// before all of its parts were uploaded.
+type UploadIncompleteError struct {
+	MissingParts []int
+}
+
+func (e *UploadIncompleteError) Error() string {
+	return fmt.Sprintf("upload is missing %d part(s)", len(e.MissingParts))
+}
+
+// LoginThrottledError is returned while sign-in attempts are delayed or
+// locked. Both cases share one message so it reveals nothing about the account.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return "too many failed sign-in attempts, try again later"
+}
+
 func (e *PaymentGatewayError) Error() string {
 	return e.Message
 }
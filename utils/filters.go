@@ -1,6 +1,10 @@
 package utils
 
-import "github.com/go-playground/validator/v10"
+import (
+	"encoding/json"
+
+	"github.com/go-playground/validator/v10"
+)
 
 func ValidateStruct(structToValidate interface{}) error {
 	return validator.New().Struct(structToValidate)
@@ -15,3 +19,30 @@ type SortBy struct {
 	Field     string `json:"field" validate:"required"`
 	Direction string `json:"direction" validate:"required,oneof=asc desc"`
 }
+
+// ParsePagination converts the optional start/limit query values into offsets,
+// falling back to defaultLimit and capping the limit at maxLimit.
+func ParsePagination(start, limit json.Number, defaultLimit, maxLimit int) (int, int, error) {
+	offset, size := 0, defaultLimit
+
+	if start != "" {
+		v, err := start.Int64()
+		if err != nil || v < 0 {
+			return 0, 0, ErrInvalidStart
+		}
+		offset = int(v)
+	}
+
+	if limit != "" {
+		v, err := limit.Int64()
+		if err != nil || v <= 0 {
+			return 0, 0, ErrInvalidLimit
+		}
+		size = int(v)
+	}
+
+	if size > maxLimit {
+		size = maxLimit
+	}
+	return offset, size, nil
+}
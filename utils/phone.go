@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"strings"
+)
+
+// NormalizeE164 combines a country calling code ("91", "+91") and a national
+// number into E.164 form ("+919876543210"). Spaces, dashes, dots and
+// parentheses are ignored and a national trunk prefix ("0") is dropped. A
+// number that already starts with "+" is taken as complete and countryCode is
+// ignored.
+func NormalizeE164(countryCode, number string) (string, error) {
+	number = stripPhoneFormatting(number)
+	countryCode = strings.TrimPrefix(stripPhoneFormatting(countryCode), "+")
+
+	var digits string
+	if strings.HasPrefix(number, "+") {
+		digits = number[1:]
+	} else {
+		if strings.HasPrefix(number, "00") {
+			// international call prefix used instead of "+"
+			digits = number[2:]
+		} else {
+			if countryCode == "" || len(countryCode) > 3 || countryCode[0] == '0' || !isDigits(countryCode) {
+				return "", ErrInvalidPhoneNumber
+			}
+			digits = countryCode + strings.TrimLeft(number, "0")
+		}
+	}
+
+	// E.164 allows at most 15 digits; anything under 8 is not a real subscriber number
+	if !isDigits(digits) || digits[0] == '0' || len(digits) < 8 || len(digits) > 15 {
+		return "", ErrInvalidPhoneNumber
+	}
+	return "+" + digits, nil
+}
+
+func stripPhoneFormatting(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')', '\t':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(s))
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"log"
 	"mime/multipart"
+	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/minio/minio-go/v7"
@@ -98,3 +100,76 @@ func S3DeleteFile(bucketName string, objectKey string) error {
 	log.Printf("Successfully deleted %s from bucket %s\n", objectKey, bucketName)
 	return nil
 }
+
+// S3NewMultipartUpload starts a multipart upload of objectKey and returns
+// its upload ID. Parts are uploaded by clients through presigned URLs.
+func S3NewMultipartUpload(ctx context.Context, bucketName, objectKey, contentType string) (string, error) {
+	core := minio.Core{Client: MinioClient}
+	return core.NewMultipartUpload(ctx, bucketName, objectKey, minio.PutObjectOptions{ContentType: contentType})
+}
+
+// S3PresignUploadPart returns a URL that accepts a PUT of one part of a
+// multipart upload until expiry.
+func S3PresignUploadPart(ctx context.Context, bucketName, objectKey, uploadID string, partNumber int, expiry time.Duration) (string, error) {
+	params := url.Values{}
+	params.Set("partNumber", strconv.Itoa(partNumber))
+	params.Set("uploadId", uploadID)
+
+	u, err := MinioClient.Presign(ctx, http.MethodPut, bucketName, objectKey, expiry, params)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// S3ListUploadedParts returns every part uploaded so far, in part order.
+func S3ListUploadedParts(ctx context.Context, bucketName, objectKey, uploadID string) ([]minio.ObjectPart, error) {
+	core := minio.Core{Client: MinioClient}
+
+	var parts []minio.ObjectPart
+	marker := 0
+	for {
+		result, err := core.ListObjectParts(ctx, bucketName, objectKey, uploadID, marker, 1000)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, result.ObjectParts...)
+		if !result.IsTruncated {
+			return parts, nil
+		}
+		marker = result.NextPartNumberMarker
+	}
+}
+
+func S3CompleteMultipartUpload(ctx context.Context, bucketName, objectKey, uploadID string, parts []minio.CompletePart) (minio.UploadInfo, error) {
+	core := minio.Core{Client: MinioClient}
+	return core.CompleteMultipartUpload(ctx, bucketName, objectKey, uploadID, parts, minio.PutObjectOptions{})
+}
+
+func S3AbortMultipartUpload(ctx context.Context, bucketName, objectKey, uploadID string) error {
+	core := minio.Core{Client: MinioClient}
+	return core.AbortMultipartUpload(ctx, bucketName, objectKey, uploadID)
+}
+
+// S3StatObject returns the metadata of objectKey, including the checksums S3
+// stored for it.
+func S3StatObject(ctx context.Context, bucketName, objectKey string) (minio.ObjectInfo, error) {
+	return MinioClient.StatObject(ctx, bucketName, objectKey, minio.StatObjectOptions{Checksum: true})
+}
+
+// S3PresignPutObject returns a URL that accepts a PUT of objectKey until
+// expiry. The request must carry headers exactly, as they are part of the
+// signature.
+func S3PresignPutObject(ctx context.Context, bucketName, objectKey string, expiry time.Duration, headers http.Header) (string, error) {
+	u, err := MinioClient.PresignHeader(ctx, http.MethodPut, bucketName, objectKey, expiry, nil, headers)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// S3RemoveObject deletes objectKey. Unlike S3DeleteFile it reports failures
+// to the caller.
+func S3RemoveObject(ctx context.Context, bucketName, objectKey string) error {
+	return MinioClient.RemoveObject(ctx, bucketName, objectKey, minio.RemoveObjectOptions{})
+}
@@ -68,3 +68,136 @@ func (pt PlanType) IsValid() bool {
 	_, exists := PlanTypes[string(pt)]
 	return exists
 }
+
+type EmailStatus string
+
+const (
+	EmailStatusPending EmailStatus = "pending"
+	EmailStatusSending EmailStatus = "sending"
+	EmailStatusSent    EmailStatus = "sent"
+	EmailStatusFailed  EmailStatus = "failed"
+)
+
+type NotificationCategory string
+
+const (
+	NotificationCategorySecurity       NotificationCategory = "security"
+	NotificationCategoryBilling        NotificationCategory = "billing"
+	NotificationCategoryProductUpdates NotificationCategory = "product_updates"
+	NotificationCategoryMarketing      NotificationCategory = "marketing"
+)
+
+var NotificationCategories []NotificationCategory = []NotificationCategory{
+	NotificationCategorySecurity,
+	NotificationCategoryBilling,
+	NotificationCategoryProductUpdates,
+	NotificationCategoryMarketing,
+}
+
+func (nc NotificationCategory) IsValid() bool {
+	return slices.Contains(NotificationCategories, nc)
+}
+
+// IsOptional reports whether users can turn the category off. Security emails
+// (verification, password resets, account changes) are always sent.
+func (nc NotificationCategory) IsOptional() bool {
+	return nc.IsValid() && nc != NotificationCategorySecurity
+}
+
+// WebhookEvent names an event delivered to webhook endpoints. Subscription
+// status changes use the SubscriptionStatus values as their event names.
+type WebhookEvent string
+
+const (
+	WebhookEventUserCreated          WebhookEvent = "user.created"
+	WebhookEventUserEmailVerified    WebhookEvent = "user.email_verified"
+	WebhookEventUserEmailChanged     WebhookEvent = "user.email_changed"
+	WebhookEventTransactionCompleted WebhookEvent = "transaction.completed"
+)
+
+func WebhookEvents() []WebhookEvent {
+	events := []WebhookEvent{
+		WebhookEventUserCreated,
+		WebhookEventUserEmailVerified,
+		WebhookEventUserEmailChanged,
+		WebhookEventTransactionCompleted,
+	}
+	for _, status := range SubscriptionHooks {
+		events = append(events, WebhookEvent(status))
+	}
+	return events
+}
+
+func (we WebhookEvent) IsValid() bool {
+	return slices.Contains(WebhookEvents(), we)
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending    WebhookDeliveryStatus = "pending"
+	WebhookDeliveryStatusDelivering WebhookDeliveryStatus = "delivering"
+	WebhookDeliveryStatusSucceeded  WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryStatusFailed     WebhookDeliveryStatus = "failed"
+)
+
+type OTPPurpose string
+
+const (
+	OTPPurposeVerifyPhone OTPPurpose = "verify_phone"
+	OTPPurposeLogin       OTPPurpose = "login"
+)
+
+// OneTimeTokenPurpose is the flow a one-time token was issued for. A token
+// only works for its own purpose.
+type OneTimeTokenPurpose string
+
+const (
+	OneTimeTokenPurposeEmailVerification OneTimeTokenPurpose = "email_verification"
+	OneTimeTokenPurposePasswordReset     OneTimeTokenPurpose = "password_reset"
+)
+
+type UploadStatus string
+
+const (
+	UploadStatusPending   UploadStatus = "pending"
+	UploadStatusCompleted UploadStatus = "completed"
+	UploadStatusAborted   UploadStatus = "aborted"
+)
+
+// FileVisibility is who besides the owner and users it is shared with can
+// read a file.
+type FileVisibility string
+
+const (
+	FileVisibilityPrivate      FileVisibility = "private"
+	FileVisibilityOrganization FileVisibility = "organization"
+	FileVisibilityPublic       FileVisibility = "public"
+)
+
+// StorageScope is whose storage a quota covers.
+type StorageScope string
+
+const (
+	StorageScopeUser         StorageScope = "user"
+	StorageScopeOrganization StorageScope = "organization"
+)
+
+// FileAccessVia is what granted a recorded file access.
+type FileAccessVia string
+
+const (
+	FileAccessViaOwner        FileAccessVia = "owner"
+	FileAccessViaShare        FileAccessVia = "share"
+	FileAccessViaOrganization FileAccessVia = "organization"
+	FileAccessViaPublic       FileAccessVia = "public"
+	FileAccessViaLink         FileAccessVia = "link"
+)
+
+// LoginThrottleScope is what failed password sign-ins are counted against.
+type LoginThrottleScope string
+
+const (
+	LoginThrottleScopeAccount LoginThrottleScope = "account"
+	LoginThrottleScopeIP      LoginThrottleScope = "ip"
+)
@@ -6,8 +6,10 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
@@ -59,6 +61,27 @@ func GetToken(r *http.Request) (string, error) {
 	return authToken, nil
 }
 
+// ClientIP returns the address of the client that sent r. When
+// TRUST_PROXY_HEADERS is "true" the API is assumed to sit behind a single
+// reverse proxy, and the last X-Forwarded-For entry, the one that proxy
+// appended, is used instead of the connection's address.
+func ClientIP(r *http.Request) string {
+	if GetEnv("TRUST_PROXY_HEADERS", true) == "true" {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			parts := strings.Split(forwarded, ",")
+			if ip := net.ParseIP(strings.TrimSpace(parts[len(parts)-1])); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 func ValidateHeaderHMACSha256(body []byte, secret string, signature string) bool {
 	hmac := hmac.New(sha256.New, []byte(secret))
 	hmac.Write(body)